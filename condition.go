@@ -0,0 +1,22 @@
+package rbac
+
+import "time"
+
+// Attributes are named values describing subject, resource or environment of a check.
+type Attributes map[string]interface{}
+
+// EvalContext carries attributes conditional Permissions are evaluated against.
+// User, Domain and Time are filled by RBAC controller, zero Time is replaced with current time.
+type EvalContext struct {
+	User   User
+	Domain Domain
+	Time   time.Time
+
+	Subject     Attributes
+	Resource    Attributes
+	Environment Attributes
+}
+
+// Condition decides if conditional Permission applies in EvalContext.
+// It is called after RBAC controller released its lock, so it may use the controller itself.
+type Condition func(ctx EvalContext) bool
@@ -0,0 +1,31 @@
+package rbac
+
+import "context"
+
+type (
+	userContextKey   struct{}
+	domainContextKey struct{}
+)
+
+// WithUser returns copy of ctx carrying User as authenticated subject
+func WithUser(ctx context.Context, u User) context.Context {
+	return context.WithValue(ctx, userContextKey{}, u)
+}
+
+// UserFromContext returns User carried by ctx.
+// Returns false if ctx carries no User.
+func UserFromContext(ctx context.Context) (User, bool) {
+	u, ok := ctx.Value(userContextKey{}).(User)
+	return u, ok
+}
+
+// WithDomain returns copy of ctx carrying Domain subject acts in
+func WithDomain(ctx context.Context, d Domain) context.Context {
+	return context.WithValue(ctx, domainContextKey{}, d)
+}
+
+// DomainFromContext returns Domain carried by ctx, DefaultDomain if ctx carries none
+func DomainFromContext(ctx context.Context) Domain {
+	d, _ := ctx.Value(domainContextKey{}).(Domain)
+	return d
+}
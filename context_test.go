@@ -0,0 +1,35 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+)
+
+func TestUserFromContext(t *testing.T) {
+	u := NewUser(defaultUserID)
+
+	// case 1: context without user
+	if _, ok := UserFromContext(context.Background()); ok {
+		t.Errorf("[case 1] invalid output: expected %t, got %t", false, ok)
+	}
+
+	// case 2: context with user
+	got, ok := UserFromContext(WithUser(context.Background(), u))
+	if !ok || got != u {
+		t.Errorf("[case 2] invalid output: expected %v, got %v (%t)", u, got, ok)
+	}
+}
+
+func TestDomainFromContext(t *testing.T) {
+	d := NewDomain(defaultDomainID)
+
+	// case 1: context without domain
+	if got := DomainFromContext(context.Background()); got != DefaultDomain {
+		t.Errorf("[case 1] invalid output: expected %v, got %v", DefaultDomain, got)
+	}
+
+	// case 2: context with domain
+	if got := DomainFromContext(WithDomain(context.Background(), d)); got != d {
+		t.Errorf("[case 2] invalid output: expected %v, got %v", d, got)
+	}
+}
@@ -0,0 +1,77 @@
+package rbac
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Reason describes why access decision was made
+type Reason int
+
+const (
+	// ReasonGranted means some of User Roles has matching Permission and none denies it
+	ReasonGranted Reason = iota
+	// ReasonDenied means some of User Roles has matching Permission explicitly denied
+	ReasonDenied
+	// ReasonNoRoleMatched means none of User Roles has matching Permission
+	ReasonNoRoleMatched
+	// ReasonUserNotRegistered means User is not registered
+	ReasonUserNotRegistered
+	// ReasonPermissionNotRegistered means Permission is neither registered nor matched by registered wildcard
+	ReasonPermissionNotRegistered
+)
+
+func (r Reason) String() string {
+	switch r {
+	case ReasonGranted:
+		return "granted"
+	case ReasonDenied:
+		return "denied"
+	case ReasonNoRoleMatched:
+		return "no role matched"
+	case ReasonUserNotRegistered:
+		return "user is not registered"
+	case ReasonPermissionNotRegistered:
+		return "permission is not registered"
+	}
+	return fmt.Sprintf("Reason(%d)", int(r))
+}
+
+// Decision explains outcome of permission check
+type Decision struct {
+	Allowed bool
+	Reason  Reason
+
+	User       User
+	Domain     Domain
+	Permission Permission
+
+	// Path lists Roles from Role assigned to User up through role hierarchy to Role holding Rule.
+	// Path is empty unless Reason is ReasonGranted or ReasonDenied.
+	Path []Role
+	// Rule is registered Permission, possibly wildcard, assigned or denied to the last Role of Path
+	Rule Permission
+}
+
+// String formats Decision as chain, e.g.
+// user "alice" -> role "editor" -> role "viewer" -> permission "invoice" "*": granted
+func (d Decision) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "user %q", d.User.id)
+	if d.Domain != DefaultDomain {
+		fmt.Fprintf(&b, " in domain %q", string(d.Domain))
+	}
+	for _, r := range d.Path {
+		fmt.Fprintf(&b, " -> role %q", r.id)
+	}
+
+	p := d.Permission
+	if len(d.Path) > 0 {
+		p = d.Rule
+		b.WriteString(" ->")
+	} else {
+		b.WriteString(":")
+	}
+	fmt.Fprintf(&b, " permission %q %q: %s", p.object, p.action, d.Reason)
+	return b.String()
+}
@@ -0,0 +1,41 @@
+package rbac
+
+import "testing"
+
+func TestDecisionString(t *testing.T) {
+	u := NewUser("alice")
+	p := NewPermission(NewObject("invoice"), NewAction("export"))
+	all := NewPermission(NewObject("invoice"), NewAction("*"))
+
+	tests := []struct {
+		d        Decision
+		expected string
+	}{
+		// case 1: granted through hierarchy
+		{
+			Decision{Allowed: true, Reason: ReasonGranted, User: u, Permission: p, Path: []Role{NewRole("editor"), NewRole("viewer")}, Rule: all},
+			`user "alice" -> role "editor" -> role "viewer" -> permission "invoice" "*": granted`,
+		},
+		// case 2: no role matched in domain
+		{
+			Decision{Reason: ReasonNoRoleMatched, User: u, Domain: NewDomain("org"), Permission: p},
+			`user "alice" in domain "org": permission "invoice" "export": no role matched`,
+		},
+	}
+
+	for i, test := range tests {
+		if s := test.d.String(); s != test.expected {
+			t.Errorf("[case %d] invalid output: expected %s, got %s", i+1, test.expected, s)
+		}
+	}
+}
+
+func TestReasonString(t *testing.T) {
+	if s := ReasonDenied.String(); s != "denied" {
+		t.Errorf("[case 1] invalid output: expected %s, got %s", "denied", s)
+	}
+
+	if s := Reason(42).String(); s != "Reason(42)" {
+		t.Errorf("[case 2] invalid output: expected %s, got %s", "Reason(42)", s)
+	}
+}
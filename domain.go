@@ -0,0 +1,16 @@
+package rbac
+
+// Domain describes scope (e.g. tenant) Roles are assigned to Users in
+type Domain string
+
+// DefaultDomain is Domain used by methods without explicit Domain
+const DefaultDomain = Domain("")
+
+// NewDomain creates Domain instance
+func NewDomain(id string) Domain {
+	return Domain(id)
+}
+
+func (d Domain) String() string {
+	return string(d)
+}
@@ -0,0 +1,23 @@
+package rbac
+
+import "testing"
+
+var (
+	defaultDomainID = "defaultDomainID"
+)
+
+func TestNewDomain(t *testing.T) {
+	d := NewDomain(defaultDomainID)
+
+	if string(d) != defaultDomainID {
+		t.Errorf("Invalid Domain creation: id expected %s, got %s", defaultDomainID, string(d))
+	}
+}
+
+func TestDomainString(t *testing.T) {
+	d := NewDomain(defaultDomainID)
+
+	if d.String() != defaultDomainID {
+		t.Errorf("Invalid output: expected %s, got %s", defaultDomainID, d.String())
+	}
+}
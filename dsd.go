@@ -0,0 +1,11 @@
+package rbac
+
+// DSDConstraint is dynamic separation of duty constraint:
+// no Session may have N or more of Roles active at the same time, directly or through role hierarchy.
+// Unlike SSDConstraint it does not limit Role assignments.
+type DSDConstraint SSDConstraint
+
+// NewDSDConstraint creates DSDConstraint, duplicated Roles are dropped
+func NewDSDConstraint(name string, roles []Role, n int) DSDConstraint {
+	return DSDConstraint(NewSSDConstraint(name, roles, n))
+}
@@ -1,9 +1,97 @@
 package rbac
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
 	ErrorPermissionNotRegistered = errors.New("permission is not registered")
 	ErrorRoleNotRegistered = errors.New("role is not registered")
 	ErrorUserNotRegistered = errors.New("user is not registered")
+	ErrorInvalidValidity = errors.New("validity ends before it starts")
+	ErrorInvalidSSDConstraint = errors.New("separation of duty constraint needs a name and 2 <= n <= number of roles")
+	ErrorRoleNotAssigned = errors.New("role is not assigned to user")
+	ErrorNoUserInContext = errors.New("context carries no user")
+	ErrorNilCondition = errors.New("condition is nil")
+	ErrorTxDone = errors.New("transaction is already finished")
+	ErrorInvalidInterval = errors.New("interval has to be positive")
 )
+
+// RoleCycleError is returned when adding a parent to a Role would make role hierarchy cyclic.
+type RoleCycleError struct {
+	Child  Role
+	Parent Role
+}
+
+func (e *RoleCycleError) Error() string {
+	return fmt.Sprintf("role %q can not inherit role %q: hierarchy cycle", e.Child.id, e.Parent.id)
+}
+
+// SSDViolationError is returned when User would hold too many Roles of static separation of duty constraint.
+type SSDViolationError struct {
+	Constraint string
+	User       User
+	Domain     Domain
+	Roles      []Role
+}
+
+func (e *SSDViolationError) Error() string {
+	ids := make([]string, 0, len(e.Roles))
+	for _, r := range e.Roles {
+		ids = append(ids, r.id)
+	}
+	return fmt.Sprintf("user %q can not hold roles %q together: separation of duty constraint %q", e.User.id, ids, e.Constraint)
+}
+
+// DSDViolationError is returned when Session would have too many Roles of dynamic separation of duty constraint active.
+type DSDViolationError struct {
+	Constraint string
+	User       User
+	Roles      []Role
+}
+
+func (e *DSDViolationError) Error() string {
+	ids := make([]string, 0, len(e.Roles))
+	for _, r := range e.Roles {
+		ids = append(ids, r.id)
+	}
+	return fmt.Sprintf("session of user %q can not activate roles %q together: separation of duty constraint %q", e.User.id, ids, e.Constraint)
+}
+
+// ExpressionError is returned when condition expression can not be compiled.
+type ExpressionError struct {
+	Expression string
+	Offset     int
+	Message    string
+}
+
+func (e *ExpressionError) Error() string {
+	return fmt.Sprintf("invalid expression %q at offset %d: %s", e.Expression, e.Offset, e.Message)
+}
+
+// ActionCycleError is returned when declaring Action implication would make implications cyclic.
+type ActionCycleError struct {
+	Action  Action
+	Implied Action
+}
+
+func (e *ActionCycleError) Error() string {
+	return fmt.Sprintf("action %q can not imply action %q: implication cycle", e.Action, e.Implied)
+}
+
+// RollbackError is returned by RBAC.Update when changes made by failed transaction could not be rolled back,
+// content of controller may be left partially changed.
+type RollbackError struct {
+	Err      error
+	Rollback error
+}
+
+func (e *RollbackError) Error() string {
+	return fmt.Sprintf("rollback failed: %v (transaction failed with: %v)", e.Rollback, e.Err)
+}
+
+// Unwrap returns error transaction failed with
+func (e *RollbackError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,722 @@
+package rbac
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// limits keeping compilation and evaluation of untrusted expressions cheap
+const (
+	maxExpressionLength = 4096
+	maxExpressionDepth  = 64
+)
+
+// Expression is compiled condition of conditional Permission.
+//
+// Expression language supports:
+//   - literals: strings in single or double quotes, numbers, true, false and lists [a, b, c]
+//   - attributes: subject.*, resource.* and env.*, nested maps are accessed with further dots
+//   - comparisons: == != < <= > >=, numbers and strings are ordered
+//   - membership: x in [a, b], x in resource.tags
+//   - boolean logic: && || ! and parentheses
+//   - functions: startsWith(s, prefix), endsWith(s, suffix)
+//
+// subject.id is ID of checked User, env.time is time of check formatted as "15:04",
+// env.weekday is its weekday name and env.domain is checked Domain.
+// Type errors between literals are reported at compile time, attributes are typed at evaluation:
+// comparison involving missing attribute or values of different types is false.
+//
+// Expression has no side effects and is safe for concurrent use.
+type Expression struct {
+	src  string
+	root exprNode
+}
+
+// CompileExpression parses and type checks expression.
+// Returns *ExpressionError if expression is malformed.
+func CompileExpression(src string) (*Expression, error) {
+	p := &exprParser{src: src}
+	if len(src) > maxExpressionLength {
+		return nil, p.errorf(maxExpressionLength, "expression is longer than %d bytes", maxExpressionLength)
+	}
+	if err := p.tokenize(); err != nil {
+		return nil, err
+	}
+
+	root, err := p.parseOr(0)
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, p.errorf(tok.offset, "unexpected %s", tok)
+	}
+	if t := root.typ(); t != typeBool && t != typeAny {
+		return nil, p.errorf(0, "expression is %s, expected bool", t)
+	}
+	return &Expression{src: src, root: root}, nil
+}
+
+// String returns source of Expression
+func (e *Expression) String() string {
+	return e.src
+}
+
+// Eval evaluates Expression in ctx, so Eval can be used as Condition.
+// Returns false unless Expression evaluates to true.
+func (e *Expression) Eval(ctx EvalContext) bool {
+	v, _ := e.root.eval(&ctx).(bool)
+	return v
+}
+
+// exprType is type of expression node known at compile time
+type exprType int
+
+const (
+	typeAny exprType = iota
+	typeBool
+	typeNumber
+	typeString
+	typeList
+)
+
+func (t exprType) String() string {
+	switch t {
+	case typeBool:
+		return "bool"
+	case typeNumber:
+		return "number"
+	case typeString:
+		return "string"
+	case typeList:
+		return "list"
+	}
+	return "any"
+}
+
+// exprNode evaluates to nil (undefined), bool, float64, string or []interface{}
+type exprNode interface {
+	typ() exprType
+	eval(ctx *EvalContext) interface{}
+}
+
+type literalNode struct {
+	value interface{}
+	t     exprType
+}
+
+func (n *literalNode) typ() exprType { return n.t }
+
+func (n *literalNode) eval(*EvalContext) interface{} { return n.value }
+
+type listNode struct {
+	items []exprNode
+}
+
+func (n *listNode) typ() exprType { return typeList }
+
+func (n *listNode) eval(ctx *EvalContext) interface{} {
+	out := make([]interface{}, 0, len(n.items))
+	for _, item := range n.items {
+		out = append(out, item.eval(ctx))
+	}
+	return out
+}
+
+type attributeNode struct {
+	scope string
+	path  []string
+}
+
+func (n *attributeNode) typ() exprType { return typeAny }
+
+func (n *attributeNode) eval(ctx *EvalContext) interface{} {
+	var attrs Attributes
+	switch n.scope {
+	case "subject":
+		if len(n.path) == 1 && n.path[0] == "id" {
+			return ctx.User.id
+		}
+		attrs = ctx.Subject
+	case "resource":
+		attrs = ctx.Resource
+	case "env":
+		if len(n.path) == 1 {
+			switch n.path[0] {
+			case "time":
+				return ctx.Time.Format("15:04")
+			case "weekday":
+				return ctx.Time.Weekday().String()
+			case "domain":
+				return ctx.Domain.String()
+			}
+		}
+		attrs = ctx.Environment
+	}
+
+	var v interface{} = map[string]interface{}(attrs)
+	for _, key := range n.path {
+		v = lookupAttribute(v, key)
+		if v == nil {
+			return nil
+		}
+	}
+	return normalizeValue(v)
+}
+
+type notNode struct {
+	x exprNode
+}
+
+func (n *notNode) typ() exprType { return typeBool }
+
+func (n *notNode) eval(ctx *EvalContext) interface{} {
+	v, ok := n.x.eval(ctx).(bool)
+	return ok && !v
+}
+
+type logicNode struct {
+	and  bool
+	l, r exprNode
+}
+
+func (n *logicNode) typ() exprType { return typeBool }
+
+func (n *logicNode) eval(ctx *EvalContext) interface{} {
+	l, _ := n.l.eval(ctx).(bool)
+	if l != n.and {
+		return l
+	}
+	r, _ := n.r.eval(ctx).(bool)
+	return r
+}
+
+type compareNode struct {
+	op   string
+	l, r exprNode
+}
+
+func (n *compareNode) typ() exprType { return typeBool }
+
+func (n *compareNode) eval(ctx *EvalContext) interface{} {
+	l, r := n.l.eval(ctx), n.r.eval(ctx)
+	if !isScalar(l) || !isScalar(r) || reflect.TypeOf(l) != reflect.TypeOf(r) {
+		return false
+	}
+
+	switch n.op {
+	case "==":
+		return l == r
+	case "!=":
+		return l != r
+	}
+
+	var c int
+	switch l := l.(type) {
+	case float64:
+		r := r.(float64)
+		switch {
+		case l < r:
+			c = -1
+		case l > r:
+			c = 1
+		}
+	case string:
+		c = strings.Compare(l, r.(string))
+	default:
+		return false
+	}
+
+	switch n.op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	}
+	return c >= 0
+}
+
+type inNode struct {
+	x, list exprNode
+}
+
+func (n *inNode) typ() exprType { return typeBool }
+
+func (n *inNode) eval(ctx *EvalContext) interface{} {
+	x := n.x.eval(ctx)
+	list, ok := n.list.eval(ctx).([]interface{})
+	if !isScalar(x) || !ok {
+		return false
+	}
+	for _, item := range list {
+		if item == x {
+			return true
+		}
+	}
+	return false
+}
+
+type callNode struct {
+	fn   func(s, arg string) bool
+	args [2]exprNode
+}
+
+func (n *callNode) typ() exprType { return typeBool }
+
+func (n *callNode) eval(ctx *EvalContext) interface{} {
+	s, ok := n.args[0].eval(ctx).(string)
+	if !ok {
+		return false
+	}
+	arg, ok := n.args[1].eval(ctx).(string)
+	return ok && n.fn(s, arg)
+}
+
+// exprFunctions are functions available in expressions, all take two strings
+var exprFunctions = map[string]func(s, arg string) bool{
+	"startsWith": strings.HasPrefix,
+	"endsWith":   strings.HasSuffix,
+}
+
+// isScalar checks if evaluated value is defined and is not a list
+func isScalar(v interface{}) bool {
+	switch v.(type) {
+	case bool, float64, string:
+		return true
+	}
+	return false
+}
+
+// lookupAttribute returns value of key in map v, nil if v is not a map keyed by strings
+func lookupAttribute(v interface{}, key string) interface{} {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m[key]
+	case Attributes:
+		return m[key]
+	case map[string]string:
+		if s, ok := m[key]; ok {
+			return s
+		}
+		return nil
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+		return nil
+	}
+	item := rv.MapIndex(reflect.ValueOf(key).Convert(rv.Type().Key()))
+	if !item.IsValid() {
+		return nil
+	}
+	return item.Interface()
+}
+
+// normalizeValue converts attribute value to one of types expression nodes evaluate to.
+// Values of other types are undefined.
+func normalizeValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil, bool, string, float64:
+		return v
+	case fmt.Stringer:
+		// String of typed nil pointer usually panics, nil is undefined instead
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return nil
+		}
+		return v.String()
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.String:
+		return rv.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.Slice, reflect.Array:
+		out := make([]interface{}, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			out = append(out, normalizeValue(rv.Index(i).Interface()))
+		}
+		return out
+	}
+	return nil
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenOperator
+)
+
+type token struct {
+	kind   tokenKind
+	text   string
+	offset int
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of expression"
+	}
+	return strconv.Quote(t.text)
+}
+
+// exprParser is recursive descent parser of expression language
+type exprParser struct {
+	src    string
+	tokens []token
+	pos    int
+}
+
+func (p *exprParser) errorf(offset int, format string, args ...interface{}) error {
+	return &ExpressionError{Expression: p.src, Offset: offset, Message: fmt.Sprintf(format, args...)}
+}
+
+func (p *exprParser) tokenize() error {
+	src := p.src
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '_' || unicode.IsLetter(c):
+			start := i
+			for i < len(src) && (src[i] == '_' || unicode.IsLetter(rune(src[i])) || unicode.IsDigit(rune(src[i]))) {
+				i++
+			}
+			p.tokens = append(p.tokens, token{tokenIdent, src[start:i], start})
+		case unicode.IsDigit(c):
+			start := i
+			for i < len(src) && (unicode.IsDigit(rune(src[i])) || src[i] == '.') {
+				i++
+			}
+			p.tokens = append(p.tokens, token{tokenNumber, src[start:i], start})
+		case c == '"' || c == '\'':
+			start := i
+			var sb strings.Builder
+			for i++; ; i++ {
+				if i >= len(src) {
+					return p.errorf(start, "unterminated string")
+				}
+				if src[i] == byte(c) {
+					i++
+					break
+				}
+				if src[i] == '\\' {
+					i++
+					if i >= len(src) {
+						return p.errorf(start, "unterminated string")
+					}
+				}
+				sb.WriteByte(src[i])
+			}
+			p.tokens = append(p.tokens, token{tokenString, sb.String(), start})
+		default:
+			op := ""
+			for _, candidate := range []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "(", ")", "[", "]", ",", ".", "-"} {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return p.errorf(i, "unexpected character %q", c)
+			}
+			p.tokens = append(p.tokens, token{tokenOperator, op, i})
+			i += len(op)
+		}
+	}
+	p.tokens = append(p.tokens, token{tokenEOF, "", len(src)})
+	return nil
+}
+
+func (p *exprParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// accept consumes next token if it is operator op
+func (p *exprParser) accept(op string) bool {
+	if tok := p.peek(); tok.kind == tokenOperator && tok.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) expect(op string) error {
+	if tok := p.peek(); !p.accept(op) {
+		return p.errorf(tok.offset, "expected %q, got %s", op, tok)
+	}
+	return nil
+}
+
+func (p *exprParser) parseOr(depth int) (exprNode, error) {
+	l, err := p.parseAnd(depth)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.peek()
+		if !p.accept("||") {
+			return l, nil
+		}
+		r, err := p.parseAnd(depth)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.checkBool(tok, l, r); err != nil {
+			return nil, err
+		}
+		l = &logicNode{and: false, l: l, r: r}
+	}
+}
+
+func (p *exprParser) parseAnd(depth int) (exprNode, error) {
+	l, err := p.parseNot(depth)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.peek()
+		if !p.accept("&&") {
+			return l, nil
+		}
+		r, err := p.parseNot(depth)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.checkBool(tok, l, r); err != nil {
+			return nil, err
+		}
+		l = &logicNode{and: true, l: l, r: r}
+	}
+}
+
+func (p *exprParser) parseNot(depth int) (exprNode, error) {
+	tok := p.peek()
+	if !p.accept("!") {
+		return p.parseComparison(depth)
+	}
+	if depth >= maxExpressionDepth {
+		return nil, p.errorf(tok.offset, "expression is nested deeper than %d levels", maxExpressionDepth)
+	}
+	x, err := p.parseNot(depth + 1)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.checkBool(tok, x); err != nil {
+		return nil, err
+	}
+	return &notNode{x: x}, nil
+}
+
+func (p *exprParser) parseComparison(depth int) (exprNode, error) {
+	l, err := p.parseOperand(depth)
+	if err != nil {
+		return nil, err
+	}
+
+	tok := p.peek()
+	switch {
+	case tok.kind == tokenIdent && tok.text == "in":
+		p.next()
+		r, err := p.parseOperand(depth)
+		if err != nil {
+			return nil, err
+		}
+		if l.typ() == typeList {
+			return nil, p.errorf(tok.offset, "left operand of in is list")
+		}
+		if t := r.typ(); t != typeList && t != typeAny {
+			return nil, p.errorf(tok.offset, "right operand of in is %s, expected list", t)
+		}
+		if list, ok := r.(*listNode); ok {
+			for _, item := range list.items {
+				if err := p.checkComparable(tok, l, item); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return &inNode{x: l, list: r}, nil
+
+	case tok.kind == tokenOperator && (tok.text == "==" || tok.text == "!=" || tok.text == "<" || tok.text == "<=" || tok.text == ">" || tok.text == ">="):
+		p.next()
+		r, err := p.parseOperand(depth)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.checkComparable(tok, l, r); err != nil {
+			return nil, err
+		}
+		if tok.text != "==" && tok.text != "!=" {
+			for _, x := range []exprNode{l, r} {
+				if t := x.typ(); t == typeBool {
+					return nil, p.errorf(tok.offset, "operands of %s have to be numbers or strings, got %s", tok.text, t)
+				}
+			}
+		}
+		return &compareNode{op: tok.text, l: l, r: r}, nil
+	}
+	return l, nil
+}
+
+func (p *exprParser) parseOperand(depth int) (exprNode, error) {
+	if depth >= maxExpressionDepth {
+		return nil, p.errorf(p.peek().offset, "expression is nested deeper than %d levels", maxExpressionDepth)
+	}
+
+	tok := p.next()
+	switch tok.kind {
+	case tokenString:
+		return &literalNode{value: tok.text, t: typeString}, nil
+
+	case tokenNumber:
+		return p.number(tok, tok.text)
+
+	case tokenIdent:
+		switch tok.text {
+		case "true", "false":
+			return &literalNode{value: tok.text == "true", t: typeBool}, nil
+		case "subject", "resource", "env":
+			return p.parseAttribute(tok)
+		}
+		if fn, ok := exprFunctions[tok.text]; ok {
+			return p.parseCall(tok, fn, depth)
+		}
+		return nil, p.errorf(tok.offset, "unknown identifier %s", tok)
+
+	case tokenOperator:
+		switch tok.text {
+		case "-":
+			num := p.next()
+			if num.kind != tokenNumber || num.offset != tok.offset+1 {
+				return nil, p.errorf(tok.offset, "unexpected %s", tok)
+			}
+			return p.number(tok, "-"+num.text)
+		case "(":
+			x, err := p.parseOr(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			return x, p.expect(")")
+		case "[":
+			return p.parseList(depth)
+		}
+	}
+	return nil, p.errorf(tok.offset, "unexpected %s", tok)
+}
+
+func (p *exprParser) number(tok token, text string) (exprNode, error) {
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return nil, p.errorf(tok.offset, "invalid number %q", text)
+	}
+	return &literalNode{value: f, t: typeNumber}, nil
+}
+
+func (p *exprParser) parseAttribute(scope token) (exprNode, error) {
+	n := &attributeNode{scope: scope.text}
+	for p.accept(".") {
+		name := p.next()
+		if name.kind != tokenIdent {
+			return nil, p.errorf(name.offset, "expected attribute name, got %s", name)
+		}
+		n.path = append(n.path, name.text)
+	}
+	if len(n.path) == 0 {
+		return nil, p.errorf(scope.offset, "%s has to be followed by attribute name", scope.text)
+	}
+	return n, nil
+}
+
+func (p *exprParser) parseList(depth int) (exprNode, error) {
+	n := &listNode{}
+	if p.accept("]") {
+		return n, nil
+	}
+	for {
+		tok := p.peek()
+		item, err := p.parseOperand(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if item.typ() == typeList {
+			return nil, p.errorf(tok.offset, "lists can not be nested")
+		}
+		n.items = append(n.items, item)
+		if p.accept("]") {
+			return n, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *exprParser) parseCall(name token, fn func(s, arg string) bool, depth int) (exprNode, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	n := &callNode{fn: fn}
+	for i := range n.args {
+		if i > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		tok := p.peek()
+		arg, err := p.parseOperand(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if t := arg.typ(); t != typeString && t != typeAny {
+			return nil, p.errorf(tok.offset, "argument %d of %s is %s, expected string", i+1, name.text, t)
+		}
+		n.args[i] = arg
+	}
+	return n, p.expect(")")
+}
+
+// checkBool reports operands of boolean operator which are known not to be bool
+func (p *exprParser) checkBool(op token, operands ...exprNode) error {
+	for _, x := range operands {
+		if t := x.typ(); t != typeBool && t != typeAny {
+			return p.errorf(op.offset, "operand of %s is %s, expected bool", op.text, t)
+		}
+	}
+	return nil
+}
+
+// checkComparable reports operands known to be of different types or lists
+func (p *exprParser) checkComparable(op token, l, r exprNode) error {
+	lt, rt := l.typ(), r.typ()
+	if lt == typeList || rt == typeList {
+		return p.errorf(op.offset, "lists can not be compared with %s", op.text)
+	}
+	if lt != typeAny && rt != typeAny && lt != rt {
+		return p.errorf(op.offset, "mismatched types %s and %s of %s", lt, rt, op.text)
+	}
+	return nil
+}
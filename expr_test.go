@@ -0,0 +1,133 @@
+package rbac
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompileExpression(t *testing.T) {
+	valid := []string{
+		`resource.owner == subject.id`,
+		`env.time >= "09:00" && env.time < "18:00"`,
+		`subject.role in ['admin', 'owner'] || !(resource.public == false)`,
+		`startsWith(resource.path, "/public/") && resource.size <= 1024`,
+		`resource.meta.team in subject.teams`,
+		`resource.level > -1.5`,
+		`subject.flag`,
+		`true`,
+	}
+	for i, src := range valid {
+		if _, err := CompileExpression(src); err != nil {
+			t.Errorf("[valid %d] compile error: expected err equal nil, got %v", i+1, err)
+		}
+	}
+
+	invalid := []struct {
+		src    string
+		offset int
+	}{
+		{``, 0},
+		{`resource.owner ==`, 17},
+		{`resource.owner = subject.id`, 15},
+		{`"a" == 1`, 4},
+		{`"a" < true`, 4},
+		{`true < false`, 5},
+		{`1 && subject.flag`, 2},
+		{`!"a"`, 0},
+		{`"a"`, 0},
+		{`resource.tags == [1]`, 14},
+		{`"a" in "abc"`, 4},
+		{`"a" in [1, 2]`, 4},
+		{`startsWith(resource.path, 1)`, 26},
+		{`startsWith(resource.path)`, 24},
+		{`unknown(resource.path, "a")`, 0},
+		{`user.id == "a"`, 0},
+		{`subject == "a"`, 0},
+		{`resource. == "a"`, 10},
+		{`"unterminated`, 0},
+		{`resource.owner == subject.id)`, 28},
+		{`resource.owner # 1`, 15},
+		{`resource.size > 1.2.3`, 16},
+		{strings.Repeat("(", maxExpressionDepth+1) + "true" + strings.Repeat(")", maxExpressionDepth+1), maxExpressionDepth},
+		{strings.Repeat(" ", maxExpressionLength+1), maxExpressionLength},
+	}
+	for i, c := range invalid {
+		_, err := CompileExpression(c.src)
+
+		var exprErr *ExpressionError
+		if !errors.As(err, &exprErr) {
+			t.Errorf("[invalid %d] compile error: expected *ExpressionError, got %v", i+1, err)
+			continue
+		}
+		if exprErr.Offset != c.offset {
+			t.Errorf("[invalid %d] invalid output: expected offset %d, got %d (%v)", i+1, c.offset, exprErr.Offset, err)
+		}
+	}
+}
+
+func TestExpressionEval(t *testing.T) {
+	ctx := EvalContext{
+		User:   NewUser(defaultUserID),
+		Domain: NewDomain(defaultDomainID),
+		Time:   time.Date(2024, 1, 1, 10, 30, 0, 0, time.UTC),
+
+		Subject: Attributes{"teams": []string{"red", "blue"}, "level": 3, "admin": true},
+		Resource: Attributes{
+			"owner": defaultUserID,
+			"path":  "/public/report.pdf",
+			"size":  int64(512),
+			"meta":  map[string]interface{}{"team": "blue"},
+			"tags":  []interface{}{"a", 1},
+			"until": (*time.Time)(nil),
+		},
+		Environment: Attributes{"ip": "10.0.0.1"},
+	}
+
+	cases := []struct {
+		src      string
+		expected bool
+	}{
+		// case 1-4: attributes and built-ins
+		{`resource.owner == subject.id`, true},
+		{`resource.owner != subject.id`, false},
+		{`env.time >= "09:00" && env.time < "18:00"`, true},
+		{`env.weekday == "Monday" && env.domain == "` + defaultDomainID + `"`, true},
+		// case 5-8: numbers of different Go types are comparable
+		{`resource.size <= 1024 && subject.level > 2.5`, true},
+		{`resource.size == 512`, true},
+		{`subject.level < -1`, false},
+		{`subject.admin`, true},
+		// case 9-13: membership and functions
+		{`resource.meta.team in subject.teams`, true},
+		{`"green" in subject.teams`, false},
+		{`1 in resource.tags`, true},
+		{`startsWith(resource.path, "/public/") && endsWith(resource.path, '.pdf')`, true},
+		{`startsWith(env.ip, "192.168.")`, false},
+		// case 14-18: missing attributes and mismatched types are false
+		{`resource.missing == "a"`, false},
+		{`resource.missing != "a"`, false},
+		{`resource.owner == 1`, false},
+		{`resource.meta.team.name == "blue"`, false},
+		{`resource.tags == subject.teams`, false},
+		// case 19-21: boolean logic
+		{`!(resource.owner == "bob") && (false || subject.admin)`, true},
+		{`!resource.missing`, false},
+		{`resource.missing || subject.admin`, true},
+		// case 22-23: typed nil Stringer is missing
+		{`resource.until == ""`, false},
+		{`resource.until != ""`, false},
+	}
+
+	for i, c := range cases {
+		e, err := CompileExpression(c.src)
+		if err != nil {
+			t.Errorf("[case %d] compile error: expected err equal nil, got %v", i+1, err)
+			continue
+		}
+		if ok := e.Eval(ctx); ok != c.expected {
+			t.Errorf("[case %d] invalid output: expected %t, got %t for %s", i+1, c.expected, ok, c.src)
+		}
+	}
+}
@@ -1,5 +1,10 @@
 package rbac
 
+import (
+	"path"
+	"strings"
+)
+
 type (
 	// Object describes object of access control
 	Object string
@@ -44,4 +49,35 @@ func (p Permission)Object()Object{
 // Action returns Action property of Permission
 func (p Permission)Action()Action{
 	return p.action
-}
\ No newline at end of file
+}
+
+// Wildcard checks if Object or Action of Permission is a pattern.
+// Pattern contains "*" matching any sequence of characters except "/", e.g. "reports/*" or "*".
+// Other characters, including "?" and "[", match only themselves.
+func (p Permission) Wildcard() bool {
+	return isPattern(string(p.object)) || isPattern(string(p.action))
+}
+
+// Match checks if Permission covers provided one.
+// Object and Action of Permission are matched as patterns, Object and Action of provided Permission - as is.
+func (p Permission) Match(q Permission) bool {
+	if p == q {
+		return true
+	}
+	return matchPattern(string(p.object), string(q.object)) && matchPattern(string(p.action), string(q.action))
+}
+
+// patternEscaper makes path.Match treat every pattern character except "*" literally
+var patternEscaper = strings.NewReplacer(`\`, `\\`, "?", `\?`, "[", `\[`)
+
+func isPattern(s string) bool {
+	return strings.Contains(s, "*")
+}
+
+func matchPattern(pattern, s string) bool {
+	if !isPattern(pattern) {
+		return pattern == s
+	}
+	ok, err := path.Match(patternEscaper.Replace(pattern), s)
+	return err == nil && ok
+}
@@ -67,4 +67,54 @@ func TestPermissionObjectAction(t *testing.T) {
 	if p.Action() != a {
 		t.Errorf("Invalid output: expected action %s, got %s", a, p.Action())
 	}
-}
\ No newline at end of file
+}
+
+func TestPermissionWildcard(t *testing.T) {
+	cases := []struct {
+		p        Permission
+		wildcard bool
+	}{
+		{NewPermission(NewObject("invoice"), NewAction("read")), false},
+		{NewPermission(NewObject("invoice"), NewAction("*")), true},
+		{NewPermission(NewObject("reports/*"), NewAction("read")), true},
+		{NewPermission(NewObject("report?"), NewAction("read")), false},
+		{NewPermission(NewObject("file[1]"), NewAction("read")), false},
+	}
+
+	for i, c := range cases {
+		if c.p.Wildcard() != c.wildcard {
+			t.Errorf("[case %d] invalid output: expected %t, got %t", i+1, c.wildcard, c.p.Wildcard())
+		}
+	}
+}
+
+func TestPermissionMatch(t *testing.T) {
+	cases := []struct {
+		p     Permission
+		q     Permission
+		match bool
+	}{
+		{NewPermission("invoice", "read"), NewPermission("invoice", "read"), true},
+		{NewPermission("invoice", "read"), NewPermission("invoice", "write"), false},
+		{NewPermission("invoice", "*"), NewPermission("invoice", "write"), true},
+		{NewPermission("invoice", "*"), NewPermission("order", "write"), false},
+		{NewPermission("reports/*", "read"), NewPermission("reports/2018", "read"), true},
+		{NewPermission("reports/*", "read"), NewPermission("reports/2018/q1", "read"), false},
+		{NewPermission("reports/*", "read"), NewPermission("reports/2018", "write"), false},
+		{NewPermission("*", "*"), NewPermission("invoice", "read"), true},
+		// concrete permission does not match pattern
+		{NewPermission("invoice", "read"), NewPermission("invoice", "*"), false},
+		// only "*" is special, other characters match themselves
+		{NewPermission("file[1]", "read"), NewPermission("file[1]", "read"), true},
+		{NewPermission("file[1]", "read"), NewPermission("file1", "read"), false},
+		{NewPermission("file[1]/*", "read"), NewPermission("file[1]/a", "read"), true},
+		{NewPermission("file?/*", "read"), NewPermission("file1/a", "read"), false},
+		{NewPermission(`dir\*`, "read"), NewPermission(`dir\a`, "read"), true},
+	}
+
+	for i, c := range cases {
+		if c.p.Match(c.q) != c.match {
+			t.Errorf("[case %d] invalid output: %v match %v expected %t, got %t", i+1, c.p, c.q, c.match, c.p.Match(c.q))
+		}
+	}
+}
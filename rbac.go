@@ -1,30 +1,122 @@
 package rbac
 
-import "sync"
+import (
+	"sync"
+	"time"
+)
 
 // RBAC describes controller that operates Users, Roles and Object-Action-based Permissions
 // For usage all objects( Users, Roles, Permissions has to be registered using correlated methods.
 type RBAC struct {
-	registeredPermissions map[Permission]struct{}
-	registeredRoles map[Role]struct{}
-	registeredUsers map[User]struct{}
+	store Store
+	now   func() time.Time
+	// separator splits Object IDs into hierarchy path, empty disables hierarchy
+	separator string
 
-	perms2roles map[Role]map[Permission]struct{}
-	roles2users map[User]map[Role]struct{}
+	// conditions holds conditional Permissions of Roles, see AssignConditionalPermissionToRole
+	conditions map[Role]map[Permission]Condition
+	// conditionRoles is reverse index of conditions, so removal of Permission touches only Roles holding it
+	conditionRoles map[Permission]map[Role]struct{}
+	// expressions caches compiled expressions of conditional Permissions kept by store
+	expressions *expressionCache
+	// actions caches transitive closure of Action implications kept by store
+	actions *actionClosure
+	// effective materializes effective Permissions of Users, see effectiveIndex
+	effective *effectiveIndex
 
+	// mutex guards store and settings above. Public methods take it exactly once and delegate
+	// to unexported helpers, which never lock and never call public methods: sync.RWMutex read lock
+	// is not re-entrant, it deadlocks as soon as a writer is waiting between the two calls.
+	// Write lock is released by unlock, which publishes effective permission index brought up to date.
+	// Checks answered by published index take no lock at all, see hasPermission.
 	mutex *sync.RWMutex
 }
 
-// NewRBAC creates instance of RBAC controller
+// NewRBAC creates instance of RBAC controller backed by MemoryStore
 func NewRBAC() *RBAC {
-	return &RBAC{
-		registeredPermissions: make(map[Permission]struct{}),
-		registeredRoles: make(map[Role]struct{}),
-		registeredUsers: make(map[User]struct{}),
+	return NewRBACWithStore(NewMemoryStore())
+}
+
+// NewRBACWithStore creates instance of RBAC controller backed by provided Store
+func NewRBACWithStore(s Store) *RBAC {
+	rbac := &RBAC{
+		store: s,
+		now:   time.Now,
 
-		perms2roles: make(map[Role]map[Permission]struct{}),
-		roles2users: make(map[User]map[Role]struct{}),
+		conditions:     make(map[Role]map[Permission]Condition),
+		conditionRoles: make(map[Permission]map[Role]struct{}),
+		expressions:    newExpressionCache(),
+		actions:        newActionClosure(),
+		effective:      newEffectiveIndex(),
 
 		mutex: new(sync.RWMutex),
 	}
+
+	// index follows changes made through controller, so it is kept for in-memory Stores only
+	switch s.(type) {
+	case *MemoryStore, *FileStore:
+		rbac.store = &effectiveStore{Store: s, index: rbac.effective}
+		rbac.effective.enabled = true
+		rbac.effective.rebuild = true
+		rbac.refreshEffective()
+	}
+	return rbac
+}
+
+// SetClock replaces source of current time used to evaluate Role assignments Validity.
+// Nil restores time.Now.
+func (rbac *RBAC) SetClock(now func() time.Time) {
+	rbac.mutex.Lock()
+	defer rbac.unlock()
+
+	rbac.setClock(now)
+}
+
+// setClock is SetClock without locking.
+// Caller has to hold the mutex.
+func (rbac *RBAC) setClock(now func() time.Time) {
+	if now == nil {
+		now = time.Now
+	}
+	rbac.now = now
+	rbac.effective.touchSettings()
+}
+
+// checkUser returns ErrorUserNotRegistered if User is not registered.
+// Caller has to hold the mutex.
+func (rbac *RBAC) checkUser(u User) error {
+	ok, err := rbac.store.HasUser(u)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrorUserNotRegistered
+	}
+	return nil
+}
+
+// checkRole returns ErrorRoleNotRegistered if Role is not registered.
+// Caller has to hold the mutex.
+func (rbac *RBAC) checkRole(r Role) error {
+	ok, err := rbac.store.HasRole(r)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrorRoleNotRegistered
+	}
+	return nil
+}
+
+// checkPermission returns ErrorPermissionNotRegistered if Permission is not registered.
+// Caller has to hold the mutex.
+func (rbac *RBAC) checkPermission(p Permission) error {
+	ok, err := rbac.store.HasPermission(p)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrorPermissionNotRegistered
+	}
+	return nil
 }
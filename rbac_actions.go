@@ -0,0 +1,198 @@
+package rbac
+
+import (
+	"sort"
+	"sync"
+)
+
+// ActionImplication declares that Permission with Action also grants the same Object with Implied Action.
+type ActionImplication struct {
+	Action  Action
+	Implied Action
+}
+
+// DeclareActionImplies makes Permission with Action a grant the same Object with Action b, e.g. write implies read.
+// Implications are transitive: with admin implying write and write implying read, admin implies read.
+// Implications extend grants only, denied Permission does not deny Actions it implies.
+// Returns *ActionCycleError if b is a or already implies a.
+func (rbac *RBAC) DeclareActionImplies(a, b Action) error {
+	rbac.mutex.Lock()
+	defer rbac.unlock()
+
+	return rbac.declareActionImplies(a, b)
+}
+
+// RemoveActionImplies removes implication declared by DeclareActionImplies.
+// Actions implied transitively through other declared implications stay implied.
+func (rbac *RBAC) RemoveActionImplies(a, b Action) error {
+	rbac.mutex.Lock()
+	defer rbac.unlock()
+
+	return rbac.removeActionImplies(a, b)
+}
+
+// removeActionImplies is RemoveActionImplies without locking.
+// Caller has to hold the mutex.
+func (rbac *RBAC) removeActionImplies(a, b Action) error {
+	removed, err := rbac.store.RemoveActionImplication(ActionImplication{Action: a, Implied: b})
+	if removed {
+		rbac.actions.invalidate()
+	}
+	return err
+}
+
+// ListImpliedActions returns all Actions implied by Action, directly or transitively, sorted by ID.
+func (rbac *RBAC) ListImpliedActions(a Action) ([]Action, error) {
+	rbac.mutex.RLock()
+	defer rbac.mutex.RUnlock()
+
+	return rbac.listImpliedActions(a)
+}
+
+// listImpliedActions is ListImpliedActions without locking.
+// Caller has to hold the mutex.
+func (rbac *RBAC) listImpliedActions(a Action) ([]Action, error) {
+	g, err := rbac.actions.get(rbac.store)
+	if err != nil {
+		return nil, err
+	}
+	return append([]Action(nil), g.implied[a]...), nil
+}
+
+// declareActionImplies is DeclareActionImplies without locking.
+// Caller has to hold the mutex.
+func (rbac *RBAC) declareActionImplies(a, b Action) error {
+	g, err := rbac.actions.get(rbac.store)
+	if err != nil {
+		return err
+	}
+	if a == b || containsAction(g.implied[b], a) {
+		return &ActionCycleError{Action: a, Implied: b}
+	}
+
+	added, err := rbac.store.AddActionImplication(ActionImplication{Action: a, Implied: b})
+	if added {
+		rbac.actions.invalidate()
+	}
+	return err
+}
+
+// implyingPermissions returns Permissions on the same Object with Actions implying Permission Action.
+// Caller has to hold the mutex.
+func (rbac *RBAC) implyingPermissions(p Permission) ([]Permission, error) {
+	g, err := rbac.actions.get(rbac.store)
+	if err != nil {
+		return nil, err
+	}
+
+	implying := g.implying[p.action]
+	if len(implying) == 0 {
+		return nil, nil
+	}
+	out := make([]Permission, 0, len(implying))
+	for _, a := range implying {
+		out = append(out, NewPermission(p.object, a))
+	}
+	return out, nil
+}
+
+// actionGraph is transitive closure of Action implications, it is never modified once built
+type actionGraph struct {
+	// implied maps Action to all Actions it implies
+	implied map[Action][]Action
+	// implying maps Action to all Actions implying it
+	implying map[Action][]Action
+}
+
+// actionClosure keeps actionGraph built from Store until implications change.
+// Graph is built during checks holding read lock of controller, so it has its own mutex.
+type actionClosure struct {
+	mutex sync.RWMutex
+	graph *actionGraph
+}
+
+func newActionClosure() *actionClosure {
+	return &actionClosure{}
+}
+
+// get returns cached graph, building it from Store implications if needed
+func (c *actionClosure) get(s Store) (*actionGraph, error) {
+	c.mutex.RLock()
+	g := c.graph
+	c.mutex.RUnlock()
+	if g != nil {
+		return g, nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.graph != nil {
+		return c.graph, nil
+	}
+	list, err := s.ActionImplications()
+	if err != nil {
+		return nil, err
+	}
+	c.graph = buildActionGraph(list)
+	return c.graph, nil
+}
+
+// invalidate drops cached graph, so it is rebuilt on next use
+func (c *actionClosure) invalidate() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.graph = nil
+}
+
+func buildActionGraph(list []ActionImplication) *actionGraph {
+	direct := make(map[Action][]Action)
+	for _, i := range list {
+		direct[i.Action] = append(direct[i.Action], i.Implied)
+	}
+
+	g := &actionGraph{
+		implied:  make(map[Action][]Action, len(direct)),
+		implying: make(map[Action][]Action),
+	}
+	for a := range direct {
+		seen := map[Action]struct{}{a: {}}
+		queue := []Action{a}
+		for len(queue) > 0 {
+			next := queue[0]
+			queue = queue[1:]
+			for _, b := range direct[next] {
+				if _, ok := seen[b]; ok {
+					continue
+				}
+				seen[b] = struct{}{}
+				queue = append(queue, b)
+				g.implied[a] = append(g.implied[a], b)
+				g.implying[b] = append(g.implying[b], a)
+			}
+		}
+	}
+	for _, list := range g.implied {
+		sortActions(list)
+	}
+	for _, list := range g.implying {
+		sortActions(list)
+	}
+	return g
+}
+
+func sortActions(list []Action) {
+	sort.Slice(list, func(i, j int) bool {
+		return list[i] < list[j]
+	})
+}
+
+func containsAction(list []Action, a Action) bool {
+	for _, tmp := range list {
+		if tmp == a {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+package rbac
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDeclareActionImplies(t *testing.T) {
+	rbac := NewRBAC()
+
+	admin, write, read := NewAction("admin"), NewAction("write"), NewAction("read")
+
+	// case 1: action can not imply itself
+	err := rbac.DeclareActionImplies(read, read)
+
+	var cycleErr *ActionCycleError
+	if !errors.As(err, &cycleErr) {
+		t.Errorf("[case 1] declare error: expected *ActionCycleError, got %v", err)
+	}
+
+	// case 2: implications are transitive
+	if err := rbac.DeclareActionImplies(admin, write); err != nil {
+		t.Errorf("[case 2] declare error: expected err equal nil, got %v", err)
+	}
+	if err := rbac.DeclareActionImplies(write, read); err != nil {
+		t.Errorf("[case 2] declare error: expected err equal nil, got %v", err)
+	}
+
+	implied, err := rbac.ListImpliedActions(admin)
+	if err != nil || len(implied) != 2 || implied[0] != read || implied[1] != write {
+		t.Errorf("[case 2] invalid output: expected [%v %v], got %v (err %v)", read, write, implied, err)
+	}
+
+	// case 3: cycle through transitive implication is rejected
+	err = rbac.DeclareActionImplies(read, admin)
+	if !errors.As(err, &cycleErr) || cycleErr.Action != read || cycleErr.Implied != admin {
+		t.Errorf("[case 3] declare error: expected *ActionCycleError, got %v", err)
+	}
+
+	// case 4: declaring implication twice is no-op
+	if err := rbac.DeclareActionImplies(write, read); err != nil {
+		t.Errorf("[case 4] declare error: expected err equal nil, got %v", err)
+	}
+
+	// case 5: removed implication breaks transitive chain
+	if err := rbac.RemoveActionImplies(write, read); err != nil {
+		t.Errorf("[case 5] remove error: expected err equal nil, got %v", err)
+	}
+
+	implied, err = rbac.ListImpliedActions(admin)
+	if err != nil || len(implied) != 1 || implied[0] != write {
+		t.Errorf("[case 5] invalid output: expected [%v], got %v (err %v)", write, implied, err)
+	}
+
+	if err := rbac.DeclareActionImplies(read, admin); err != nil {
+		t.Errorf("[case 5] declare error: expected err equal nil, got %v", err)
+	}
+}
+
+func TestActionImpliesChecks(t *testing.T) {
+	rbac := NewRBAC()
+
+	u := NewUser(defaultUserID)
+	editor := NewRole("editor")
+	invoice := NewObject("invoice")
+	write := NewPermission(invoice, NewAction("write"))
+	read := NewPermission(invoice, NewAction("read"))
+
+	rbac.RegisterUser(u)
+	rbac.RegisterRole(editor)
+	rbac.RegisterPermission(write)
+	rbac.AssignPermissionToRole(editor, write)
+	rbac.AssignRoleToUser(u, editor)
+
+	// case 1: implied permission is not known without implication
+	_, err := rbac.UserHasObjectAction(u, invoice, NewAction("read"))
+	if err != ErrorPermissionNotRegistered {
+		t.Errorf("[case 1] check error: expected err equal %v, got %v", ErrorPermissionNotRegistered, err)
+	}
+
+	// case 2: write implies read
+	rbac.DeclareActionImplies(NewAction("admin"), NewAction("write"))
+	rbac.DeclareActionImplies(NewAction("write"), NewAction("read"))
+
+	ok, err := rbac.UserHasObjectAction(u, invoice, NewAction("read"))
+	if err != nil || !ok {
+		t.Errorf("[case 2] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	ok, err = rbac.RoleHasPermission(editor, read)
+	if err != nil || !ok {
+		t.Errorf("[case 2] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	// case 3: implication does not work backwards
+	rbac.RegisterPermission(NewPermission(invoice, NewAction("admin")))
+
+	ok, err = rbac.UserHasObjectAction(u, invoice, NewAction("admin"))
+	if err != nil || ok {
+		t.Errorf("[case 3] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+
+	// case 4: deny of implied action overrides implied grant, deny of implying action does not deny implied one
+	rbac.RegisterPermission(read)
+	rbac.DenyPermissionToRole(editor, read)
+
+	ok, err = rbac.UserHasPermission(u, read)
+	if err != nil || ok {
+		t.Errorf("[case 4] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+
+	rbac.RemoveDenyFromRole(editor, read)
+	rbac.DenyPermissionToRole(editor, write)
+
+	ok, err = rbac.UserHasPermission(u, read)
+	if err != nil || !ok {
+		t.Errorf("[case 4] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	ok, err = rbac.UserHasPermission(u, write)
+	if err != nil || ok {
+		t.Errorf("[case 4] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+
+	// case 5: explanation names implying rule
+	rbac.RemoveDenyFromRole(editor, write)
+
+	d, err := rbac.ExplainUserPermission(u, read)
+	if err != nil || !d.Allowed || d.Rule != write {
+		t.Errorf("[case 5] invalid output: expected rule %v, got %v (err %v)", write, d, err)
+	}
+
+	// case 6: implications combine with object hierarchy and conditions
+	rbac.SetObjectSeparator("/")
+	project := NewPermission(NewObject("project"), NewAction("admin"))
+	rbac.RegisterPermission(project)
+	rbac.AssignPermissionToRoleWhen(editor, project, `resource.owner == subject.id`)
+
+	ok, err = rbac.UserHasPermissionWithContext(u, NewPermission(NewObject("project/doc"), NewAction("read")), EvalContext{Resource: Attributes{"owner": defaultUserID}})
+	if err != nil || !ok {
+		t.Errorf("[case 6] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+}
@@ -0,0 +1,339 @@
+package rbac
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// concurrencyDocument is imported by Import and UnmarshalJSON operations
+const concurrencyDocument = `{"version":1,"users":["user-0"],"roles":["role-0"],"permissions":[{"object":"object-0","action":"read"}],
+"role_permissions":[{"role":"role-0","object":"object-0","action":"read"}],"user_roles":[{"user":"user-0","role":"role-0"}]}`
+
+// concurrencyOps returns operation exercising every public method of RBAC, keyed by method name.
+// Operations work on small set of entities, so writers keep changing what readers see.
+// Errors are ignored: entities are registered and removed concurrently, so any of them may be missing.
+func concurrencyOps() map[string]func(rbac *RBAC, i int) {
+	user := func(i int) User { return NewUser(fmt.Sprintf("user-%d", i%4)) }
+	role := func(i int) Role { return NewRole(fmt.Sprintf("role-%d", i%4)) }
+	perm := func(i int) Permission {
+		return NewPermission(NewObject(fmt.Sprintf("object-%d", i%4)), NewAction("read"))
+	}
+	domain := func(i int) Domain { return NewDomain(fmt.Sprintf("domain-%d", i%2)) }
+	read, write := NewAction("read"), NewAction("write")
+
+	return map[string]func(rbac *RBAC, i int){
+		// users
+		"RegisterUser": func(rbac *RBAC, i int) { rbac.RegisterUser(user(i)) },
+		"RemoveUser": func(rbac *RBAC, i int) {
+			if i%16 == 0 {
+				rbac.RemoveUser(user(i))
+			}
+		},
+		"ListUsers":       func(rbac *RBAC, i int) { rbac.ListUsers() },
+		"UserExists":      func(rbac *RBAC, i int) { rbac.UserExists(user(i)) },
+		"RegisterUserErr": func(rbac *RBAC, i int) { rbac.RegisterUserErr(user(i)) },
+		"RemoveUserErr": func(rbac *RBAC, i int) {
+			if i%16 == 1 {
+				rbac.RemoveUserErr(user(i))
+			}
+		},
+		"ListUsersErr":  func(rbac *RBAC, i int) { rbac.ListUsersErr() },
+		"UserExistsErr": func(rbac *RBAC, i int) { rbac.UserExistsErr(user(i)) },
+
+		// roles
+		"RegisterRole": func(rbac *RBAC, i int) { rbac.RegisterRole(role(i)) },
+		"RemoveRole": func(rbac *RBAC, i int) {
+			if i%16 == 0 {
+				rbac.RemoveRole(role(i))
+			}
+		},
+		"ListRoles":       func(rbac *RBAC, i int) { rbac.ListRoles() },
+		"RoleExists":      func(rbac *RBAC, i int) { rbac.RoleExists(role(i)) },
+		"RegisterRoleErr": func(rbac *RBAC, i int) { rbac.RegisterRoleErr(role(i)) },
+		"RemoveRoleErr": func(rbac *RBAC, i int) {
+			if i%16 == 1 {
+				rbac.RemoveRoleErr(role(i))
+			}
+		},
+		"ListRolesErr":             func(rbac *RBAC, i int) { rbac.ListRolesErr() },
+		"RoleExistsErr":            func(rbac *RBAC, i int) { rbac.RoleExistsErr(role(i)) },
+		"AssignPermissionToRole":   func(rbac *RBAC, i int) { rbac.AssignPermissionToRole(role(i), perm(i)) },
+		"RemovePermissionFromRole": func(rbac *RBAC, i int) { rbac.RemovePermissionFromRole(role(i), perm(i+1)) },
+		"ListRolePermissions":      func(rbac *RBAC, i int) { rbac.ListRolePermissions(role(i)) },
+		"RoleHasPermission":        func(rbac *RBAC, i int) { rbac.RoleHasPermission(role(i), perm(i)) },
+		"DenyPermissionToRole":     func(rbac *RBAC, i int) { rbac.DenyPermissionToRole(role(i), perm(i+2)) },
+		"RemoveDenyFromRole":       func(rbac *RBAC, i int) { rbac.RemoveDenyFromRole(role(i), perm(i+2)) },
+		"ListRoleDenies":           func(rbac *RBAC, i int) { rbac.ListRoleDenies(role(i)) },
+		"RoleDeniesPermission":     func(rbac *RBAC, i int) { rbac.RoleDeniesPermission(role(i), perm(i)) },
+		"AddRoleParent":            func(rbac *RBAC, i int) { rbac.AddRoleParent(role(i), role(i+1)) },
+		"RemoveRoleParent":         func(rbac *RBAC, i int) { rbac.RemoveRoleParent(role(i), role(i+1)) },
+		"ListRoleParents":          func(rbac *RBAC, i int) { rbac.ListRoleParents(role(i)) },
+
+		// permissions
+		"RegisterPermission": func(rbac *RBAC, i int) { rbac.RegisterPermission(perm(i)) },
+		"RemovePermission": func(rbac *RBAC, i int) {
+			if i%16 == 0 {
+				rbac.RemovePermission(perm(i))
+			}
+		},
+		"ListPermissions":       func(rbac *RBAC, i int) { rbac.ListPermissions() },
+		"PermissionExists":      func(rbac *RBAC, i int) { rbac.PermissionExists(perm(i)) },
+		"RegisterPermissionErr": func(rbac *RBAC, i int) { rbac.RegisterPermissionErr(perm(i)) },
+		"RemovePermissionErr": func(rbac *RBAC, i int) {
+			if i%16 == 1 {
+				rbac.RemovePermissionErr(perm(i))
+			}
+		},
+		"ListPermissionsErr":  func(rbac *RBAC, i int) { rbac.ListPermissionsErr() },
+		"PermissionExistsErr": func(rbac *RBAC, i int) { rbac.PermissionExistsErr(perm(i)) },
+		"SetObjectSeparator":  func(rbac *RBAC, i int) { rbac.SetObjectSeparator([]string{"", "/"}[i%2]) },
+		"ObjectSeparator":     func(rbac *RBAC, i int) { rbac.ObjectSeparator() },
+		"SetPermissionExact":  func(rbac *RBAC, i int) { rbac.SetPermissionExact(perm(i), i%2 == 0) },
+		"PermissionIsExact":   func(rbac *RBAC, i int) { rbac.PermissionIsExact(perm(i)) },
+
+		// actions
+		"DeclareActionImplies": func(rbac *RBAC, i int) { rbac.DeclareActionImplies(write, read) },
+		"RemoveActionImplies":  func(rbac *RBAC, i int) { rbac.RemoveActionImplies(write, read) },
+		"ListImpliedActions":   func(rbac *RBAC, i int) { rbac.ListImpliedActions(write) },
+
+		// conditions
+		"AssignConditionalPermissionToRole": func(rbac *RBAC, i int) {
+			rbac.AssignConditionalPermissionToRole(role(i), perm(i+3), ownerOnly)
+		},
+		"AssignPermissionToRoleWhen": func(rbac *RBAC, i int) {
+			rbac.AssignPermissionToRoleWhen(role(i), perm(i+3), `resource.owner == subject.id`)
+		},
+		"RemoveConditionalPermissionFromRole": func(rbac *RBAC, i int) { rbac.RemoveConditionalPermissionFromRole(role(i), perm(i+3)) },
+		"ListRoleConditionalPermissions":      func(rbac *RBAC, i int) { rbac.ListRoleConditionalPermissions(role(i)) },
+
+		// assignments
+		"AssignRoleToUser":         func(rbac *RBAC, i int) { rbac.AssignRoleToUser(user(i), role(i)) },
+		"AssignRoleToUserInDomain": func(rbac *RBAC, i int) { rbac.AssignRoleToUserInDomain(user(i), role(i), domain(i)) },
+		"AssignRoleToUserUntil": func(rbac *RBAC, i int) {
+			rbac.AssignRoleToUserUntil(user(i), role(i+1), time.Now().Add(time.Hour))
+		},
+		"AssignRoleToUserWithValidity": func(rbac *RBAC, i int) {
+			rbac.AssignRoleToUserWithValidity(user(i), role(i+2), Between(time.Now().Add(-time.Hour), time.Now().Add(time.Hour)))
+		},
+		"AssignRoleToUserInDomainWithValidity": func(rbac *RBAC, i int) {
+			rbac.AssignRoleToUserInDomainWithValidity(user(i), role(i), domain(i), Until(time.Now().Add(-time.Hour)))
+		},
+		"RemoveRoleFromUser":         func(rbac *RBAC, i int) { rbac.RemoveRoleFromUser(user(i), role(i+1)) },
+		"RemoveRoleFromUserInDomain": func(rbac *RBAC, i int) { rbac.RemoveRoleFromUserInDomain(user(i), role(i), domain(i)) },
+		"ListUserRoles":              func(rbac *RBAC, i int) { rbac.ListUserRoles(user(i)) },
+		"ListUserRolesInDomain":      func(rbac *RBAC, i int) { rbac.ListUserRolesInDomain(user(i), domain(i)) },
+		"ListUserDomains":            func(rbac *RBAC, i int) { rbac.ListUserDomains(user(i)) },
+		"UserHasRole":                func(rbac *RBAC, i int) { rbac.UserHasRole(user(i), role(i)) },
+		"UserHasRoleInDomain":        func(rbac *RBAC, i int) { rbac.UserHasRoleInDomain(user(i), role(i), domain(i)) },
+		"UserRoleValidity":           func(rbac *RBAC, i int) { rbac.UserRoleValidity(user(i), role(i), DefaultDomain) },
+		"SweepExpiredAssignments":    func(rbac *RBAC, i int) { rbac.SweepExpiredAssignments() },
+		"StartSweeper": func(rbac *RBAC, i int) {
+			if i%16 == 0 {
+				if stop, err := rbac.StartSweeper(time.Millisecond, nil); err == nil {
+					stop()
+				}
+			}
+		},
+		"SetClock": func(rbac *RBAC, i int) { rbac.SetClock(nil) },
+
+		// checks
+		"UserHasPermission":           func(rbac *RBAC, i int) { rbac.UserHasPermission(user(i), perm(i)) },
+		"UserHasPermissionInDomain":   func(rbac *RBAC, i int) { rbac.UserHasPermissionInDomain(user(i), perm(i), domain(i)) },
+		"UserHasObjectAction":         func(rbac *RBAC, i int) { rbac.UserHasObjectAction(user(i), perm(i).Object(), read) },
+		"UserHasObjectActionInDomain": func(rbac *RBAC, i int) { rbac.UserHasObjectActionInDomain(user(i), perm(i).Object(), read, domain(i)) },
+		"UserHasPermissionWithContext": func(rbac *RBAC, i int) {
+			rbac.UserHasPermissionWithContext(user(i), perm(i+3), EvalContext{Resource: Attributes{"owner": user(i).ID()}})
+		},
+		"UserHasPermissionInDomainWithContext": func(rbac *RBAC, i int) {
+			rbac.UserHasPermissionInDomainWithContext(user(i), perm(i+3), domain(i), EvalContext{})
+		},
+		"Can": func(rbac *RBAC, i int) {
+			rbac.Can(WithDomain(WithUser(context.Background(), user(i)), domain(i)), perm(i).Object(), read)
+		},
+		"ExplainUserPermission":         func(rbac *RBAC, i int) { rbac.ExplainUserPermission(user(i), perm(i)) },
+		"ExplainUserPermissionInDomain": func(rbac *RBAC, i int) { rbac.ExplainUserPermissionInDomain(user(i), perm(i), domain(i)) },
+
+		// reverse queries
+		"RolesWithPermission":         func(rbac *RBAC, i int) { rbac.RolesWithPermission(perm(i)) },
+		"UsersWithPermission":         func(rbac *RBAC, i int) { rbac.UsersWithPermission(perm(i)) },
+		"UsersWithPermissionInDomain": func(rbac *RBAC, i int) { rbac.UsersWithPermissionInDomain(perm(i), domain(i)) },
+		"UsersWithRole":               func(rbac *RBAC, i int) { rbac.UsersWithRole(role(i)) },
+		"UsersWithRoleInDomain":       func(rbac *RBAC, i int) { rbac.UsersWithRoleInDomain(role(i), domain(i)) },
+		"ListUserPermissions":         func(rbac *RBAC, i int) { rbac.ListUserPermissions(user(i)) },
+		"ListUserPermissionsInDomain": func(rbac *RBAC, i int) { rbac.ListUserPermissionsInDomain(user(i), domain(i)) },
+
+		// separation of duty
+		"AddSSDConstraint": func(rbac *RBAC, i int) {
+			rbac.AddSSDConstraint(fmt.Sprintf("ssd-%d", i%2), []Role{role(i), role(i + 1)}, 2)
+		},
+		"RemoveSSDConstraint": func(rbac *RBAC, i int) { rbac.RemoveSSDConstraint(fmt.Sprintf("ssd-%d", i%2)) },
+		"ListSSDConstraints":  func(rbac *RBAC, i int) { rbac.ListSSDConstraints() },
+		"AddDSDConstraint": func(rbac *RBAC, i int) {
+			rbac.AddDSDConstraint(fmt.Sprintf("dsd-%d", i%2), []Role{role(i), role(i + 1)}, 2)
+		},
+		"RemoveDSDConstraint": func(rbac *RBAC, i int) { rbac.RemoveDSDConstraint(fmt.Sprintf("dsd-%d", i%2)) },
+		"ListDSDConstraints":  func(rbac *RBAC, i int) { rbac.ListDSDConstraints() },
+
+		// sessions
+		"CreateSession": func(rbac *RBAC, i int) {
+			s, err := rbac.CreateSession(user(i), role(i))
+			if err != nil {
+				return
+			}
+			for _, op := range sessionOps() {
+				op(s, i)
+			}
+		},
+		"CreateSessionInDomain": func(rbac *RBAC, i int) { rbac.CreateSessionInDomain(user(i), domain(i), role(i)) },
+
+		// persistence
+		"Export":      func(rbac *RBAC, i int) { rbac.Export(io.Discard) },
+		"MarshalJSON": func(rbac *RBAC, i int) { rbac.MarshalJSON() },
+		"Import": func(rbac *RBAC, i int) {
+			if i%32 == 0 {
+				rbac.Import(bytes.NewBufferString(concurrencyDocument))
+			}
+		},
+		"UnmarshalJSON": func(rbac *RBAC, i int) {
+			if i%32 == 16 {
+				rbac.UnmarshalJSON([]byte(concurrencyDocument))
+			}
+		},
+		"Snapshot": func(rbac *RBAC, i int) {
+			if s, err := rbac.Snapshot(); err == nil {
+				s.UserHasPermission(user(i), perm(i))
+			}
+		},
+		"Update": func(rbac *RBAC, i int) {
+			// odd rounds fail and are rolled back
+			rbac.Update(func(tx *Tx) error {
+				tx.RegisterRole(role(i))
+				tx.AssignRoleToUser(user(i), role(i))
+				tx.RemovePermission(perm(i + 1))
+				if i%2 == 1 {
+					return io.EOF
+				}
+				return nil
+			})
+		},
+		"VerifyEffectivePermissions": func(rbac *RBAC, i int) { rbac.VerifyEffectivePermissions() },
+		"ResetEffectivePermissions": func(rbac *RBAC, i int) {
+			if i%32 == 8 {
+				rbac.ResetEffectivePermissions()
+			}
+		},
+	}
+}
+
+// sessionOps returns operation exercising every public method of Session, keyed by method name.
+// Sessions are used concurrently with controller they were created by, see CreateSession operation.
+func sessionOps() map[string]func(s *Session, i int) {
+	role := func(i int) Role { return NewRole(fmt.Sprintf("role-%d", i%4)) }
+	perm := func(i int) Permission {
+		return NewPermission(NewObject(fmt.Sprintf("object-%d", i%4)), NewAction("read"))
+	}
+
+	return map[string]func(s *Session, i int){
+		"User":            func(s *Session, i int) { s.User() },
+		"Domain":          func(s *Session, i int) { s.Domain() },
+		"ActiveRoles":     func(s *Session, i int) { s.ActiveRoles() },
+		"AddActiveRole":   func(s *Session, i int) { s.AddActiveRole(role(i + 1)) },
+		"DropActiveRole":  func(s *Session, i int) { s.DropActiveRole(role(i + 1)) },
+		"HasPermission":   func(s *Session, i int) { s.HasPermission(perm(i)) },
+		"HasObjectAction": func(s *Session, i int) { s.HasObjectAction(perm(i).Object(), NewAction("read")) },
+	}
+}
+
+func TestConcurrencyOpsCoverPublicMethods(t *testing.T) {
+	covered := map[reflect.Type]map[string]struct{}{
+		reflect.TypeOf(&RBAC{}):    {},
+		reflect.TypeOf(&Session{}): {},
+	}
+	for name := range concurrencyOps() {
+		covered[reflect.TypeOf(&RBAC{})][name] = struct{}{}
+	}
+	for name := range sessionOps() {
+		covered[reflect.TypeOf(&Session{})][name] = struct{}{}
+	}
+
+	for typ, ops := range covered {
+		for i := 0; i < typ.NumMethod(); i++ {
+			name := typ.Method(i).Name
+			if _, ok := ops[name]; !ok {
+				t.Errorf("public method %s of %v is not exercised by concurrency tests", name, typ)
+			}
+		}
+	}
+}
+
+// TestConcurrentAccess calls every public method from many goroutines at once.
+// Any lock taken twice by the same goroutine deadlocks as soon as a writer queues between the two,
+// so the test fails on timeout instead of hanging. Run it with -race to catch unsynchronized access too.
+// Controller keeping effective permission index answers checks without locking, so the test runs
+// against Store without index as well, where checks take read lock.
+func TestConcurrentAccess(t *testing.T) {
+	t.Run("index", func(t *testing.T) {
+		testConcurrentAccess(t, NewRBAC())
+	})
+	t.Run("store", func(t *testing.T) {
+		testConcurrentAccess(t, NewRBACWithStore(&txMemoryStore{MemoryStore: NewMemoryStore()}))
+	})
+}
+
+func testConcurrentAccess(t *testing.T, rbac *RBAC) {
+	ops := concurrencyOps()
+	names := make([]string, 0, len(ops))
+	for name := range ops {
+		names = append(names, name)
+	}
+
+	// goroutines have to be preempted between lock calls to expose re-entrant locking, even on a single CPU
+	if prev := runtime.GOMAXPROCS(0); prev < 8 {
+		runtime.GOMAXPROCS(8)
+		defer runtime.GOMAXPROCS(prev)
+	}
+
+	rounds := 200
+	if testing.Short() {
+		rounds = 20
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				for j := range names {
+					ops[names[(j+g*7)%len(names)]](rbac, i+g)
+				}
+			}
+		}(g)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		buf := make([]byte, 1<<20)
+		n := runtime.Stack(buf, true)
+		t.Fatalf("concurrent access did not finish in time, probably deadlocked:\n%s", buf[:n])
+	}
+
+	// every decision kept by effective permission index has to survive the mutations above
+	if err := rbac.VerifyEffectivePermissions(); err != nil {
+		t.Errorf("effective permissions error: expected err equal nil, got %v", err)
+	}
+}
@@ -0,0 +1,313 @@
+package rbac
+
+import "sync"
+
+// AssignConditionalPermissionToRole assigns Permission to Role granted only when Condition holds.
+// Conditional Permissions are evaluated by UserHasPermissionWithContext only, any other check treats them as not granted.
+// Conditions are Go functions, so they are kept by RBAC controller and never reach Store or exported document,
+// use AssignPermissionToRoleWhen for conditions to be persisted.
+// Both Permission and Role has to be registered, Condition can not be nil.
+// Returns false if Role already had conditional Permission, its Condition or expression is replaced.
+func (rbac *RBAC) AssignConditionalPermissionToRole(r Role, p Permission, c Condition) (bool, error) {
+	rbac.mutex.Lock()
+	defer rbac.unlock()
+
+	return rbac.assignConditionalPermissionToRole(r, p, c)
+}
+
+// assignConditionalPermissionToRole is AssignConditionalPermissionToRole without locking.
+// Caller has to hold the mutex.
+func (rbac *RBAC) assignConditionalPermissionToRole(r Role, p Permission, c Condition) (bool, error) {
+	if err := rbac.checkRole(r); err != nil {
+		return false, err
+	}
+	if err := rbac.checkPermission(p); err != nil {
+		return false, err
+	}
+	if c == nil {
+		return false, ErrorNilCondition
+	}
+
+	removed, err := rbac.store.RemoveRoleCondition(r, p)
+	if err != nil {
+		return false, err
+	}
+
+	conditions, ok := rbac.conditions[r]
+	if !ok {
+		conditions = make(map[Permission]Condition)
+		rbac.conditions[r] = conditions
+	}
+	_, replaced := conditions[p]
+	conditions[p] = c
+	if rbac.conditionRoles[p] == nil {
+		rbac.conditionRoles[p] = make(map[Role]struct{})
+	}
+	rbac.conditionRoles[p][r] = struct{}{}
+	return !replaced && !removed, nil
+}
+
+// AssignPermissionToRoleWhen assigns Permission to Role granted only when expression holds.
+// Expression is compiled with CompileExpression, unlike Go function Conditions it is kept by Store and exported.
+// Evaluation follows AssignConditionalPermissionToRole rules.
+// Both Permission and Role has to be registered.
+// Returns *ExpressionError if expression is malformed, false if Role already had Permission on the same expression.
+func (rbac *RBAC) AssignPermissionToRoleWhen(r Role, p Permission, expr string) (bool, error) {
+	rbac.mutex.Lock()
+	defer rbac.unlock()
+
+	return rbac.assignPermissionToRoleWhen(r, p, expr)
+}
+
+// assignPermissionToRoleWhen is AssignPermissionToRoleWhen without locking.
+// Caller has to hold the mutex.
+func (rbac *RBAC) assignPermissionToRoleWhen(r Role, p Permission, expr string) (bool, error) {
+	if err := rbac.checkRole(r); err != nil {
+		return false, err
+	}
+	if err := rbac.checkPermission(p); err != nil {
+		return false, err
+	}
+	if _, err := rbac.expressions.compile(expr); err != nil {
+		return false, err
+	}
+
+	added, err := rbac.store.AddRoleCondition(r, p, expr)
+	if err != nil {
+		return false, err
+	}
+	return rbac.dropCondition(r, p) || added, nil
+}
+
+// RemoveConditionalPermissionFromRole removes conditional Permission from Role.
+// Both Role and Permission has to be registered.
+// Returns false if Role had no such conditional Permission.
+func (rbac *RBAC) RemoveConditionalPermissionFromRole(r Role, p Permission) (bool, error) {
+	rbac.mutex.Lock()
+	defer rbac.unlock()
+
+	return rbac.removeConditionalPermissionFromRole(r, p)
+}
+
+// removeConditionalPermissionFromRole is RemoveConditionalPermissionFromRole without locking.
+// Caller has to hold the mutex.
+func (rbac *RBAC) removeConditionalPermissionFromRole(r Role, p Permission) (bool, error) {
+	if err := rbac.checkRole(r); err != nil {
+		return false, err
+	}
+	if err := rbac.checkPermission(p); err != nil {
+		return false, err
+	}
+	removed, err := rbac.store.RemoveRoleCondition(r, p)
+	if err != nil {
+		return false, err
+	}
+	return rbac.dropCondition(r, p) || removed, nil
+}
+
+// ListRoleConditionalPermissions returns conditional Permissions directly assigned to Role.
+// Role has to be registered.
+func (rbac *RBAC) ListRoleConditionalPermissions(r Role) ([]Permission, error) {
+	rbac.mutex.RLock()
+	defer rbac.mutex.RUnlock()
+
+	return rbac.listRoleConditionalPermissions(r)
+}
+
+// listRoleConditionalPermissions is ListRoleConditionalPermissions without locking.
+// Caller has to hold the mutex.
+func (rbac *RBAC) listRoleConditionalPermissions(r Role) ([]Permission, error) {
+	if err := rbac.checkRole(r); err != nil {
+		return nil, err
+	}
+
+	exprs, err := rbac.store.RoleConditions(r)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]Permission, 0, len(rbac.conditions[r])+len(exprs))
+	for p := range rbac.conditions[r] {
+		out = append(out, p)
+	}
+	for p := range exprs {
+		out = append(out, p)
+	}
+	sortPermissions(out)
+	return out, nil
+}
+
+// UserHasPermissionWithContext checks if Roles assigned to User in DefaultDomain allow Permission
+// either unconditionally or by conditional Permission which Condition holds in ec.
+// Deny overrides both kinds of grants, evaluation follows UserHasPermission rules otherwise.
+// Conditions are called after the lock is released, so they may call RBAC controller themselves.
+func (rbac *RBAC) UserHasPermissionWithContext(u User, p Permission, ec EvalContext) (bool, error) {
+	return rbac.userHasPermissionWithContext(u, p, DefaultDomain, ec)
+}
+
+// UserHasPermissionInDomainWithContext checks if Roles assigned to User in Domain allow Permission.
+// Evaluation follows UserHasPermissionWithContext rules.
+func (rbac *RBAC) UserHasPermissionInDomainWithContext(u User, p Permission, d Domain, ec EvalContext) (bool, error) {
+	return rbac.userHasPermissionWithContext(u, p, d, ec)
+}
+
+// userHasPermissionWithContext checks if Roles assigned to User in Domain allow Permission in ec.
+// Conditions matching Permission are collected under the mutex and evaluated after it is released.
+func (rbac *RBAC) userHasPermissionWithContext(u User, p Permission, d Domain, ec EvalContext) (bool, error) {
+	granted, conds, err := rbac.userConditions(u, p, d, &ec)
+	if err != nil || granted {
+		return granted, err
+	}
+
+	for _, c := range conds {
+		if c(ec) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// userConditions checks if Roles assigned to User in Domain allow Permission unconditionally,
+// otherwise returns Conditions of conditional Permissions which may grant it.
+// No Conditions are returned when any Role denies Permission.
+// User, Domain and missing Time of ec are filled for Conditions to be evaluated in.
+func (rbac *RBAC) userConditions(u User, p Permission, d Domain, ec *EvalContext) (bool, []Condition, error) {
+	rbac.mutex.RLock()
+	defer rbac.mutex.RUnlock()
+
+	ec.User = u
+	ec.Domain = d
+	if ec.Time.IsZero() {
+		ec.Time = rbac.now()
+	}
+
+	if err := rbac.checkUser(u); err != nil {
+		return false, nil, err
+	}
+	wildcards, err := rbac.checkPermissionMatch(p)
+	if err != nil {
+		return false, nil, err
+	}
+
+	userRoles, err := rbac.activeUserRoles(u, d)
+	if err != nil {
+		return false, nil, err
+	}
+	roles, err := rbac.rolesWithAncestors(userRoles)
+	if err != nil {
+		return false, nil, err
+	}
+
+	var conds []Condition
+	granted, err := rbac.rolesAllowIn(roles, p, wildcards, &conds)
+	if err != nil || granted {
+		return granted, nil, err
+	}
+	return false, conds, nil
+}
+
+// roleConditions appends to conds Conditions and compiled expressions of conditional Permissions
+// assigned to Role which match Permission.
+// Caller has to hold the mutex.
+func (rbac *RBAC) roleConditions(r Role, p Permission, conds *[]Condition) error {
+	for cp, c := range rbac.conditions[r] {
+		ok, err := rbac.ruleApplies(cp, p)
+		if err != nil {
+			return err
+		}
+		if ok {
+			*conds = append(*conds, c)
+		}
+	}
+
+	exprs, err := rbac.store.RoleConditions(r)
+	if err != nil {
+		return err
+	}
+	for cp, src := range exprs {
+		ok, err := rbac.ruleApplies(cp, p)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		e, err := rbac.expressions.compile(src)
+		if err != nil {
+			return err
+		}
+		*conds = append(*conds, e.Eval)
+	}
+	return nil
+}
+
+// dropCondition removes conditional Permission from Role.
+// Caller has to hold the mutex.
+func (rbac *RBAC) dropCondition(r Role, p Permission) bool {
+	if _, ok := rbac.conditions[r][p]; !ok {
+		return false
+	}
+	delete(rbac.conditions[r], p)
+	if len(rbac.conditions[r]) == 0 {
+		delete(rbac.conditions, r)
+	}
+	delete(rbac.conditionRoles[p], r)
+	if len(rbac.conditionRoles[p]) == 0 {
+		delete(rbac.conditionRoles, p)
+	}
+	return true
+}
+
+// indexConditions builds reverse index of conditional Permissions, see RBAC.conditionRoles
+func indexConditions(conditions map[Role]map[Permission]Condition) map[Permission]map[Role]struct{} {
+	out := make(map[Permission]map[Role]struct{})
+	for r, conds := range conditions {
+		for p := range conds {
+			if out[p] == nil {
+				out[p] = make(map[Role]struct{})
+			}
+			out[p][r] = struct{}{}
+		}
+	}
+	return out
+}
+
+// expressionCache keeps compiled expressions by their source, so every expression is compiled once.
+// Cache is filled during checks holding read lock of controller, so it has its own mutex.
+// expressionCacheSize is number of compiled expressions kept by each generation of expressionCache
+const expressionCacheSize = 1024
+
+// expressionCache keeps recently used compiled expressions in two generations,
+// so expressions no longer assigned to any Role are dropped once enough others are compiled.
+type expressionCache struct {
+	mutex    sync.Mutex
+	compiled map[string]*Expression
+	previous map[string]*Expression
+}
+
+func newExpressionCache() *expressionCache {
+	return &expressionCache{compiled: make(map[string]*Expression)}
+}
+
+// compile returns compiled expression from cache, compiling it on the first use
+func (c *expressionCache) compile(src string) (*Expression, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if e, ok := c.compiled[src]; ok {
+		return e, nil
+	}
+	e, ok := c.previous[src]
+	if !ok {
+		var err error
+		if e, err = CompileExpression(src); err != nil {
+			return nil, err
+		}
+	}
+	if len(c.compiled) >= expressionCacheSize {
+		c.previous = c.compiled
+		c.compiled = make(map[string]*Expression)
+	}
+	c.compiled[src] = e
+	return e, nil
+}
@@ -0,0 +1,369 @@
+package rbac
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func ownerOnly(ctx EvalContext) bool {
+	return ctx.Resource["owner"] == ctx.User.ID()
+}
+
+func businessHours(ctx EvalContext) bool {
+	h := ctx.Time.Hour()
+	return h >= 9 && h < 18
+}
+
+func TestAssignConditionalPermissionToRole(t *testing.T) {
+	rbac := NewRBAC()
+
+	r := NewRole(defaultRoleID)
+	p := NewPermission(NewObject(defaultObjectID), NewAction(defaultActionID))
+
+	// case 1: role is not registered
+	_, err := rbac.AssignConditionalPermissionToRole(r, p, ownerOnly)
+	if err != ErrorRoleNotRegistered {
+		t.Errorf("[case 1] assign error: expected err equal %v, got %v", ErrorRoleNotRegistered, err)
+	}
+
+	// case 2: permission is not registered
+	rbac.RegisterRole(r)
+
+	_, err = rbac.AssignConditionalPermissionToRole(r, p, ownerOnly)
+	if err != ErrorPermissionNotRegistered {
+		t.Errorf("[case 2] assign error: expected err equal %v, got %v", ErrorPermissionNotRegistered, err)
+	}
+
+	// case 3: condition is nil
+	rbac.RegisterPermission(p)
+
+	_, err = rbac.AssignConditionalPermissionToRole(r, p, nil)
+	if err != ErrorNilCondition {
+		t.Errorf("[case 3] assign error: expected err equal %v, got %v", ErrorNilCondition, err)
+	}
+
+	// case 4: conditional permission is assigned
+	ok, err := rbac.AssignConditionalPermissionToRole(r, p, ownerOnly)
+	if err != nil || !ok {
+		t.Errorf("[case 4] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	if _, ok := rbac.conditions[r][p]; !ok {
+		t.Errorf("[case 4] assign error: condition were not assigned to role")
+	}
+
+	if ok, _ := memory(rbac).HasRolePermission(r, p); ok {
+		t.Errorf("[case 4] assign error: conditional permission reached store")
+	}
+
+	// case 5: condition is replaced
+	ok, err = rbac.AssignConditionalPermissionToRole(r, p, businessHours)
+	if err != nil || ok {
+		t.Errorf("[case 5] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+
+	list, _ := rbac.ListRoleConditionalPermissions(r)
+	if len(list) != 1 || list[0] != p {
+		t.Errorf("[case 5] invalid output: expected [%v], got %v", p, list)
+	}
+}
+
+func TestRemoveConditionalPermissionFromRole(t *testing.T) {
+	rbac := NewRBAC()
+
+	r := NewRole(defaultRoleID)
+	p := NewPermission(NewObject(defaultObjectID), NewAction(defaultActionID))
+
+	rbac.RegisterRole(r)
+	rbac.RegisterPermission(p)
+
+	// case 1: permission is not assigned
+	ok, err := rbac.RemoveConditionalPermissionFromRole(r, p)
+	if err != nil || ok {
+		t.Errorf("[case 1] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+
+	// case 2: permission is assigned
+	rbac.AssignConditionalPermissionToRole(r, p, ownerOnly)
+
+	ok, err = rbac.RemoveConditionalPermissionFromRole(r, p)
+	if err != nil || !ok {
+		t.Errorf("[case 2] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	if _, ok := rbac.conditions[r]; ok {
+		t.Errorf("[case 2] remove error: role conditions were not dropped")
+	}
+
+	// case 3: removing role drops its conditions
+	rbac.AssignConditionalPermissionToRole(r, p, ownerOnly)
+	rbac.RemoveRole(r)
+
+	if _, ok := rbac.conditions[r]; ok {
+		t.Errorf("[case 3] remove error: conditions of removed role were kept")
+	}
+
+	if _, ok := rbac.conditionRoles[p]; ok {
+		t.Errorf("[case 3] remove error: removed role were kept in conditions index")
+	}
+
+	// case 4: removing permission drops its conditions
+	rbac.RegisterRole(r)
+	rbac.AssignConditionalPermissionToRole(r, p, ownerOnly)
+	rbac.RemovePermission(p)
+
+	if _, ok := rbac.conditions[r][p]; ok {
+		t.Errorf("[case 4] remove error: conditions of removed permission were kept")
+	}
+
+	if _, ok := rbac.conditionRoles[p]; ok {
+		t.Errorf("[case 4] remove error: removed permission were kept in conditions index")
+	}
+
+	// case 5: rolled back removal restores conditions index
+	rbac.RegisterPermission(p)
+	rbac.AssignConditionalPermissionToRole(r, p, ownerOnly)
+	rbac.Update(func(tx *Tx) error {
+		tx.RemovePermission(p)
+		return errors.New("failure")
+	})
+
+	if _, ok := rbac.conditionRoles[p][r]; !ok {
+		t.Errorf("[case 5] rollback error: conditions index were not restored")
+	}
+}
+
+func TestUserHasPermissionWithContext(t *testing.T) {
+	rbac := NewRBAC()
+
+	u := NewUser(defaultUserID)
+	owner := NewRole("owner")
+	staff := NewRole("staff")
+	edit := NewPermission(NewObject("invoice"), NewAction("edit"))
+	read := NewPermission(NewObject("invoice"), NewAction("read"))
+	all := NewPermission(NewObject("invoice"), NewAction("*"))
+
+	rbac.RegisterUser(u)
+	rbac.RegisterRole(owner)
+	rbac.RegisterRole(staff)
+	rbac.RegisterPermission(edit)
+	rbac.RegisterPermission(read)
+	rbac.RegisterPermission(all)
+	rbac.AssignConditionalPermissionToRole(owner, edit, ownerOnly)
+	rbac.AssignConditionalPermissionToRole(staff, all, businessHours)
+	rbac.AssignRoleToUser(u, owner)
+
+	mine := EvalContext{Resource: Attributes{"owner": defaultUserID}}
+	theirs := EvalContext{Resource: Attributes{"owner": "bob"}}
+
+	// case 1: condition holds
+	ok, err := rbac.UserHasPermissionWithContext(u, edit, mine)
+	if err != nil || !ok {
+		t.Errorf("[case 1] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	// case 2: condition does not hold
+	ok, err = rbac.UserHasPermissionWithContext(u, edit, theirs)
+	if err != nil || ok {
+		t.Errorf("[case 2] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+
+	// case 3: checks without context ignore conditional permissions
+	ok, err = rbac.UserHasPermission(u, edit)
+	if err != nil || ok {
+		t.Errorf("[case 3] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+
+	// case 4: unconditional grant does not need condition to hold
+	rbac.AssignPermissionToRole(owner, edit)
+
+	ok, err = rbac.UserHasPermissionWithContext(u, edit, theirs)
+	if err != nil || !ok {
+		t.Errorf("[case 4] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	// case 5: deny overrides conditional grant
+	rbac.RemovePermissionFromRole(owner, edit)
+	rbac.DenyPermissionToRole(owner, edit)
+
+	ok, err = rbac.UserHasPermissionWithContext(u, edit, mine)
+	if err != nil || ok {
+		t.Errorf("[case 5] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+
+	// case 6: conditional wildcard permission, time taken from context
+	rbac.AssignRoleToUser(u, staff)
+
+	noon := EvalContext{Time: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	ok, err = rbac.UserHasPermissionWithContext(u, read, noon)
+	if err != nil || !ok {
+		t.Errorf("[case 6] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	// case 7: time defaults to controller clock
+	clock := &testClock{now: time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)}
+	rbac.SetClock(clock.Now)
+
+	ok, err = rbac.UserHasPermissionWithContext(u, read, EvalContext{})
+	if err != nil || ok {
+		t.Errorf("[case 7] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+
+	// case 8: conditional permission inherited from parent role
+	rbac.RemoveRoleFromUser(u, staff)
+	rbac.RemoveDenyFromRole(owner, edit)
+	rbac.AddRoleParent(staff, owner)
+	rbac.AssignRoleToUserInDomain(u, staff, NewDomain(defaultDomainID))
+
+	ok, err = rbac.UserHasPermissionInDomainWithContext(u, edit, NewDomain(defaultDomainID), mine)
+	if err != nil || !ok {
+		t.Errorf("[case 8] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	// case 9: user is not registered
+	_, err = rbac.UserHasPermissionWithContext(NewUser("bob"), edit, mine)
+	if err != ErrorUserNotRegistered {
+		t.Errorf("[case 9] check error: expected err equal %v, got %v", ErrorUserNotRegistered, err)
+	}
+
+	// case 10: condition calls controller, mutex is not held while it runs
+	rbac.AssignConditionalPermissionToRole(owner, read, func(ctx EvalContext) bool {
+		if !rbac.mutex.TryLock() {
+			return false
+		}
+		rbac.mutex.Unlock()
+
+		ok, err := rbac.UserHasRole(ctx.User, owner)
+		return err == nil && ok
+	})
+
+	ok, err = rbac.UserHasPermissionWithContext(u, read, EvalContext{})
+	if err != nil || !ok {
+		t.Errorf("[case 10] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+}
+
+func TestAssignPermissionToRoleWhen(t *testing.T) {
+	rbac := NewRBAC()
+
+	u := NewUser(defaultUserID)
+	r := NewRole(defaultRoleID)
+	p := NewPermission(NewObject(defaultObjectID), NewAction(defaultActionID))
+	owner := `resource.owner == subject.id`
+
+	rbac.RegisterUser(u)
+	rbac.RegisterRole(r)
+	rbac.RegisterPermission(p)
+	rbac.AssignRoleToUser(u, r)
+
+	// case 1: malformed expression
+	_, err := rbac.AssignPermissionToRoleWhen(r, p, `resource.owner ==`)
+
+	var exprErr *ExpressionError
+	if !errors.As(err, &exprErr) {
+		t.Errorf("[case 1] assign error: expected *ExpressionError, got %v", err)
+	}
+
+	// case 2: expression is assigned and kept by store
+	ok, err := rbac.AssignPermissionToRoleWhen(r, p, owner)
+	if err != nil || !ok {
+		t.Errorf("[case 2] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	if memory(rbac).conds2roles[r][p] != owner {
+		t.Errorf("[case 2] assign error: expression were not stored")
+	}
+
+	// case 3: the same expression is already assigned
+	ok, err = rbac.AssignPermissionToRoleWhen(r, p, owner)
+	if err != nil || ok {
+		t.Errorf("[case 3] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+
+	// case 4: expression is evaluated
+	ok, err = rbac.UserHasPermissionWithContext(u, p, EvalContext{Resource: Attributes{"owner": defaultUserID}})
+	if err != nil || !ok {
+		t.Errorf("[case 4] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	ok, err = rbac.UserHasPermissionWithContext(u, p, EvalContext{Resource: Attributes{"owner": "bob"}})
+	if err != nil || ok {
+		t.Errorf("[case 4] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+
+	// case 5: function condition replaces expression and the other way round
+	ok, err = rbac.AssignConditionalPermissionToRole(r, p, businessHours)
+	if err != nil || ok {
+		t.Errorf("[case 5] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+
+	if _, ok := memory(rbac).conds2roles[r][p]; ok {
+		t.Errorf("[case 5] assign error: replaced expression were kept")
+	}
+
+	ok, err = rbac.AssignPermissionToRoleWhen(r, p, owner)
+	if err != nil || !ok {
+		t.Errorf("[case 5] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	if _, ok := rbac.conditions[r][p]; ok {
+		t.Errorf("[case 5] assign error: replaced condition were kept")
+	}
+
+	// case 6: expression is removed
+	ok, err = rbac.RemoveConditionalPermissionFromRole(r, p)
+	if err != nil || !ok {
+		t.Errorf("[case 6] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	list, _ := rbac.ListRoleConditionalPermissions(r)
+	if len(list) != 0 {
+		t.Errorf("[case 6] invalid output: expected [], got %v", list)
+	}
+
+	// case 7: malformed expression put into store directly fails the check
+	memory(rbac).AddRoleCondition(r, p, `resource.owner ==`)
+
+	_, err = rbac.UserHasPermissionWithContext(u, p, EvalContext{})
+	if !errors.As(err, &exprErr) {
+		t.Errorf("[case 7] check error: expected *ExpressionError, got %v", err)
+	}
+}
+
+func TestExpressionCache(t *testing.T) {
+	c := newExpressionCache()
+
+	hot, err := c.compile(`resource.owner == subject.id`)
+	if err != nil {
+		t.Fatalf("compile error: expected err equal nil, got %v", err)
+	}
+
+	// case 1: cache is bounded, expressions used in meantime are kept
+	for i := 0; i < 3*expressionCacheSize; i++ {
+		if _, err := c.compile(`resource.size < ` + strconv.Itoa(i)); err != nil {
+			t.Fatalf("[case 1] compile error: expected err equal nil, got %v", err)
+		}
+		if i%expressionCacheSize == 0 {
+			c.compile(`resource.owner == subject.id`)
+		}
+	}
+
+	if n := len(c.compiled) + len(c.previous); n > 2*expressionCacheSize {
+		t.Errorf("[case 1] invalid size: expected at most %d, got %d", 2*expressionCacheSize, n)
+	}
+
+	e, err := c.compile(`resource.owner == subject.id`)
+	if err != nil || e != hot {
+		t.Errorf("[case 1] invalid output: expected cached expression (err %v)", err)
+	}
+
+	// case 2: expressions not used for a generation are dropped
+	if _, ok := c.compiled[`resource.size < 0`]; ok {
+		t.Errorf("[case 2] invalid output: expected expression to be dropped")
+	}
+	if _, ok := c.previous[`resource.size < 0`]; ok {
+		t.Errorf("[case 2] invalid output: expected expression to be dropped")
+	}
+}
@@ -0,0 +1,15 @@
+package rbac
+
+import "context"
+
+// Can checks if User carried by ctx has Permission with provided Object and Action
+// in Domain carried by ctx, DefaultDomain if there is none.
+// Returns ErrorNoUserInContext if ctx carries no User, evaluation follows UserHasPermission rules otherwise.
+func (rbac *RBAC) Can(ctx context.Context, o Object, a Action) (bool, error) {
+	u, ok := UserFromContext(ctx)
+	if !ok {
+		return false, ErrorNoUserInContext
+	}
+
+	return rbac.hasPermission(u, NewPermission(o, a), DomainFromContext(ctx))
+}
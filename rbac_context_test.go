@@ -0,0 +1,47 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCan(t *testing.T) {
+	rbac := NewRBAC()
+
+	u := NewUser(defaultUserID)
+	r := NewRole(defaultRoleID)
+	d := NewDomain(defaultDomainID)
+	o, a := NewObject(defaultObjectID), NewAction(defaultActionID)
+
+	rbac.RegisterUser(u)
+	rbac.RegisterRole(r)
+	rbac.RegisterPermission(NewPermission(o, a))
+	rbac.AssignPermissionToRole(r, NewPermission(o, a))
+	rbac.AssignRoleToUserInDomain(u, r, d)
+
+	// case 1: context carries no user
+	_, err := rbac.Can(context.Background(), o, a)
+	if err != ErrorNoUserInContext {
+		t.Errorf("[case 1] can error: expected err equal %v, got %v", ErrorNoUserInContext, err)
+	}
+
+	// case 2: role is assigned in other domain
+	ctx := WithUser(context.Background(), u)
+
+	ok, err := rbac.Can(ctx, o, a)
+	if err != nil || ok {
+		t.Errorf("[case 2] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+
+	// case 3: domain is carried by context
+	ok, err = rbac.Can(WithDomain(ctx, d), o, a)
+	if err != nil || !ok {
+		t.Errorf("[case 3] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	// case 4: user is not registered
+	_, err = rbac.Can(WithUser(context.Background(), NewUser("unknown")), o, a)
+	if err != ErrorUserNotRegistered {
+		t.Errorf("[case 4] can error: expected err equal %v, got %v", ErrorUserNotRegistered, err)
+	}
+}
@@ -0,0 +1,146 @@
+package rbac
+
+// DenyPermissionToRole explicitly denies Permission to Role.
+// Deny overrides every allow: User having Role with denied Permission is never granted it,
+// no matter which other Roles User has. Denies are inherited through role hierarchy as Permissions are.
+// Both Role and Permission has to be registered.
+// Returns false if Permission already denied to Role.
+func (rbac *RBAC) DenyPermissionToRole(r Role, p Permission) (bool, error) {
+	rbac.mutex.Lock()
+	defer rbac.unlock()
+
+	return rbac.denyPermissionToRole(r, p)
+}
+
+// denyPermissionToRole is DenyPermissionToRole without locking.
+// Caller has to hold the mutex.
+func (rbac *RBAC) denyPermissionToRole(r Role, p Permission) (bool, error) {
+	if err := rbac.checkRole(r); err != nil {
+		return false, err
+	}
+	if err := rbac.checkPermission(p); err != nil {
+		return false, err
+	}
+	return rbac.store.AddRoleDeny(r, p)
+}
+
+// RemoveDenyFromRole removes explicit deny of Permission from Role.
+// Both Role and Permission has to be registered.
+// Returns false if Permission was not denied to Role.
+func (rbac *RBAC) RemoveDenyFromRole(r Role, p Permission) (bool, error) {
+	rbac.mutex.Lock()
+	defer rbac.unlock()
+
+	return rbac.removeDenyFromRole(r, p)
+}
+
+// removeDenyFromRole is RemoveDenyFromRole without locking.
+// Caller has to hold the mutex.
+func (rbac *RBAC) removeDenyFromRole(r Role, p Permission) (bool, error) {
+	if err := rbac.checkRole(r); err != nil {
+		return false, err
+	}
+	if err := rbac.checkPermission(p); err != nil {
+		return false, err
+	}
+	return rbac.store.RemoveRoleDeny(r, p)
+}
+
+// ListRoleDenies returns all Permissions denied to Role, including inherited from parent Roles.
+// Role has to be registered.
+func (rbac *RBAC) ListRoleDenies(r Role) ([]Permission, error) {
+	rbac.mutex.RLock()
+	defer rbac.mutex.RUnlock()
+
+	return rbac.listRoleDenies(r)
+}
+
+// listRoleDenies is ListRoleDenies without locking.
+// Caller has to hold the mutex.
+func (rbac *RBAC) listRoleDenies(r Role) ([]Permission, error) {
+	if err := rbac.checkRole(r); err != nil {
+		return nil, err
+	}
+	return rbac.collectPermissions(r, rbac.store.RoleDenies)
+}
+
+// RoleDeniesPermission checks if Permission is denied to Role or to any of its ancestors.
+// Role has to be registered, Permission has to be registered or matched by registered wildcard Permission.
+func (rbac *RBAC) RoleDeniesPermission(r Role, p Permission) (bool, error) {
+	rbac.mutex.RLock()
+	defer rbac.mutex.RUnlock()
+
+	return rbac.roleDeniesPermission(r, p)
+}
+
+// roleDeniesPermission is RoleDeniesPermission without locking.
+// Caller has to hold the mutex.
+func (rbac *RBAC) roleDeniesPermission(r Role, p Permission) (bool, error) {
+	if err := rbac.checkRole(r); err != nil {
+		return false, err
+	}
+	wildcards, err := rbac.checkPermissionMatch(p)
+	if err != nil {
+		return false, err
+	}
+
+	roles, err := rbac.roleAncestors(r)
+	if err != nil {
+		return false, err
+	}
+	for ancestor := range roles {
+		ok, err := rbac.roleDenies(ancestor, p, wildcards)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+// roleDenies checks if Permission is directly denied to Role or matched by wildcard Permission denied to Role.
+// Inherited denies are not taken into account.
+// Caller has to hold the mutex.
+func (rbac *RBAC) roleDenies(r Role, p Permission, wildcards []Permission) (bool, error) {
+	return rbac.matchesAny(rbac.store.HasRoleDeny, r, p, wildcards)
+}
+
+// rolesAllow evaluates Permission against set of Roles using deny-overrides:
+// Permission is allowed if any Role grants it and no Role denies it.
+// Roles set has to already include inherited Roles.
+// Caller has to hold the mutex.
+func (rbac *RBAC) rolesAllow(roles map[Role]struct{}, p Permission, wildcards []Permission) (bool, error) {
+	return rbac.rolesAllowIn(roles, p, wildcards, nil)
+}
+
+// rolesAllowIn is rolesAllow also collecting Conditions of matching conditional Permissions into conds when it is not nil.
+// Collected Conditions are left to the caller, Permission is granted if any of them holds.
+// conds is emptied when any Role denies Permission.
+// Caller has to hold the mutex.
+func (rbac *RBAC) rolesAllowIn(roles map[Role]struct{}, p Permission, wildcards []Permission, conds *[]Condition) (bool, error) {
+	granted := false
+	for r := range roles {
+		denied, err := rbac.roleDenies(r, p, wildcards)
+		if err != nil {
+			return false, err
+		}
+		if denied {
+			if conds != nil {
+				*conds = nil
+			}
+			return false, nil
+		}
+		if granted {
+			continue
+		}
+		granted, err = rbac.roleGrants(r, p, wildcards)
+		if err != nil {
+			return false, err
+		}
+		if !granted && conds != nil {
+			if err := rbac.roleConditions(r, p, conds); err != nil {
+				return false, err
+			}
+		}
+	}
+	return granted, nil
+}
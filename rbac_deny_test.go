@@ -0,0 +1,205 @@
+package rbac
+
+import "testing"
+
+func TestDenyPermissionToRole(t *testing.T) {
+	rbac := NewRBAC()
+
+	r := NewRole(defaultRoleID)
+	p := NewPermission(NewObject(defaultObjectID), NewAction(defaultActionID))
+
+	// case 1: role is not registered
+	_, err := rbac.DenyPermissionToRole(r, p)
+	if err != ErrorRoleNotRegistered {
+		t.Errorf("[case 1] deny error: expected err equal %v, got %v", ErrorRoleNotRegistered, err)
+	}
+
+	// case 2: role is registered, permission is not registered
+	rbac.RegisterRole(r)
+
+	_, err = rbac.DenyPermissionToRole(r, p)
+	if err != ErrorPermissionNotRegistered {
+		t.Errorf("[case 2] deny error: expected err equal %v, got %v", ErrorPermissionNotRegistered, err)
+	}
+
+	// case 3: role and permission are registered, permission is not denied
+	rbac.RegisterPermission(p)
+
+	ok, err := rbac.DenyPermissionToRole(r, p)
+	if err != nil {
+		t.Errorf("[case 3] deny error: expected err equal nil, got %v", err)
+	}
+
+	if !ok {
+		t.Errorf("[case 3] invalid output: expected %t, got %t", true, ok)
+	}
+
+	if _, ok := memory(rbac).denies2roles[r][p]; !ok {
+		t.Errorf("[case 3] deny error: permission were not denied to role")
+	}
+
+	// case 4: permission is already denied
+	ok, err = rbac.DenyPermissionToRole(r, p)
+	if err != nil {
+		t.Errorf("[case 4] deny error: expected err equal nil, got %v", err)
+	}
+
+	if ok {
+		t.Errorf("[case 4] invalid output: expected %t, got %t", false, ok)
+	}
+}
+
+func TestRemoveDenyFromRole(t *testing.T) {
+	rbac := NewRBAC()
+
+	r := NewRole(defaultRoleID)
+	p := NewPermission(NewObject(defaultObjectID), NewAction(defaultActionID))
+
+	// case 1: role is not registered
+	_, err := rbac.RemoveDenyFromRole(r, p)
+	if err != ErrorRoleNotRegistered {
+		t.Errorf("[case 1] invalid output: expected err equal %v, got %v", ErrorRoleNotRegistered, err)
+	}
+
+	// case 2: role is registered, permission is not registered
+	rbac.RegisterRole(r)
+
+	_, err = rbac.RemoveDenyFromRole(r, p)
+	if err != ErrorPermissionNotRegistered {
+		t.Errorf("[case 2] invalid output: expected err equal %v, got %v", ErrorPermissionNotRegistered, err)
+	}
+
+	// case 3: permission is not denied
+	rbac.RegisterPermission(p)
+
+	ok, err := rbac.RemoveDenyFromRole(r, p)
+	if err != nil {
+		t.Errorf("[case 3] invalid output: expected err equal nil, got %v", err)
+	}
+
+	if ok {
+		t.Errorf("[case 3] invalid output: expected %t, got %t", false, ok)
+	}
+
+	// case 4: permission is denied
+	rbac.DenyPermissionToRole(r, p)
+
+	ok, err = rbac.RemoveDenyFromRole(r, p)
+	if err != nil {
+		t.Errorf("[case 4] invalid output: expected err equal nil, got %v", err)
+	}
+
+	if !ok {
+		t.Errorf("[case 4] invalid output: expected %t, got %t", true, ok)
+	}
+
+	if _, ok := memory(rbac).denies2roles[r][p]; ok {
+		t.Errorf("[case 4] deny is not removed from role")
+	}
+}
+
+func TestListRoleDenies(t *testing.T) {
+	rbac := NewRBAC()
+
+	child := NewRole("child")
+	parent := NewRole("parent")
+	p1 := NewPermission(NewObject("billing"), NewAction("export"))
+	p2 := NewPermission(NewObject("billing"), NewAction("delete"))
+
+	// case 1: role is not registered
+	_, err := rbac.ListRoleDenies(child)
+	if err != ErrorRoleNotRegistered {
+		t.Errorf("[case 1] list error: expected err equal %v, got %v", ErrorRoleNotRegistered, err)
+	}
+
+	// case 2: own and inherited denies are listed
+	rbac.RegisterRole(child)
+	rbac.RegisterRole(parent)
+	rbac.RegisterPermission(p1)
+	rbac.RegisterPermission(p2)
+	rbac.AddRoleParent(child, parent)
+	rbac.DenyPermissionToRole(child, p1)
+	rbac.DenyPermissionToRole(parent, p2)
+
+	list, err := rbac.ListRoleDenies(child)
+	if err != nil {
+		t.Errorf("[case 2] list error: expected err equal nil, got %v", err)
+	}
+
+	if len(list) != 2 || !permissionExistsIn(p1, list) || !permissionExistsIn(p2, list) {
+		t.Errorf("[case 2] invalid output: expected [%v %v], got %v", p1, p2, list)
+	}
+}
+
+func TestDenyOverridesAllow(t *testing.T) {
+	rbac := NewRBAC()
+
+	u := NewUser(defaultUserID)
+	employee := NewRole("employee")
+	contractor := NewRole("contractor")
+	export := NewPermission(NewObject("billing"), NewAction("export"))
+	allBilling := NewPermission(NewObject("billing"), NewAction("*"))
+
+	rbac.RegisterUser(u)
+	rbac.RegisterRole(employee)
+	rbac.RegisterRole(contractor)
+	rbac.RegisterPermission(export)
+	rbac.RegisterPermission(allBilling)
+	rbac.AssignPermissionToRole(employee, allBilling)
+	rbac.AssignRoleToUser(u, employee)
+	rbac.AssignRoleToUser(u, contractor)
+
+	// case 1: no denies
+	ok, err := rbac.UserHasPermission(u, export)
+	if err != nil || !ok {
+		t.Errorf("[case 1] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	// case 2: deny on one of user roles beats allow on another
+	rbac.DenyPermissionToRole(contractor, export)
+
+	ok, err = rbac.UserHasPermission(u, export)
+	if err != nil || ok {
+		t.Errorf("[case 2] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+
+	ok, err = rbac.RoleDeniesPermission(contractor, export)
+	if err != nil || !ok {
+		t.Errorf("[case 2] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	// case 3: deny does not affect other permissions
+	ok, err = rbac.UserHasObjectAction(u, NewObject("billing"), NewAction("view"))
+	if err != nil || !ok {
+		t.Errorf("[case 3] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	// case 4: deny and allow on the same role
+	rbac.AssignPermissionToRole(contractor, export)
+
+	ok, err = rbac.RoleHasPermission(contractor, export)
+	if err != nil || ok {
+		t.Errorf("[case 4] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+
+	// case 5: wildcard deny is inherited by child roles
+	rbac.RemoveDenyFromRole(contractor, export)
+	rbac.DenyPermissionToRole(employee, allBilling)
+
+	ok, err = rbac.UserHasPermission(u, export)
+	if err != nil || ok {
+		t.Errorf("[case 5] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+
+	// case 6: removing role removes its denies
+	rbac.RemoveRole(employee)
+
+	if _, ok := memory(rbac).denies2roles[employee]; ok {
+		t.Errorf("[case 6] denies of removed role were not removed")
+	}
+
+	ok, err = rbac.UserHasPermission(u, export)
+	if err != nil || !ok {
+		t.Errorf("[case 6] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+}
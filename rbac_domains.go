@@ -0,0 +1,68 @@
+package rbac
+
+// AssignRoleToUserInDomain assigns Role to User in Domain with no time bounds.
+// Role assigned in one Domain has no effect in others.
+// Both User and Role has to be registered.
+// Returns *SSDViolationError if assignment violates separation of duty constraint.
+// Returns false if Role already assigned to User in Domain with no time bounds.
+func (rbac *RBAC) AssignRoleToUserInDomain(u User, r Role, d Domain) (bool, error) {
+	rbac.mutex.Lock()
+	defer rbac.unlock()
+
+	return rbac.assignRoleToUser(u, r, d, Validity{})
+}
+
+// RemoveRoleFromUserInDomain removes Role from User in Domain.
+// Both User and Role has to be registered.
+// Returns false if Role was not assigned to User in Domain.
+func (rbac *RBAC) RemoveRoleFromUserInDomain(u User, r Role, d Domain) (bool, error) {
+	rbac.mutex.Lock()
+	defer rbac.unlock()
+
+	return rbac.removeRoleFromUser(u, r, d)
+}
+
+// ListUserRolesInDomain returns all Roles assigned to User in Domain and currently in effect.
+// User has to be registered.
+func (rbac *RBAC) ListUserRolesInDomain(u User, d Domain) ([]Role, error) {
+	rbac.mutex.RLock()
+	defer rbac.mutex.RUnlock()
+
+	return rbac.listUserRoles(u, d)
+}
+
+// ListUserDomains returns all Domains User has any Role assigned in.
+// User has to be registered.
+func (rbac *RBAC) ListUserDomains(u User) ([]Domain, error) {
+	rbac.mutex.RLock()
+	defer rbac.mutex.RUnlock()
+
+	return rbac.listUserDomains(u)
+}
+
+// listUserDomains is ListUserDomains without locking.
+// Caller has to hold the mutex.
+func (rbac *RBAC) listUserDomains(u User) ([]Domain, error) {
+	if err := rbac.checkUser(u); err != nil {
+		return nil, err
+	}
+	return rbac.store.UserDomains(u)
+}
+
+// UserHasRoleInDomain checks if Role is assigned to User in Domain and currently in effect.
+// Both User and Role has to be registered.
+func (rbac *RBAC) UserHasRoleInDomain(u User, r Role, d Domain) (bool, error) {
+	return rbac.hasRole(u, r, d)
+}
+
+// UserHasPermissionInDomain checks if Roles assigned to User in Domain allow Permission.
+// Evaluation follows UserHasPermission rules.
+func (rbac *RBAC) UserHasPermissionInDomain(u User, p Permission, d Domain) (bool, error) {
+	return rbac.hasPermission(u, p, d)
+}
+
+// UserHasObjectActionInDomain checks if Roles assigned to User in Domain allow provided Object and Action.
+// Evaluation follows UserHasObjectAction rules.
+func (rbac *RBAC) UserHasObjectActionInDomain(u User, o Object, a Action, d Domain) (bool, error) {
+	return rbac.hasPermission(u, NewPermission(o, a), d)
+}
@@ -0,0 +1,173 @@
+package rbac
+
+import "testing"
+
+func TestAssignRoleToUserInDomain(t *testing.T) {
+	rbac := NewRBAC()
+
+	u := NewUser(defaultUserID)
+	r := NewRole(defaultRoleID)
+	d := NewDomain(defaultDomainID)
+
+	// case 1: user is not registered
+	_, err := rbac.AssignRoleToUserInDomain(u, r, d)
+	if err != ErrorUserNotRegistered {
+		t.Errorf("[case 1] assign error: expected err equal %v, got %v", ErrorUserNotRegistered, err)
+	}
+
+	// case 2: user is registered, role is not registered
+	rbac.RegisterUser(u)
+
+	_, err = rbac.AssignRoleToUserInDomain(u, r, d)
+	if err != ErrorRoleNotRegistered {
+		t.Errorf("[case 2] assign error: expected err equal %v, got %v", ErrorRoleNotRegistered, err)
+	}
+
+	// case 3: role is assigned in domain only
+	rbac.RegisterRole(r)
+
+	ok, err := rbac.AssignRoleToUserInDomain(u, r, d)
+	if err != nil || !ok {
+		t.Errorf("[case 3] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	if _, ok := memory(rbac).roles2users[u][d][r]; !ok {
+		t.Errorf("[case 3] assign error: role were not assigned to user in domain")
+	}
+
+	ok, err = rbac.UserHasRole(u, r)
+	if err != nil || ok {
+		t.Errorf("[case 3] invalid output: expected %t in default domain, got %t (err %v)", false, ok, err)
+	}
+
+	// case 4: role is already assigned in domain
+	ok, err = rbac.AssignRoleToUserInDomain(u, r, d)
+	if err != nil || ok {
+		t.Errorf("[case 4] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+}
+
+func TestRemoveRoleFromUserInDomain(t *testing.T) {
+	rbac := NewRBAC()
+
+	u := NewUser(defaultUserID)
+	r := NewRole(defaultRoleID)
+	d := NewDomain(defaultDomainID)
+
+	rbac.RegisterUser(u)
+	rbac.RegisterRole(r)
+	rbac.AssignRoleToUser(u, r)
+	rbac.AssignRoleToUserInDomain(u, r, d)
+
+	// case 1: role is removed from domain only
+	ok, err := rbac.RemoveRoleFromUserInDomain(u, r, d)
+	if err != nil || !ok {
+		t.Errorf("[case 1] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	ok, err = rbac.UserHasRole(u, r)
+	if err != nil || !ok {
+		t.Errorf("[case 1] invalid output: expected %t in default domain, got %t (err %v)", true, ok, err)
+	}
+
+	// case 2: role is not assigned in domain
+	ok, err = rbac.RemoveRoleFromUserInDomain(u, r, d)
+	if err != nil || ok {
+		t.Errorf("[case 2] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+}
+
+func TestListUserDomains(t *testing.T) {
+	rbac := NewRBAC()
+
+	u := NewUser(defaultUserID)
+	r := NewRole(defaultRoleID)
+	d := NewDomain(defaultDomainID)
+
+	// case 1: user is not registered
+	_, err := rbac.ListUserDomains(u)
+	if err != ErrorUserNotRegistered {
+		t.Errorf("[case 1] list error: expected err equal %v, got %v", ErrorUserNotRegistered, err)
+	}
+
+	// case 2: user has no roles
+	rbac.RegisterUser(u)
+	rbac.RegisterRole(r)
+
+	domains, err := rbac.ListUserDomains(u)
+	if err != nil || len(domains) != 0 {
+		t.Errorf("[case 2] invalid output: expected [], got %v (err %v)", domains, err)
+	}
+
+	// case 3: user has role in domain
+	rbac.AssignRoleToUserInDomain(u, r, d)
+
+	domains, err = rbac.ListUserDomains(u)
+	if err != nil || len(domains) != 1 || domains[0] != d {
+		t.Errorf("[case 3] invalid output: expected [%v], got %v (err %v)", d, domains, err)
+	}
+
+	roles, err := rbac.ListUserRolesInDomain(u, d)
+	if err != nil || !roleExistsIn(r, roles) {
+		t.Errorf("[case 3] invalid output: expected %v in %v (err %v)", r, roles, err)
+	}
+
+	roles, err = rbac.ListUserRoles(u)
+	if err != nil || len(roles) != 0 {
+		t.Errorf("[case 3] invalid output: expected [] in default domain, got %v (err %v)", roles, err)
+	}
+}
+
+func TestUserHasPermissionInDomain(t *testing.T) {
+	rbac := NewRBAC()
+
+	u := NewUser(defaultUserID)
+	admin := NewRole("admin")
+	viewer := NewRole("viewer")
+	orgA := NewDomain("org-a")
+	orgB := NewDomain("org-b")
+	read := NewPermission(NewObject("project"), NewAction("read"))
+	edit := NewPermission(NewObject("project"), NewAction("edit"))
+
+	rbac.RegisterUser(u)
+	rbac.RegisterRole(admin)
+	rbac.RegisterRole(viewer)
+	rbac.RegisterPermission(read)
+	rbac.RegisterPermission(edit)
+	rbac.AssignPermissionToRole(viewer, read)
+	rbac.AssignPermissionToRole(admin, edit)
+	rbac.AddRoleParent(admin, viewer)
+
+	// admin in org A, viewer in org B
+	rbac.AssignRoleToUserInDomain(u, admin, orgA)
+	rbac.AssignRoleToUserInDomain(u, viewer, orgB)
+
+	// case 1: admin can edit in org A
+	ok, err := rbac.UserHasPermissionInDomain(u, edit, orgA)
+	if err != nil || !ok {
+		t.Errorf("[case 1] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	// case 2: viewer can not edit in org B
+	ok, err = rbac.UserHasPermissionInDomain(u, edit, orgB)
+	if err != nil || ok {
+		t.Errorf("[case 2] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+
+	// case 3: both can read
+	ok, err = rbac.UserHasObjectActionInDomain(u, read.Object(), read.Action(), orgB)
+	if err != nil || !ok {
+		t.Errorf("[case 3] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	// case 4: no roles in default domain
+	ok, err = rbac.UserHasPermission(u, read)
+	if err != nil || ok {
+		t.Errorf("[case 4] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+
+	ok, err = rbac.UserHasRoleInDomain(u, admin, orgB)
+	if err != nil || ok {
+		t.Errorf("[case 4] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+}
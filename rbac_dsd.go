@@ -0,0 +1,64 @@
+package rbac
+
+// AddDSDConstraint adds dynamic separation of duty constraint: no Session may have n or more of roles
+// active at the same time. Roles inherited by active Roles are counted.
+// Constraint applies to activations made after it is added, already created Sessions are not revalidated.
+// All Roles has to be registered, n has to be at least 2 and not greater than number of distinct roles.
+// Returns false if constraint with the same name already exists.
+func (rbac *RBAC) AddDSDConstraint(name string, roles []Role, n int) (bool, error) {
+	rbac.mutex.Lock()
+	defer rbac.unlock()
+
+	return rbac.addDSDConstraint(NewDSDConstraint(name, roles, n))
+}
+
+// RemoveDSDConstraint removes dynamic separation of duty constraint.
+// Returns false if there is no constraint with such name.
+func (rbac *RBAC) RemoveDSDConstraint(name string) (bool, error) {
+	rbac.mutex.Lock()
+	defer rbac.unlock()
+
+	return rbac.store.RemoveDSDConstraint(name)
+}
+
+// ListDSDConstraints returns all dynamic separation of duty constraints
+func (rbac *RBAC) ListDSDConstraints() ([]DSDConstraint, error) {
+	rbac.mutex.RLock()
+	defer rbac.mutex.RUnlock()
+
+	return rbac.store.DSDConstraints()
+}
+
+// addDSDConstraint validates and stores constraint.
+// Caller has to hold the mutex.
+func (rbac *RBAC) addDSDConstraint(c DSDConstraint) (bool, error) {
+	if !SSDConstraint(c).valid() {
+		return false, ErrorInvalidSSDConstraint
+	}
+	for _, r := range c.Roles {
+		if err := rbac.checkRole(r); err != nil {
+			return false, err
+		}
+	}
+	return rbac.store.AddDSDConstraint(c)
+}
+
+// checkDSD returns *DSDViolationError if Roles active together violate any of dynamic constraints.
+// Caller has to hold the mutex.
+func (rbac *RBAC) checkDSD(u User, active []Role) error {
+	constraints, err := rbac.store.DSDConstraints()
+	if err != nil || len(constraints) == 0 {
+		return err
+	}
+
+	roles, err := rbac.rolesWithAncestors(active)
+	if err != nil {
+		return err
+	}
+	for _, c := range constraints {
+		if matched := SSDConstraint(c).held(roles); len(matched) >= c.N {
+			return &DSDViolationError{Constraint: c.Name, User: u, Roles: matched}
+		}
+	}
+	return nil
+}
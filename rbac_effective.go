@@ -0,0 +1,492 @@
+package rbac
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// VerifyEffectivePermissions compares effective permission index with one built from scratch out of Store
+// and returns error describing the first mismatch.
+// It is meant for tests: index is updated by controller mutations, a mismatch means some mutation missed it.
+func (rbac *RBAC) VerifyEffectivePermissions() error {
+	rbac.mutex.RLock()
+	defer rbac.mutex.RUnlock()
+
+	if !rbac.effective.ready() {
+		// checks evaluate Store directly, there is nothing to be stale
+		return nil
+	}
+	fresh, err := rbac.buildView()
+	if err != nil {
+		return err
+	}
+	return rbac.effective.load().compare(fresh)
+}
+
+// ResetEffectivePermissions rebuilds effective permission index from Store.
+// Index follows changes made through controller, MemoryStore changed directly requires reset to be seen by checks.
+// Controllers backed by other Stores keep no index.
+func (rbac *RBAC) ResetEffectivePermissions() {
+	rbac.mutex.Lock()
+	defer rbac.unlock()
+
+	rbac.actions.invalidate()
+	rbac.effective.rebuild = rbac.effective.enabled
+}
+
+// unlock publishes effective permission index brought up to date with changes made under write lock
+// and releases the mutex. Public methods taking write lock release it this way, so checks never observe stale index.
+func (rbac *RBAC) unlock() {
+	rbac.refreshEffective()
+	rbac.mutex.Unlock()
+}
+
+// refreshEffective publishes new version of effective permission index with entries marked by changes rebuilt.
+// Index failed to refresh is withdrawn and rebuilt from scratch by the next refresh, checks evaluate Store meanwhile.
+// Caller has to hold the mutex.
+func (rbac *RBAC) refreshEffective() {
+	x := rbac.effective
+	if !x.enabled || !x.pending() {
+		return
+	}
+	var err error
+	if x.rebuild || x.load() == nil {
+		err = x.build(rbac)
+	} else {
+		err = x.refresh(rbac)
+	}
+	if err == nil {
+		x.publish(rbac)
+	} else {
+		x.current.Store(nil)
+	}
+	x.users2refresh = make(map[User]struct{})
+	x.roles2refresh = make(map[Role]struct{})
+	x.perms2refresh = make(map[Permission]struct{})
+	x.actionsChanged = false
+	x.settingsChanged = false
+	x.rebuild = err != nil
+}
+
+// buildView builds view of effective permission index from scratch out of Store.
+// Caller has to hold the mutex.
+func (rbac *RBAC) buildView() (*view, error) {
+	x := newEffectiveIndex()
+	if err := x.build(rbac); err != nil {
+		return nil, err
+	}
+	x.publish(rbac)
+	return x.load(), nil
+}
+
+// effectiveIndex materializes effective Permissions of Users, so check of User Permission costs
+// a few map lookups regardless of number of Roles User has and their hierarchy.
+// Every registered Role has set of Permissions it grants and denies along with its ancestors. Users holding
+// the same unbounded Roles share one set, union of them; sets no User holds any more are dropped.
+// Registered Permissions, wildcards and Action implications are kept to match checked Permission
+// the way Store evaluation does.
+//
+// Index is published as immutable view, checks load it without locking. Writers work under write lock
+// of controller: Store changes mark affected Users, Roles and Permissions (see effectiveStore), once changes
+// are done marked entries are rebuilt out of Store into next version of view, sharing everything else
+// with the previous one, and the new version is published at once.
+// Index is enabled for in-memory Stores only, content of persistent Store may be changed by other processes.
+type effectiveIndex struct {
+	enabled bool
+	current atomic.Pointer[view]
+
+	// next version of view, set only while it is built
+	users       *viewMapWriter[User, *effectiveUser]
+	roles       *viewMapWriter[Role, *grantSet]
+	permissions *viewMapWriter[Permission, bool]
+	wildcards   []Permission
+	actions     *actionGraph
+	sets        *viewMapWriter[int, *grantSet]
+
+	// sets are interned by Roles they unite, see intern
+	setIDs   map[string]int
+	setRoles map[int][]Role
+	setRefs  map[int]int
+	lastSet  int
+	// roleSets is reverse index of setRoles, so sets uniting changed Role are found without scanning all of them
+	roleSets map[Role]map[int]struct{}
+
+	// changes not published yet, see refreshEffective
+	rebuild         bool
+	users2refresh   map[User]struct{}
+	roles2refresh   map[Role]struct{}
+	perms2refresh   map[Permission]struct{}
+	actionsChanged  bool
+	settingsChanged bool
+}
+
+func newEffectiveIndex() *effectiveIndex {
+	return &effectiveIndex{
+		users2refresh: make(map[User]struct{}),
+		roles2refresh: make(map[Role]struct{}),
+		perms2refresh: make(map[Permission]struct{}),
+	}
+}
+
+// load returns published view, nil if controller keeps no index or index failed to refresh
+func (x *effectiveIndex) load() *view {
+	return x.current.Load()
+}
+
+func (x *effectiveIndex) touchUser(u User) {
+	x.users2refresh[u] = struct{}{}
+}
+
+func (x *effectiveIndex) touchRole(r Role) {
+	x.roles2refresh[r] = struct{}{}
+}
+
+func (x *effectiveIndex) touchPermission(p Permission) {
+	x.perms2refresh[p] = struct{}{}
+}
+
+func (x *effectiveIndex) touchActions() {
+	x.actionsChanged = true
+}
+
+// touchSettings marks change of clock or Object separator, which view carries
+func (x *effectiveIndex) touchSettings() {
+	x.settingsChanged = true
+}
+
+// pending checks if there are changes not published yet
+func (x *effectiveIndex) pending() bool {
+	return x.rebuild || x.actionsChanged || x.settingsChanged ||
+		len(x.users2refresh) > 0 || len(x.roles2refresh) > 0 || len(x.perms2refresh) > 0
+}
+
+// ready checks if published view is up to date with Store, so checks holding the mutex can be answered by it
+func (x *effectiveIndex) ready() bool {
+	return x.enabled && !x.pending() && x.load() != nil
+}
+
+// begin starts next version of view out of v
+func (x *effectiveIndex) begin(v *view) {
+	x.users = v.users.writer()
+	x.roles = v.roles.writer()
+	x.permissions = v.permissions.writer()
+	x.wildcards = v.wildcards
+	x.actions = v.actions
+	x.sets = v.sets.writer()
+}
+
+// publish makes next version of view current one.
+// Caller has to hold the mutex.
+func (x *effectiveIndex) publish(rbac *RBAC) {
+	x.current.Store(&view{
+		now:         rbac.now,
+		separator:   rbac.separator,
+		users:       x.users.viewMap,
+		roles:       x.roles.viewMap,
+		permissions: x.permissions.viewMap,
+		wildcards:   x.wildcards,
+		actions:     x.actions,
+		sets:        x.sets.viewMap,
+	})
+	x.users, x.roles, x.permissions, x.sets = nil, nil, nil, nil
+}
+
+// build fills next version of view from scratch out of Store.
+// Caller has to hold the mutex.
+func (x *effectiveIndex) build(rbac *RBAC) error {
+	x.begin(newView())
+	x.setIDs = make(map[string]int)
+	x.setRoles = make(map[int][]Role)
+	x.setRefs = make(map[int]int)
+	x.roleSets = make(map[Role]map[int]struct{})
+
+	g, err := rbac.actions.get(rbac.store)
+	if err != nil {
+		return err
+	}
+	x.actions = g
+
+	perms, err := rbac.store.Permissions()
+	if err != nil {
+		return err
+	}
+	for _, p := range perms {
+		x.permissions.set(p, false)
+	}
+	exact, err := rbac.store.ExactPermissions()
+	if err != nil {
+		return err
+	}
+	for _, p := range exact {
+		x.permissions.set(p, true)
+	}
+	if x.wildcards, err = rbac.store.WildcardPermissions(); err != nil {
+		return err
+	}
+
+	roles, err := rbac.store.Roles()
+	if err != nil {
+		return err
+	}
+	for _, r := range roles {
+		s, err := rbac.roleGrantSet(r)
+		if err != nil {
+			return err
+		}
+		x.roles.set(r, s)
+	}
+
+	users, err := rbac.store.Users()
+	if err != nil {
+		return err
+	}
+	for _, u := range users {
+		if err := x.refreshUser(rbac, u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refresh builds next version of view out of current one, rebuilding entries marked by changes of Store.
+// Caller has to hold the mutex.
+func (x *effectiveIndex) refresh(rbac *RBAC) error {
+	x.begin(x.load())
+
+	if x.actionsChanged {
+		rbac.actions.invalidate()
+		g, err := rbac.actions.get(rbac.store)
+		if err != nil {
+			return err
+		}
+		x.actions = g
+	}
+
+	wildcards := false
+	for p := range x.perms2refresh {
+		ok, err := rbac.store.HasPermission(p)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			x.permissions.delete(p)
+		} else {
+			exact, err := rbac.store.HasExactPermission(p)
+			if err != nil {
+				return err
+			}
+			x.permissions.set(p, exact)
+		}
+		wildcards = wildcards || p.Wildcard()
+	}
+	if wildcards {
+		var err error
+		if x.wildcards, err = rbac.store.WildcardPermissions(); err != nil {
+			return err
+		}
+	}
+
+	// Permissions of Role are inherited by all its descendants
+	touched := make([]Role, 0, len(x.roles2refresh))
+	for r := range x.roles2refresh {
+		touched = append(touched, r)
+	}
+	roles, err := rbac.rolesWithDescendants(touched)
+	if err != nil {
+		return err
+	}
+	for r := range roles {
+		ok, err := rbac.store.HasRole(r)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			x.roles.delete(r)
+			continue
+		}
+		s, err := rbac.roleGrantSet(r)
+		if err != nil {
+			return err
+		}
+		x.roles.set(r, s)
+	}
+	sets := make(map[int]struct{})
+	for r := range roles {
+		for id := range x.roleSets[r] {
+			sets[id] = struct{}{}
+		}
+	}
+	for id := range sets {
+		x.sets.set(id, x.union(x.setRoles[id]))
+	}
+
+	for u := range x.users2refresh {
+		if err := x.refreshUser(rbac, u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refreshUser rebuilds entry of User out of its Role assignments.
+// Caller has to hold the mutex.
+func (x *effectiveIndex) refreshUser(rbac *RBAC, u User) error {
+	old, _ := x.users.get(u)
+	ok, err := rbac.store.HasUser(u)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		x.users.delete(u)
+		x.release(old)
+		return nil
+	}
+
+	domains, err := rbac.store.UserDomains(u)
+	if err != nil {
+		return err
+	}
+	e := &effectiveUser{domains: make(map[Domain]*effectiveDomain, len(domains))}
+	for _, d := range domains {
+		roles, err := rbac.store.UserRoles(u, d)
+		if err != nil {
+			return err
+		}
+		ed := &effectiveDomain{roles: make(map[Role]Validity, len(roles))}
+		var unbounded []Role
+		for _, r := range roles {
+			v, err := rbac.store.UserRoleValidity(u, r, d)
+			if err != nil {
+				return err
+			}
+			ed.roles[r] = v
+			if v.IsZero() {
+				unbounded = append(unbounded, r)
+			} else {
+				ed.bounded = append(ed.bounded, r)
+			}
+		}
+		if len(unbounded) > 0 {
+			ed.set = x.intern(unbounded)
+		}
+		e.domains[d] = ed
+	}
+	// sets of previous entry are released after new ones are taken, so sets both share are kept
+	x.users.set(u, e)
+	x.release(old)
+	return nil
+}
+
+// roleGrantSet collects Permissions granted and denied to Role and its ancestors.
+// Caller has to hold the mutex.
+func (rbac *RBAC) roleGrantSet(r Role) (*grantSet, error) {
+	roles, err := rbac.roleAncestors(r)
+	if err != nil {
+		return nil, err
+	}
+	s := &grantSet{grants: make(map[Permission]struct{}), denies: make(map[Permission]struct{})}
+	for a := range roles {
+		grants, err := rbac.store.RolePermissions(a)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range grants {
+			s.grants[p] = struct{}{}
+		}
+		denies, err := rbac.store.RoleDenies(a)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range denies {
+			s.denies[p] = struct{}{}
+		}
+	}
+	return s, nil
+}
+
+// intern returns ID of set of Roles, building the set if no User holds the same Roles yet
+func (x *effectiveIndex) intern(roles []Role) int {
+	sort.Slice(roles, func(i, j int) bool {
+		return roles[i].id < roles[j].id
+	})
+	key := setKey(roles)
+	id, ok := x.setIDs[key]
+	if !ok {
+		x.lastSet++
+		id = x.lastSet
+		x.setIDs[key] = id
+		x.setRoles[id] = roles
+		for _, r := range roles {
+			if x.roleSets[r] == nil {
+				x.roleSets[r] = make(map[int]struct{})
+			}
+			x.roleSets[r][id] = struct{}{}
+		}
+		x.sets.set(id, x.union(roles))
+	}
+	x.setRefs[id]++
+	return id
+}
+
+// release drops references of User entry to sets, sets nobody refers to are removed
+func (x *effectiveIndex) release(e *effectiveUser) {
+	if e == nil {
+		return
+	}
+	for _, ed := range e.domains {
+		id := ed.set
+		if id == 0 {
+			continue
+		}
+		x.setRefs[id]--
+		if x.setRefs[id] > 0 {
+			continue
+		}
+		for _, r := range x.setRoles[id] {
+			delete(x.roleSets[r], id)
+			if len(x.roleSets[r]) == 0 {
+				delete(x.roleSets, r)
+			}
+		}
+		delete(x.setIDs, setKey(x.setRoles[id]))
+		delete(x.setRoles, id)
+		delete(x.setRefs, id)
+		x.sets.delete(id)
+	}
+}
+
+// union merges sets of Roles, set of single Role is shared
+func (x *effectiveIndex) union(roles []Role) *grantSet {
+	if len(roles) == 1 {
+		if s, ok := x.roles.get(roles[0]); ok {
+			return s
+		}
+	}
+	s := &grantSet{grants: make(map[Permission]struct{}), denies: make(map[Permission]struct{})}
+	for _, r := range roles {
+		rs, ok := x.roles.get(r)
+		if !ok {
+			continue
+		}
+		for p := range rs.grants {
+			s.grants[p] = struct{}{}
+		}
+		for p := range rs.denies {
+			s.denies[p] = struct{}{}
+		}
+	}
+	return s
+}
+
+// setKey identifies sorted list of Roles, IDs are prefixed by length so no separator can be confused with ID
+func setKey(roles []Role) string {
+	var b strings.Builder
+	for _, r := range roles {
+		b.WriteString(strconv.Itoa(len(r.id)))
+		b.WriteByte(':')
+		b.WriteString(r.id)
+	}
+	return b.String()
+}
@@ -0,0 +1,297 @@
+package rbac
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestEffectivePermissions(t *testing.T) {
+	rbac := newTestRBAC()
+
+	alice, bob, carol := NewUser("alice"), NewUser("bob"), NewUser("carol")
+	reader, auditor, editor := NewRole("reader"), NewRole("auditor"), NewRole("editor")
+	read := NewPermission(NewObject("invoice"), NewAction("read"))
+	write := NewPermission(NewObject("invoice"), NewAction("write"))
+	approve := NewPermission(NewObject("invoice"), NewAction("approve"))
+	rbac.RegisterPermission(approve)
+
+	cases := []struct {
+		mutate   func()
+		user     User
+		perm     Permission
+		expected bool
+	}{
+		// case 0: index answers as Store evaluation does
+		{func() {}, bob, read, true},
+		// case 1: assigning Role to User
+		{func() { rbac.AssignRoleToUser(bob, editor) }, bob, write, true},
+		// case 2: removing Role from User
+		{func() { rbac.RemoveRoleFromUser(bob, editor) }, bob, write, false},
+		// case 3: assigning Permission to inherited Role
+		{func() { rbac.AssignPermissionToRole(reader, approve) }, bob, approve, true},
+		// case 4: removing Permission from inherited Role
+		{func() { rbac.RemovePermissionFromRole(reader, approve) }, bob, approve, false},
+		// case 5: denying Permission to Role
+		{func() { rbac.DenyPermissionToRole(auditor, read) }, bob, read, false},
+		// case 6: removing deny from Role
+		{func() { rbac.RemoveDenyFromRole(auditor, read) }, bob, read, true},
+		// case 7: removing Role parent
+		{func() { rbac.RemoveRoleParent(auditor, reader) }, bob, read, false},
+		// case 8: adding Role parent
+		{func() { rbac.AddRoleParent(auditor, editor) }, bob, write, true},
+		// case 9: declaring Action implication
+		{func() { rbac.DeclareActionImplies(NewAction("write"), NewAction("approve")) }, alice, approve, true},
+		// case 10: removing Action implication
+		{func() { rbac.RemoveActionImplies(NewAction("write"), NewAction("approve")) }, alice, approve, false},
+		// case 11: removing Role
+		{func() { rbac.RemoveRole(editor) }, alice, write, false},
+		// case 12: assigning Role to User without Roles
+		{func() { rbac.AssignRoleToUser(carol, reader) }, carol, read, true},
+	}
+
+	for i, c := range cases {
+		// check before mutation, so stale index would answer it the same way
+		rbac.UserHasPermission(c.user, c.perm)
+		c.mutate()
+
+		ok, err := rbac.UserHasPermission(c.user, c.perm)
+		if err != nil || ok != c.expected {
+			t.Errorf("[case %d] invalid output: expected %t, got %t (err %v)", i, c.expected, ok, err)
+		}
+		if err := rbac.VerifyEffectivePermissions(); err != nil {
+			t.Errorf("[case %d] verify error: expected err equal nil, got %v", i, err)
+		}
+	}
+
+	// case 13: removing User
+	rbac.RemoveUser(carol)
+	_, err := rbac.UserHasPermission(carol, read)
+	if err != ErrorUserNotRegistered {
+		t.Errorf("[case 13] check error: expected err equal %v, got %v", ErrorUserNotRegistered, err)
+	}
+
+	// case 14: removing Permission
+	rbac.RemovePermission(read)
+	_, err = rbac.UserHasPermission(bob, read)
+	if err != ErrorPermissionNotRegistered {
+		t.Errorf("[case 14] check error: expected err equal %v, got %v", ErrorPermissionNotRegistered, err)
+	}
+}
+
+func TestEffectivePermissionsRefresh(t *testing.T) {
+	rbac := newTestRBAC()
+
+	alice, bob, carol := NewUser("alice"), NewUser("bob"), NewUser("carol")
+	reader, auditor, editor := NewRole("reader"), NewRole("auditor"), NewRole("editor")
+	write := NewPermission(NewObject("invoice"), NewAction("write"))
+
+	roleSet := func(r Role) *grantSet {
+		s, _ := rbac.effective.load().roles.get(r)
+		return s
+	}
+	userEntry := func(u User) *effectiveUser {
+		e, _ := rbac.effective.load().users.get(u)
+		return e
+	}
+
+	// case 1: change of Role rebuilds sets of Roles inheriting it only
+	before := map[Role]*grantSet{reader: roleSet(reader), auditor: roleSet(auditor), editor: roleSet(editor)}
+	rbac.AssignPermissionToRole(reader, write)
+	if roleSet(reader) == before[reader] || roleSet(auditor) == before[auditor] || roleSet(editor) != before[editor] {
+		t.Errorf("[case 1] invalid output: expected reader and auditor rebuilt, editor kept")
+	}
+
+	// case 2: assignment rebuilds entry of its User only
+	entries := map[User]*effectiveUser{alice: userEntry(alice), bob: userEntry(bob)}
+	rbac.AssignRoleToUserInDomain(bob, editor, NewDomain("acme"))
+	if userEntry(alice) != entries[alice] || userEntry(bob) == entries[bob] {
+		t.Errorf("[case 2] invalid output: expected bob rebuilt, alice kept")
+	}
+
+	// case 3: Users holding the same Roles share one set
+	rbac.AssignRoleToUser(carol, editor)
+	a, c := userEntry(alice).domains[DefaultDomain].set, userEntry(carol).domains[DefaultDomain].set
+	if a == 0 || a != c {
+		t.Errorf("[case 3] invalid output: expected shared set, got %d and %d", a, c)
+	}
+
+	// case 4: set nobody holds any more is dropped
+	rbac.AssignRoleToUser(carol, auditor)
+	sets := rbac.effective.load().sets.count()
+	rbac.RemoveRoleFromUser(carol, auditor)
+	if got := rbac.effective.load().sets.count(); got != sets-1 {
+		t.Errorf("[case 4] invalid output: expected %d sets, got %d", sets-1, got)
+	}
+
+	// case 5: published version is never modified, change publishes a new one
+	published := rbac.effective.load()
+	rbac.RemoveRoleFromUser(alice, editor)
+	if e, _ := published.users.get(alice); e != entries[alice] || rbac.effective.load() == published {
+		t.Errorf("[case 5] invalid output: expected published version kept and replaced")
+	}
+
+	// case 6: change of Role rebuilds sets uniting it only
+	rbac.AssignRoleToUser(carol, reader)
+	united := userEntry(carol).domains[DefaultDomain].set
+	kept, _ := rbac.effective.load().sets.get(united)
+	rbac.DenyPermissionToRole(auditor, write)
+	if got, _ := rbac.effective.load().sets.get(united); got != kept {
+		t.Errorf("[case 6] invalid output: expected set of editor and reader kept")
+	}
+	if _, ok := rbac.effective.roleSets[reader][united]; !ok {
+		t.Errorf("[case 6] invalid output: expected set %d indexed by %v", united, reader)
+	}
+
+	if err := rbac.VerifyEffectivePermissions(); err != nil {
+		t.Errorf("verify error: expected err equal nil, got %v", err)
+	}
+}
+
+func TestEffectivePermissionsStore(t *testing.T) {
+	s := &txMemoryStore{MemoryStore: NewMemoryStore()}
+	rbac := NewRBACWithStore(s)
+
+	u := NewUser("alice")
+	r := NewRole("reader")
+	p := NewPermission(NewObject("invoice"), NewAction("read"))
+	rbac.RegisterUser(u)
+	rbac.RegisterRole(r)
+	rbac.RegisterPermission(p)
+	rbac.AssignPermissionToRole(r, p)
+	rbac.AssignRoleToUser(u, r)
+
+	// case 1: no index is kept for Store which may be shared
+	if rbac.effective.load() != nil {
+		t.Errorf("[case 1] invalid output: expected index disabled for %T", s)
+	}
+
+	// case 2: change made behind controller is seen by the next check
+	s.RemoveUserRole(u, r, DefaultDomain)
+	ok, err := rbac.UserHasPermission(u, p)
+	if err != nil || ok {
+		t.Errorf("[case 2] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+}
+
+func TestEffectivePermissionsExpiry(t *testing.T) {
+	rbac := NewRBAC()
+	clock := &testClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	rbac.SetClock(clock.Now)
+
+	u := NewUser(defaultUserID)
+	r := NewRole(defaultRoleID)
+	p := NewPermission(NewObject(defaultObjectID), NewAction(defaultActionID))
+
+	rbac.RegisterUser(u)
+	rbac.RegisterRole(r)
+	rbac.RegisterPermission(p)
+	rbac.AssignPermissionToRole(r, p)
+
+	start := clock.now.Add(time.Hour)
+	end := clock.now.Add(2 * time.Hour)
+	rbac.AssignRoleToUserWithValidity(u, r, Between(start, end))
+
+	// case 1: bounded assignment is in effect from its start to its end
+	steps := []struct {
+		at       time.Time
+		expected bool
+	}{
+		{clock.now, false},
+		{start.Add(-time.Nanosecond), false},
+		{start, true},
+		{end, true},
+		{end.Add(time.Nanosecond), false},
+	}
+	for _, s := range steps {
+		clock.now = s.at
+		ok, err := rbac.UserHasPermission(u, p)
+		if err != nil || ok != s.expected {
+			t.Errorf("[case 1] invalid output at %v: expected %t, got %t (err %v)", s.at, s.expected, ok, err)
+		}
+	}
+
+	// case 2: replaced clock is used by following checks
+	rbac.SetClock(func() time.Time { return start })
+	ok, err := rbac.UserHasPermission(u, p)
+	if err != nil || !ok {
+		t.Errorf("[case 2] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+}
+
+func TestResetEffectivePermissions(t *testing.T) {
+	s := NewMemoryStore()
+	rbac := NewRBACWithStore(s)
+
+	u := NewUser("alice")
+	r := NewRole("reader")
+	p := NewPermission(NewObject("invoice"), NewAction("read"))
+	rbac.RegisterUser(u)
+	rbac.RegisterRole(r)
+	rbac.RegisterPermission(p)
+	rbac.AssignPermissionToRole(r, p)
+	rbac.AssignRoleToUser(u, r)
+
+	rbac.UserHasPermission(u, p)
+
+	// case 1: Store changed behind controller is not seen until reset
+	s.RemoveUserRole(u, r, DefaultDomain)
+	ok, err := rbac.UserHasPermission(u, p)
+	if err != nil || !ok {
+		t.Errorf("[case 1] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+	if err := rbac.VerifyEffectivePermissions(); err == nil {
+		t.Errorf("[case 1] verify error: expected stale index error, got nil")
+	}
+
+	// case 2: reset rebuilds index from Store
+	rbac.ResetEffectivePermissions()
+	ok, err = rbac.UserHasPermission(u, p)
+	if err != nil || ok {
+		t.Errorf("[case 2] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+	if err := rbac.VerifyEffectivePermissions(); err != nil {
+		t.Errorf("[case 2] verify error: expected err equal nil, got %v", err)
+	}
+}
+
+func BenchmarkUserHasPermission(b *testing.B) {
+	rbac := benchmarkPolicy(1000)
+	u := NewUser("user-3")
+	p := NewPermission(NewObject("object-3"), NewAction("action-7"))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rbac.UserHasPermission(u, p)
+	}
+}
+
+// BenchmarkUserHasPermissionManyRoles checks User holding 40 Roles, each inheriting another one
+func BenchmarkUserHasPermissionManyRoles(b *testing.B) {
+	rbac := benchmarkPolicy(1000)
+	u := NewUser("admin")
+	rbac.RegisterUser(u)
+	for i := 0; i < 40; i++ {
+		r := NewRole(fmt.Sprintf("admin-%d", i))
+		rbac.RegisterRole(r)
+		rbac.AddRoleParent(r, NewRole(fmt.Sprintf("role-%d", i%10)))
+		rbac.AssignRoleToUser(u, r)
+	}
+	p := NewPermission(NewObject("object-3"), NewAction("action-7"))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rbac.UserHasPermission(u, p)
+	}
+}
+
+func BenchmarkUserHasPermissionUncached(b *testing.B) {
+	rbac := benchmarkPolicy(1000)
+	u := NewUser("user-3")
+	p := NewPermission(NewObject("object-3"), NewAction("action-7"))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rbac.evaluateUserPermission(u, p, DefaultDomain)
+	}
+}
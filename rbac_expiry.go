@@ -0,0 +1,160 @@
+package rbac
+
+import (
+	"sync"
+	"time"
+)
+
+// AssignRoleToUserUntil assigns Role to User in DefaultDomain until provided time.
+// Validity of existing assignment is replaced, so the method also extends or shortens assignment.
+// Both User and Role has to be registered.
+// Returns *SSDViolationError if assignment violates separation of duty constraint.
+// Returns false if Role already assigned to User with the same Validity.
+func (rbac *RBAC) AssignRoleToUserUntil(u User, r Role, notAfter time.Time) (bool, error) {
+	rbac.mutex.Lock()
+	defer rbac.unlock()
+
+	return rbac.assignRoleToUser(u, r, DefaultDomain, Until(notAfter))
+}
+
+// AssignRoleToUserWithValidity assigns Role to User in DefaultDomain bounded by Validity.
+// Validity of existing assignment is replaced.
+// Both User and Role has to be registered, Validity must not end before it starts.
+// Returns *SSDViolationError if assignment violates separation of duty constraint.
+// Returns false if Role already assigned to User with the same Validity.
+func (rbac *RBAC) AssignRoleToUserWithValidity(u User, r Role, v Validity) (bool, error) {
+	rbac.mutex.Lock()
+	defer rbac.unlock()
+
+	return rbac.assignRoleToUser(u, r, DefaultDomain, v)
+}
+
+// AssignRoleToUserInDomainWithValidity assigns Role to User in Domain bounded by Validity.
+// Validity of existing assignment is replaced.
+// Both User and Role has to be registered, Validity must not end before it starts.
+// Returns *SSDViolationError if assignment violates separation of duty constraint.
+// Returns false if Role already assigned to User in Domain with the same Validity.
+func (rbac *RBAC) AssignRoleToUserInDomainWithValidity(u User, r Role, d Domain, v Validity) (bool, error) {
+	rbac.mutex.Lock()
+	defer rbac.unlock()
+
+	return rbac.assignRoleToUser(u, r, d, v)
+}
+
+// UserRoleValidity returns Validity of Role assignment to User in Domain.
+// Both User and Role has to be registered.
+// Returns false if Role is not assigned to User in Domain.
+func (rbac *RBAC) UserRoleValidity(u User, r Role, d Domain) (Validity, bool, error) {
+	rbac.mutex.RLock()
+	defer rbac.mutex.RUnlock()
+
+	return rbac.userRoleValidity(u, r, d)
+}
+
+// userRoleValidity is UserRoleValidity without locking.
+// Caller has to hold the mutex.
+func (rbac *RBAC) userRoleValidity(u User, r Role, d Domain) (Validity, bool, error) {
+	if err := rbac.checkUser(u); err != nil {
+		return Validity{}, false, err
+	}
+	if err := rbac.checkRole(r); err != nil {
+		return Validity{}, false, err
+	}
+	ok, err := rbac.store.HasUserRole(u, r, d)
+	if err != nil || !ok {
+		return Validity{}, false, err
+	}
+	v, err := rbac.store.UserRoleValidity(u, r, d)
+	if err != nil {
+		return Validity{}, false, err
+	}
+	return v, true, nil
+}
+
+// SweepExpiredAssignments removes Role assignments which Validity ended before current time.
+// Expired assignments are already ignored by checks, sweeping only cleans them up.
+// Returns number of removed assignments.
+func (rbac *RBAC) SweepExpiredAssignments() (int, error) {
+	rbac.mutex.Lock()
+	defer rbac.unlock()
+
+	return rbac.sweepExpiredAssignments()
+}
+
+// sweepExpiredAssignments is SweepExpiredAssignments without locking.
+// Caller has to hold the mutex.
+func (rbac *RBAC) sweepExpiredAssignments() (int, error) {
+	users, err := rbac.store.Users()
+	if err != nil {
+		return 0, err
+	}
+
+	now := rbac.now()
+	removed := 0
+	for _, u := range users {
+		domains, err := rbac.store.UserDomains(u)
+		if err != nil {
+			return removed, err
+		}
+		for _, d := range domains {
+			roles, err := rbac.store.UserRoles(u, d)
+			if err != nil {
+				return removed, err
+			}
+			for _, r := range roles {
+				v, err := rbac.store.UserRoleValidity(u, r, d)
+				if err != nil {
+					return removed, err
+				}
+				if !v.ExpiredAt(now) {
+					continue
+				}
+				ok, err := rbac.store.RemoveUserRole(u, r, d)
+				if err != nil {
+					return removed, err
+				}
+				if ok {
+					removed++
+				}
+			}
+		}
+	}
+	return removed, nil
+}
+
+// StartSweeper runs SweepExpiredAssignments every interval in background goroutine.
+// Sweeping errors are passed to onError if it is not nil.
+// Returned function stops the sweeper and waits for running sweep to finish.
+// Returns ErrorInvalidInterval if interval is not positive, no sweeper is started then.
+func (rbac *RBAC) StartSweeper(interval time.Duration, onError func(error)) (stop func(), err error) {
+	if interval <= 0 {
+		return nil, ErrorInvalidInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	finished := make(chan struct{})
+
+	go func() {
+		defer close(finished)
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := rbac.SweepExpiredAssignments(); err != nil && onError != nil {
+					onError(err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ticker.Stop()
+			close(done)
+			<-finished
+		})
+	}, nil
+}
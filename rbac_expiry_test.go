@@ -0,0 +1,214 @@
+package rbac
+
+import (
+	"testing"
+	"time"
+)
+
+// testClock is manually advanced clock for deterministic expiry tests
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func TestAssignRoleToUserUntil(t *testing.T) {
+	rbac := NewRBAC()
+	clock := &testClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	rbac.SetClock(clock.Now)
+
+	u := NewUser(defaultUserID)
+	r := NewRole(defaultRoleID)
+	p := NewPermission(NewObject(defaultObjectID), NewAction(defaultActionID))
+
+	rbac.RegisterUser(u)
+	rbac.RegisterRole(r)
+	rbac.RegisterPermission(p)
+	rbac.AssignPermissionToRole(r, p)
+
+	// case 1: role is assigned until tomorrow
+	ok, err := rbac.AssignRoleToUserUntil(u, r, clock.now.Add(24*time.Hour))
+	if err != nil || !ok {
+		t.Errorf("[case 1] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	ok, err = rbac.UserHasPermission(u, p)
+	if err != nil || !ok {
+		t.Errorf("[case 1] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	// case 2: assignment is extended
+	ok, err = rbac.AssignRoleToUserUntil(u, r, clock.now.Add(48*time.Hour))
+	if err != nil || !ok {
+		t.Errorf("[case 2] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	// case 3: the same bound again
+	ok, err = rbac.AssignRoleToUserUntil(u, r, clock.now.Add(48*time.Hour))
+	if err != nil || ok {
+		t.Errorf("[case 3] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+
+	// case 4: assignment expired
+	clock.now = clock.now.Add(72 * time.Hour)
+
+	ok, err = rbac.UserHasRole(u, r)
+	if err != nil || ok {
+		t.Errorf("[case 4] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+
+	ok, err = rbac.UserHasPermission(u, p)
+	if err != nil || ok {
+		t.Errorf("[case 4] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+
+	roles, err := rbac.ListUserRoles(u)
+	if err != nil || len(roles) != 0 {
+		t.Errorf("[case 4] invalid output: expected [], got %v (err %v)", roles, err)
+	}
+
+	// case 5: plain assignment removes bounds
+	ok, err = rbac.AssignRoleToUser(u, r)
+	if err != nil || !ok {
+		t.Errorf("[case 5] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	ok, err = rbac.UserHasRole(u, r)
+	if err != nil || !ok {
+		t.Errorf("[case 5] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+}
+
+func TestAssignRoleToUserWithValidity(t *testing.T) {
+	rbac := NewRBAC()
+	clock := &testClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	rbac.SetClock(clock.Now)
+
+	u := NewUser(defaultUserID)
+	r := NewRole(defaultRoleID)
+	p := NewPermission(NewObject(defaultObjectID), NewAction(defaultActionID))
+
+	rbac.RegisterUser(u)
+	rbac.RegisterRole(r)
+	rbac.RegisterPermission(p)
+	rbac.AssignPermissionToRole(r, p)
+	d := NewDomain(defaultDomainID)
+
+	// case 1: validity ends before it starts
+	_, err := rbac.AssignRoleToUserWithValidity(u, r, Between(clock.now, clock.now.Add(-time.Hour)))
+	if err != ErrorInvalidValidity {
+		t.Errorf("[case 1] assign error: expected err equal %v, got %v", ErrorInvalidValidity, err)
+	}
+
+	// case 2: assignment is not yet in effect
+	v := Between(clock.now.Add(time.Hour), clock.now.Add(2*time.Hour))
+	ok, err := rbac.AssignRoleToUserInDomainWithValidity(u, r, d, v)
+	if err != nil || !ok {
+		t.Errorf("[case 2] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	ok, err = rbac.UserHasPermissionInDomain(u, p, d)
+	if err != nil || ok {
+		t.Errorf("[case 2] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+
+	// case 3: assignment is in effect
+	clock.now = clock.now.Add(90 * time.Minute)
+
+	ok, err = rbac.UserHasPermissionInDomain(u, p, d)
+	if err != nil || !ok {
+		t.Errorf("[case 3] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	got, ok, err := rbac.UserRoleValidity(u, r, d)
+	if err != nil || !ok || !got.Equal(v) {
+		t.Errorf("[case 3] invalid output: expected %v, got %v (err %v)", v, got, err)
+	}
+
+	// case 4: validity of not assigned role
+	_, ok, err = rbac.UserRoleValidity(u, r, DefaultDomain)
+	if err != nil || ok {
+		t.Errorf("[case 4] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+}
+
+func TestSweepExpiredAssignments(t *testing.T) {
+	rbac := NewRBAC()
+	clock := &testClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	rbac.SetClock(clock.Now)
+
+	u := NewUser(defaultUserID)
+	r := NewRole(defaultRoleID)
+	future := NewRole("future")
+	forever := NewRole("forever")
+
+	rbac.RegisterUser(u)
+	rbac.RegisterRole(r)
+	rbac.RegisterRole(future)
+	rbac.RegisterRole(forever)
+
+	rbac.AssignRoleToUserUntil(u, r, clock.now.Add(time.Hour))
+	rbac.AssignRoleToUserWithValidity(u, future, Between(clock.now.Add(2*time.Hour), time.Time{}))
+	rbac.AssignRoleToUser(u, forever)
+
+	// case 1: nothing expired
+	n, err := rbac.SweepExpiredAssignments()
+	if err != nil || n != 0 {
+		t.Errorf("[case 1] invalid output: expected %d, got %d (err %v)", 0, n, err)
+	}
+
+	// case 2: only expired assignment is removed
+	clock.now = clock.now.Add(90 * time.Minute)
+
+	n, err = rbac.SweepExpiredAssignments()
+	if err != nil || n != 1 {
+		t.Errorf("[case 2] invalid output: expected %d, got %d (err %v)", 1, n, err)
+	}
+
+	if _, ok := memory(rbac).roles2users[u][DefaultDomain][r]; ok {
+		t.Errorf("[case 2] sweep error: expired assignment were not removed")
+	}
+
+	if len(memory(rbac).roles2users[u][DefaultDomain]) != 2 {
+		t.Errorf("[case 2] sweep error: expected 2 assignments left, got %d", len(memory(rbac).roles2users[u][DefaultDomain]))
+	}
+}
+
+func TestStartSweeper(t *testing.T) {
+	rbac := NewRBAC()
+	clock := &testClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	rbac.SetClock(clock.Now)
+
+	u := NewUser(defaultUserID)
+	r := NewRole(defaultRoleID)
+
+	rbac.RegisterUser(u)
+	rbac.RegisterRole(r)
+	rbac.AssignRoleToUserUntil(u, r, clock.now.Add(-time.Hour))
+
+	// case 1: interval is not positive
+	_, err := rbac.StartSweeper(0, nil)
+	if err != ErrorInvalidInterval {
+		t.Errorf("[case 1] start error: expected err equal %v, got %v", ErrorInvalidInterval, err)
+	}
+
+	// case 2: expired assignment is swept
+	stop, err := rbac.StartSweeper(time.Millisecond, func(err error) {
+		t.Errorf("[case 2] sweep error: expected err equal nil, got %v", err)
+	})
+	if err != nil {
+		t.Fatalf("[case 2] start error: expected err equal nil, got %v", err)
+	}
+	defer stop()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if _, assigned, _ := rbac.UserRoleValidity(u, r, DefaultDomain); !assigned {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Errorf("[case 2] sweeper error: expired assignment were not removed")
+}
@@ -0,0 +1,124 @@
+package rbac
+
+import "sort"
+
+// ExplainUserPermission evaluates Permission as UserHasPermission does and explains the outcome:
+// which chain of Roles granted or denied it, or why no decision could be reached.
+// Unregistered User or Permission is reported in Decision Reason rather than as error.
+func (rbac *RBAC) ExplainUserPermission(u User, p Permission) (Decision, error) {
+	rbac.mutex.RLock()
+	defer rbac.mutex.RUnlock()
+
+	return rbac.explainUserPermission(u, p, DefaultDomain)
+}
+
+// ExplainUserPermissionInDomain is ExplainUserPermission evaluating Roles assigned to User in Domain
+func (rbac *RBAC) ExplainUserPermissionInDomain(u User, p Permission, d Domain) (Decision, error) {
+	rbac.mutex.RLock()
+	defer rbac.mutex.RUnlock()
+
+	return rbac.explainUserPermission(u, p, d)
+}
+
+// explainUserPermission walks User Roles breadth-first, so reported Path is the shortest one.
+// Caller has to hold the mutex.
+func (rbac *RBAC) explainUserPermission(u User, p Permission, d Domain) (Decision, error) {
+	dec := Decision{User: u, Domain: d, Permission: p}
+
+	if err := rbac.checkUser(u); err != nil {
+		if err == ErrorUserNotRegistered {
+			dec.Reason = ReasonUserNotRegistered
+			return dec, nil
+		}
+		return dec, err
+	}
+	wildcards, err := rbac.checkPermissionMatch(p)
+	if err != nil {
+		if err == ErrorPermissionNotRegistered {
+			dec.Reason = ReasonPermissionNotRegistered
+			return dec, nil
+		}
+		return dec, err
+	}
+
+	assigned, err := rbac.activeUserRoles(u, d)
+	if err != nil {
+		return dec, err
+	}
+	order, paths, err := rbac.rolePaths(assigned)
+	if err != nil {
+		return dec, err
+	}
+
+	// deny overrides allow, so every Role is checked for denies even after grant is found
+	var grant *Decision
+	for _, r := range order {
+		rule, denied, err := rbac.matchingRule(rbac.store.HasRoleDeny, r, p, wildcards)
+		if err != nil {
+			return dec, err
+		}
+		if denied {
+			dec.Reason, dec.Path, dec.Rule = ReasonDenied, paths[r], rule
+			return dec, nil
+		}
+		if grant != nil {
+			continue
+		}
+		rule, granted, err := rbac.matchingGrant(r, p, wildcards)
+		if err != nil {
+			return dec, err
+		}
+		if granted {
+			grant = &Decision{Allowed: true, Reason: ReasonGranted, User: u, Domain: d, Permission: p, Path: paths[r], Rule: rule}
+		}
+	}
+	if grant != nil {
+		return *grant, nil
+	}
+
+	dec.Reason = ReasonNoRoleMatched
+	return dec, nil
+}
+
+// rolePaths walks Roles and their ancestors breadth-first in Role ID order.
+// Returns visited Roles in walk order and the shortest path from any of provided Roles to each of them.
+// Caller has to hold the mutex.
+func (rbac *RBAC) rolePaths(roles []Role) ([]Role, map[Role][]Role, error) {
+	roles = append([]Role(nil), roles...)
+	sortRoles(roles)
+
+	paths := make(map[Role][]Role, len(roles))
+	order := make([]Role, 0, len(roles))
+	for _, r := range roles {
+		if _, ok := paths[r]; ok {
+			continue
+		}
+		paths[r] = []Role{r}
+		order = append(order, r)
+	}
+
+	for i := 0; i < len(order); i++ {
+		r := order[i]
+		parents, err := rbac.store.RoleParents(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		sortRoles(parents)
+		for _, parent := range parents {
+			if _, ok := paths[parent]; ok {
+				continue
+			}
+			path := make([]Role, len(paths[r]), len(paths[r])+1)
+			copy(path, paths[r])
+			paths[parent] = append(path, parent)
+			order = append(order, parent)
+		}
+	}
+	return order, paths, nil
+}
+
+func sortRoles(roles []Role) {
+	sort.Slice(roles, func(i, j int) bool {
+		return roles[i].id < roles[j].id
+	})
+}
@@ -0,0 +1,89 @@
+package rbac
+
+import "testing"
+
+func TestExplainUserPermission(t *testing.T) {
+	rbac := NewRBAC()
+
+	u := NewUser("alice")
+	viewer := NewRole("viewer")
+	editor := NewRole("editor")
+	contractor := NewRole("contractor")
+	read := NewPermission(NewObject("invoice"), NewAction("read"))
+	all := NewPermission(NewObject("invoice"), NewAction("*"))
+	remove := NewPermission(NewObject("invoice"), NewAction("delete"))
+	export := NewPermission(NewObject("invoice"), NewAction("export"))
+
+	// case 1: user is not registered
+	dec, err := rbac.ExplainUserPermission(u, read)
+	if err != nil || dec.Allowed || dec.Reason != ReasonUserNotRegistered {
+		t.Errorf("[case 1] invalid output: expected %v, got %v (err %v)", ReasonUserNotRegistered, dec.Reason, err)
+	}
+
+	// case 2: permission is not registered
+	rbac.RegisterUser(u)
+
+	dec, err = rbac.ExplainUserPermission(u, read)
+	if err != nil || dec.Allowed || dec.Reason != ReasonPermissionNotRegistered {
+		t.Errorf("[case 2] invalid output: expected %v, got %v (err %v)", ReasonPermissionNotRegistered, dec.Reason, err)
+	}
+
+	// case 3: no role matched
+	rbac.RegisterRole(viewer)
+	rbac.RegisterRole(editor)
+	rbac.RegisterRole(contractor)
+	rbac.RegisterPermission(read)
+	rbac.RegisterPermission(all)
+	rbac.RegisterPermission(remove)
+	rbac.AssignPermissionToRole(viewer, read)
+	rbac.AddRoleParent(editor, viewer)
+	rbac.AssignRoleToUser(u, editor)
+
+	rbac.DenyPermissionToRole(contractor, remove)
+
+	rbac.RegisterPermission(export)
+
+	dec, err = rbac.ExplainUserPermission(u, export)
+	if err != nil || dec.Allowed || dec.Reason != ReasonNoRoleMatched || len(dec.Path) != 0 {
+		t.Errorf("[case 3] invalid output: expected %v, got %v (err %v)", ReasonNoRoleMatched, dec, err)
+	}
+
+	// case 4: granted through hierarchy and wildcard
+	rbac.AssignPermissionToRole(viewer, all)
+
+	dec, err = rbac.ExplainUserPermission(u, export)
+	if err != nil || !dec.Allowed || dec.Reason != ReasonGranted {
+		t.Errorf("[case 4] invalid output: expected %v, got %v (err %v)", ReasonGranted, dec, err)
+	}
+
+	if len(dec.Path) != 2 || dec.Path[0] != editor || dec.Path[1] != viewer || dec.Rule != all {
+		t.Errorf("[case 4] invalid output: expected path [%v %v] to %v, got %v to %v", editor, viewer, all, dec.Path, dec.Rule)
+	}
+
+	// case 5: denied
+	rbac.AssignRoleToUser(u, contractor)
+
+	dec, err = rbac.ExplainUserPermission(u, remove)
+	if err != nil || dec.Allowed || dec.Reason != ReasonDenied {
+		t.Errorf("[case 5] invalid output: expected %v, got %v (err %v)", ReasonDenied, dec, err)
+	}
+
+	if len(dec.Path) != 1 || dec.Path[0] != contractor || dec.Rule != remove {
+		t.Errorf("[case 5] invalid output: expected path [%v] to %v, got %v to %v", contractor, remove, dec.Path, dec.Rule)
+	}
+
+	// case 6: explanation agrees with check
+	for _, p := range []Permission{read, all, remove, export} {
+		dec, _ := rbac.ExplainUserPermission(u, p)
+		ok, _ := rbac.UserHasPermission(u, p)
+		if dec.Allowed != ok {
+			t.Errorf("[case 6] invalid output: expected %t for %v, got %t", ok, p, dec.Allowed)
+		}
+	}
+
+	// case 7: roles of other domain
+	dec, err = rbac.ExplainUserPermissionInDomain(u, read, NewDomain(defaultDomainID))
+	if err != nil || dec.Allowed || dec.Reason != ReasonNoRoleMatched {
+		t.Errorf("[case 7] invalid output: expected %v, got %v (err %v)", ReasonNoRoleMatched, dec, err)
+	}
+}
@@ -0,0 +1,160 @@
+package rbac
+
+// AddRoleParent makes child Role inherit all Permissions of parent Role and of its ancestors.
+// Both Roles has to be registered.
+// Returns *RoleCycleError if parent is the child itself or already inherits child,
+// *SSDViolationError if inheritance makes any User violate separation of duty constraint.
+func (rbac *RBAC) AddRoleParent(child, parent Role) error {
+	rbac.mutex.Lock()
+	defer rbac.unlock()
+
+	return rbac.addRoleParent(child, parent)
+}
+
+// addRoleParent is AddRoleParent without locking.
+// Caller has to hold the mutex.
+func (rbac *RBAC) addRoleParent(child, parent Role) error {
+	if err := rbac.checkRole(child); err != nil {
+		return err
+	}
+	if err := rbac.checkRole(parent); err != nil {
+		return err
+	}
+
+	ancestors, err := rbac.roleAncestors(parent)
+	if err != nil {
+		return err
+	}
+	if _, ok := ancestors[child]; ok {
+		return &RoleCycleError{Child: child, Parent: parent}
+	}
+
+	added, err := rbac.store.AddRoleParent(child, parent)
+	if err != nil || !added {
+		return err
+	}
+
+	// inheriting parent may make holders of child violate separation of duty
+	constraints, err := rbac.store.SSDConstraints()
+	if err != nil {
+		return err
+	}
+	if err := rbac.checkHoldersSSD(constraints, []Role{child}); err != nil {
+		if _, rerr := rbac.store.RemoveRoleParent(child, parent); rerr != nil {
+			return rerr
+		}
+		return err
+	}
+	return nil
+}
+
+// RemoveRoleParent stops child Role from inheriting Permissions of parent Role.
+// Both Roles has to be registered.
+func (rbac *RBAC) RemoveRoleParent(child, parent Role) error {
+	rbac.mutex.Lock()
+	defer rbac.unlock()
+
+	return rbac.removeRoleParent(child, parent)
+}
+
+// removeRoleParent is RemoveRoleParent without locking.
+// Caller has to hold the mutex.
+func (rbac *RBAC) removeRoleParent(child, parent Role) error {
+	if err := rbac.checkRole(child); err != nil {
+		return err
+	}
+	if err := rbac.checkRole(parent); err != nil {
+		return err
+	}
+
+	_, err := rbac.store.RemoveRoleParent(child, parent)
+	return err
+}
+
+// ListRoleParents returns Roles directly inherited by Role.
+// Role has to be registered.
+func (rbac *RBAC) ListRoleParents(r Role) ([]Role, error) {
+	rbac.mutex.RLock()
+	defer rbac.mutex.RUnlock()
+
+	return rbac.listRoleParents(r)
+}
+
+// listRoleParents is ListRoleParents without locking.
+// Caller has to hold the mutex.
+func (rbac *RBAC) listRoleParents(r Role) ([]Role, error) {
+	if err := rbac.checkRole(r); err != nil {
+		return nil, err
+	}
+	return rbac.store.RoleParents(r)
+}
+
+// roleAncestors returns Role itself and all Roles it inherits, directly or transitively.
+// Caller has to hold the mutex.
+func (rbac *RBAC) roleAncestors(r Role) (map[Role]struct{}, error) {
+	return rbac.rolesWithAncestors([]Role{r})
+}
+
+// rolesWithAncestors returns provided Roles extended with all Roles they inherit.
+// Caller has to hold the mutex.
+func (rbac *RBAC) rolesWithAncestors(roles []Role) (map[Role]struct{}, error) {
+	out := make(map[Role]struct{}, len(roles))
+	queue := make([]Role, 0, len(roles))
+	for _, r := range roles {
+		if _, ok := out[r]; ok {
+			continue
+		}
+		out[r] = struct{}{}
+		queue = append(queue, r)
+	}
+
+	for len(queue) > 0 {
+		r := queue[0]
+		queue = queue[1:]
+
+		parents, err := rbac.store.RoleParents(r)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range parents {
+			if _, ok := out[p]; ok {
+				continue
+			}
+			out[p] = struct{}{}
+			queue = append(queue, p)
+		}
+	}
+	return out, nil
+}
+
+// rolesWithDescendants returns provided Roles extended with all Roles inheriting them.
+// Caller has to hold the mutex.
+func (rbac *RBAC) rolesWithDescendants(roles []Role) (map[Role]struct{}, error) {
+	out := make(map[Role]struct{}, len(roles))
+	queue := make([]Role, 0, len(roles))
+	for _, r := range roles {
+		if _, ok := out[r]; ok {
+			continue
+		}
+		out[r] = struct{}{}
+		queue = append(queue, r)
+	}
+
+	for len(queue) > 0 {
+		r := queue[0]
+		queue = queue[1:]
+
+		children, err := rbac.store.RoleChildren(r)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range children {
+			if _, ok := out[c]; ok {
+				continue
+			}
+			out[c] = struct{}{}
+			queue = append(queue, c)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,195 @@
+package rbac
+
+import "testing"
+
+func TestAddRoleParent(t *testing.T) {
+	rbac := NewRBAC()
+
+	child := NewRole("child")
+	parent := NewRole("parent")
+	grandParent := NewRole("grandParent")
+
+	// case 1: child is not registered
+	err := rbac.AddRoleParent(child, parent)
+	if err != ErrorRoleNotRegistered {
+		t.Errorf("[case 1] invalid output: expected err equal %v, got %v", ErrorRoleNotRegistered, err)
+	}
+
+	// case 2: child is registered, parent is not registered
+	rbac.RegisterRole(child)
+
+	err = rbac.AddRoleParent(child, parent)
+	if err != ErrorRoleNotRegistered {
+		t.Errorf("[case 2] invalid output: expected err equal %v, got %v", ErrorRoleNotRegistered, err)
+	}
+
+	// case 3: both roles are registered
+	rbac.RegisterRole(parent)
+
+	err = rbac.AddRoleParent(child, parent)
+	if err != nil {
+		t.Errorf("[case 3] invalid output: expected err equal nil, got %v", err)
+	}
+
+	if _, ok := memory(rbac).parents2roles[child][parent]; !ok {
+		t.Errorf("[case 3] parent were not added to role")
+	}
+
+	// case 4: role can not inherit itself
+	err = rbac.AddRoleParent(child, child)
+	if _, ok := err.(*RoleCycleError); !ok {
+		t.Errorf("[case 4] invalid output: expected *RoleCycleError, got %v", err)
+	}
+
+	// case 5: transitive cycle
+	rbac.RegisterRole(grandParent)
+
+	err = rbac.AddRoleParent(parent, grandParent)
+	if err != nil {
+		t.Errorf("[case 5] invalid output: expected err equal nil, got %v", err)
+	}
+
+	err = rbac.AddRoleParent(grandParent, child)
+	cycleErr, ok := err.(*RoleCycleError)
+	if !ok {
+		t.Fatalf("[case 5] invalid output: expected *RoleCycleError, got %v", err)
+	}
+
+	if cycleErr.Child != grandParent || cycleErr.Parent != child {
+		t.Errorf("[case 5] invalid output: expected cycle %v -> %v, got %v -> %v", grandParent, child, cycleErr.Child, cycleErr.Parent)
+	}
+
+	if _, ok := memory(rbac).parents2roles[grandParent][child]; ok {
+		t.Errorf("[case 5] cyclic parent were added to role")
+	}
+}
+
+func TestRemoveRoleParent(t *testing.T) {
+	rbac := NewRBAC()
+
+	child := NewRole("child")
+	parent := NewRole("parent")
+
+	// case 1: roles are not registered
+	err := rbac.RemoveRoleParent(child, parent)
+	if err != ErrorRoleNotRegistered {
+		t.Errorf("[case 1] invalid output: expected err equal %v, got %v", ErrorRoleNotRegistered, err)
+	}
+
+	// case 2: parent is assigned
+	rbac.RegisterRole(child)
+	rbac.RegisterRole(parent)
+	rbac.AddRoleParent(child, parent)
+
+	err = rbac.RemoveRoleParent(child, parent)
+	if err != nil {
+		t.Errorf("[case 2] invalid output: expected err equal nil, got %v", err)
+	}
+
+	if _, ok := memory(rbac).parents2roles[child][parent]; ok {
+		t.Errorf("[case 2] parent were not removed from role")
+	}
+}
+
+func TestListRoleParents(t *testing.T) {
+	rbac := NewRBAC()
+
+	child := NewRole("child")
+	parent := NewRole("parent")
+	grandParent := NewRole("grandParent")
+
+	// case 1: role is not registered
+	_, err := rbac.ListRoleParents(child)
+	if err != ErrorRoleNotRegistered {
+		t.Errorf("[case 1] invalid output: expected err equal %v, got %v", ErrorRoleNotRegistered, err)
+	}
+
+	// case 2: only direct parents are listed
+	rbac.RegisterRole(child)
+	rbac.RegisterRole(parent)
+	rbac.RegisterRole(grandParent)
+	rbac.AddRoleParent(child, parent)
+	rbac.AddRoleParent(parent, grandParent)
+
+	list, err := rbac.ListRoleParents(child)
+	if err != nil {
+		t.Errorf("[case 2] invalid output: expected err equal nil, got %v", err)
+	}
+
+	if len(list) != 1 || !roleExistsIn(parent, list) {
+		t.Errorf("[case 2] invalid output: expected [%v], got %v", parent, list)
+	}
+}
+
+func TestRoleHierarchyPermissions(t *testing.T) {
+	rbac := NewRBAC()
+
+	u := NewUser(defaultUserID)
+	viewer := NewRole("viewer")
+	editor := NewRole("editor")
+	admin := NewRole("admin")
+
+	read := NewPermission(NewObject("invoice"), NewAction("read"))
+	write := NewPermission(NewObject("invoice"), NewAction("write"))
+	remove := NewPermission(NewObject("invoice"), NewAction("delete"))
+
+	rbac.RegisterUser(u)
+	for _, r := range []Role{viewer, editor, admin} {
+		rbac.RegisterRole(r)
+	}
+	for _, p := range []Permission{read, write, remove} {
+		rbac.RegisterPermission(p)
+	}
+
+	rbac.AssignPermissionToRole(viewer, read)
+	rbac.AssignPermissionToRole(editor, write)
+	rbac.AssignPermissionToRole(admin, remove)
+	rbac.AddRoleParent(editor, viewer)
+	rbac.AddRoleParent(admin, editor)
+	rbac.AssignRoleToUser(u, admin)
+
+	// case 1: role sees permissions of all ancestors
+	list, err := rbac.ListRolePermissions(admin)
+	if err != nil {
+		t.Errorf("[case 1] invalid output: expected err equal nil, got %v", err)
+	}
+
+	for _, p := range []Permission{read, write, remove} {
+		if !permissionExistsIn(p, list) {
+			t.Errorf("[case 1] invalid output: expected to find %v, got nothing", p)
+		}
+	}
+
+	if len(list) != 3 {
+		t.Errorf("[case 1] invalid output: expected list len %d, got %d", 3, len(list))
+	}
+
+	ok, err := rbac.RoleHasPermission(admin, read)
+	if err != nil || !ok {
+		t.Errorf("[case 1] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	// case 2: parent does not see permissions of its children
+	ok, err = rbac.RoleHasPermission(viewer, write)
+	if err != nil || ok {
+		t.Errorf("[case 2] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+
+	// case 3: user sees inherited permissions
+	ok, err = rbac.UserHasPermission(u, read)
+	if err != nil || !ok {
+		t.Errorf("[case 3] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	// case 4: removing parent role breaks inheritance
+	rbac.RemoveRole(viewer)
+
+	ok, err = rbac.UserHasPermission(u, read)
+	if err != nil || ok {
+		t.Errorf("[case 4] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+
+	if _, ok := memory(rbac).parents2roles[editor][viewer]; ok {
+		t.Errorf("[case 4] removed role is still a parent")
+	}
+}
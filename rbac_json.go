@@ -0,0 +1,579 @@
+package rbac
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// DocumentVersion is the version of document format produced by Export and MarshalJSON.
+// Version is bumped whenever document gains a field:
+//
+//	1: users, roles, permissions, role_parents, role_permissions, role_denies and user_roles
+//	2: domain of user_roles
+//	3: not_before and not_after of user_roles
+//	4: ssd_constraints
+//	5: dsd_constraints
+//	6: role_conditions
+//	7: exact of permissions
+//	8: action_implications
+//
+// Import and UnmarshalJSON accept documents of this and earlier versions. Fields unknown to
+// document format or added after document version are rejected.
+const DocumentVersion = 8
+
+type (
+	document struct {
+		Version         int                      `json:"version"`
+		Users           []string                 `json:"users"`
+		Roles           []string                 `json:"roles"`
+		Permissions     []documentPermission     `json:"permissions"`
+		RoleParents     []documentRoleParent     `json:"role_parents"`
+		RolePermissions []documentRolePermission `json:"role_permissions"`
+		RoleDenies      []documentRolePermission `json:"role_denies"`
+		UserRoles       []documentUserRole       `json:"user_roles"`
+
+		RoleConditions     []documentRolePermission `json:"role_conditions,omitempty"`
+		ActionImplications []documentImplication    `json:"action_implications,omitempty"`
+		SSDConstraints     []documentConstraint     `json:"ssd_constraints,omitempty"`
+		DSDConstraints     []documentConstraint     `json:"dsd_constraints,omitempty"`
+	}
+
+	documentPermission struct {
+		Object string `json:"object"`
+		Action string `json:"action"`
+		Exact  bool   `json:"exact,omitempty"`
+	}
+
+	documentRoleParent struct {
+		Role   string `json:"role"`
+		Parent string `json:"parent"`
+	}
+
+	documentRolePermission struct {
+		Role   string `json:"role"`
+		Object string `json:"object"`
+		Action string `json:"action"`
+		// When is expression of conditional Permission, set in role_conditions only
+		When string `json:"when,omitempty"`
+	}
+
+	documentUserRole struct {
+		User   string `json:"user"`
+		Role   string `json:"role"`
+		Domain string `json:"domain,omitempty"`
+
+		NotBefore *time.Time `json:"not_before,omitempty"`
+		NotAfter  *time.Time `json:"not_after,omitempty"`
+	}
+
+	documentImplication struct {
+		Action  string `json:"action"`
+		Implies string `json:"implies"`
+	}
+
+	documentConstraint struct {
+		Name  string   `json:"name"`
+		Roles []string `json:"roles"`
+		N     int      `json:"n"`
+	}
+)
+
+// Export writes all registered Users, Roles, Permissions and relations between them to w as JSON document.
+// Document entries are sorted, so equal controllers produce equal documents.
+func (rbac *RBAC) Export(w io.Writer) error {
+	data, err := rbac.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// Import replaces content of RBAC controller with JSON document read from r.
+// Document has to be produced by Export (or follow the same format) and reference only Users,
+// Roles and Permissions it registers. Controller is left untouched if document is rejected.
+// Conditional Permissions with Go function Conditions are not part of document and are dropped.
+func (rbac *RBAC) Import(r io.Reader) error {
+	doc, err := decodeDocument(r)
+	if err != nil {
+		return fmt.Errorf("rbac: %w", err)
+	}
+	return rbac.importDocument(doc)
+}
+
+// MarshalJSON implements json.Marshaler, see Export.
+func (rbac *RBAC) MarshalJSON() ([]byte, error) {
+	rbac.mutex.RLock()
+	defer rbac.mutex.RUnlock()
+
+	return rbac.marshalJSON()
+}
+
+// marshalJSON is MarshalJSON without locking.
+// Caller has to hold the mutex.
+func (rbac *RBAC) marshalJSON() ([]byte, error) {
+	doc, err := exportDocument(rbac.store)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, see Import.
+// Zero RBAC, like one allocated by json package, is initialised as NewRBAC does first.
+func (rbac *RBAC) UnmarshalJSON(data []byte) error {
+	if rbac.mutex == nil {
+		*rbac = *NewRBAC()
+	}
+	doc, err := decodeDocument(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("rbac: %w", err)
+	}
+	return rbac.importDocument(doc)
+}
+
+// decodeDocument reads document from r, rejecting fields unknown to document format
+// and documents of unsupported versions.
+func decodeDocument(r io.Reader) (document, error) {
+	var doc document
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&doc); err != nil {
+		return doc, fmt.Errorf("decode document: %w", err)
+	}
+	if doc.Version < 1 || doc.Version > DocumentVersion {
+		return doc, fmt.Errorf("unsupported document version %d, expected at most %d", doc.Version, DocumentVersion)
+	}
+	if version, field := doc.requiredVersion(); version > doc.Version {
+		return doc, fmt.Errorf("document version %d has no %s field, added in version %d", doc.Version, field, version)
+	}
+	return doc, nil
+}
+
+// requiredVersion returns the lowest document version having all fields set in document,
+// along with the name of the latest added field among them.
+func (doc *document) requiredVersion() (int, string) {
+	version, field := 1, ""
+	use := func(v int, name string, set bool) {
+		if set && v > version {
+			version, field = v, name
+		}
+	}
+	for _, ur := range doc.UserRoles {
+		use(2, "user_roles.domain", ur.Domain != "")
+		use(3, "user_roles.not_before", ur.NotBefore != nil)
+		use(3, "user_roles.not_after", ur.NotAfter != nil)
+	}
+	use(4, "ssd_constraints", len(doc.SSDConstraints) > 0)
+	use(5, "dsd_constraints", len(doc.DSDConstraints) > 0)
+	use(6, "role_conditions", len(doc.RoleConditions) > 0)
+	for _, dp := range doc.Permissions {
+		use(7, "permissions.exact", dp.Exact)
+	}
+	use(8, "action_implications", len(doc.ActionImplications) > 0)
+	return version, field
+}
+
+// exportDocument builds document from Store content.
+// Caller has to guard Store from concurrent mutations.
+func exportDocument(s Store) (document, error) {
+	doc := document{
+		Version:         DocumentVersion,
+		Users:           make([]string, 0),
+		Roles:           make([]string, 0),
+		Permissions:     make([]documentPermission, 0),
+		RoleParents:     make([]documentRoleParent, 0),
+		RolePermissions: make([]documentRolePermission, 0),
+		RoleDenies:      make([]documentRolePermission, 0),
+		UserRoles:       make([]documentUserRole, 0),
+	}
+
+	users, err := s.Users()
+	if err != nil {
+		return doc, err
+	}
+	for _, u := range users {
+		doc.Users = append(doc.Users, u.id)
+
+		domains, err := s.UserDomains(u)
+		if err != nil {
+			return doc, err
+		}
+		for _, d := range domains {
+			roles, err := s.UserRoles(u, d)
+			if err != nil {
+				return doc, err
+			}
+			for _, r := range roles {
+				v, err := s.UserRoleValidity(u, r, d)
+				if err != nil {
+					return doc, err
+				}
+				ur := documentUserRole{User: u.id, Role: r.id, Domain: string(d)}
+				if !v.NotBefore.IsZero() {
+					ur.NotBefore = &v.NotBefore
+				}
+				if !v.NotAfter.IsZero() {
+					ur.NotAfter = &v.NotAfter
+				}
+				doc.UserRoles = append(doc.UserRoles, ur)
+			}
+		}
+	}
+
+	perms, err := s.Permissions()
+	if err != nil {
+		return doc, err
+	}
+	exact, err := s.ExactPermissions()
+	if err != nil {
+		return doc, err
+	}
+	exactSet := make(map[Permission]struct{}, len(exact))
+	for _, p := range exact {
+		exactSet[p] = struct{}{}
+	}
+	for _, p := range perms {
+		_, isExact := exactSet[p]
+		doc.Permissions = append(doc.Permissions, documentPermission{Object: string(p.object), Action: string(p.action), Exact: isExact})
+	}
+
+	roles, err := s.Roles()
+	if err != nil {
+		return doc, err
+	}
+	for _, r := range roles {
+		doc.Roles = append(doc.Roles, r.id)
+
+		parents, err := s.RoleParents(r)
+		if err != nil {
+			return doc, err
+		}
+		for _, parent := range parents {
+			doc.RoleParents = append(doc.RoleParents, documentRoleParent{Role: r.id, Parent: parent.id})
+		}
+
+		perms, err := s.RolePermissions(r)
+		if err != nil {
+			return doc, err
+		}
+		for _, p := range perms {
+			doc.RolePermissions = append(doc.RolePermissions, documentRolePermission{Role: r.id, Object: string(p.object), Action: string(p.action)})
+		}
+
+		denies, err := s.RoleDenies(r)
+		if err != nil {
+			return doc, err
+		}
+		for _, p := range denies {
+			doc.RoleDenies = append(doc.RoleDenies, documentRolePermission{Role: r.id, Object: string(p.object), Action: string(p.action)})
+		}
+
+		conds, err := s.RoleConditions(r)
+		if err != nil {
+			return doc, err
+		}
+		for p, expr := range conds {
+			doc.RoleConditions = append(doc.RoleConditions, documentRolePermission{Role: r.id, Object: string(p.object), Action: string(p.action), When: expr})
+		}
+	}
+
+	sort.Strings(doc.Users)
+	sort.Strings(doc.Roles)
+	sort.Slice(doc.Permissions, func(i, j int) bool {
+		a, b := doc.Permissions[i], doc.Permissions[j]
+		if a.Object != b.Object {
+			return a.Object < b.Object
+		}
+		return a.Action < b.Action
+	})
+	sort.Slice(doc.RoleParents, func(i, j int) bool {
+		a, b := doc.RoleParents[i], doc.RoleParents[j]
+		if a.Role != b.Role {
+			return a.Role < b.Role
+		}
+		return a.Parent < b.Parent
+	})
+	sortRolePermissions(doc.RolePermissions)
+	sortRolePermissions(doc.RoleDenies)
+	sortRolePermissions(doc.RoleConditions)
+	sort.Slice(doc.UserRoles, func(i, j int) bool {
+		a, b := doc.UserRoles[i], doc.UserRoles[j]
+		if a.User != b.User {
+			return a.User < b.User
+		}
+		if a.Domain != b.Domain {
+			return a.Domain < b.Domain
+		}
+		return a.Role < b.Role
+	})
+
+	implications, err := s.ActionImplications()
+	if err != nil {
+		return doc, err
+	}
+	for _, i := range implications {
+		doc.ActionImplications = append(doc.ActionImplications, documentImplication{Action: string(i.Action), Implies: string(i.Implied)})
+	}
+	sort.Slice(doc.ActionImplications, func(i, j int) bool {
+		a, b := doc.ActionImplications[i], doc.ActionImplications[j]
+		if a.Action != b.Action {
+			return a.Action < b.Action
+		}
+		return a.Implies < b.Implies
+	})
+
+	ssd, err := s.SSDConstraints()
+	if err != nil {
+		return doc, err
+	}
+	for _, c := range ssd {
+		doc.SSDConstraints = append(doc.SSDConstraints, exportConstraint(c))
+	}
+	sortConstraints(doc.SSDConstraints)
+
+	dsd, err := s.DSDConstraints()
+	if err != nil {
+		return doc, err
+	}
+	for _, c := range dsd {
+		doc.DSDConstraints = append(doc.DSDConstraints, exportConstraint(SSDConstraint(c)))
+	}
+	sortConstraints(doc.DSDConstraints)
+	return doc, nil
+}
+
+func exportConstraint(c SSDConstraint) documentConstraint {
+	dc := documentConstraint{Name: c.Name, Roles: make([]string, 0, len(c.Roles)), N: c.N}
+	for _, r := range c.Roles {
+		dc.Roles = append(dc.Roles, r.id)
+	}
+	sort.Strings(dc.Roles)
+	return dc
+}
+
+func sortConstraints(list []documentConstraint) {
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+}
+
+func sortRolePermissions(list []documentRolePermission) {
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.Role != b.Role {
+			return a.Role < b.Role
+		}
+		if a.Object != b.Object {
+			return a.Object < b.Object
+		}
+		return a.Action < b.Action
+	})
+}
+
+// importDocument validates document and replaces controller content with it.
+func (rbac *RBAC) importDocument(doc document) error {
+	rbac.mutex.Lock()
+	defer rbac.unlock()
+
+	// document is validated against controller settings while loaded, in transaction,
+	// so rejected document leaves rbac untouched
+	return rbac.update(func(*Tx) error {
+		if err := rbac.clear(); err != nil {
+			return err
+		}
+		return rbac.loadDocument(doc)
+	})
+}
+
+// loadDocument adds document content to controller validating all references.
+// Caller has to hold the mutex.
+func (rbac *RBAC) loadDocument(doc document) error {
+	for _, id := range doc.Users {
+		if _, err := rbac.store.AddUser(NewUser(id)); err != nil {
+			return err
+		}
+	}
+	for _, id := range doc.Roles {
+		if _, err := rbac.store.AddRole(NewRole(id)); err != nil {
+			return err
+		}
+	}
+	for _, dp := range doc.Permissions {
+		p := NewPermission(NewObject(dp.Object), NewAction(dp.Action))
+		if _, err := rbac.store.AddPermission(p); err != nil {
+			return err
+		}
+		if !dp.Exact {
+			continue
+		}
+		if _, err := rbac.store.AddExactPermission(p); err != nil {
+			return err
+		}
+	}
+
+	for i, rp := range doc.RoleParents {
+		if err := rbac.addRoleParent(NewRole(rp.Role), NewRole(rp.Parent)); err != nil {
+			return fmt.Errorf("rbac: role_parents[%d]: role %q parent %q: %w", i, rp.Role, rp.Parent, err)
+		}
+	}
+	for i, rp := range doc.RolePermissions {
+		if err := rbac.loadRolePermission(rbac.store.AddRolePermission, rp); err != nil {
+			return fmt.Errorf("rbac: role_permissions[%d]: %w", i, err)
+		}
+	}
+	for i, rp := range doc.RoleDenies {
+		if err := rbac.loadRolePermission(rbac.store.AddRoleDeny, rp); err != nil {
+			return fmt.Errorf("rbac: role_denies[%d]: %w", i, err)
+		}
+	}
+	for i, di := range doc.ActionImplications {
+		if err := rbac.declareActionImplies(NewAction(di.Action), NewAction(di.Implies)); err != nil {
+			return fmt.Errorf("rbac: action_implications[%d]: %w", i, err)
+		}
+	}
+	for i, rp := range doc.RoleConditions {
+		when := rp.When
+		err := rbac.loadRolePermission(func(r Role, p Permission) (bool, error) {
+			if _, err := rbac.expressions.compile(when); err != nil {
+				return false, err
+			}
+			return rbac.store.AddRoleCondition(r, p, when)
+		}, rp)
+		if err != nil {
+			return fmt.Errorf("rbac: role_conditions[%d]: %w", i, err)
+		}
+	}
+	for i, ur := range doc.UserRoles {
+		u, r := NewUser(ur.User), NewRole(ur.Role)
+		if err := rbac.checkUser(u); err != nil {
+			return fmt.Errorf("rbac: user_roles[%d]: user %q: %w", i, ur.User, err)
+		}
+		if err := rbac.checkRole(r); err != nil {
+			return fmt.Errorf("rbac: user_roles[%d]: role %q: %w", i, ur.Role, err)
+		}
+		var v Validity
+		if ur.NotBefore != nil {
+			v.NotBefore = *ur.NotBefore
+		}
+		if ur.NotAfter != nil {
+			v.NotAfter = *ur.NotAfter
+		}
+		if !v.valid() {
+			return fmt.Errorf("rbac: user_roles[%d]: %w", i, ErrorInvalidValidity)
+		}
+		if _, err := rbac.store.AddUserRole(u, r, NewDomain(ur.Domain)); err != nil {
+			return err
+		}
+		if _, err := rbac.store.SetUserRoleValidity(u, r, NewDomain(ur.Domain), v); err != nil {
+			return err
+		}
+	}
+	for i, dc := range doc.SSDConstraints {
+		if _, err := rbac.addSSDConstraint(importConstraint(dc)); err != nil {
+			return fmt.Errorf("rbac: ssd_constraints[%d]: %w", i, err)
+		}
+	}
+	for i, dc := range doc.DSDConstraints {
+		if _, err := rbac.addDSDConstraint(DSDConstraint(importConstraint(dc))); err != nil {
+			return fmt.Errorf("rbac: dsd_constraints[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func importConstraint(dc documentConstraint) SSDConstraint {
+	roles := make([]Role, 0, len(dc.Roles))
+	for _, id := range dc.Roles {
+		roles = append(roles, NewRole(id))
+	}
+	return NewSSDConstraint(dc.Name, roles, dc.N)
+}
+
+// loadRolePermission validates document role-permission relation and stores it using add.
+// Caller has to hold the mutex.
+func (rbac *RBAC) loadRolePermission(add func(Role, Permission) (bool, error), rp documentRolePermission) error {
+	r := NewRole(rp.Role)
+	p := NewPermission(NewObject(rp.Object), NewAction(rp.Action))
+	if err := rbac.checkRole(r); err != nil {
+		return fmt.Errorf("role %q: %w", rp.Role, err)
+	}
+	if err := rbac.checkPermission(p); err != nil {
+		return fmt.Errorf("permission %q %q: %w", rp.Object, rp.Action, err)
+	}
+	_, err := add(r, p)
+	return err
+}
+
+// clear removes all Users, Roles, Permissions, Action implications, constraints and conditional Permissions from controller.
+// Caller has to hold the mutex.
+func (rbac *RBAC) clear() error {
+	users, err := rbac.store.Users()
+	if err != nil {
+		return err
+	}
+	for _, u := range users {
+		if _, err := rbac.store.RemoveUser(u); err != nil {
+			return err
+		}
+	}
+
+	roles, err := rbac.store.Roles()
+	if err != nil {
+		return err
+	}
+	for _, r := range roles {
+		if _, err := rbac.store.RemoveRole(r); err != nil {
+			return err
+		}
+	}
+
+	perms, err := rbac.store.Permissions()
+	if err != nil {
+		return err
+	}
+	for _, p := range perms {
+		if _, err := rbac.store.RemovePermission(p); err != nil {
+			return err
+		}
+	}
+
+	constraints, err := rbac.store.SSDConstraints()
+	if err != nil {
+		return err
+	}
+	for _, c := range constraints {
+		if _, err := rbac.store.RemoveSSDConstraint(c.Name); err != nil {
+			return err
+		}
+	}
+
+	dynamic, err := rbac.store.DSDConstraints()
+	if err != nil {
+		return err
+	}
+	for _, c := range dynamic {
+		if _, err := rbac.store.RemoveDSDConstraint(c.Name); err != nil {
+			return err
+		}
+	}
+
+	implications, err := rbac.store.ActionImplications()
+	if err != nil {
+		return err
+	}
+	for _, i := range implications {
+		if _, err := rbac.store.RemoveActionImplication(i); err != nil {
+			return err
+		}
+	}
+	rbac.actions.invalidate()
+
+	rbac.conditions = make(map[Role]map[Permission]Condition)
+	rbac.conditionRoles = make(map[Permission]map[Role]struct{})
+	return nil
+}
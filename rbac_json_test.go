@@ -0,0 +1,266 @@
+package rbac
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportImport(t *testing.T) {
+	src := NewRBAC()
+
+	u := NewUser(defaultUserID)
+	viewer := NewRole("viewer")
+	editor := NewRole("editor")
+	read := NewPermission(NewObject("invoice"), NewAction("read"))
+	all := NewPermission(NewObject("invoice"), NewAction("*"))
+	remove := NewPermission(NewObject("invoice"), NewAction("delete"))
+
+	src.RegisterUser(u)
+	src.RegisterUser(NewUser("idle"))
+	src.RegisterRole(viewer)
+	src.RegisterRole(editor)
+	src.RegisterPermission(read)
+	src.RegisterPermission(all)
+	src.RegisterPermission(remove)
+	src.AssignPermissionToRole(viewer, read)
+	src.AssignPermissionToRole(editor, all)
+	src.DenyPermissionToRole(editor, remove)
+	src.AssignPermissionToRoleWhen(viewer, remove, `resource.owner == subject.id`)
+	src.AssignConditionalPermissionToRole(viewer, read, func(EvalContext) bool { return true })
+	src.SetPermissionExact(remove, true)
+	src.DeclareActionImplies(NewAction("write"), NewAction("read"))
+	src.AddRoleParent(editor, viewer)
+	src.AssignRoleToUser(u, editor)
+	src.AssignRoleToUserInDomain(u, viewer, NewDomain(defaultDomainID))
+	src.AssignRoleToUserWithValidity(NewUser("idle"), viewer, Until(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)))
+	src.RegisterRole(NewRole("auditor"))
+	src.AddSSDConstraint("audit", []Role{NewRole("auditor"), editor}, 2)
+	src.AddDSDConstraint("review", []Role{viewer, NewRole("auditor")}, 2)
+
+	var buf bytes.Buffer
+	if err := src.Export(&buf); err != nil {
+		t.Fatalf("export error: expected err equal nil, got %v", err)
+	}
+	exported := buf.String()
+
+	dst := NewRBAC()
+	if err := dst.Import(strings.NewReader(exported)); err != nil {
+		t.Fatalf("import error: expected err equal nil, got %v", err)
+	}
+
+	// case 1: imported controller exports the same document
+	buf.Reset()
+	dst.Export(&buf)
+	if buf.String() != exported {
+		t.Errorf("[case 1] invalid output: expected document %s, got %s", exported, buf.String())
+	}
+
+	// case 2: imported controller makes the same decisions
+	ok, err := dst.UserHasObjectAction(u, NewObject("invoice"), NewAction("write"))
+	if err != nil || !ok {
+		t.Errorf("[case 2] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	ok, err = dst.UserHasObjectAction(u, NewObject("invoice"), NewAction("delete"))
+	if err != nil || ok {
+		t.Errorf("[case 2] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+
+	if implied, _ := dst.ListImpliedActions(NewAction("write")); len(implied) != 1 || implied[0] != NewAction("read") {
+		t.Errorf("[case 2] import error: action implications were not imported, got %v", implied)
+	}
+
+	if ok, _ := memory(dst).HasExactPermission(NewPermission(NewObject("invoice"), NewAction("delete"))); !ok {
+		t.Errorf("[case 2] import error: exact permission were not imported")
+	}
+
+	// case 3: expressions survive round trip, function conditions are dropped
+	if memory(dst).conds2roles[NewRole("viewer")][NewPermission(NewObject("invoice"), NewAction("delete"))] != `resource.owner == subject.id` {
+		t.Errorf("[case 3] import error: conditional permission expression were not imported")
+	}
+
+	if len(dst.conditions) != 0 {
+		t.Errorf("[case 3] import error: expected no function conditions, got %v", dst.conditions)
+	}
+
+	if _, ok := memory(dst).registeredWildcards[NewPermission(NewObject("invoice"), NewAction("*"))]; !ok {
+		t.Errorf("[case 2] wildcard permission not presented in registeredWildcards")
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	// case 1: empty controller
+	data, err := json.Marshal(NewRBAC())
+	if err != nil {
+		t.Fatalf("[case 1] marshal error: expected err equal nil, got %v", err)
+	}
+
+	expected := `{"version":8,"users":[],"roles":[],"permissions":[],"role_parents":[],"role_permissions":[],"role_denies":[],"user_roles":[]}`
+	if string(data) != expected {
+		t.Errorf("[case 1] invalid output: expected %s, got %s", expected, data)
+	}
+
+	// case 2: round trip through json package
+	src := newTestRBAC()
+	data, err = json.Marshal(src)
+	if err != nil {
+		t.Fatalf("[case 2] marshal error: expected err equal nil, got %v", err)
+	}
+
+	dst := NewRBAC()
+	if err := json.Unmarshal(data, dst); err != nil {
+		t.Fatalf("[case 2] unmarshal error: expected err equal nil, got %v", err)
+	}
+
+	again, _ := json.Marshal(dst)
+	if string(again) != string(data) {
+		t.Errorf("[case 2] invalid output: expected %s, got %s", data, again)
+	}
+
+	// case 3: controller allocated by json package
+	var allocated *RBAC
+	if err := json.Unmarshal(data, &allocated); err != nil {
+		t.Fatalf("[case 3] unmarshal error: expected err equal nil, got %v", err)
+	}
+
+	again, _ = json.Marshal(allocated)
+	if string(again) != string(data) {
+		t.Errorf("[case 3] invalid output: expected %s, got %s", data, again)
+	}
+
+	// case 4: zero controller field of another struct
+	var holder struct {
+		Controller RBAC `json:"controller"`
+	}
+	if err := json.Unmarshal([]byte(`{"controller":`+string(data)+`}`), &holder); err != nil {
+		t.Fatalf("[case 4] unmarshal error: expected err equal nil, got %v", err)
+	}
+
+	ok, err := holder.Controller.UserHasPermission(NewUser("alice"), NewPermission(NewObject("invoice"), NewAction("read")))
+	if err != nil || !ok {
+		t.Errorf("[case 4] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+}
+
+func TestImportEarlierVersion(t *testing.T) {
+	doc := `{"version":1,"users":["u"],"roles":["r"],"permissions":[{"object":"o","action":"a"}],` +
+		`"role_permissions":[{"role":"r","object":"o","action":"a"}],"user_roles":[{"user":"u","role":"r"}]}`
+
+	rbac := NewRBAC()
+	if err := rbac.Import(strings.NewReader(doc)); err != nil {
+		t.Fatalf("import error: expected err equal nil, got %v", err)
+	}
+
+	ok, err := rbac.UserHasObjectAction(NewUser("u"), NewObject("o"), NewAction("a"))
+	if err != nil || !ok {
+		t.Errorf("invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+}
+
+func TestImportTargetSettings(t *testing.T) {
+	// assignment of b expired by clock of controller, so document does not violate constraint
+	doc := `{"version":8,"users":["u"],"roles":["a","b"],` +
+		`"user_roles":[{"user":"u","role":"a"},{"user":"u","role":"b","not_after":"2030-01-01T00:00:00Z"}],` +
+		`"ssd_constraints":[{"name":"ab","roles":["a","b"],"n":2}]}`
+
+	// case 1: document is validated with clock of controller
+	rbac := NewRBAC()
+	rbac.SetClock(func() time.Time { return time.Date(2031, 1, 1, 0, 0, 0, 0, time.UTC) })
+	if err := rbac.Import(strings.NewReader(doc)); err != nil {
+		t.Errorf("[case 1] import error: expected err equal nil, got %v", err)
+	}
+
+	// case 2: rejected document keeps Go function Conditions
+	rbac = newTestRBAC()
+	rbac.AssignConditionalPermissionToRole(NewRole("reader"), NewPermission(NewObject("invoice"), NewAction("write")), ownerOnly)
+
+	var ssdErr *SSDViolationError
+	if err := rbac.Import(strings.NewReader(doc)); !errors.As(err, &ssdErr) {
+		t.Errorf("[case 2] import error: expected *SSDViolationError, got %v", err)
+	}
+	if len(rbac.conditions[NewRole("reader")]) != 1 {
+		t.Errorf("[case 2] import error: expected function condition kept, got %v", rbac.conditions)
+	}
+}
+
+func TestImportValidation(t *testing.T) {
+	cases := []struct {
+		doc string
+		err error
+	}{
+		{`{"version":0}`, nil},
+		{`{"version":99}`, nil},
+		{`{"version":1,"groups":[]}`, nil},
+		{`{"version":1,"users":["u"],"roles":["r"],"user_roles":[{"user":"u","role":"r","domain":"acme"}]}`, nil},
+		{`{"version":1,"users":["u"],"user_roles":[{"user":"u","role":"r"}]}`, ErrorRoleNotRegistered},
+		{`{"version":1,"roles":["r"],"user_roles":[{"user":"u","role":"r"}]}`, ErrorUserNotRegistered},
+		{`{"version":1,"roles":["r"],"role_permissions":[{"role":"r","object":"o","action":"a"}]}`, ErrorPermissionNotRegistered},
+		{`{"version":1,"permissions":[{"object":"o","action":"a"}],"role_denies":[{"role":"r","object":"o","action":"a"}]}`, ErrorRoleNotRegistered},
+		{`{"version":1,"roles":["r"],"role_parents":[{"role":"r","parent":"p"}]}`, ErrorRoleNotRegistered},
+		{`{"version":3,"users":["u"],"roles":["r"],"user_roles":[{"user":"u","role":"r","not_before":"2024-02-01T00:00:00Z","not_after":"2024-01-01T00:00:00Z"}]}`, ErrorInvalidValidity},
+		{`{"version":2,"users":["u"],"roles":["r"],"user_roles":[{"user":"u","role":"r","not_after":"2024-01-01T00:00:00Z"}]}`, nil},
+		{`{"version":3,"roles":["a","b"],"ssd_constraints":[{"name":"ab","roles":["a","b"],"n":2}]}`, nil},
+		{`{"version":4,"roles":["a","b"],"dsd_constraints":[{"name":"ab","roles":["a","b"],"n":2}]}`, nil},
+		{`{"version":5,"roles":["r"],"permissions":[{"object":"o","action":"a"}],"role_conditions":[{"role":"r","object":"o","action":"a","when":"true"}]}`, nil},
+		{`{"version":6,"permissions":[{"object":"o","action":"a","exact":true}]}`, nil},
+		{`{"version":7,"action_implications":[{"action":"write","implies":"read"}]}`, nil},
+		{`{"version":6,"roles":["r"],"permissions":[{"object":"o","action":"a"}],"role_conditions":[{"role":"r","object":"o","action":"a","when":"1 =="}]}`, nil},
+		{`{"version":6,"roles":["r"],"role_conditions":[{"role":"r","object":"o","action":"a","when":"true"}]}`, ErrorPermissionNotRegistered},
+		{`not a json`, nil},
+	}
+
+	for i, c := range cases {
+		rbac := newTestRBAC()
+		rbac.AssignPermissionToRoleWhen(NewRole("reader"), NewPermission(NewObject("invoice"), NewAction("write")), `resource.owner == subject.id`)
+		rbac.DeclareActionImplies(NewAction("write"), NewAction("read"))
+		rbac.AddSSDConstraint("audit", []Role{NewRole("auditor"), NewRole("editor")}, 2)
+		before, _ := json.Marshal(rbac)
+
+		err := rbac.Import(strings.NewReader(c.doc))
+		if err == nil {
+			t.Errorf("[case %d] import error: expected error, got nil", i+1)
+			continue
+		}
+
+		if c.err != nil && !errors.Is(err, c.err) {
+			t.Errorf("[case %d] import error: expected err wrapping %v, got %v", i+1, c.err, err)
+		}
+
+		after, _ := json.Marshal(rbac)
+		if string(before) != string(after) {
+			t.Errorf("[case %d] rejected document changed controller content", i+1)
+		}
+	}
+
+	// hierarchy cycle
+	doc := `{"version":1,"roles":["a","b"],"role_parents":[{"role":"a","parent":"b"},{"role":"b","parent":"a"}]}`
+	err := NewRBAC().Import(strings.NewReader(doc))
+
+	var cycleErr *RoleCycleError
+	if !errors.As(err, &cycleErr) {
+		t.Errorf("[cycle] import error: expected *RoleCycleError, got %v", err)
+	}
+
+	// action implication cycle
+	doc = `{"version":8,"action_implications":[{"action":"write","implies":"read"},{"action":"read","implies":"write"}]}`
+	err = NewRBAC().Import(strings.NewReader(doc))
+
+	var actionErr *ActionCycleError
+	if !errors.As(err, &actionErr) {
+		t.Errorf("[action cycle] import error: expected *ActionCycleError, got %v", err)
+	}
+
+	// separation of duty violation
+	doc = `{"version":4,"users":["u"],"roles":["a","b"],"user_roles":[{"user":"u","role":"a"},{"user":"u","role":"b"}],` +
+		`"ssd_constraints":[{"name":"ab","roles":["a","b"],"n":2}]}`
+	err = NewRBAC().Import(strings.NewReader(doc))
+
+	var ssdErr *SSDViolationError
+	if !errors.As(err, &ssdErr) {
+		t.Errorf("[ssd] import error: expected *SSDViolationError, got %v", err)
+	}
+}
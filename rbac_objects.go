@@ -0,0 +1,120 @@
+package rbac
+
+import "strings"
+
+// SetObjectSeparator enables Object hierarchy: Object IDs are split by sep into path,
+// e.g. "org/acme/project/42" with "/", and Permission on Object applies to the same Action
+// on all its descendants unless Permission is exact, see SetPermissionExact.
+// Denies are inherited downward the same way as grants.
+// Empty separator disables hierarchy, which is the default.
+// Separator is setting of controller, it is neither persisted nor exported.
+func (rbac *RBAC) SetObjectSeparator(sep string) {
+	rbac.mutex.Lock()
+	defer rbac.unlock()
+
+	rbac.setObjectSeparator(sep)
+}
+
+// setObjectSeparator is SetObjectSeparator without locking.
+// Caller has to hold the mutex.
+func (rbac *RBAC) setObjectSeparator(sep string) {
+	rbac.separator = sep
+	rbac.effective.touchSettings()
+}
+
+// ObjectSeparator returns separator of Object hierarchy, empty if hierarchy is disabled.
+func (rbac *RBAC) ObjectSeparator() string {
+	rbac.mutex.RLock()
+	defer rbac.mutex.RUnlock()
+
+	return rbac.separator
+}
+
+// SetPermissionExact marks Permission as applying to its own Object only (exact true)
+// or to its Object and all descendants (exact false, the default).
+// Permission has to be registered.
+// Returns false if Permission is already marked the same way.
+func (rbac *RBAC) SetPermissionExact(p Permission, exact bool) (bool, error) {
+	rbac.mutex.Lock()
+	defer rbac.unlock()
+
+	return rbac.setPermissionExact(p, exact)
+}
+
+// setPermissionExact is SetPermissionExact without locking.
+// Caller has to hold the mutex.
+func (rbac *RBAC) setPermissionExact(p Permission, exact bool) (bool, error) {
+	if err := rbac.checkPermission(p); err != nil {
+		return false, err
+	}
+	if exact {
+		return rbac.store.AddExactPermission(p)
+	}
+	return rbac.store.RemoveExactPermission(p)
+}
+
+// PermissionIsExact checks if Permission applies to its own Object only.
+// Permission has to be registered.
+func (rbac *RBAC) PermissionIsExact(p Permission) (bool, error) {
+	rbac.mutex.RLock()
+	defer rbac.mutex.RUnlock()
+
+	return rbac.permissionIsExact(p)
+}
+
+// permissionIsExact is PermissionIsExact without locking.
+// Caller has to hold the mutex.
+func (rbac *RBAC) permissionIsExact(p Permission) (bool, error) {
+	if err := rbac.checkPermission(p); err != nil {
+		return false, err
+	}
+	return rbac.store.HasExactPermission(p)
+}
+
+// ancestorPermissions returns Permissions with the same Action on ancestors of Permission Object, nearest first.
+// Returns nil if Object hierarchy is disabled.
+// Caller has to hold the mutex.
+func (rbac *RBAC) ancestorPermissions(p Permission) []Permission {
+	return objectAncestorPermissions(p, rbac.separator)
+}
+
+// objectAncestorPermissions returns Permissions with the same Action on Object ancestors split by separator,
+// nearest first
+func objectAncestorPermissions(p Permission, separator string) []Permission {
+	if separator == "" {
+		return nil
+	}
+
+	var out []Permission
+	object := string(p.object)
+	for i := strings.LastIndex(object, separator); i > 0; i = strings.LastIndex(object, separator) {
+		object = object[:i]
+		out = append(out, NewPermission(NewObject(object), p.action))
+	}
+	return out
+}
+
+// ruleApplies checks if registered Permission rule applies to Permission p:
+// rule matches p itself or, unless rule is exact, any of p ancestors.
+// Permissions with Actions implying p Action are tried the same way.
+// Caller has to hold the mutex.
+func (rbac *RBAC) ruleApplies(rule, p Permission) (bool, error) {
+	implying, err := rbac.implyingPermissions(p)
+	if err != nil {
+		return false, err
+	}
+	for _, q := range append([]Permission{p}, implying...) {
+		if rule.Match(q) {
+			return true, nil
+		}
+		for _, a := range rbac.ancestorPermissions(q) {
+			if !rule.Match(a) {
+				continue
+			}
+			if ok, err := rbac.inheritable(rule); err != nil || ok {
+				return ok, err
+			}
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,177 @@
+package rbac
+
+import "testing"
+
+func TestObjectHierarchy(t *testing.T) {
+	rbac := NewRBAC()
+
+	u := NewUser(defaultUserID)
+	r := NewRole(defaultRoleID)
+
+	rbac.RegisterUser(u)
+	rbac.RegisterRole(r)
+	rbac.AssignRoleToUser(u, r)
+
+	project := NewPermission(NewObject("org/acme/project/42"), NewAction("read"))
+	doc := NewObject("org/acme/project/42/doc/7")
+
+	rbac.RegisterPermission(project)
+	rbac.AssignPermissionToRole(r, project)
+
+	// case 1: hierarchy is disabled by default
+	_, err := rbac.UserHasObjectAction(u, doc, NewAction("read"))
+	if err != ErrorPermissionNotRegistered {
+		t.Errorf("[case 1] check error: expected err equal %v, got %v", ErrorPermissionNotRegistered, err)
+	}
+
+	// case 2: permission applies to descendants
+	rbac.SetObjectSeparator("/")
+
+	ok, err := rbac.UserHasObjectAction(u, doc, NewAction("read"))
+	if err != nil || !ok {
+		t.Errorf("[case 2] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	// case 3: other actions and siblings sharing prefix are not inherited
+	_, err = rbac.UserHasObjectAction(u, doc, NewAction("write"))
+	if err != ErrorPermissionNotRegistered {
+		t.Errorf("[case 3] check error: expected err equal %v, got %v", ErrorPermissionNotRegistered, err)
+	}
+
+	_, err = rbac.UserHasObjectAction(u, NewObject("org/acme/project/420/doc/7"), NewAction("read"))
+	if err != ErrorPermissionNotRegistered {
+		t.Errorf("[case 3] check error: expected err equal %v, got %v", ErrorPermissionNotRegistered, err)
+	}
+
+	// case 4: permission on ancestor is not granted by descendant
+	org := NewPermission(NewObject("org/acme"), NewAction("read"))
+	rbac.RegisterPermission(org)
+
+	ok, err = rbac.UserHasPermission(u, org)
+	if err != nil || ok {
+		t.Errorf("[case 4] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+
+	// case 5: deny on ancestor overrides grant on descendant
+	secret := NewPermission(NewObject("org/acme/project/42/doc"), NewAction("read"))
+	rbac.RegisterPermission(secret)
+	rbac.DenyPermissionToRole(r, secret)
+
+	ok, err = rbac.UserHasObjectAction(u, doc, NewAction("read"))
+	if err != nil || ok {
+		t.Errorf("[case 5] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+
+	ok, err = rbac.UserHasObjectAction(u, NewObject("org/acme/project/42/wiki"), NewAction("read"))
+	if err != nil || !ok {
+		t.Errorf("[case 5] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	// case 6: explanation names inherited rule
+	rbac.RemoveDenyFromRole(r, secret)
+
+	d, err := rbac.ExplainUserPermission(u, NewPermission(doc, NewAction("read")))
+	if err != nil || !d.Allowed || d.Rule != project {
+		t.Errorf("[case 6] invalid output: expected rule %v, got %v (err %v)", project, d, err)
+	}
+
+	// case 7: custom separator
+	rbac.SetObjectSeparator(".")
+
+	_, err = rbac.UserHasObjectAction(u, doc, NewAction("read"))
+	if err != ErrorPermissionNotRegistered {
+		t.Errorf("[case 7] check error: expected err equal %v, got %v", ErrorPermissionNotRegistered, err)
+	}
+
+	if sep := rbac.ObjectSeparator(); sep != "." {
+		t.Errorf("[case 7] invalid output: expected separator %q, got %q", ".", sep)
+	}
+}
+
+func TestSetPermissionExact(t *testing.T) {
+	rbac := NewRBAC()
+
+	u := NewUser(defaultUserID)
+	r := NewRole(defaultRoleID)
+
+	rbac.RegisterUser(u)
+	rbac.RegisterRole(r)
+	rbac.AssignRoleToUser(u, r)
+	rbac.SetObjectSeparator("/")
+
+	folder := NewPermission(NewObject("folder"), NewAction("read"))
+	file := NewObject("folder/file")
+
+	// case 1: permission is not registered
+	_, err := rbac.SetPermissionExact(folder, true)
+	if err != ErrorPermissionNotRegistered {
+		t.Errorf("[case 1] set error: expected err equal %v, got %v", ErrorPermissionNotRegistered, err)
+	}
+
+	// case 2: permission is marked exact
+	rbac.RegisterPermission(folder)
+	rbac.AssignPermissionToRole(r, folder)
+
+	ok, err := rbac.SetPermissionExact(folder, true)
+	if err != nil || !ok {
+		t.Errorf("[case 2] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	ok, err = rbac.SetPermissionExact(folder, true)
+	if err != nil || ok {
+		t.Errorf("[case 2] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+
+	ok, err = rbac.PermissionIsExact(folder)
+	if err != nil || !ok {
+		t.Errorf("[case 2] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	// case 3: exact permission applies to its own object only
+	ok, err = rbac.UserHasPermission(u, folder)
+	if err != nil || !ok {
+		t.Errorf("[case 3] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	_, err = rbac.UserHasObjectAction(u, file, NewAction("read"))
+	if err != ErrorPermissionNotRegistered {
+		t.Errorf("[case 3] check error: expected err equal %v, got %v", ErrorPermissionNotRegistered, err)
+	}
+
+	// case 4: exact permission assigned to role is not inherited even if descendant is registered
+	rbac.RegisterPermission(NewPermission(file, NewAction("read")))
+
+	ok, err = rbac.UserHasObjectAction(u, file, NewAction("read"))
+	if err != nil || ok {
+		t.Errorf("[case 4] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+
+	// case 5: inheritable wildcard permission on ancestor
+	all := NewPermission(NewObject("folder"), NewAction("*"))
+	rbac.RegisterPermission(all)
+	rbac.AssignPermissionToRole(r, all)
+
+	ok, err = rbac.UserHasObjectAction(u, NewObject("folder/sub/file"), NewAction("write"))
+	if err != nil || !ok {
+		t.Errorf("[case 5] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	// case 6: conditional permission on ancestor
+	rbac.SetPermissionExact(folder, false)
+	rbac.RemovePermissionFromRole(r, folder)
+	rbac.RemovePermissionFromRole(r, all)
+	rbac.AssignPermissionToRoleWhen(r, folder, `resource.owner == subject.id`)
+
+	ok, err = rbac.UserHasPermissionWithContext(u, NewPermission(file, NewAction("read")), EvalContext{Resource: Attributes{"owner": defaultUserID}})
+	if err != nil || !ok {
+		t.Errorf("[case 6] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	// case 7: exact conditional permission on ancestor
+	rbac.SetPermissionExact(folder, true)
+
+	ok, err = rbac.UserHasPermissionWithContext(u, NewPermission(file, NewAction("read")), EvalContext{Resource: Attributes{"owner": defaultUserID}})
+	if err != nil || ok {
+		t.Errorf("[case 7] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+}
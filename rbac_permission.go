@@ -1,62 +1,237 @@
 package rbac
 
 // RegisterPermission registers new Permission in RBAC controller.
+// Permission with wildcard Object or Action (see Permission.Wildcard) covers every matching concrete Permission.
 // Returns false if such Permission already registered.
+// Store errors are dropped, use RegisterPermissionErr to get them.
 func (rbac *RBAC) RegisterPermission(p Permission) bool {
+	ok, _ := rbac.RegisterPermissionErr(p)
+	return ok
+}
+
+// RegisterPermissionErr is RegisterPermission returning Store errors.
+func (rbac *RBAC) RegisterPermissionErr(p Permission) (bool, error) {
 	rbac.mutex.Lock()
-	defer rbac.mutex.Unlock()
+	defer rbac.unlock()
 
-	_, ok := rbac.registeredPermissions[p]
-	if ok {
-		return false
-	}
-	rbac.registeredPermissions[p] = struct{}{}
-	return true
+	return rbac.store.AddPermission(p)
 }
 
-
 // RemovePermission removes Permission from RBAC controller registered permissions list.
-// Will also remove this Permission from all Roles.
+// Will also remove this Permission, denies of it and conditional grants of it from all Roles.
 // Returns false if no such Permission were registered in controller.
+// Store errors are dropped, use RemovePermissionErr to get them.
 func (rbac *RBAC) RemovePermission(p Permission) bool {
+	ok, _ := rbac.RemovePermissionErr(p)
+	return ok
+}
+
+// RemovePermissionErr is RemovePermission returning Store errors.
+func (rbac *RBAC) RemovePermissionErr(p Permission) (bool, error) {
 	rbac.mutex.Lock()
-	defer rbac.mutex.Unlock()
+	defer rbac.unlock()
 
-	_, ok := rbac.registeredPermissions[p]
-	if !ok {
-		return false
-	}
+	return rbac.removePermission(p)
+}
 
-	// removing permission from all roles
-	for r, perms := range rbac.perms2roles {
-		if _, ok := perms[p]; ok {
-			delete(perms, p)
-			rbac.perms2roles[r] = perms
-		}
+// removePermission is RemovePermission without locking.
+// Caller has to hold the mutex.
+func (rbac *RBAC) removePermission(p Permission) (bool, error) {
+	removed, err := rbac.store.RemovePermission(p)
+	if err != nil {
+		return false, err
 	}
-
-	delete(rbac.registeredPermissions, p)
-	return true
+	for r := range rbac.conditionRoles[p] {
+		rbac.dropCondition(r, p)
+	}
+	return removed, nil
 }
 
-// ListPermissions returns all registered Permissions
+// ListPermissions returns all registered Permissions.
+// Store errors are dropped, use ListPermissionsErr to get them.
 func (rbac *RBAC) ListPermissions() []Permission {
+	list, _ := rbac.ListPermissionsErr()
+	return list
+}
+
+// ListPermissionsErr is ListPermissions returning Store errors.
+func (rbac *RBAC) ListPermissionsErr() ([]Permission, error) {
 	rbac.mutex.RLock()
 	defer rbac.mutex.RUnlock()
 
-	out := make([]Permission, 0, len(rbac.registeredPermissions))
-	for p := range rbac.registeredPermissions {
-		out = append(out, p)
-	}
-	return out
+	return rbac.store.Permissions()
 }
 
-// PermissionExists checks if Permission is registered in RBAC controller
+// PermissionExists checks if Permission is registered in RBAC controller.
+// Store errors are dropped, use PermissionExistsErr to get them.
 func (rbac *RBAC) PermissionExists(p Permission) bool {
+	ok, _ := rbac.PermissionExistsErr(p)
+	return ok
+}
+
+// PermissionExistsErr is PermissionExists returning Store errors.
+func (rbac *RBAC) PermissionExistsErr(p Permission) (bool, error) {
 	rbac.mutex.RLock()
 	defer rbac.mutex.RUnlock()
 
-	_, ok := rbac.registeredPermissions[p]
-	return ok
+	return rbac.store.HasPermission(p)
+}
+
+// checkPermissionMatch returns ErrorPermissionNotRegistered if Permission is neither registered
+// nor matched by any registered wildcard Permission nor inherited from Object ancestor,
+// nor implied by Permission which is.
+// Returns registered wildcard Permissions to be used for further matching.
+// Caller has to hold the mutex.
+func (rbac *RBAC) checkPermissionMatch(p Permission) ([]Permission, error) {
+	wildcards, err := rbac.store.WildcardPermissions()
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := rbac.permissionKnown(p, wildcards)
+	if err != nil || ok {
+		return wildcards, err
+	}
+
+	// Permission is also known if it is implied by known Permission
+	implying, err := rbac.implyingPermissions(p)
+	if err != nil {
+		return nil, err
+	}
+	for _, q := range implying {
+		ok, err := rbac.permissionKnown(q, wildcards)
+		if err != nil || ok {
+			return wildcards, err
+		}
+	}
+	return nil, ErrorPermissionNotRegistered
+}
+
+// permissionKnown checks if Permission is registered, matched by any of registered wildcard Permissions
+// or inherited from Object ancestor.
+// Caller has to hold the mutex.
+func (rbac *RBAC) permissionKnown(p Permission, wildcards []Permission) (bool, error) {
+	ok, err := rbac.store.HasPermission(p)
+	if err != nil || ok {
+		return ok, err
+	}
+
+	for _, w := range wildcards {
+		if w.Match(p) {
+			return true, nil
+		}
+	}
+
+	// Permission on Object ancestor applies to descendants unless it is exact
+	for _, a := range rbac.ancestorPermissions(p) {
+		ok, err := rbac.store.HasPermission(a)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			if ok, err := rbac.inheritable(a); err != nil || ok {
+				return ok, err
+			}
+		}
+		for _, w := range wildcards {
+			if !w.Match(a) {
+				continue
+			}
+			if ok, err := rbac.inheritable(w); err != nil || ok {
+				return ok, err
+			}
+		}
+	}
+	return false, nil
+}
+
+// inheritable checks if registered Permission applies to descendants of its Object.
+// Caller has to hold the mutex.
+func (rbac *RBAC) inheritable(p Permission) (bool, error) {
+	exact, err := rbac.store.HasExactPermission(p)
+	return !exact, err
 }
 
+// roleGrants checks if Permission is directly assigned to Role or matched by wildcard Permission assigned to Role,
+// or implied by such Permission. Permissions inherited from parent Roles are not taken into account.
+// Caller has to hold the mutex.
+func (rbac *RBAC) roleGrants(r Role, p Permission, wildcards []Permission) (bool, error) {
+	_, ok, err := rbac.matchingGrant(r, p, wildcards)
+	return ok, err
+}
+
+// matchingGrant returns rule assigned to Role which grants Permission, as matchingRule does,
+// also trying Permissions with Actions implying Permission Action.
+// Caller has to hold the mutex.
+func (rbac *RBAC) matchingGrant(r Role, p Permission, wildcards []Permission) (Permission, bool, error) {
+	rule, ok, err := rbac.matchingRule(rbac.store.HasRolePermission, r, p, wildcards)
+	if err != nil || ok {
+		return rule, ok, err
+	}
+
+	implying, err := rbac.implyingPermissions(p)
+	if err != nil {
+		return Permission{}, false, err
+	}
+	for _, q := range implying {
+		rule, ok, err := rbac.matchingRule(rbac.store.HasRolePermission, r, q, wildcards)
+		if err != nil || ok {
+			return rule, ok, err
+		}
+	}
+	return Permission{}, false, nil
+}
+
+// matchesAny checks if Permission or any of wildcard Permissions matching it are related to Role by has.
+// Caller has to hold the mutex.
+func (rbac *RBAC) matchesAny(has func(Role, Permission) (bool, error), r Role, p Permission, wildcards []Permission) (bool, error) {
+	_, ok, err := rbac.matchingRule(has, r, p, wildcards)
+	return ok, err
+}
+
+// matchingRule returns Permission itself or the first wildcard Permission matching it which is related to Role by has.
+// If Object hierarchy is enabled, Permissions on Object ancestors which are not exact are tried next, nearest first.
+// Caller has to hold the mutex.
+func (rbac *RBAC) matchingRule(has func(Role, Permission) (bool, error), r Role, p Permission, wildcards []Permission) (Permission, bool, error) {
+	rule, ok, err := rbac.matchingOwnRule(has, r, p, wildcards, false)
+	if err != nil || ok {
+		return rule, ok, err
+	}
+	for _, a := range rbac.ancestorPermissions(p) {
+		rule, ok, err := rbac.matchingOwnRule(has, r, a, wildcards, true)
+		if err != nil || ok {
+			return rule, ok, err
+		}
+	}
+	return Permission{}, false, nil
+}
+
+// matchingOwnRule returns Permission itself or the first wildcard Permission matching it which is related to Role by has.
+// Exact Permissions are skipped if inherited is set.
+// Caller has to hold the mutex.
+func (rbac *RBAC) matchingOwnRule(has func(Role, Permission) (bool, error), r Role, p Permission, wildcards []Permission, inherited bool) (Permission, bool, error) {
+	ok, err := rbac.ruleRelated(has, r, p, inherited)
+	if err != nil || ok {
+		return p, ok, err
+	}
+	for _, w := range wildcards {
+		if w == p || !w.Match(p) {
+			continue
+		}
+		ok, err := rbac.ruleRelated(has, r, w, inherited)
+		if err != nil || ok {
+			return w, ok, err
+		}
+	}
+	return Permission{}, false, nil
+}
+
+// ruleRelated checks if Permission is related to Role by has and, if inherited is set, is not exact.
+// Caller has to hold the mutex.
+func (rbac *RBAC) ruleRelated(has func(Role, Permission) (bool, error), r Role, p Permission, inherited bool) (bool, error) {
+	ok, err := has(r, p)
+	if err != nil || !ok || !inherited {
+		return ok, err
+	}
+	return rbac.inheritable(p)
+}
@@ -19,12 +19,12 @@ func TestRegisterPermission(t *testing.T) {
 		t.Errorf("[case 1] invalid output: expected %t got %t ", true, ok)
 	}
 
-	if _, ok := rbac.registeredPermissions[p]; !ok {
+	if _, ok := memory(rbac).registeredPermissions[p]; !ok {
 		t.Errorf("[case 1] permission %v not presented in registeredPermissions", p)
 	}
 
 	// case 2: permission is registered
-	rbac.registeredPermissions[p] = struct{}{}
+	memory(rbac).registeredPermissions[p] = struct{}{}
 
 	ok = rbac.RegisterPermission(p)
 	if ok {
@@ -47,32 +47,32 @@ func TestRemovePermission(t *testing.T) {
 	}
 
 	// case 2 : permission is registered, no assigning to role
-	rbac.registeredPermissions[p] = struct{}{}
+	memory(rbac).registeredPermissions[p] = struct{}{}
 
 	exist = rbac.RemovePermission(p)
 	if !exist {
 		t.Errorf("[case 2] invalid output: expected %t got %t ", true, exist)
 	}
 
-	if _, ok := rbac.registeredPermissions[p]; ok {
+	if _, ok := memory(rbac).registeredPermissions[p]; ok {
 		t.Errorf("[case 2] permission were not removed from registered permission list")
 	}
 
 	// case 3: permission is registered, assigned to role
-	rbac.registeredPermissions[p] = struct{}{}
-	rbac.registeredRoles[r] = struct{}{}
-	rbac.perms2roles[r] = map[Permission]struct{}{p:struct{}{}}
+	rbac.RegisterPermission(p)
+	rbac.RegisterRole(r)
+	rbac.AssignPermissionToRole(r, p)
 
 	exist = rbac.RemovePermission(p)
 	if !exist {
 		t.Errorf("[case 3] invalid output: expected %t got %t ", true, exist)
 	}
 
-	if _, ok := rbac.registeredPermissions[p]; ok {
+	if _, ok := memory(rbac).registeredPermissions[p]; ok {
 		t.Errorf("[case 3] permission were not removed from registered permission list")
 	}
 
-	if _, ok := rbac.perms2roles[r][p]; ok {
+	if _, ok := memory(rbac).perms2roles[r][p]; ok {
 		t.Errorf("[case 3] permission were not unassigned from role")
 	}
 }
@@ -145,4 +145,67 @@ func TestPermissionExists(t *testing.T) {
 	if rbac.PermissionExists(p) {
 		t.Errorf("invalid output: expected permission %v to not exists, got %t", p, rbac.PermissionExists(p))
 	}
-}
\ No newline at end of file
+}
+
+func TestWildcardPermissions(t *testing.T) {
+	rbac := NewRBAC()
+
+	u := NewUser(defaultUserID)
+	r := NewRole(defaultRoleID)
+
+	allInvoice := NewPermission(NewObject("invoice"), NewAction("*"))
+	readReports := NewPermission(NewObject("reports/*"), NewAction("read"))
+
+	rbac.RegisterUser(u)
+	rbac.RegisterRole(r)
+	rbac.RegisterPermission(allInvoice)
+	rbac.RegisterPermission(readReports)
+	rbac.AssignRoleToUser(u, r)
+	rbac.AssignPermissionToRole(r, allInvoice)
+
+	if _, ok := memory(rbac).registeredWildcards[allInvoice]; !ok {
+		t.Errorf("wildcard permission %v not presented in registeredWildcards", allInvoice)
+	}
+
+	// case 1: concrete permission matched by assigned wildcard
+	ok, err := rbac.UserHasObjectAction(u, NewObject("invoice"), NewAction("delete"))
+	if err != nil || !ok {
+		t.Errorf("[case 1] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	// case 2: concrete permission matched by registered, but not assigned wildcard
+	ok, err = rbac.UserHasObjectAction(u, NewObject("reports/2018"), NewAction("read"))
+	if err != nil || ok {
+		t.Errorf("[case 2] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+
+	rbac.AssignPermissionToRole(r, readReports)
+
+	ok, err = rbac.UserHasObjectAction(u, NewObject("reports/2018"), NewAction("read"))
+	if err != nil || !ok {
+		t.Errorf("[case 2] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	ok, err = rbac.RoleHasPermission(r, NewPermission(NewObject("reports/2018"), NewAction("read")))
+	if err != nil || !ok {
+		t.Errorf("[case 2] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	// case 3: concrete permission matched by no registered permission
+	_, err = rbac.UserHasObjectAction(u, NewObject("reports/2018"), NewAction("write"))
+	if err != ErrorPermissionNotRegistered {
+		t.Errorf("[case 3] invalid output: expected err equal %v, got %v", ErrorPermissionNotRegistered, err)
+	}
+
+	// case 4: removed wildcard permission matches nothing
+	rbac.RemovePermission(allInvoice)
+
+	if _, ok := memory(rbac).registeredWildcards[allInvoice]; ok {
+		t.Errorf("[case 4] wildcard permission were not removed from registeredWildcards")
+	}
+
+	_, err = rbac.UserHasObjectAction(u, NewObject("invoice"), NewAction("delete"))
+	if err != ErrorPermissionNotRegistered {
+		t.Errorf("[case 4] invalid output: expected err equal %v, got %v", ErrorPermissionNotRegistered, err)
+	}
+}
@@ -0,0 +1,296 @@
+package rbac
+
+import "sort"
+
+// RolesWithPermission returns all Roles allowing Permission, sorted by ID.
+// Evaluation follows RoleHasPermission rules, conditional Permissions are not taken into account.
+// Candidate Roles are looked up through Store reverse indexes, so only Roles holding matching rules
+// and Roles inheriting them are evaluated.
+// Permission has to be registered or matched by registered wildcard Permission.
+func (rbac *RBAC) RolesWithPermission(p Permission) ([]Role, error) {
+	rbac.mutex.RLock()
+	defer rbac.mutex.RUnlock()
+
+	return rbac.rolesWithPermission(p)
+}
+
+// UsersWithPermission returns all Users allowed Permission in DefaultDomain, sorted by ID.
+// Evaluation follows UserHasPermission rules.
+// Permission has to be registered or matched by registered wildcard Permission.
+func (rbac *RBAC) UsersWithPermission(p Permission) ([]User, error) {
+	rbac.mutex.RLock()
+	defer rbac.mutex.RUnlock()
+
+	return rbac.usersWithPermission(p, DefaultDomain)
+}
+
+// UsersWithPermissionInDomain returns all Users allowed Permission in Domain, sorted by ID.
+// Evaluation follows UserHasPermission rules.
+// Permission has to be registered or matched by registered wildcard Permission.
+func (rbac *RBAC) UsersWithPermissionInDomain(p Permission, d Domain) ([]User, error) {
+	rbac.mutex.RLock()
+	defer rbac.mutex.RUnlock()
+
+	return rbac.usersWithPermission(p, d)
+}
+
+// UsersWithRole returns all Users having Role assigned in DefaultDomain and currently in effect, sorted by ID.
+// Like UserHasRole, only direct assignments count, Users of Roles inheriting Role are not returned.
+// Role has to be registered.
+func (rbac *RBAC) UsersWithRole(r Role) ([]User, error) {
+	rbac.mutex.RLock()
+	defer rbac.mutex.RUnlock()
+
+	return rbac.usersWithRole(r, DefaultDomain)
+}
+
+// UsersWithRoleInDomain returns all Users having Role assigned in Domain and currently in effect, sorted by ID.
+// Role has to be registered.
+func (rbac *RBAC) UsersWithRoleInDomain(r Role, d Domain) ([]User, error) {
+	rbac.mutex.RLock()
+	defer rbac.mutex.RUnlock()
+
+	return rbac.usersWithRole(r, d)
+}
+
+// ListUserPermissions returns Permissions allowed to User in DefaultDomain, sorted by Object and Action.
+// Listed are Permissions assigned to User Roles and their ancestors along with Permissions implied by their Actions,
+// unless denied. Wildcard Permissions are listed as assigned, Object descendants are not enumerated.
+// User has to be registered.
+func (rbac *RBAC) ListUserPermissions(u User) ([]Permission, error) {
+	rbac.mutex.RLock()
+	defer rbac.mutex.RUnlock()
+
+	return rbac.listUserPermissions(u, DefaultDomain)
+}
+
+// ListUserPermissionsInDomain returns Permissions allowed to User in Domain, sorted by Object and Action.
+// Listing follows ListUserPermissions rules.
+// User has to be registered.
+func (rbac *RBAC) ListUserPermissionsInDomain(u User, d Domain) ([]Permission, error) {
+	rbac.mutex.RLock()
+	defer rbac.mutex.RUnlock()
+
+	return rbac.listUserPermissions(u, d)
+}
+
+// rolesWithPermission returns Roles allowing Permission.
+// Caller has to hold the mutex.
+func (rbac *RBAC) rolesWithPermission(p Permission) ([]Role, error) {
+	wildcards, err := rbac.checkPermissionMatch(p)
+	if err != nil {
+		return nil, err
+	}
+	candidates, err := rbac.grantingRoles(p)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]Role, 0, len(candidates))
+	for r := range candidates {
+		roles, err := rbac.roleAncestors(r)
+		if err != nil {
+			return nil, err
+		}
+		ok, err := rbac.rolesAllow(roles, p, wildcards)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out = append(out, r)
+		}
+	}
+	sortRoles(out)
+	return out, nil
+}
+
+// usersWithPermission returns Users allowed Permission in Domain.
+// Caller has to hold the mutex.
+func (rbac *RBAC) usersWithPermission(p Permission, d Domain) ([]User, error) {
+	wildcards, err := rbac.checkPermissionMatch(p)
+	if err != nil {
+		return nil, err
+	}
+	candidates, err := rbac.grantingRoles(p)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[User]struct{})
+	out := make([]User, 0)
+	for r := range candidates {
+		users, err := rbac.store.RoleUsers(r, d)
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range users {
+			if _, ok := seen[u]; ok {
+				continue
+			}
+			seen[u] = struct{}{}
+
+			roles, err := rbac.activeUserRoles(u, d)
+			if err != nil {
+				return nil, err
+			}
+			ok, err := rbac.rolesHavePermission(roles, p, wildcards)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				out = append(out, u)
+			}
+		}
+	}
+	sortUsers(out)
+	return out, nil
+}
+
+// usersWithRole returns Users having Role assigned in Domain and currently in effect.
+// Caller has to hold the mutex.
+func (rbac *RBAC) usersWithRole(r Role, d Domain) ([]User, error) {
+	if err := rbac.checkRole(r); err != nil {
+		return nil, err
+	}
+	users, err := rbac.store.RoleUsers(r, d)
+	if err != nil {
+		return nil, err
+	}
+
+	now := rbac.now()
+	out := users[:0]
+	for _, u := range users {
+		v, err := rbac.store.UserRoleValidity(u, r, d)
+		if err != nil {
+			return nil, err
+		}
+		if v.ActiveAt(now) {
+			out = append(out, u)
+		}
+	}
+	sortUsers(out)
+	return out, nil
+}
+
+// listUserPermissions returns Permissions allowed to User in Domain.
+// Caller has to hold the mutex.
+func (rbac *RBAC) listUserPermissions(u User, d Domain) ([]Permission, error) {
+	if err := rbac.checkUser(u); err != nil {
+		return nil, err
+	}
+	userRoles, err := rbac.activeUserRoles(u, d)
+	if err != nil {
+		return nil, err
+	}
+	roles, err := rbac.rolesWithAncestors(userRoles)
+	if err != nil {
+		return nil, err
+	}
+	g, err := rbac.actions.get(rbac.store)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make(map[Permission]struct{})
+	for r := range roles {
+		perms, err := rbac.store.RolePermissions(r)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range perms {
+			candidates[p] = struct{}{}
+			for _, a := range g.implied[p.action] {
+				candidates[NewPermission(p.object, a)] = struct{}{}
+			}
+		}
+	}
+
+	out := make([]Permission, 0, len(candidates))
+	for p := range candidates {
+		wildcards, err := rbac.checkPermissionMatch(p)
+		if err != nil {
+			return nil, err
+		}
+		ok, err := rbac.rolesAllow(roles, p, wildcards)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out = append(out, p)
+		}
+	}
+	sortPermissions(out)
+	return out, nil
+}
+
+// grantingRoles returns Roles which may allow Permission: Roles holding any rule that can apply to Permission
+// along with all Roles inheriting them. Denies are not taken into account, so every Role has to be evaluated.
+// Caller has to hold the mutex.
+func (rbac *RBAC) grantingRoles(p Permission) (map[Role]struct{}, error) {
+	rules, err := rbac.candidateRules(p)
+	if err != nil {
+		return nil, err
+	}
+
+	holders := make([]Role, 0)
+	for _, rule := range rules {
+		roles, err := rbac.store.PermissionRoles(rule)
+		if err != nil {
+			return nil, err
+		}
+		holders = append(holders, roles...)
+	}
+	return rbac.rolesWithDescendants(holders)
+}
+
+// candidateRules returns Permissions which, assigned to Role, can apply to Permission:
+// Permission itself, Permissions with implying Actions, Permissions on Object ancestors
+// and registered wildcard Permissions matching any of them.
+// Exact flags are ignored, so some of returned rules may not apply.
+// Caller has to hold the mutex.
+func (rbac *RBAC) candidateRules(p Permission) ([]Permission, error) {
+	implying, err := rbac.implyingPermissions(p)
+	if err != nil {
+		return nil, err
+	}
+	wildcards, err := rbac.store.WildcardPermissions()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[Permission]struct{})
+	out := make([]Permission, 0)
+	add := func(rule Permission) {
+		if _, ok := seen[rule]; ok {
+			return
+		}
+		seen[rule] = struct{}{}
+		out = append(out, rule)
+	}
+	for _, q := range append([]Permission{p}, implying...) {
+		for _, a := range append([]Permission{q}, rbac.ancestorPermissions(q)...) {
+			add(a)
+			for _, w := range wildcards {
+				if w.Match(a) {
+					add(w)
+				}
+			}
+		}
+	}
+	return out, nil
+}
+
+func sortUsers(users []User) {
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].id < users[j].id
+	})
+}
+
+func sortPermissions(perms []Permission) {
+	sort.Slice(perms, func(i, j int) bool {
+		if perms[i].object != perms[j].object {
+			return perms[i].object < perms[j].object
+		}
+		return perms[i].action < perms[j].action
+	})
+}
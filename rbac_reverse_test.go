@@ -0,0 +1,196 @@
+package rbac
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRolesWithPermission(t *testing.T) {
+	rbac := newTestRBAC()
+
+	read := NewPermission(NewObject("invoice"), NewAction("read"))
+	write := NewPermission(NewObject("invoice"), NewAction("write"))
+
+	// case 1: permission is not registered
+	_, err := rbac.RolesWithPermission(NewPermission(NewObject("invoice"), NewAction("delete")))
+	if err != ErrorPermissionNotRegistered {
+		t.Errorf("[case 1] query error: expected err equal %v, got %v", ErrorPermissionNotRegistered, err)
+	}
+
+	// case 2: direct and inherited grants
+	roles, err := rbac.RolesWithPermission(read)
+	if got := fmt.Sprint(roles); err != nil || got != "[{auditor} {editor} {reader}]" {
+		t.Errorf("[case 2] invalid output: expected [{auditor} {editor} {reader}], got %v (err %v)", got, err)
+	}
+
+	// case 3: deny excludes role and its children
+	rbac.DenyPermissionToRole(NewRole("reader"), read)
+
+	roles, err = rbac.RolesWithPermission(read)
+	if got := fmt.Sprint(roles); err != nil || got != "[{editor}]" {
+		t.Errorf("[case 3] invalid output: expected [{editor}], got %v (err %v)", got, err)
+	}
+
+	// case 4: wildcard and implied grants
+	rbac.RemoveDenyFromRole(NewRole("reader"), read)
+	all := NewPermission(NewObject("*"), NewAction("write"))
+	rbac.RegisterPermission(all)
+	rbac.AssignPermissionToRole(NewRole("auditor"), all)
+	rbac.DeclareActionImplies(NewAction("write"), NewAction("approve"))
+
+	roles, err = rbac.RolesWithPermission(write)
+	if got := fmt.Sprint(roles); err != nil || got != "[{auditor} {editor}]" {
+		t.Errorf("[case 4] invalid output: expected [{auditor} {editor}], got %v (err %v)", got, err)
+	}
+
+	roles, err = rbac.RolesWithPermission(NewPermission(NewObject("invoice"), NewAction("approve")))
+	if got := fmt.Sprint(roles); err != nil || got != "[{auditor} {editor}]" {
+		t.Errorf("[case 4] invalid output: expected [{auditor} {editor}], got %v (err %v)", got, err)
+	}
+
+	// case 5: grant on object ancestor
+	rbac.SetObjectSeparator("/")
+
+	roles, err = rbac.RolesWithPermission(NewPermission(NewObject("invoice/42"), NewAction("read")))
+	if got := fmt.Sprint(roles); err != nil || got != "[{auditor} {editor} {reader}]" {
+		t.Errorf("[case 5] invalid output: expected [{auditor} {editor} {reader}], got %v (err %v)", got, err)
+	}
+}
+
+func TestUsersWithPermission(t *testing.T) {
+	rbac := newTestRBAC()
+
+	read := NewPermission(NewObject("invoice"), NewAction("read"))
+	write := NewPermission(NewObject("invoice"), NewAction("write"))
+
+	// case 1: permission is not registered
+	_, err := rbac.UsersWithPermission(NewPermission(NewObject("invoice"), NewAction("delete")))
+	if err != ErrorPermissionNotRegistered {
+		t.Errorf("[case 1] query error: expected err equal %v, got %v", ErrorPermissionNotRegistered, err)
+	}
+
+	// case 2: users with direct and inherited grants
+	users, err := rbac.UsersWithPermission(read)
+	if got := fmt.Sprint(users); err != nil || got != "[{alice} {bob}]" {
+		t.Errorf("[case 2] invalid output: expected [{alice} {bob}], got %v (err %v)", got, err)
+	}
+
+	users, err = rbac.UsersWithPermission(write)
+	if got := fmt.Sprint(users); err != nil || got != "[{alice}]" {
+		t.Errorf("[case 2] invalid output: expected [{alice}], got %v (err %v)", got, err)
+	}
+
+	// case 3: deny of other user role overrides grant
+	rbac.RegisterRole(NewRole("suspended"))
+	rbac.DenyPermissionToRole(NewRole("suspended"), write)
+	rbac.AssignRoleToUser(NewUser("alice"), NewRole("suspended"))
+
+	users, err = rbac.UsersWithPermission(write)
+	if err != nil || len(users) != 0 {
+		t.Errorf("[case 3] invalid output: expected [], got %v (err %v)", users, err)
+	}
+
+	// case 4: expired assignment does not count
+	clock := &testClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	rbac.SetClock(clock.Now)
+	rbac.AssignRoleToUserUntil(NewUser("carol"), NewRole("reader"), clock.now.Add(time.Hour))
+
+	users, err = rbac.UsersWithPermission(read)
+	if got := fmt.Sprint(users); err != nil || got != "[{alice} {bob} {carol}]" {
+		t.Errorf("[case 4] invalid output: expected [{alice} {bob} {carol}], got %v (err %v)", got, err)
+	}
+
+	clock.now = clock.now.Add(2 * time.Hour)
+
+	users, err = rbac.UsersWithPermission(read)
+	if got := fmt.Sprint(users); err != nil || got != "[{alice} {bob}]" {
+		t.Errorf("[case 4] invalid output: expected [{alice} {bob}], got %v (err %v)", got, err)
+	}
+
+	// case 5: roles in other domains do not count
+	d := NewDomain(defaultDomainID)
+	rbac.AssignRoleToUserInDomain(NewUser("carol"), NewRole("editor"), d)
+
+	users, err = rbac.UsersWithPermissionInDomain(write, d)
+	if got := fmt.Sprint(users); err != nil || got != "[{carol}]" {
+		t.Errorf("[case 5] invalid output: expected [{carol}], got %v (err %v)", got, err)
+	}
+}
+
+func TestUsersWithRole(t *testing.T) {
+	rbac := newTestRBAC()
+
+	// case 1: role is not registered
+	_, err := rbac.UsersWithRole(NewRole("admin"))
+	if err != ErrorRoleNotRegistered {
+		t.Errorf("[case 1] query error: expected err equal %v, got %v", ErrorRoleNotRegistered, err)
+	}
+
+	// case 2: direct assignments only
+	users, err := rbac.UsersWithRole(NewRole("reader"))
+	if err != nil || len(users) != 0 {
+		t.Errorf("[case 2] invalid output: expected [], got %v (err %v)", users, err)
+	}
+
+	rbac.AssignRoleToUser(NewUser("carol"), NewRole("auditor"))
+
+	users, err = rbac.UsersWithRole(NewRole("auditor"))
+	if got := fmt.Sprint(users); err != nil || got != "[{bob} {carol}]" {
+		t.Errorf("[case 2] invalid output: expected [{bob} {carol}], got %v (err %v)", got, err)
+	}
+
+	// case 3: removed assignments and users are dropped
+	rbac.RemoveRoleFromUser(NewUser("carol"), NewRole("auditor"))
+	rbac.RemoveUser(NewUser("bob"))
+
+	users, err = rbac.UsersWithRole(NewRole("auditor"))
+	if err != nil || len(users) != 0 {
+		t.Errorf("[case 3] invalid output: expected [], got %v (err %v)", users, err)
+	}
+
+	// case 4: assignment in domain
+	d := NewDomain(defaultDomainID)
+	rbac.AssignRoleToUserInDomain(NewUser("carol"), NewRole("editor"), d)
+
+	users, err = rbac.UsersWithRoleInDomain(NewRole("editor"), d)
+	if got := fmt.Sprint(users); err != nil || got != "[{carol}]" {
+		t.Errorf("[case 4] invalid output: expected [{carol}], got %v (err %v)", got, err)
+	}
+}
+
+func TestListUserPermissions(t *testing.T) {
+	rbac := newTestRBAC()
+
+	// case 1: user is not registered
+	_, err := rbac.ListUserPermissions(NewUser("dave"))
+	if err != ErrorUserNotRegistered {
+		t.Errorf("[case 1] list error: expected err equal %v, got %v", ErrorUserNotRegistered, err)
+	}
+
+	// case 2: user without roles
+	perms, err := rbac.ListUserPermissions(NewUser("carol"))
+	if err != nil || len(perms) != 0 {
+		t.Errorf("[case 2] invalid output: expected [], got %v (err %v)", perms, err)
+	}
+
+	// case 3: permissions of direct and inherited roles
+	perms, err = rbac.ListUserPermissions(NewUser("alice"))
+	if got := fmt.Sprint(perms); err != nil || got != "[{invoice read} {invoice write}]" {
+		t.Errorf("[case 3] invalid output: expected [{invoice read} {invoice write}], got %v (err %v)", got, err)
+	}
+
+	perms, err = rbac.ListUserPermissions(NewUser("bob"))
+	if got := fmt.Sprint(perms); err != nil || got != "[{invoice read}]" {
+		t.Errorf("[case 3] invalid output: expected [{invoice read}], got %v (err %v)", got, err)
+	}
+
+	// case 4: implied permissions are listed, denied are not
+	rbac.DeclareActionImplies(NewAction("write"), NewAction("approve"))
+	rbac.DenyPermissionToRole(NewRole("editor"), NewPermission(NewObject("invoice"), NewAction("read")))
+
+	perms, err = rbac.ListUserPermissions(NewUser("alice"))
+	if got := fmt.Sprint(perms); err != nil || got != "[{invoice approve} {invoice write}]" {
+		t.Errorf("[case 4] invalid output: expected [{invoice approve} {invoice write}], got %v (err %v)", got, err)
+	}
+}
@@ -2,101 +2,126 @@ package rbac
 
 // RegisterRole registers new Role in RBAC controller.
 // Returns false if such Role already registered.
+// Store errors are dropped, use RegisterRoleErr to get them.
 func (rbac *RBAC) RegisterRole(r Role) bool {
+	ok, _ := rbac.RegisterRoleErr(r)
+	return ok
+}
+
+// RegisterRoleErr is RegisterRole returning Store errors.
+func (rbac *RBAC) RegisterRoleErr(r Role) (bool, error) {
 	rbac.mutex.Lock()
-	defer rbac.mutex.Unlock()
+	defer rbac.unlock()
 
-	_, ok := rbac.registeredRoles[r]
-	if ok {
-		return false
-	}
-	rbac.registeredRoles[r] = struct{}{}
-	return true
+	return rbac.store.AddRole(r)
 }
 
 // RemoveRole removes Role from RBAC controller registered roles list.
-// Will also remove this Role from all Users.
+// Will also remove this Role from all Users and from role hierarchy, along with its conditional Permissions.
+// Separation of duty constraints which can not be violated without this Role are removed.
 // Returns false if no such Role were registered in controller.
+// Store errors are dropped, use RemoveRoleErr to get them.
 func (rbac *RBAC) RemoveRole(r Role) bool {
+	ok, _ := rbac.RemoveRoleErr(r)
+	return ok
+}
+
+// RemoveRoleErr is RemoveRole returning Store errors.
+func (rbac *RBAC) RemoveRoleErr(r Role) (bool, error) {
 	rbac.mutex.Lock()
-	defer rbac.mutex.Unlock()
+	defer rbac.unlock()
 
-	_, ok := rbac.registeredRoles[r]
-	if !ok {
-		return false
-	}
+	return rbac.removeRole(r)
+}
 
-	// removing Role from all Users
-	for user, roles := range rbac.roles2users {
-		if _, ok := roles[r]; ok {
-			delete(roles, r)
-			rbac.roles2users[user] = roles
-		}
+// removeRole is RemoveRole without locking.
+// Caller has to hold the mutex.
+func (rbac *RBAC) removeRole(r Role) (bool, error) {
+	removed, err := rbac.store.RemoveRole(r)
+	if err != nil {
+		return false, err
 	}
-
-	delete(rbac.registeredRoles, r)
-	return true
+	for p := range rbac.conditions[r] {
+		rbac.dropCondition(r, p)
+	}
+	return removed, nil
 }
 
-// ListRoles returns all registered Roles
+// ListRoles returns all registered Roles.
+// Store errors are dropped, use ListRolesErr to get them.
 func (rbac *RBAC) ListRoles() []Role {
+	list, _ := rbac.ListRolesErr()
+	return list
+}
+
+// ListRolesErr is ListRoles returning Store errors.
+func (rbac *RBAC) ListRolesErr() ([]Role, error) {
 	rbac.mutex.RLock()
 	defer rbac.mutex.RUnlock()
 
-	out := make([]Role, 0, len(rbac.registeredRoles))
-	for r := range rbac.registeredRoles {
-		out = append(out, r)
-	}
-	return out
+	return rbac.store.Roles()
 }
 
-// RoleExists checks if Role is registered in RBAC controller
+// RoleExists checks if Role is registered in RBAC controller.
+// Store errors are dropped, use RoleExistsErr to get them.
 func (rbac *RBAC) RoleExists(r Role) bool {
+	ok, _ := rbac.RoleExistsErr(r)
+	return ok
+}
+
+// RoleExistsErr is RoleExists returning Store errors.
+func (rbac *RBAC) RoleExistsErr(r Role) (bool, error) {
 	rbac.mutex.RLock()
 	defer rbac.mutex.RUnlock()
 
-	_, ok := rbac.registeredRoles[r]
-	return ok
+	return rbac.store.HasRole(r)
 }
 
-// ListRolePermissions returns all Permissions assigned to Role.
+// ListRolePermissions returns all Permissions assigned to Role, including inherited from parent Roles.
 // Role has to be registered.
 func (rbac *RBAC) ListRolePermissions(r Role) ([]Permission, error) {
 	rbac.mutex.RLock()
 	defer rbac.mutex.RUnlock()
 
-	_, ok := rbac.registeredRoles[r]
-	if !ok {
-		return nil, ErrorRoleNotRegistered
-	}
-	rolePerms, ok := rbac.perms2roles[r]
-	if !ok {
-		return []Permission{}, nil
-	}
-	out := make([]Permission, 0, len(rolePerms))
-	for p := range rolePerms {
-		out = append(out, p)
+	return rbac.listRolePermissions(r)
+}
+
+// listRolePermissions is ListRolePermissions without locking.
+// Caller has to hold the mutex.
+func (rbac *RBAC) listRolePermissions(r Role) ([]Permission, error) {
+	if err := rbac.checkRole(r); err != nil {
+		return nil, err
 	}
-	return out, nil
+	return rbac.collectPermissions(r, rbac.store.RolePermissions)
 }
 
-// RoleHasPermission checks if Permission is assigned to Role
-// Both Role and Permission has to be registered.
+// RoleHasPermission checks if Permission is assigned to Role or to any of its ancestors
+// and is not denied to any of them.
+// Wildcard Permissions assigned to Roles are matched against provided Permission.
+// Role has to be registered, Permission has to be registered or matched by registered wildcard Permission.
 func (rbac *RBAC) RoleHasPermission(r Role, p Permission) (bool, error) {
 	rbac.mutex.RLock()
 	defer rbac.mutex.RUnlock()
 
-	_, ok := rbac.registeredRoles[r]
-	if !ok {
-		return false, ErrorRoleNotRegistered
+	return rbac.roleHasPermission(r, p)
+}
+
+// roleHasPermission checks if Role along with its ancestors allows Permission.
+// Caller has to hold the mutex.
+func (rbac *RBAC) roleHasPermission(r Role, p Permission) (bool, error) {
+	if err := rbac.checkRole(r); err != nil {
+		return false, err
 	}
-	_, ok = rbac.registeredPermissions[p]
-	if !ok {
-		return false, ErrorPermissionNotRegistered
+	wildcards, err := rbac.checkPermissionMatch(p)
+	if err != nil {
+		return false, err
 	}
 
-	_, ok = rbac.perms2roles[r][p]
-	return ok, nil
+	roles, err := rbac.roleAncestors(r)
+	if err != nil {
+		return false, err
+	}
+	return rbac.rolesAllow(roles, p, wildcards)
 }
 
 // AssignPermissionToRole assigns Permission to Role.
@@ -104,32 +129,21 @@ func (rbac *RBAC) RoleHasPermission(r Role, p Permission) (bool, error) {
 // Returns false if Permission already assigned to Role.
 func (rbac *RBAC) AssignPermissionToRole(r Role, p Permission) (bool, error) {
 	rbac.mutex.Lock()
-	defer rbac.mutex.Unlock()
+	defer rbac.unlock()
 
-	_, ok := rbac.registeredRoles[r]
-	if !ok {
-		return false, ErrorRoleNotRegistered
-	}
-
-	_, ok = rbac.registeredPermissions[p]
-	if !ok {
-		return false, ErrorPermissionNotRegistered
-	}
+	return rbac.assignPermissionToRole(r, p)
+}
 
-	rolePerms, ok := rbac.perms2roles[r]
-	if !ok {
-		rolePerms = make(map[Permission]struct{})
-		rolePerms[p] = struct{}{}
-		rbac.perms2roles[r] = rolePerms
-		return true, nil
+// assignPermissionToRole is AssignPermissionToRole without locking.
+// Caller has to hold the mutex.
+func (rbac *RBAC) assignPermissionToRole(r Role, p Permission) (bool, error) {
+	if err := rbac.checkRole(r); err != nil {
+		return false, err
 	}
-	_, ok = rolePerms[p]
-	if ok {
-		return false, nil
+	if err := rbac.checkPermission(p); err != nil {
+		return false, err
 	}
-	rolePerms[p] = struct{}{}
-	rbac.perms2roles[r] = rolePerms
-	return true, nil
+	return rbac.store.AddRolePermission(r, p)
 }
 
 // RemovePermissionFromRole removes Permission from Role.
@@ -137,23 +151,40 @@ func (rbac *RBAC) AssignPermissionToRole(r Role, p Permission) (bool, error) {
 // Returns false if Permission was not assigned to Role.
 func (rbac *RBAC) RemovePermissionFromRole(r Role, p Permission) (bool, error) {
 	rbac.mutex.Lock()
-	defer rbac.mutex.Unlock()
+	defer rbac.unlock()
 
-	_, ok := rbac.registeredRoles[r]
-	if !ok {
-		return false, ErrorRoleNotRegistered
-	}
+	return rbac.removePermissionFromRole(r, p)
+}
 
-	_, ok = rbac.registeredPermissions[p]
-	if !ok {
-		return false, ErrorPermissionNotRegistered
+// removePermissionFromRole is RemovePermissionFromRole without locking.
+// Caller has to hold the mutex.
+func (rbac *RBAC) removePermissionFromRole(r Role, p Permission) (bool, error) {
+	if err := rbac.checkRole(r); err != nil {
+		return false, err
 	}
+	if err := rbac.checkPermission(p); err != nil {
+		return false, err
+	}
+	return rbac.store.RemoveRolePermission(r, p)
+}
 
-	_, ok = rbac.perms2roles[r][p]
-	if !ok {
-		return false, nil
+// collectPermissions returns union of Permissions related by list to Role and to all its ancestors.
+// Caller has to hold the mutex.
+func (rbac *RBAC) collectPermissions(r Role, list func(Role) ([]Permission, error)) ([]Permission, error) {
+	roles, err := rbac.roleAncestors(r)
+	if err != nil {
+		return nil, err
 	}
 
-	delete(rbac.perms2roles[r], p)
-	return true, nil
-}
\ No newline at end of file
+	perms := make(map[Permission]struct{})
+	for ancestor := range roles {
+		list, err := list(ancestor)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range list {
+			perms[p] = struct{}{}
+		}
+	}
+	return permissionsOf(perms), nil
+}
@@ -16,12 +16,12 @@ func TestRegisterRole(t *testing.T) {
 		t.Errorf("[case 1] invalid output: expected %t got %t ", true, ok)
 	}
 
-	if _, ok := rbac.registeredRoles[r]; !ok {
+	if _, ok := memory(rbac).registeredRoles[r]; !ok {
 		t.Errorf("[case 1] role %v not presented in registeredRoles", r)
 	}
 
 	// case 2: role is registered
-	rbac.registeredRoles[r] = struct{}{}
+	memory(rbac).registeredRoles[r] = struct{}{}
 
 	ok = rbac.RegisterRole(r)
 	if ok {
@@ -42,32 +42,32 @@ func TestRemoveRole(t *testing.T) {
 	}
 
 	// case 2 : role is registered, no assigning to user
-	rbac.registeredRoles[r] = struct{}{}
+	memory(rbac).registeredRoles[r] = struct{}{}
 
 	exist = rbac.RemoveRole(r)
 	if !exist {
 		t.Errorf("[case 2] invalid output: expected %t got %t ", true, exist)
 	}
 
-	if _, ok := rbac.registeredRoles[r]; ok {
+	if _, ok := memory(rbac).registeredRoles[r]; ok {
 		t.Errorf("[case 2] role were not removed from registered role list")
 	}
 
 	// case 3: role is registered, assigned to user
-	rbac.registeredRoles[r] = struct{}{}
-	rbac.registeredUsers[u] = struct{}{}
-	rbac.roles2users[u] = map[Role]struct{}{r:{}}
+	rbac.RegisterRole(r)
+	rbac.RegisterUser(u)
+	rbac.AssignRoleToUser(u, r)
 
 	exist = rbac.RemoveRole(r)
 	if !exist {
 		t.Errorf("[case 3] invalid output: expected %t got %t ", true, exist)
 	}
 
-	if _, ok := rbac.registeredRoles[r]; ok {
+	if _, ok := memory(rbac).registeredRoles[r]; ok {
 		t.Errorf("[case 3] role were not removed from registered role list")
 	}
 
-	if _, ok := rbac.roles2users[u][r]; ok {
+	if _, ok := memory(rbac).roles2users[u][DefaultDomain][r]; ok {
 		t.Errorf("[case 3] role were not unassigned from user")
 	}
 }
@@ -176,7 +176,7 @@ func TestAssignPermissionToRole(t *testing.T) {
 		t.Errorf("[case 3] invalid output: expected %v, got %v", true, ok)
 	}
 
-	if _, ok := rbac.perms2roles[r][p]; !ok {
+	if _, ok := memory(rbac).perms2roles[r][p]; !ok {
 		t.Errorf("[case 3] assign error: permission were not assigned to role")
 	}
 
@@ -184,7 +184,7 @@ func TestAssignPermissionToRole(t *testing.T) {
 	rbac.RegisterRole(r)
 	rbac.RegisterPermission(p)
 
-	rbac.perms2roles[r]=map[Permission]struct{}{p:{}}
+	memory(rbac).perms2roles[r]=map[Permission]struct{}{p:{}}
 
 	ok, err = rbac.AssignPermissionToRole(r, p)
 	if err != nil {
@@ -378,7 +378,7 @@ func TestRemovePermissionFromRole(t *testing.T) {
 		t.Errorf("[case 4] invalid output: expected %t, got %t", true, ok)
 	}
 
-	if _, ok := rbac.perms2roles[r][p]; ok {
+	if _, ok := memory(rbac).perms2roles[r][p]; ok {
 		t.Errorf("[case 4] permission is not removed from role")
 	}
 }
\ No newline at end of file
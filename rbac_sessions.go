@@ -0,0 +1,53 @@
+package rbac
+
+// CreateSession creates Session of User in DefaultDomain with provided Roles active.
+// User has to be registered, Roles has to be assigned to User and in effect.
+// Returns *DSDViolationError if Roles can not be active together.
+func (rbac *RBAC) CreateSession(u User, roles ...Role) (*Session, error) {
+	return rbac.CreateSessionInDomain(u, DefaultDomain, roles...)
+}
+
+// CreateSessionInDomain creates Session of User in Domain with provided Roles active.
+// User has to be registered, Roles has to be assigned to User in Domain and in effect.
+// Returns *DSDViolationError if Roles can not be active together.
+func (rbac *RBAC) CreateSessionInDomain(u User, d Domain, roles ...Role) (*Session, error) {
+	rbac.mutex.RLock()
+	defer rbac.mutex.RUnlock()
+
+	return rbac.createSessionInDomain(u, d, roles...)
+}
+
+// createSessionInDomain is CreateSessionInDomain without locking.
+// Caller has to hold the mutex.
+func (rbac *RBAC) createSessionInDomain(u User, d Domain, roles ...Role) (*Session, error) {
+	if err := rbac.checkUser(u); err != nil {
+		return nil, err
+	}
+	if err := rbac.checkSessionRoles(u, d, roles); err != nil {
+		return nil, err
+	}
+	if err := rbac.checkDSD(u, roles); err != nil {
+		return nil, err
+	}
+
+	active := make(map[Role]struct{}, len(roles))
+	for _, r := range roles {
+		active[r] = struct{}{}
+	}
+	return &Session{rbac: rbac, user: u, domain: d, active: active}, nil
+}
+
+// checkSessionRoles returns ErrorRoleNotAssigned if any of Roles is not assigned to User in Domain or not in effect.
+// Caller has to hold the mutex.
+func (rbac *RBAC) checkSessionRoles(u User, d Domain, roles []Role) error {
+	for _, r := range roles {
+		ok, err := rbac.userHasRole(u, r, d)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrorRoleNotAssigned
+		}
+	}
+	return nil
+}
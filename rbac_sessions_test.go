@@ -0,0 +1,167 @@
+package rbac
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCreateSession(t *testing.T) {
+	rbac := NewRBAC()
+
+	u := NewUser(defaultUserID)
+	cashier := NewRole("cashier")
+	auditor := NewRole("auditor")
+
+	rbac.RegisterUser(u)
+	rbac.RegisterRole(cashier)
+	rbac.RegisterRole(auditor)
+	rbac.AssignRoleToUser(u, cashier)
+	rbac.AssignRoleToUser(u, auditor)
+
+	// case 1: user is not registered
+	_, err := rbac.CreateSession(NewUser("unknown"))
+	if err != ErrorUserNotRegistered {
+		t.Errorf("[case 1] create error: expected err equal %v, got %v", ErrorUserNotRegistered, err)
+	}
+
+	// case 2: role is not assigned
+	other := NewRole("other")
+	rbac.RegisterRole(other)
+
+	_, err = rbac.CreateSession(u, other)
+	if err != ErrorRoleNotAssigned {
+		t.Errorf("[case 2] create error: expected err equal %v, got %v", ErrorRoleNotAssigned, err)
+	}
+
+	// case 3: roles can not be active together
+	rbac.AddDSDConstraint("till", []Role{cashier, auditor}, 2)
+
+	_, err = rbac.CreateSession(u, cashier, auditor)
+	var dsdErr *DSDViolationError
+	if !errors.As(err, &dsdErr) || dsdErr.Constraint != "till" {
+		t.Errorf("[case 3] create error: expected *DSDViolationError, got %v", err)
+	}
+
+	// case 4: session is created
+	s, err := rbac.CreateSession(u, cashier)
+	if err != nil {
+		t.Fatalf("[case 4] create error: expected err equal nil, got %v", err)
+	}
+
+	if s.User() != u || s.Domain() != DefaultDomain {
+		t.Errorf("[case 4] invalid output: expected session of %v in default domain, got %v in %v", u, s.User(), s.Domain())
+	}
+
+	if roles := s.ActiveRoles(); len(roles) != 1 || roles[0] != cashier {
+		t.Errorf("[case 4] invalid output: expected [%v], got %v", cashier, roles)
+	}
+}
+
+func TestSessionActiveRoles(t *testing.T) {
+	rbac := NewRBAC()
+
+	u := NewUser(defaultUserID)
+	cashier := NewRole("cashier")
+	auditor := NewRole("auditor")
+	p := NewPermission(NewObject("till"), NewAction("open"))
+
+	rbac.RegisterUser(u)
+	rbac.RegisterRole(cashier)
+	rbac.RegisterRole(auditor)
+	rbac.RegisterPermission(p)
+	rbac.AssignPermissionToRole(cashier, p)
+	rbac.AssignRoleToUser(u, cashier)
+	rbac.AssignRoleToUser(u, auditor)
+	rbac.AddDSDConstraint("till", []Role{cashier, auditor}, 2)
+
+	s, _ := rbac.CreateSession(u, auditor)
+
+	// case 1: no active role has permission
+	ok, err := s.HasPermission(p)
+	if err != nil || ok {
+		t.Errorf("[case 1] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+
+	// case 2: activation violates constraint
+	_, err = s.AddActiveRole(cashier)
+	var dsdErr *DSDViolationError
+	if !errors.As(err, &dsdErr) {
+		t.Errorf("[case 2] activation error: expected *DSDViolationError, got %v", err)
+	}
+
+	// case 3: roles are switched
+	if !s.DropActiveRole(auditor) {
+		t.Errorf("[case 3] invalid output: expected %t, got %t", true, false)
+	}
+
+	ok, err = s.AddActiveRole(cashier)
+	if err != nil || !ok {
+		t.Errorf("[case 3] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	ok, err = s.HasObjectAction(p.Object(), p.Action())
+	if err != nil || !ok {
+		t.Errorf("[case 3] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	// case 4: role is already active
+	ok, err = s.AddActiveRole(cashier)
+	if err != nil || ok {
+		t.Errorf("[case 4] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+
+	// case 5: role is removed from user after activation
+	rbac.RemoveRoleFromUser(u, cashier)
+
+	ok, err = s.HasPermission(p)
+	if err != nil || ok {
+		t.Errorf("[case 5] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+
+	// case 6: role is not active
+	if s.DropActiveRole(auditor) {
+		t.Errorf("[case 6] invalid output: expected %t, got %t", false, true)
+	}
+}
+
+func TestAddDSDConstraint(t *testing.T) {
+	rbac := NewRBAC()
+
+	u := NewUser(defaultUserID)
+	cashier := NewRole("cashier")
+	auditor := NewRole("auditor")
+
+	rbac.RegisterUser(u)
+	rbac.RegisterRole(cashier)
+	rbac.RegisterRole(auditor)
+	rbac.AssignRoleToUser(u, cashier)
+	rbac.AssignRoleToUser(u, auditor)
+
+	// case 1: constraint can not be violated
+	_, err := rbac.AddDSDConstraint("till", []Role{cashier, auditor}, 3)
+	if err != ErrorInvalidSSDConstraint {
+		t.Errorf("[case 1] add error: expected err equal %v, got %v", ErrorInvalidSSDConstraint, err)
+	}
+
+	// case 2: assignments are not limited
+	ok, err := rbac.AddDSDConstraint("till", []Role{cashier, auditor}, 2)
+	if err != nil || !ok {
+		t.Errorf("[case 2] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	constraints, err := rbac.ListDSDConstraints()
+	if err != nil || len(constraints) != 1 || constraints[0].Name != "till" {
+		t.Errorf("[case 2] invalid output: expected [till], got %v (err %v)", constraints, err)
+	}
+
+	// case 3: constraint is removed
+	ok, err = rbac.RemoveDSDConstraint("till")
+	if err != nil || !ok {
+		t.Errorf("[case 3] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	ok, err = rbac.RemoveDSDConstraint("till")
+	if err != nil || ok {
+		t.Errorf("[case 3] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+}
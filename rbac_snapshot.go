@@ -0,0 +1,72 @@
+package rbac
+
+// Snapshot is consistent read-only view of Users, their Role assignments and effective Permissions,
+// taken by RBAC.Snapshot. Snapshot is never modified, so its methods take no lock and never wait
+// for writers of the controller, it can be shared by any number of goroutines.
+// Checks follow the same rules as checks of the controller. Assignments Validity is evaluated
+// at the time of check against the clock the controller had when Snapshot was taken.
+// Conditional Permissions, reverse queries and export are answered by controller only.
+type Snapshot struct {
+	view *view
+}
+
+// Snapshot returns current content of controller as Snapshot.
+// Controller keeping effective permission index (see ResetEffectivePermissions) publishes immutable version
+// of it on every change, which is returned as is, so taking Snapshot costs nothing.
+// Snapshot of controller backed by other Store is built out of Store under read lock.
+func (rbac *RBAC) Snapshot() (*Snapshot, error) {
+	if v := rbac.effective.load(); v != nil {
+		return &Snapshot{view: v}, nil
+	}
+
+	rbac.mutex.RLock()
+	defer rbac.mutex.RUnlock()
+
+	v, err := rbac.buildView()
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshot{view: v}, nil
+}
+
+// UserHasPermission checks if Roles assigned to User in DefaultDomain allow Permission.
+// Evaluation follows RBAC.UserHasPermission rules.
+func (s *Snapshot) UserHasPermission(u User, p Permission) (bool, error) {
+	return s.view.userHasPermission(u, p, DefaultDomain)
+}
+
+// UserHasPermissionInDomain checks if Roles assigned to User in Domain allow Permission.
+// Evaluation follows RBAC.UserHasPermission rules.
+func (s *Snapshot) UserHasPermissionInDomain(u User, p Permission, d Domain) (bool, error) {
+	return s.view.userHasPermission(u, p, d)
+}
+
+// UserHasObjectAction checks if Roles assigned to User in DefaultDomain allow provided Object and Action.
+func (s *Snapshot) UserHasObjectAction(u User, o Object, a Action) (bool, error) {
+	return s.view.userHasPermission(u, NewPermission(o, a), DefaultDomain)
+}
+
+// UserHasObjectActionInDomain checks if Roles assigned to User in Domain allow provided Object and Action.
+func (s *Snapshot) UserHasObjectActionInDomain(u User, o Object, a Action, d Domain) (bool, error) {
+	return s.view.userHasPermission(u, NewPermission(o, a), d)
+}
+
+// UserHasRole checks if Role is assigned to User in DefaultDomain and currently in effect.
+func (s *Snapshot) UserHasRole(u User, r Role) (bool, error) {
+	return s.view.userHasRole(u, r, DefaultDomain)
+}
+
+// UserHasRoleInDomain checks if Role is assigned to User in Domain and currently in effect.
+func (s *Snapshot) UserHasRoleInDomain(u User, r Role, d Domain) (bool, error) {
+	return s.view.userHasRole(u, r, d)
+}
+
+// ListUserRoles returns all Roles assigned to User in DefaultDomain and currently in effect.
+func (s *Snapshot) ListUserRoles(u User) ([]Role, error) {
+	return s.view.listUserRoles(u, DefaultDomain)
+}
+
+// ListUserRolesInDomain returns all Roles assigned to User in Domain and currently in effect.
+func (s *Snapshot) ListUserRolesInDomain(u User, d Domain) ([]Role, error) {
+	return s.view.listUserRoles(u, d)
+}
@@ -0,0 +1,260 @@
+package rbac
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+	rbac := newTestRBAC()
+
+	alice, bob := NewUser("alice"), NewUser("bob")
+	editor := NewRole("editor")
+	read := NewPermission(NewObject("invoice"), NewAction("read"))
+	write := NewPermission(NewObject("invoice"), NewAction("write"))
+
+	rbac.SetObjectSeparator("/")
+	rbac.DeclareActionImplies(NewAction("write"), NewAction("approve"))
+
+	s, err := rbac.Snapshot()
+	if err != nil {
+		t.Fatalf("snapshot error: expected err equal nil, got %v", err)
+	}
+
+	// case 1: snapshot answers as controller does
+	ok, err := s.UserHasPermission(alice, write)
+	if err != nil || !ok {
+		t.Errorf("[case 1] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	ok, err = s.UserHasObjectAction(alice, NewObject("invoice/42"), NewAction("approve"))
+	if err != nil || !ok {
+		t.Errorf("[case 1] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	ok, err = s.UserHasPermission(bob, write)
+	if err != nil || ok {
+		t.Errorf("[case 1] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+
+	_, err = s.UserHasPermission(NewUser("dave"), read)
+	if err != ErrorUserNotRegistered {
+		t.Errorf("[case 1] check error: expected err equal %v, got %v", ErrorUserNotRegistered, err)
+	}
+
+	roles, err := s.ListUserRoles(bob)
+	if got := fmt.Sprint(roles); err != nil || got != "[{auditor}]" {
+		t.Errorf("[case 1] invalid output: expected [{auditor}], got %v (err %v)", got, err)
+	}
+
+	// case 2: later mutations of controller do not reach snapshot
+	rbac.RemoveRoleFromUser(alice, editor)
+	rbac.RegisterUser(NewUser("dave"))
+	rbac.RemoveActionImplies(NewAction("write"), NewAction("approve"))
+	rbac.SetObjectSeparator("")
+
+	ok, err = s.UserHasRole(alice, editor)
+	if err != nil || !ok {
+		t.Errorf("[case 2] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	ok, err = s.UserHasObjectAction(alice, NewObject("invoice/42"), NewAction("approve"))
+	if err != nil || !ok {
+		t.Errorf("[case 2] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	_, err = s.UserHasPermission(NewUser("dave"), read)
+	if err != ErrorUserNotRegistered {
+		t.Errorf("[case 2] check error: expected err equal %v, got %v", ErrorUserNotRegistered, err)
+	}
+
+	ok, err = rbac.UserHasRole(alice, editor)
+	if err != nil || ok {
+		t.Errorf("[case 2] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+
+	// case 3: snapshot is the version controller published, nothing is copied
+	first, _ := rbac.Snapshot()
+	second, _ := rbac.Snapshot()
+	if first.view != second.view || first.view != rbac.effective.load() {
+		t.Errorf("[case 3] invalid output: expected snapshots sharing published version")
+	}
+}
+
+func TestSnapshotStore(t *testing.T) {
+	rbac := NewRBACWithStore(&txMemoryStore{MemoryStore: NewMemoryStore()})
+
+	u := NewUser("alice")
+	r := NewRole("reader")
+	p := NewPermission(NewObject("invoice"), NewAction("read"))
+	rbac.RegisterUser(u)
+	rbac.RegisterRole(r)
+	rbac.RegisterPermission(p)
+	rbac.AssignPermissionToRole(r, p)
+	rbac.AssignRoleToUser(u, r)
+
+	// case 1: snapshot of controller keeping no index is built out of Store
+	s, err := rbac.Snapshot()
+	if err != nil {
+		t.Fatalf("[case 1] snapshot error: expected err equal nil, got %v", err)
+	}
+	rbac.RemoveRoleFromUser(u, r)
+
+	ok, err := s.UserHasPermission(u, p)
+	if err != nil || !ok {
+		t.Errorf("[case 1] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+}
+
+func TestUserHasPermissionWithoutLock(t *testing.T) {
+	rbac := benchmarkPolicy(10)
+
+	u := NewUser("user-3")
+	p := NewPermission(NewObject("object-3"), NewAction("action-7"))
+
+	// case 1: check does not wait for writer holding the lock
+	rbac.mutex.Lock()
+	done := make(chan bool)
+	go func() {
+		ok, _ := rbac.UserHasPermission(u, p)
+		done <- ok
+	}()
+	select {
+	case ok := <-done:
+		if !ok {
+			t.Errorf("[case 1] invalid output: expected %t, got %t", true, ok)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("[case 1] check error: check waits for write lock")
+	}
+	rbac.mutex.Unlock()
+}
+
+func TestSnapshotConcurrentWriters(t *testing.T) {
+	rbac := benchmarkPolicy(10)
+
+	u := NewUser("user-3")
+	p := NewPermission(NewObject("object-3"), NewAction("action-7"))
+
+	// writer keeps mutating controller, each change publishes new version
+	stop := startBenchmarkWriter(rbac)
+	defer stop()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				ok, err := rbac.UserHasPermission(u, p)
+				if err != nil || !ok {
+					t.Errorf("invalid output: expected %t, got %t (err %v)", true, ok, err)
+					return
+				}
+				s, err := rbac.Snapshot()
+				if err != nil {
+					t.Errorf("snapshot error: expected err equal nil, got %v", err)
+					return
+				}
+				ok, err = s.UserHasRole(NewUser("user-0"), NewRole("role-0"))
+				if err != nil || !ok {
+					t.Errorf("invalid output: expected %t, got %t (err %v)", true, ok, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// benchmarkPolicy builds controller with n Users, each having one of 10 Roles granting 10 Permissions
+func benchmarkPolicy(n int) *RBAC {
+	rbac := NewRBAC()
+	for i := 0; i < 10; i++ {
+		r := NewRole(fmt.Sprintf("role-%d", i))
+		rbac.RegisterRole(r)
+		for j := 0; j < 10; j++ {
+			p := NewPermission(NewObject(fmt.Sprintf("object-%d", i)), NewAction(fmt.Sprintf("action-%d", j)))
+			rbac.RegisterPermission(p)
+			rbac.AssignPermissionToRole(r, p)
+		}
+	}
+	for i := 0; i < n; i++ {
+		u := NewUser(fmt.Sprintf("user-%d", i))
+		rbac.RegisterUser(u)
+		rbac.AssignRoleToUser(u, NewRole(fmt.Sprintf("role-%d", i%10)))
+	}
+	return rbac
+}
+
+// startBenchmarkWriter keeps reassigning Role of one User until returned function is called
+func startBenchmarkWriter(rbac *RBAC) (stop func()) {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		u, r := NewUser("user-0"), NewRole("role-1")
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			rbac.AssignRoleToUser(u, r)
+			rbac.RemoveRoleFromUser(u, r)
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// BenchmarkUserHasPermissionParallel checks published version of effective permission index without locking
+func BenchmarkUserHasPermissionParallel(b *testing.B) {
+	rbac := benchmarkPolicy(1000)
+	p := NewPermission(NewObject("object-3"), NewAction("action-7"))
+
+	stop := startBenchmarkWriter(rbac)
+	defer stop()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		u := NewUser("user-3")
+		for pb.Next() {
+			rbac.UserHasPermission(u, p)
+		}
+	})
+}
+
+// BenchmarkUserHasPermissionParallelLocked evaluates Store under read lock, as checks did before
+// index was published, so readers wait for writer
+func BenchmarkUserHasPermissionParallelLocked(b *testing.B) {
+	rbac := benchmarkPolicy(1000)
+	p := NewPermission(NewObject("object-3"), NewAction("action-7"))
+
+	stop := startBenchmarkWriter(rbac)
+	defer stop()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		u := NewUser("user-3")
+		for pb.Next() {
+			rbac.mutex.RLock()
+			rbac.evaluateUserPermission(u, p, DefaultDomain)
+			rbac.mutex.RUnlock()
+		}
+	})
+}
+
+func BenchmarkSnapshot(b *testing.B) {
+	rbac := benchmarkPolicy(1000)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rbac.Snapshot()
+	}
+}
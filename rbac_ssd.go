@@ -0,0 +1,136 @@
+package rbac
+
+// AddSSDConstraint adds static separation of duty constraint: no User may hold n or more of roles
+// in the same Domain. Roles inherited through role hierarchy and assignments not yet in effect are counted,
+// expired assignments are not.
+// All Roles has to be registered, n has to be at least 2 and not greater than number of distinct roles.
+// Returns *SSDViolationError if existing assignments already violate the constraint,
+// false if constraint with the same name already exists.
+func (rbac *RBAC) AddSSDConstraint(name string, roles []Role, n int) (bool, error) {
+	rbac.mutex.Lock()
+	defer rbac.unlock()
+
+	return rbac.addSSDConstraint(NewSSDConstraint(name, roles, n))
+}
+
+// RemoveSSDConstraint removes static separation of duty constraint.
+// Returns false if there is no constraint with such name.
+func (rbac *RBAC) RemoveSSDConstraint(name string) (bool, error) {
+	rbac.mutex.Lock()
+	defer rbac.unlock()
+
+	return rbac.store.RemoveSSDConstraint(name)
+}
+
+// ListSSDConstraints returns all static separation of duty constraints
+func (rbac *RBAC) ListSSDConstraints() ([]SSDConstraint, error) {
+	rbac.mutex.RLock()
+	defer rbac.mutex.RUnlock()
+
+	return rbac.store.SSDConstraints()
+}
+
+// addSSDConstraint validates constraint against existing assignments and stores it.
+// Caller has to hold the mutex.
+func (rbac *RBAC) addSSDConstraint(c SSDConstraint) (bool, error) {
+	if !c.valid() {
+		return false, ErrorInvalidSSDConstraint
+	}
+	for _, r := range c.Roles {
+		if err := rbac.checkRole(r); err != nil {
+			return false, err
+		}
+	}
+
+	constraints, err := rbac.store.SSDConstraints()
+	if err != nil {
+		return false, err
+	}
+	for _, existing := range constraints {
+		if existing.Name == c.Name {
+			return false, nil
+		}
+	}
+
+	if err := rbac.checkHoldersSSD([]SSDConstraint{c}, c.Roles); err != nil {
+		return false, err
+	}
+	return rbac.store.AddSSDConstraint(c)
+}
+
+// checkHoldersSSD returns *SSDViolationError if any User holding any of Roles, directly or through
+// Role inheriting it, violates any of constraints. Users holding none of them are not checked.
+// Caller has to hold the mutex.
+func (rbac *RBAC) checkHoldersSSD(constraints []SSDConstraint, roles []Role) error {
+	if len(constraints) == 0 {
+		return nil
+	}
+
+	holders, err := rbac.rolesWithDescendants(roles)
+	if err != nil {
+		return err
+	}
+	checked := make(map[User]map[Domain]struct{})
+	for r := range holders {
+		domains, err := rbac.store.RoleDomains(r)
+		if err != nil {
+			return err
+		}
+		for _, d := range domains {
+			users, err := rbac.store.RoleUsers(r, d)
+			if err != nil {
+				return err
+			}
+			for _, u := range users {
+				if _, ok := checked[u][d]; ok {
+					continue
+				}
+				if checked[u] == nil {
+					checked[u] = make(map[Domain]struct{})
+				}
+				checked[u][d] = struct{}{}
+				if err := rbac.checkSSD(constraints, u, d); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkSSD returns *SSDViolationError if Roles held by User in Domain together with extra Roles violate any of constraints.
+// Caller has to hold the mutex.
+func (rbac *RBAC) checkSSD(constraints []SSDConstraint, u User, d Domain, extra ...Role) error {
+	if len(constraints) == 0 {
+		return nil
+	}
+
+	assigned, err := rbac.store.UserRoles(u, d)
+	if err != nil {
+		return err
+	}
+
+	now := rbac.now()
+	held := make([]Role, 0, len(assigned)+len(extra))
+	for _, r := range assigned {
+		v, err := rbac.store.UserRoleValidity(u, r, d)
+		if err != nil {
+			return err
+		}
+		if !v.ExpiredAt(now) {
+			held = append(held, r)
+		}
+	}
+	held = append(held, extra...)
+
+	roles, err := rbac.rolesWithAncestors(held)
+	if err != nil {
+		return err
+	}
+	for _, c := range constraints {
+		if matched := c.held(roles); len(matched) >= c.N {
+			return &SSDViolationError{Constraint: c.Name, User: u, Domain: d, Roles: matched}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package rbac
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAddSSDConstraint(t *testing.T) {
+	rbac := NewRBAC()
+
+	u := NewUser(defaultUserID)
+	creator := NewRole("payment-creator")
+	approver := NewRole("payment-approver")
+
+	rbac.RegisterUser(u)
+	rbac.RegisterRole(creator)
+	rbac.RegisterRole(approver)
+
+	// case 1: constraint can not be violated
+	_, err := rbac.AddSSDConstraint("payments", []Role{creator, approver}, 1)
+	if err != ErrorInvalidSSDConstraint {
+		t.Errorf("[case 1] add error: expected err equal %v, got %v", ErrorInvalidSSDConstraint, err)
+	}
+
+	_, err = rbac.AddSSDConstraint("payments", []Role{creator, creator}, 2)
+	if err != ErrorInvalidSSDConstraint {
+		t.Errorf("[case 1] add error: expected err equal %v, got %v", ErrorInvalidSSDConstraint, err)
+	}
+
+	// case 2: role is not registered
+	_, err = rbac.AddSSDConstraint("payments", []Role{creator, NewRole("unknown")}, 2)
+	if err != ErrorRoleNotRegistered {
+		t.Errorf("[case 2] add error: expected err equal %v, got %v", ErrorRoleNotRegistered, err)
+	}
+
+	// case 3: existing assignments violate constraint
+	rbac.AssignRoleToUser(u, creator)
+	rbac.AssignRoleToUser(u, approver)
+
+	_, err = rbac.AddSSDConstraint("payments", []Role{creator, approver}, 2)
+	var ssdErr *SSDViolationError
+	if !errors.As(err, &ssdErr) || ssdErr.Constraint != "payments" || ssdErr.User != u {
+		t.Errorf("[case 3] add error: expected *SSDViolationError, got %v", err)
+	}
+
+	constraints, _ := rbac.ListSSDConstraints()
+	if len(constraints) != 0 {
+		t.Errorf("[case 3] add error: violated constraint were added")
+	}
+
+	// case 4: constraint is added
+	rbac.RemoveRoleFromUser(u, approver)
+
+	ok, err := rbac.AddSSDConstraint("payments", []Role{creator, approver}, 2)
+	if err != nil || !ok {
+		t.Errorf("[case 4] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	constraints, err = rbac.ListSSDConstraints()
+	if err != nil || len(constraints) != 1 || constraints[0].Name != "payments" {
+		t.Errorf("[case 4] invalid output: expected [payments], got %v (err %v)", constraints, err)
+	}
+
+	// case 5: constraint with the same name
+	ok, err = rbac.AddSSDConstraint("payments", []Role{creator, approver}, 2)
+	if err != nil || ok {
+		t.Errorf("[case 5] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+}
+
+func TestAssignRoleToUserSSD(t *testing.T) {
+	rbac := NewRBAC()
+
+	u := NewUser(defaultUserID)
+	creator := NewRole("payment-creator")
+	approver := NewRole("payment-approver")
+
+	rbac.RegisterUser(u)
+	rbac.RegisterRole(creator)
+	rbac.RegisterRole(approver)
+	rbac.AddSSDConstraint("payments", []Role{creator, approver}, 2)
+
+	// case 1: single role of constraint
+	ok, err := rbac.AssignRoleToUser(u, creator)
+	if err != nil || !ok {
+		t.Errorf("[case 1] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	// case 2: second role of constraint
+	_, err = rbac.AssignRoleToUser(u, approver)
+	var ssdErr *SSDViolationError
+	if !errors.As(err, &ssdErr) || ssdErr.Constraint != "payments" {
+		t.Errorf("[case 2] assign error: expected *SSDViolationError, got %v", err)
+	}
+
+	if _, ok := memory(rbac).roles2users[u][DefaultDomain][approver]; ok {
+		t.Errorf("[case 2] assign error: violating role were assigned")
+	}
+
+	// case 3: roles in different domains
+	ok, err = rbac.AssignRoleToUserInDomain(u, approver, NewDomain(defaultDomainID))
+	if err != nil || !ok {
+		t.Errorf("[case 3] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	// case 4: expired assignment is not counted
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rbac.SetClock(func() time.Time { return clock })
+	rbac.AssignRoleToUserUntil(u, creator, clock.Add(-time.Hour))
+
+	ok, err = rbac.AssignRoleToUser(u, approver)
+	if err != nil || !ok {
+		t.Errorf("[case 4] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	// case 5: constraint is removed
+	rbac.AssignRoleToUser(u, creator)
+
+	ok, err = rbac.RemoveSSDConstraint("payments")
+	if err != nil || !ok {
+		t.Errorf("[case 5] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	ok, err = rbac.AssignRoleToUser(u, creator)
+	if err != nil || !ok {
+		t.Errorf("[case 5] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+}
+
+func TestAddRoleParentSSD(t *testing.T) {
+	rbac := NewRBAC()
+
+	u := NewUser(defaultUserID)
+	creator := NewRole("payment-creator")
+	approver := NewRole("payment-approver")
+
+	rbac.RegisterUser(u)
+	rbac.RegisterRole(creator)
+	rbac.RegisterRole(approver)
+	rbac.AddSSDConstraint("payments", []Role{creator, approver}, 2)
+
+	senior := NewRole("senior")
+	rbac.RegisterRole(senior)
+	rbac.AddRoleParent(senior, creator)
+
+	// case 1: inherited role is counted
+	rbac.AssignRoleToUser(u, approver)
+
+	_, err := rbac.AssignRoleToUser(u, senior)
+	var ssdErr *SSDViolationError
+	if !errors.As(err, &ssdErr) {
+		t.Errorf("[case 1] assign error: expected *SSDViolationError, got %v", err)
+	}
+
+	// case 2: inheritance making holder violate constraint is rejected
+	other := NewRole("other")
+	rbac.RegisterRole(other)
+	rbac.AssignRoleToUser(u, other)
+
+	err = rbac.AddRoleParent(other, creator)
+	if !errors.As(err, &ssdErr) {
+		t.Errorf("[case 2] add parent error: expected *SSDViolationError, got %v", err)
+	}
+
+	if _, ok := memory(rbac).parents2roles[other][creator]; ok {
+		t.Errorf("[case 2] add parent error: violating parent were not removed")
+	}
+
+	// case 3: holder of Role inheriting child is checked too
+	middle, junior := NewRole("middle"), NewRole("junior")
+	rbac.RegisterRole(middle)
+	rbac.RegisterRole(junior)
+	rbac.AddRoleParent(junior, middle)
+	rbac.AssignRoleToUser(u, junior)
+
+	err = rbac.AddRoleParent(middle, creator)
+	if !errors.As(err, &ssdErr) {
+		t.Errorf("[case 3] add parent error: expected *SSDViolationError, got %v", err)
+	}
+}
+
+func TestRemoveRoleSSD(t *testing.T) {
+	rbac := NewRBAC()
+
+	creator, approver, auditor := NewRole("payment-creator"), NewRole("payment-approver"), NewRole("payment-auditor")
+	rbac.RegisterRole(creator)
+	rbac.RegisterRole(approver)
+	rbac.RegisterRole(auditor)
+	rbac.AddSSDConstraint("payments", []Role{creator, approver}, 2)
+	rbac.AddSSDConstraint("audit", []Role{creator, approver, auditor}, 2)
+
+	// case 1: constraint which can not be violated anymore is dropped, other one keeps remaining roles
+	rbac.RemoveRole(approver)
+
+	constraints, err := rbac.ListSSDConstraints()
+	if err != nil || len(constraints) != 1 || constraints[0].Name != "audit" || len(constraints[0].Roles) != 2 {
+		t.Errorf("[case 1] invalid output: expected [audit], got %v (err %v)", constraints, err)
+	}
+
+	// case 2: exported content is accepted by Import
+	var buf bytes.Buffer
+	if err := rbac.Export(&buf); err != nil {
+		t.Errorf("[case 2] export error: expected err equal nil, got %v", err)
+	}
+
+	if err := NewRBAC().Import(&buf); err != nil {
+		t.Errorf("[case 2] import error: expected err equal nil, got %v", err)
+	}
+
+	// case 3: dropped constraint is restored by rollback
+	rbac.RegisterRole(approver)
+	rbac.AddSSDConstraint("payments", []Role{creator, approver}, 2)
+
+	rbac.Update(func(tx *Tx) error {
+		tx.RemoveRole(approver)
+		return errors.New("failure")
+	})
+
+	constraints, err = rbac.ListSSDConstraints()
+	if err != nil || len(constraints) != 2 {
+		t.Errorf("[case 3] invalid output: expected [payments audit], got %v (err %v)", constraints, err)
+	}
+}
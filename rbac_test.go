@@ -2,29 +2,61 @@ package rbac
 
 import "testing"
 
-func TestNewRBAC(t *testing.T) {
+// newTestRBAC builds policy where auditor inherits reader, and editor has read and write on invoice.
+// alice is editor, bob is auditor, carol has no roles.
+func newTestRBAC() *RBAC {
 	rbac := NewRBAC()
 
-	if rbac.registeredPermissions == nil {
-		t.Errorf("controller initialization error: registeredPermissions is nil")
+	for _, id := range []string{"alice", "bob", "carol"} {
+		rbac.RegisterUser(NewUser(id))
+	}
+	for _, id := range []string{"reader", "auditor", "editor"} {
+		rbac.RegisterRole(NewRole(id))
 	}
 
-	if rbac.registeredRoles == nil {
-		t.Errorf("controller initialization error: registeredRoles is nil")
+	invoice := NewObject("invoice")
+	read := NewPermission(invoice, NewAction("read"))
+	write := NewPermission(invoice, NewAction("write"))
+	rbac.RegisterPermission(read)
+	rbac.RegisterPermission(write)
+
+	rbac.AssignPermissionToRole(NewRole("reader"), read)
+	rbac.AssignPermissionToRole(NewRole("editor"), read)
+	rbac.AssignPermissionToRole(NewRole("editor"), write)
+	rbac.AddRoleParent(NewRole("auditor"), NewRole("reader"))
+
+	rbac.AssignRoleToUser(NewUser("alice"), NewRole("editor"))
+	rbac.AssignRoleToUser(NewUser("bob"), NewRole("auditor"))
+	return rbac
+}
+
+func TestNewRBAC(t *testing.T) {
+	rbac := NewRBAC()
+
+	if rbac.store == nil {
+		t.Errorf("controller initialization error: store is nil")
 	}
 
-	if rbac.registeredUsers == nil {
-		t.Errorf("controller initialization error: registeredUsers is nil")
+	if s, ok := rbac.store.(*effectiveStore); !ok {
+		t.Errorf("controller initialization error: expected store *effectiveStore, got %T", rbac.store)
+	} else if _, ok := s.Store.(*MemoryStore); !ok {
+		t.Errorf("controller initialization error: expected store *MemoryStore, got %T", s.Store)
 	}
 
-	if rbac.perms2roles == nil {
-		t.Errorf("controller initialization error: perms2roles is nil")
+	if rbac.mutex == nil {
+		t.Errorf("controller initialization error: mutex is nil")
 	}
+}
+
+func TestNewRBACWithStore(t *testing.T) {
+	s := NewMemoryStore()
+	rbac := NewRBACWithStore(s)
 
-	if rbac.roles2users == nil {
-		t.Errorf("controller initialization error: roles2users is nil")
+	// in-memory Store is wrapped to keep effective permission index
+	if es, ok := rbac.store.(*effectiveStore); !ok || es.Store != s {
+		t.Errorf("controller initialization error: expected store %p wrapped, got %#v", s, rbac.store)
 	}
-	
+
 	if rbac.mutex == nil {
 		t.Errorf("controller initialization error: mutex is nil")
 	}
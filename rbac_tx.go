@@ -0,0 +1,423 @@
+package rbac
+
+import "time"
+
+// Update applies changes made by fn through Tx atomically. fn runs under write lock of controller,
+// so readers observe either none or all of its changes. Everything fn changed is rolled back if fn returns error,
+// panics or any Tx method fails; error of the first failed Tx method is returned even if fn ignores it.
+// Stores implementing TxStore, like database backed ones, make changes in their own transaction:
+// other controllers sharing such Store observe either none or all of them too, and interrupted Update
+// leaves nothing behind. Changes to other Stores are recorded and rolled back by replaying inverse operations,
+// so they are atomic for readers of this controller only.
+// Returns *RollbackError if rollback itself fails, content of controller may be left partially changed then.
+// fn must not call methods of the controller, they would wait for the lock Update holds, and must not keep Tx.
+func (rbac *RBAC) Update(fn func(tx *Tx) error) error {
+	rbac.mutex.Lock()
+	defer rbac.unlock()
+
+	return rbac.update(fn)
+}
+
+// update is Update without locking.
+// Caller has to hold the mutex.
+func (rbac *RBAC) update(fn func(tx *Tx) error) (err error) {
+	changes, err := rbac.begin()
+	if err != nil {
+		return err
+	}
+	tx := &Tx{
+		rbac:       rbac,
+		store:      rbac.store,
+		changes:    changes,
+		conditions: copyConditions(rbac.conditions),
+	}
+	rbac.store = changes
+
+	committed := false
+	// deferred, so panicking fn is rolled back as well
+	defer func() {
+		rbac.store = tx.store
+		tx.done = true
+		if committed {
+			return
+		}
+		if rerr := rbac.rollback(tx); rerr != nil {
+			err = &RollbackError{Err: err, Rollback: rerr}
+		}
+	}()
+
+	err = fn(tx)
+	if err == nil {
+		err = tx.err
+	}
+	if err == nil {
+		err = changes.Commit()
+	}
+	committed = err == nil
+	return err
+}
+
+// begin starts transaction of Store if it implements TxStore, changes to other Stores are recorded by journalStore.
+// Caller has to hold the mutex.
+func (rbac *RBAC) begin() (StoreTx, error) {
+	if s, ok := rbac.store.(TxStore); ok {
+		return s.Begin()
+	}
+	return &journalStore{Store: rbac.store}, nil
+}
+
+// rollback undoes changes made by Tx and restores state kept by controller itself.
+// Caller has to hold the mutex.
+func (rbac *RBAC) rollback(tx *Tx) error {
+	err := tx.changes.Rollback()
+	rbac.conditions = tx.conditions
+	rbac.conditionRoles = indexConditions(tx.conditions)
+	rbac.actions.invalidate()
+	return err
+}
+
+// Tx applies changes of RBAC.Update. Its methods follow rules of RBAC methods with the same names.
+// Settings of controller, like clock and Object separator, are not part of transaction.
+// Tx is valid only until fn passed to Update returns and can not be used concurrently.
+type Tx struct {
+	rbac *RBAC
+	// store is Store of controller, changes is transaction of it Tx makes changes through
+	store   Store
+	changes StoreTx
+	// conditions are Go function Conditions of controller before transaction
+	conditions map[Role]map[Permission]Condition
+
+	// err is error of the first failed method, following methods return it without changing anything
+	err  error
+	done bool
+}
+
+// check returns error making Tx refuse any further change
+func (tx *Tx) check() error {
+	if tx.done {
+		return ErrorTxDone
+	}
+	return tx.err
+}
+
+// fail remembers err as the first error of Tx
+func (tx *Tx) fail(err error) error {
+	if err != nil && tx.err == nil {
+		tx.err = err
+	}
+	return err
+}
+
+// RegisterUser registers new User, see RBAC.RegisterUser.
+// Error is returned by Update, use RegisterUserErr to get it right away.
+func (tx *Tx) RegisterUser(u User) bool {
+	ok, _ := tx.RegisterUserErr(u)
+	return ok
+}
+
+// RegisterUserErr is RegisterUser returning error, see RBAC.RegisterUserErr.
+func (tx *Tx) RegisterUserErr(u User) (bool, error) {
+	if err := tx.check(); err != nil {
+		return false, err
+	}
+	ok, err := tx.rbac.store.AddUser(u)
+	return ok, tx.fail(err)
+}
+
+// RemoveUser removes User, see RBAC.RemoveUser.
+// Error is returned by Update, use RemoveUserErr to get it right away.
+func (tx *Tx) RemoveUser(u User) bool {
+	ok, _ := tx.RemoveUserErr(u)
+	return ok
+}
+
+// RemoveUserErr is RemoveUser returning error, see RBAC.RemoveUserErr.
+func (tx *Tx) RemoveUserErr(u User) (bool, error) {
+	if err := tx.check(); err != nil {
+		return false, err
+	}
+	ok, err := tx.rbac.removeUser(u)
+	return ok, tx.fail(err)
+}
+
+// RegisterRole registers new Role, see RBAC.RegisterRole.
+// Error is returned by Update, use RegisterRoleErr to get it right away.
+func (tx *Tx) RegisterRole(r Role) bool {
+	ok, _ := tx.RegisterRoleErr(r)
+	return ok
+}
+
+// RegisterRoleErr is RegisterRole returning error, see RBAC.RegisterRoleErr.
+func (tx *Tx) RegisterRoleErr(r Role) (bool, error) {
+	if err := tx.check(); err != nil {
+		return false, err
+	}
+	ok, err := tx.rbac.store.AddRole(r)
+	return ok, tx.fail(err)
+}
+
+// RemoveRole removes Role, see RBAC.RemoveRole.
+// Error is returned by Update, use RemoveRoleErr to get it right away.
+func (tx *Tx) RemoveRole(r Role) bool {
+	ok, _ := tx.RemoveRoleErr(r)
+	return ok
+}
+
+// RemoveRoleErr is RemoveRole returning error, see RBAC.RemoveRoleErr.
+func (tx *Tx) RemoveRoleErr(r Role) (bool, error) {
+	if err := tx.check(); err != nil {
+		return false, err
+	}
+	ok, err := tx.rbac.removeRole(r)
+	return ok, tx.fail(err)
+}
+
+// RegisterPermission registers new Permission, see RBAC.RegisterPermission.
+// Error is returned by Update, use RegisterPermissionErr to get it right away.
+func (tx *Tx) RegisterPermission(p Permission) bool {
+	ok, _ := tx.RegisterPermissionErr(p)
+	return ok
+}
+
+// RegisterPermissionErr is RegisterPermission returning error, see RBAC.RegisterPermissionErr.
+func (tx *Tx) RegisterPermissionErr(p Permission) (bool, error) {
+	if err := tx.check(); err != nil {
+		return false, err
+	}
+	ok, err := tx.rbac.store.AddPermission(p)
+	return ok, tx.fail(err)
+}
+
+// RemovePermission removes Permission, see RBAC.RemovePermission.
+// Error is returned by Update, use RemovePermissionErr to get it right away.
+func (tx *Tx) RemovePermission(p Permission) bool {
+	ok, _ := tx.RemovePermissionErr(p)
+	return ok
+}
+
+// RemovePermissionErr is RemovePermission returning error, see RBAC.RemovePermissionErr.
+func (tx *Tx) RemovePermissionErr(p Permission) (bool, error) {
+	if err := tx.check(); err != nil {
+		return false, err
+	}
+	ok, err := tx.rbac.removePermission(p)
+	return ok, tx.fail(err)
+}
+
+// SetPermissionExact marks Permission as exact or not, see RBAC.SetPermissionExact.
+func (tx *Tx) SetPermissionExact(p Permission, exact bool) (bool, error) {
+	if err := tx.check(); err != nil {
+		return false, err
+	}
+	ok, err := tx.rbac.setPermissionExact(p, exact)
+	return ok, tx.fail(err)
+}
+
+// AssignPermissionToRole assigns Permission to Role, see RBAC.AssignPermissionToRole.
+func (tx *Tx) AssignPermissionToRole(r Role, p Permission) (bool, error) {
+	if err := tx.check(); err != nil {
+		return false, err
+	}
+	ok, err := tx.rbac.assignPermissionToRole(r, p)
+	return ok, tx.fail(err)
+}
+
+// RemovePermissionFromRole removes Permission from Role, see RBAC.RemovePermissionFromRole.
+func (tx *Tx) RemovePermissionFromRole(r Role, p Permission) (bool, error) {
+	if err := tx.check(); err != nil {
+		return false, err
+	}
+	ok, err := tx.rbac.removePermissionFromRole(r, p)
+	return ok, tx.fail(err)
+}
+
+// DenyPermissionToRole denies Permission to Role, see RBAC.DenyPermissionToRole.
+func (tx *Tx) DenyPermissionToRole(r Role, p Permission) (bool, error) {
+	if err := tx.check(); err != nil {
+		return false, err
+	}
+	ok, err := tx.rbac.denyPermissionToRole(r, p)
+	return ok, tx.fail(err)
+}
+
+// RemoveDenyFromRole removes deny of Permission from Role, see RBAC.RemoveDenyFromRole.
+func (tx *Tx) RemoveDenyFromRole(r Role, p Permission) (bool, error) {
+	if err := tx.check(); err != nil {
+		return false, err
+	}
+	ok, err := tx.rbac.removeDenyFromRole(r, p)
+	return ok, tx.fail(err)
+}
+
+// AssignConditionalPermissionToRole assigns Permission to Role granted when Condition holds,
+// see RBAC.AssignConditionalPermissionToRole.
+func (tx *Tx) AssignConditionalPermissionToRole(r Role, p Permission, c Condition) (bool, error) {
+	if err := tx.check(); err != nil {
+		return false, err
+	}
+	ok, err := tx.rbac.assignConditionalPermissionToRole(r, p, c)
+	return ok, tx.fail(err)
+}
+
+// AssignPermissionToRoleWhen assigns Permission to Role granted when expression holds,
+// see RBAC.AssignPermissionToRoleWhen.
+func (tx *Tx) AssignPermissionToRoleWhen(r Role, p Permission, expr string) (bool, error) {
+	if err := tx.check(); err != nil {
+		return false, err
+	}
+	ok, err := tx.rbac.assignPermissionToRoleWhen(r, p, expr)
+	return ok, tx.fail(err)
+}
+
+// RemoveConditionalPermissionFromRole removes conditional Permission from Role,
+// see RBAC.RemoveConditionalPermissionFromRole.
+func (tx *Tx) RemoveConditionalPermissionFromRole(r Role, p Permission) (bool, error) {
+	if err := tx.check(); err != nil {
+		return false, err
+	}
+	ok, err := tx.rbac.removeConditionalPermissionFromRole(r, p)
+	return ok, tx.fail(err)
+}
+
+// AddRoleParent makes child Role inherit parent Role, see RBAC.AddRoleParent.
+func (tx *Tx) AddRoleParent(child, parent Role) error {
+	if err := tx.check(); err != nil {
+		return err
+	}
+	return tx.fail(tx.rbac.addRoleParent(child, parent))
+}
+
+// RemoveRoleParent stops child Role from inheriting parent Role, see RBAC.RemoveRoleParent.
+func (tx *Tx) RemoveRoleParent(child, parent Role) error {
+	if err := tx.check(); err != nil {
+		return err
+	}
+	return tx.fail(tx.rbac.removeRoleParent(child, parent))
+}
+
+// DeclareActionImplies makes Action a imply Action b, see RBAC.DeclareActionImplies.
+func (tx *Tx) DeclareActionImplies(a, b Action) error {
+	if err := tx.check(); err != nil {
+		return err
+	}
+	return tx.fail(tx.rbac.declareActionImplies(a, b))
+}
+
+// RemoveActionImplies removes implication of Action b by Action a, see RBAC.RemoveActionImplies.
+func (tx *Tx) RemoveActionImplies(a, b Action) error {
+	if err := tx.check(); err != nil {
+		return err
+	}
+	return tx.fail(tx.rbac.removeActionImplies(a, b))
+}
+
+// AssignRoleToUser assigns Role to User in DefaultDomain, see RBAC.AssignRoleToUser.
+func (tx *Tx) AssignRoleToUser(u User, r Role) (bool, error) {
+	return tx.assignRoleToUser(u, r, DefaultDomain, Validity{})
+}
+
+// AssignRoleToUserInDomain assigns Role to User in Domain, see RBAC.AssignRoleToUserInDomain.
+func (tx *Tx) AssignRoleToUserInDomain(u User, r Role, d Domain) (bool, error) {
+	return tx.assignRoleToUser(u, r, d, Validity{})
+}
+
+// AssignRoleToUserUntil assigns Role to User in DefaultDomain until notAfter, see RBAC.AssignRoleToUserUntil.
+func (tx *Tx) AssignRoleToUserUntil(u User, r Role, notAfter time.Time) (bool, error) {
+	return tx.assignRoleToUser(u, r, DefaultDomain, Until(notAfter))
+}
+
+// AssignRoleToUserWithValidity assigns Role to User in DefaultDomain bounded by Validity,
+// see RBAC.AssignRoleToUserWithValidity.
+func (tx *Tx) AssignRoleToUserWithValidity(u User, r Role, v Validity) (bool, error) {
+	return tx.assignRoleToUser(u, r, DefaultDomain, v)
+}
+
+// AssignRoleToUserInDomainWithValidity assigns Role to User in Domain bounded by Validity,
+// see RBAC.AssignRoleToUserInDomainWithValidity.
+func (tx *Tx) AssignRoleToUserInDomainWithValidity(u User, r Role, d Domain, v Validity) (bool, error) {
+	return tx.assignRoleToUser(u, r, d, v)
+}
+
+// RemoveRoleFromUser removes Role from User in DefaultDomain, see RBAC.RemoveRoleFromUser.
+func (tx *Tx) RemoveRoleFromUser(u User, r Role) (bool, error) {
+	return tx.removeRoleFromUser(u, r, DefaultDomain)
+}
+
+// RemoveRoleFromUserInDomain removes Role from User in Domain, see RBAC.RemoveRoleFromUserInDomain.
+func (tx *Tx) RemoveRoleFromUserInDomain(u User, r Role, d Domain) (bool, error) {
+	return tx.removeRoleFromUser(u, r, d)
+}
+
+// SweepExpiredAssignments removes expired Role assignments, see RBAC.SweepExpiredAssignments.
+func (tx *Tx) SweepExpiredAssignments() (int, error) {
+	if err := tx.check(); err != nil {
+		return 0, err
+	}
+	n, err := tx.rbac.sweepExpiredAssignments()
+	return n, tx.fail(err)
+}
+
+// AddSSDConstraint adds static separation of duty constraint, see RBAC.AddSSDConstraint.
+func (tx *Tx) AddSSDConstraint(name string, roles []Role, n int) (bool, error) {
+	if err := tx.check(); err != nil {
+		return false, err
+	}
+	ok, err := tx.rbac.addSSDConstraint(NewSSDConstraint(name, roles, n))
+	return ok, tx.fail(err)
+}
+
+// RemoveSSDConstraint removes static separation of duty constraint, see RBAC.RemoveSSDConstraint.
+func (tx *Tx) RemoveSSDConstraint(name string) (bool, error) {
+	if err := tx.check(); err != nil {
+		return false, err
+	}
+	ok, err := tx.rbac.store.RemoveSSDConstraint(name)
+	return ok, tx.fail(err)
+}
+
+// AddDSDConstraint adds dynamic separation of duty constraint, see RBAC.AddDSDConstraint.
+func (tx *Tx) AddDSDConstraint(name string, roles []Role, n int) (bool, error) {
+	if err := tx.check(); err != nil {
+		return false, err
+	}
+	ok, err := tx.rbac.addDSDConstraint(NewDSDConstraint(name, roles, n))
+	return ok, tx.fail(err)
+}
+
+// RemoveDSDConstraint removes dynamic separation of duty constraint, see RBAC.RemoveDSDConstraint.
+func (tx *Tx) RemoveDSDConstraint(name string) (bool, error) {
+	if err := tx.check(); err != nil {
+		return false, err
+	}
+	ok, err := tx.rbac.store.RemoveDSDConstraint(name)
+	return ok, tx.fail(err)
+}
+
+func (tx *Tx) assignRoleToUser(u User, r Role, d Domain, v Validity) (bool, error) {
+	if err := tx.check(); err != nil {
+		return false, err
+	}
+	ok, err := tx.rbac.assignRoleToUser(u, r, d, v)
+	return ok, tx.fail(err)
+}
+
+func (tx *Tx) removeRoleFromUser(u User, r Role, d Domain) (bool, error) {
+	if err := tx.check(); err != nil {
+		return false, err
+	}
+	ok, err := tx.rbac.removeRoleFromUser(u, r, d)
+	return ok, tx.fail(err)
+}
+
+// copyConditions copies Go function Conditions of controller, Conditions themselves are shared
+func copyConditions(conditions map[Role]map[Permission]Condition) map[Role]map[Permission]Condition {
+	out := make(map[Role]map[Permission]Condition, len(conditions))
+	for r, conds := range conditions {
+		copied := make(map[Permission]Condition, len(conds))
+		for p, c := range conds {
+			copied[p] = c
+		}
+		out[r] = copied
+	}
+	return out
+}
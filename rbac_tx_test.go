@@ -0,0 +1,315 @@
+package rbac
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// exportString returns document exported by controller
+func exportString(rbac *RBAC) string {
+	var buf bytes.Buffer
+	rbac.Export(&buf)
+	return buf.String()
+}
+
+func TestUpdate(t *testing.T) {
+	rbac := NewRBAC()
+
+	u, r := NewUser("alice"), NewRole("reader")
+	p := NewPermission(NewObject("invoice"), NewAction("read"))
+
+	// case 1: all changes are applied
+	err := rbac.Update(func(tx *Tx) error {
+		tx.RegisterUser(u)
+		tx.RegisterRole(r)
+		tx.RegisterPermission(p)
+		tx.AssignPermissionToRole(r, p)
+		_, err := tx.AssignRoleToUser(u, r)
+		return err
+	})
+	if err != nil {
+		t.Errorf("[case 1] update error: expected err equal nil, got %v", err)
+	}
+
+	ok, err := rbac.UserHasPermission(u, p)
+	if err != nil || !ok {
+		t.Errorf("[case 1] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	// case 2: Tx refuses changes after Update returns
+	var kept *Tx
+	rbac.Update(func(tx *Tx) error {
+		kept = tx
+		return nil
+	})
+	if _, err := kept.RemoveUserErr(u); err != ErrorTxDone {
+		t.Errorf("[case 2] remove error: expected err equal %v, got %v", ErrorTxDone, err)
+	}
+
+	ok, err = rbac.UserExistsErr(u)
+	if err != nil || !ok {
+		t.Errorf("[case 2] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+}
+
+func TestUpdateRollback(t *testing.T) {
+	rbac := newTestRBAC()
+
+	alice, bob, carol := NewUser("alice"), NewUser("bob"), NewUser("carol")
+	reader, auditor, editor := NewRole("reader"), NewRole("auditor"), NewRole("editor")
+	read := NewPermission(NewObject("invoice"), NewAction("read"))
+	write := NewPermission(NewObject("invoice"), NewAction("write"))
+	approve := NewPermission(NewObject("invoice"), NewAction("approve"))
+	acme := NewDomain("acme")
+
+	// every kind of relation, so rollback of each of them is checked
+	rbac.RegisterRole(NewRole("manager"))
+	rbac.RegisterRole(NewRole("approver"))
+	rbac.AddRoleParent(NewRole("manager"), editor)
+	rbac.AddRoleParent(editor, NewRole("approver"))
+	rbac.RegisterPermission(approve)
+	rbac.SetPermissionExact(approve, true)
+	rbac.SetPermissionExact(read, true)
+	rbac.DenyPermissionToRole(reader, approve)
+	rbac.AssignPermissionToRoleWhen(editor, approve, `resource.owner == subject.id`)
+	rbac.AssignConditionalPermissionToRole(reader, write, ownerOnly)
+	rbac.DeclareActionImplies(NewAction("write"), NewAction("comment"))
+	rbac.AddSSDConstraint("reader-editor", []Role{reader, editor}, 2)
+	rbac.AddDSDConstraint("auditor-editor", []Role{auditor, editor}, 2)
+	rbac.AssignRoleToUserInDomainWithValidity(alice, reader, acme,
+		Between(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC)))
+	rbac.AssignRoleToUserInDomain(bob, editor, acme)
+	rbac.AssignPermissionToRole(reader, read)
+	rbac.AssignPermissionToRole(reader, approve)
+	before := exportString(rbac)
+
+	// checks before Update, index has to answer them the same after rollback
+	rbac.UserHasPermission(alice, write)
+	rbac.UserHasPermission(bob, read)
+
+	failure := errors.New("failure")
+	steps := func(tx *Tx) error {
+		tx.RegisterUser(NewUser("dave"))
+		tx.RegisterRole(NewRole("admin"))
+		tx.RegisterPermission(NewPermission(NewObject("invoice"), NewAction("delete")))
+		tx.AssignRoleToUser(carol, auditor)
+		tx.AssignRoleToUserInDomainWithValidity(alice, reader, acme, Validity{})
+		tx.RemoveRoleFromUser(alice, editor)
+		tx.AssignPermissionToRole(auditor, write)
+		tx.RemovePermissionFromRole(editor, read)
+		tx.RemoveDenyFromRole(reader, approve)
+		tx.DenyPermissionToRole(editor, read)
+		tx.AssignPermissionToRoleWhen(editor, approve, `subject.id == "bob"`)
+		tx.RemoveConditionalPermissionFromRole(reader, write)
+		tx.SetPermissionExact(approve, false)
+		tx.RemoveRoleParent(auditor, reader)
+		tx.RemoveActionImplies(NewAction("write"), NewAction("comment"))
+		tx.DeclareActionImplies(NewAction("read"), NewAction("list"))
+		tx.RemoveSSDConstraint("reader-editor")
+		tx.RemoveDSDConstraint("auditor-editor")
+		tx.AddSSDConstraint("auditor-admin", []Role{auditor, NewRole("admin")}, 2)
+		tx.RemoveUser(bob)
+		tx.RemoveRole(editor)
+		tx.RemovePermission(approve)
+		tx.RemovePermission(read)
+		// every step has to succeed, so rollback of each of them is checked
+		return tx.err
+	}
+
+	// case 1: changes are rolled back when fn returns error
+	err := rbac.Update(func(tx *Tx) error {
+		if err := steps(tx); err != nil {
+			return err
+		}
+		return failure
+	})
+	if err != failure {
+		t.Errorf("[case 1] update error: expected err equal %v, got %v", failure, err)
+	}
+	if after := exportString(rbac); after != before {
+		t.Errorf("[case 1] rollback error: expected\n%s\ngot\n%s", before, after)
+	}
+
+	ok, err := rbac.UserHasPermission(alice, write)
+	if err != nil || !ok {
+		t.Errorf("[case 1] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+	ok, err = rbac.UserHasPermission(bob, read)
+	if err != nil || !ok {
+		t.Errorf("[case 1] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+	if err := rbac.VerifyEffectivePermissions(); err != nil {
+		t.Errorf("[case 1] verify error: expected err equal nil, got %v", err)
+	}
+
+	// case 2: changes are rolled back when any step fails, even if fn ignores it
+	err = rbac.Update(func(tx *Tx) error {
+		steps(tx)
+		tx.AssignRoleToUser(alice, NewRole("missing"))
+		return nil
+	})
+	if err != ErrorRoleNotRegistered {
+		t.Errorf("[case 2] update error: expected err equal %v, got %v", ErrorRoleNotRegistered, err)
+	}
+	if after := exportString(rbac); after != before {
+		t.Errorf("[case 2] rollback error: expected\n%s\ngot\n%s", before, after)
+	}
+
+	// case 3: Go function Conditions are rolled back
+	rbac.Update(func(tx *Tx) error {
+		tx.RemoveConditionalPermissionFromRole(reader, write)
+		tx.AssignConditionalPermissionToRole(auditor, write, ownerOnly)
+		return failure
+	})
+	perms, err := rbac.ListRoleConditionalPermissions(reader)
+	if err != nil || len(perms) != 1 || perms[0] != write {
+		t.Errorf("[case 3] invalid output: expected [%v], got %v (err %v)", write, perms, err)
+	}
+	perms, err = rbac.ListRoleConditionalPermissions(auditor)
+	if err != nil || len(perms) != 0 {
+		t.Errorf("[case 3] invalid output: expected [], got %v (err %v)", perms, err)
+	}
+}
+
+func TestUpdateFailedStep(t *testing.T) {
+	rbac := NewRBAC()
+
+	u := NewUser("alice")
+	p := NewPermission(NewObject("invoice"), NewAction("read"))
+
+	// case 1: error of failed step is returned and following steps are refused
+	var refused error
+	err := rbac.Update(func(tx *Tx) error {
+		tx.RegisterUser(u)
+		tx.RegisterPermission(p)
+		tx.AssignPermissionToRole(NewRole("missing"), p)
+		_, refused = tx.RegisterRoleErr(NewRole("reader"))
+		return nil
+	})
+	if err != ErrorRoleNotRegistered {
+		t.Errorf("[case 1] update error: expected err equal %v, got %v", ErrorRoleNotRegistered, err)
+	}
+	if refused != ErrorRoleNotRegistered {
+		t.Errorf("[case 1] step error: expected err equal %v, got %v", ErrorRoleNotRegistered, refused)
+	}
+
+	users, err := rbac.ListUsersErr()
+	if err != nil || len(users) != 0 {
+		t.Errorf("[case 1] invalid output: expected [], got %v (err %v)", users, err)
+	}
+
+	// case 2: changes are rolled back when fn panics
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("[case 2] update error: expected panic to be propagated")
+			}
+		}()
+		rbac.Update(func(tx *Tx) error {
+			tx.RegisterUser(u)
+			panic("failure")
+		})
+	}()
+
+	ok, err := rbac.UserExistsErr(u)
+	if err != nil || ok {
+		t.Errorf("[case 2] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+
+	// case 3: controller is usable after panic
+	err = rbac.Update(func(tx *Tx) error {
+		_, err := tx.RegisterUserErr(u)
+		return err
+	})
+	if err != nil {
+		t.Errorf("[case 3] update error: expected err equal nil, got %v", err)
+	}
+}
+
+func TestTxMirrorsRBAC(t *testing.T) {
+	txType := reflect.TypeOf(&Tx{})
+	rbacType := reflect.TypeOf(&RBAC{})
+
+	for i := 0; i < txType.NumMethod(); i++ {
+		m := txType.Method(i)
+		same, ok := rbacType.MethodByName(m.Name)
+		if !ok {
+			t.Errorf("Tx method %s has no RBAC counterpart", m.Name)
+			continue
+		}
+		// receivers differ, compare the rest of signatures
+		if m.Type.NumIn() != same.Type.NumIn() || m.Type.NumOut() != same.Type.NumOut() {
+			t.Errorf("Tx method %s signature differs from RBAC: %v vs %v", m.Name, m.Type, same.Type)
+			continue
+		}
+		for j := 1; j < m.Type.NumIn(); j++ {
+			if m.Type.In(j) != same.Type.In(j) {
+				t.Errorf("Tx method %s signature differs from RBAC: %v vs %v", m.Name, m.Type, same.Type)
+			}
+		}
+		for j := 0; j < m.Type.NumOut(); j++ {
+			if m.Type.Out(j) != same.Type.Out(j) {
+				t.Errorf("Tx method %s signature differs from RBAC: %v vs %v", m.Name, m.Type, same.Type)
+			}
+		}
+	}
+}
+
+// txMemoryStore is MemoryStore implementing TxStore, it counts transactions committed and rolled back
+type txMemoryStore struct {
+	*MemoryStore
+
+	committed, rolledBack int
+}
+
+func (s *txMemoryStore) Begin() (StoreTx, error) {
+	return &countedTx{journalStore: &journalStore{Store: s.MemoryStore}, store: s}, nil
+}
+
+type countedTx struct {
+	*journalStore
+
+	store *txMemoryStore
+}
+
+func (tx *countedTx) Commit() error {
+	tx.store.committed++
+	return tx.journalStore.Commit()
+}
+
+func (tx *countedTx) Rollback() error {
+	tx.store.rolledBack++
+	return tx.journalStore.Rollback()
+}
+
+func TestUpdateTxStore(t *testing.T) {
+	s := &txMemoryStore{MemoryStore: NewMemoryStore()}
+	rbac := NewRBACWithStore(s)
+	u := NewUser("alice")
+
+	// case 1: changes are committed through transaction of Store
+	rbac.Update(func(tx *Tx) error {
+		_, err := tx.RegisterUserErr(u)
+		return err
+	})
+	if s.committed != 1 || s.rolledBack != 0 {
+		t.Errorf("[case 1] invalid output: expected 1 commit and 0 rollbacks, got %d and %d", s.committed, s.rolledBack)
+	}
+
+	// case 2: failed changes are rolled back through transaction of Store
+	rbac.Update(func(tx *Tx) error {
+		tx.RemoveUser(u)
+		return errors.New("failure")
+	})
+	if s.committed != 1 || s.rolledBack != 1 {
+		t.Errorf("[case 2] invalid output: expected 1 commit and 1 rollback, got %d and %d", s.committed, s.rolledBack)
+	}
+
+	ok, err := rbac.UserExistsErr(u)
+	if err != nil || !ok {
+		t.Errorf("[case 2] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+}
@@ -2,188 +2,280 @@ package rbac
 
 // RegisterUser registers new User in RBAC controller.
 // Returns false if such User already registered.
+// Store errors are dropped, use RegisterUserErr to get them.
 func (rbac *RBAC) RegisterUser(u User) bool {
+	ok, _ := rbac.RegisterUserErr(u)
+	return ok
+}
+
+// RegisterUserErr is RegisterUser returning Store errors.
+func (rbac *RBAC) RegisterUserErr(u User) (bool, error) {
 	rbac.mutex.Lock()
-	defer rbac.mutex.Unlock()
+	defer rbac.unlock()
 
-	_, ok := rbac.registeredUsers[u]
-	if ok {
-		return false
-	}
-	rbac.registeredUsers[u] = struct{}{}
-	return true
+	return rbac.store.AddUser(u)
 }
 
 // RemoveUser removes User from RBAC controller registered users list.
 // Returns false if no such User were registered in controller.
+// Store errors are dropped, use RemoveUserErr to get them.
 func (rbac *RBAC) RemoveUser(u User) bool {
-	rbac.mutex.Lock()
-	defer rbac.mutex.Unlock()
+	ok, _ := rbac.RemoveUserErr(u)
+	return ok
+}
 
-	_, ok := rbac.registeredUsers[u]
-	if !ok {
-		return false
-	}
+// RemoveUserErr is RemoveUser returning Store errors.
+func (rbac *RBAC) RemoveUserErr(u User) (bool, error) {
+	rbac.mutex.Lock()
+	defer rbac.unlock()
 
-	delete(rbac.roles2users, u)
+	return rbac.removeUser(u)
+}
 
-	delete(rbac.registeredUsers, u)
-	return true
+// removeUser is RemoveUser without locking.
+// Caller has to hold the mutex.
+func (rbac *RBAC) removeUser(u User) (bool, error) {
+	return rbac.store.RemoveUser(u)
 }
 
-// ListUsers returns all registered Users
+// ListUsers returns all registered Users.
+// Store errors are dropped, use ListUsersErr to get them.
 func (rbac *RBAC) ListUsers() []User {
+	list, _ := rbac.ListUsersErr()
+	return list
+}
+
+// ListUsersErr is ListUsers returning Store errors.
+func (rbac *RBAC) ListUsersErr() ([]User, error) {
 	rbac.mutex.RLock()
 	defer rbac.mutex.RUnlock()
 
-	out := make([]User, 0, len(rbac.registeredUsers))
-	for u := range rbac.registeredUsers {
-		out = append(out, u)
-	}
-	return out
+	return rbac.store.Users()
 }
 
-// UserExists checks if User is registered in RBAC controller
+// UserExists checks if User is registered in RBAC controller.
+// Store errors are dropped, use UserExistsErr to get them.
 func (rbac *RBAC) UserExists(u User) bool {
+	ok, _ := rbac.UserExistsErr(u)
+	return ok
+}
+
+// UserExistsErr is UserExists returning Store errors.
+func (rbac *RBAC) UserExistsErr(u User) (bool, error) {
 	rbac.mutex.RLock()
 	defer rbac.mutex.RUnlock()
 
-	_, ok := rbac.registeredUsers[u]
-	return ok
+	return rbac.store.HasUser(u)
 }
 
-// ListUserRoles returns all Roles assigned to User.
+// ListUserRoles returns all Roles assigned to User in DefaultDomain and currently in effect.
 // User has to be registered.
 func (rbac *RBAC) ListUserRoles(u User) ([]Role, error) {
 	rbac.mutex.RLock()
 	defer rbac.mutex.RUnlock()
 
-	_, ok := rbac.registeredUsers[u]
-	if !ok {
-		return nil, ErrorUserNotRegistered
-	}
-	userRoles, ok := rbac.roles2users[u]
-	if !ok {
-		return []Role{}, nil
-	}
-	out := make([]Role, 0, len(userRoles))
-	for r := range userRoles {
-		out = append(out, r)
-	}
-	return out, nil
+	return rbac.listUserRoles(u, DefaultDomain)
 }
 
-// UserHasRole checks if Role is assigned to User
+// UserHasRole checks if Role is assigned to User in DefaultDomain
+// Assignments not in effect according to their Validity are ignored.
 // Both User and Role has to be registered.
 func (rbac *RBAC) UserHasRole(u User, r Role) (bool, error) {
-	rbac.mutex.RLock()
-	defer rbac.mutex.RUnlock()
+	return rbac.hasRole(u, r, DefaultDomain)
+}
 
-	_, ok := rbac.registeredUsers[u]
-	if !ok {
-		return false, ErrorUserNotRegistered
-	}
-	_, ok = rbac.registeredRoles[r]
-	if !ok {
-		return false, ErrorRoleNotRegistered
-	}
+// UserHasPermission checks if any assigned to User Role has provided Permission, directly or inherited.
+// Only Roles assigned in DefaultDomain and currently in effect are taken into account.
+// Permission denied to any of User Roles is never granted (deny overrides allow).
+// Wildcard Permissions assigned to Roles are matched against provided Permission.
+// User has to be registered, Permission has to be registered or matched by registered wildcard Permission.
+func (rbac *RBAC) UserHasPermission(u User, p Permission) (bool, error) {
+	return rbac.hasPermission(u, p, DefaultDomain)
+}
 
-	_, ok = rbac.roles2users[u][r]
-	return ok, nil
+// UserHasObjectAction checks if any assigned to User Role has Permission with provided Object and Action.
+// User has to be registered, Permission with provided Object and Action has to be registered
+// or matched by registered wildcard Permission.
+func (rbac *RBAC) UserHasObjectAction(u User, o Object, a Action) (bool, error) {
+	return rbac.hasPermission(u, NewPermission(o, a), DefaultDomain)
 }
 
-// UserHasPermission checks if any assigned to User Role has provided Permission.
-// Both User and Permission has to be registered.
-func (rbac *RBAC) UserHasPermission(u User, p Permission) (bool, error) {
-	rbac.mutex.RLock()
-	defer rbac.mutex.RUnlock()
+// AssignRoleToUser assigns Role to User in DefaultDomain with no time bounds.
+// Both User and Role has to be registered.
+// Returns *SSDViolationError if assignment violates separation of duty constraint.
+// Returns false if Role already assigned to User with no time bounds.
+func (rbac *RBAC) AssignRoleToUser(u User, r Role) (bool, error) {
+	rbac.mutex.Lock()
+	defer rbac.unlock()
+
+	return rbac.assignRoleToUser(u, r, DefaultDomain, Validity{})
+}
+
+// RemoveRoleFromUser removes Role from User in DefaultDomain.
+// Both User and Role has to be registered.
+// Returns false if Role was not assigned to User.
+func (rbac *RBAC) RemoveRoleFromUser(u User, r Role) (bool, error) {
+	rbac.mutex.Lock()
+	defer rbac.unlock()
+
+	return rbac.removeRoleFromUser(u, r, DefaultDomain)
+}
 
-	_, ok := rbac.registeredUsers[u]
-	if !ok {
-		return false, ErrorUserNotRegistered
+// listUserRoles returns Roles assigned to User in Domain and currently in effect.
+// Caller has to hold the mutex.
+func (rbac *RBAC) listUserRoles(u User, d Domain) ([]Role, error) {
+	if err := rbac.checkUser(u); err != nil {
+		return nil, err
 	}
+	return rbac.activeUserRoles(u, d)
+}
 
-	_, ok = rbac.registeredPermissions[p]
-	if !ok {
-		return false, ErrorPermissionNotRegistered
+// hasRole is userHasRole answered by published view of effective permission index without locking.
+// Controller keeping no index evaluates Store under read lock.
+func (rbac *RBAC) hasRole(u User, r Role, d Domain) (bool, error) {
+	if v := rbac.effective.load(); v != nil {
+		return v.userHasRole(u, r, d)
 	}
+	rbac.mutex.RLock()
+	defer rbac.mutex.RUnlock()
 
-	userRoles := rbac.roles2users[u]
-	for r := range userRoles {
-		_, ok := rbac.perms2roles[r][p]
-		if ok {
-			return true, nil
-		}
+	return rbac.userHasRole(u, r, d)
+}
+
+// userHasRole checks if Role is assigned to User in Domain and currently in effect.
+// Caller has to hold the mutex.
+func (rbac *RBAC) userHasRole(u User, r Role, d Domain) (bool, error) {
+	if err := rbac.checkUser(u); err != nil {
+		return false, err
+	}
+	if err := rbac.checkRole(r); err != nil {
+		return false, err
+	}
+	ok, err := rbac.store.HasUserRole(u, r, d)
+	if err != nil || !ok {
+		return false, err
 	}
-	return false, nil
+	v, err := rbac.store.UserRoleValidity(u, r, d)
+	if err != nil {
+		return false, err
+	}
+	return v.ActiveAt(rbac.now()), nil
 }
 
-// UserHasPermission checks if any assigned to User Role has Permission with provided Object and Action.
-// Both User and Permission with provided Object and Action has to be registered.
-func (rbac *RBAC) UserHasObjectAction(u User, o Object, a Action) (bool, error) {
+// hasPermission is userHasPermission answered by published view of effective permission index without locking,
+// so checks never wait for writers. Controller keeping no index evaluates Store under read lock.
+func (rbac *RBAC) hasPermission(u User, p Permission, d Domain) (bool, error) {
+	if v := rbac.effective.load(); v != nil {
+		return v.userHasPermission(u, p, d)
+	}
 	rbac.mutex.RLock()
 	defer rbac.mutex.RUnlock()
 
-	p := NewPermission(o, a)
+	return rbac.userHasPermission(u, p, d)
+}
 
-	return rbac.UserHasPermission(u, p)
+// userHasPermission checks if Roles assigned to User in Domain allow Permission.
+// Effective permission index answers it if controller keeps one, Store is evaluated otherwise.
+// Caller has to hold the mutex.
+func (rbac *RBAC) userHasPermission(u User, p Permission, d Domain) (bool, error) {
+	if rbac.effective.ready() {
+		return rbac.effective.load().userHasPermission(u, p, d)
+	}
+	return rbac.evaluateUserPermission(u, p, d)
 }
 
-// AssignRoleToUser assigns Role to User.
-// Both User and Role has to be registered.
-// Returns false if Role already assigned to User.
-func (rbac *RBAC) AssignRoleToUser(u User, r Role) (bool, error) {
-	rbac.mutex.Lock()
-	defer rbac.mutex.Unlock()
+// evaluateUserPermission is userHasPermission evaluated out of Store, bypassing effective permission index.
+// Caller has to hold the mutex.
+func (rbac *RBAC) evaluateUserPermission(u User, p Permission, d Domain) (bool, error) {
+	if err := rbac.checkUser(u); err != nil {
+		return false, err
+	}
+	wildcards, err := rbac.checkPermissionMatch(p)
+	if err != nil {
+		return false, err
+	}
 
-	_, ok := rbac.registeredUsers[u]
-	if !ok {
-		return false, ErrorUserNotRegistered
+	userRoles, err := rbac.activeUserRoles(u, d)
+	if err != nil {
+		return false, err
 	}
+	return rbac.rolesHavePermission(userRoles, p, wildcards)
+}
 
-	_, ok = rbac.registeredRoles[r]
-	if !ok {
-		return false, ErrorRoleNotRegistered
+// rolesHavePermission checks if Roles along with their ancestors allow Permission.
+// wildcards are registered Permissions matching p, as returned by checkPermissionMatch.
+// Caller has to hold the mutex.
+func (rbac *RBAC) rolesHavePermission(list []Role, p Permission, wildcards []Permission) (bool, error) {
+	roles, err := rbac.rolesWithAncestors(list)
+	if err != nil {
+		return false, err
 	}
+	return rbac.rolesAllow(roles, p, wildcards)
+}
 
-	userRoles, ok := rbac.roles2users[u]
-	if !ok {
-		userRoles = make(map[Role]struct{})
-		userRoles[r] = struct{}{}
-		rbac.roles2users[u] = userRoles
-		return true, nil
+// assignRoleToUser assigns Role to User in Domain bounded by Validity.
+// Validity of existing assignment is replaced.
+// Caller has to hold the mutex.
+func (rbac *RBAC) assignRoleToUser(u User, r Role, d Domain, v Validity) (bool, error) {
+	if err := rbac.checkUser(u); err != nil {
+		return false, err
+	}
+	if err := rbac.checkRole(r); err != nil {
+		return false, err
+	}
+	if !v.valid() {
+		return false, ErrorInvalidValidity
+	}
+	if !v.ExpiredAt(rbac.now()) {
+		constraints, err := rbac.store.SSDConstraints()
+		if err != nil {
+			return false, err
+		}
+		if err := rbac.checkSSD(constraints, u, d, r); err != nil {
+			return false, err
+		}
+	}
+	added, err := rbac.store.AddUserRole(u, r, d)
+	if err != nil {
+		return false, err
 	}
-	_, ok = userRoles[r]
-	if ok {
-		return false, nil
+	changed, err := rbac.store.SetUserRoleValidity(u, r, d, v)
+	if err != nil {
+		return false, err
 	}
-	userRoles[r] = struct{}{}
-	rbac.roles2users[u] = userRoles
-	return true, nil
+	return added || changed, nil
 }
 
-// RemoveRoleFromUser removes Role from User.
-// Both User and Role has to be registered.
-// Returns false if Role was not assigned to User.
-func (rbac *RBAC) RemoveRoleFromUser(u User, r Role) (bool, error) {
-	rbac.mutex.Lock()
-	defer rbac.mutex.Unlock()
-
-	_, ok := rbac.registeredUsers[u]
-	if !ok {
-		return false, ErrorUserNotRegistered
+// removeRoleFromUser removes Role from User in Domain.
+// Caller has to hold the mutex.
+func (rbac *RBAC) removeRoleFromUser(u User, r Role, d Domain) (bool, error) {
+	if err := rbac.checkUser(u); err != nil {
+		return false, err
 	}
-
-	_, ok = rbac.registeredRoles[r]
-	if !ok {
-		return false, ErrorRoleNotRegistered
+	if err := rbac.checkRole(r); err != nil {
+		return false, err
 	}
+	return rbac.store.RemoveUserRole(u, r, d)
+}
 
-	_, ok = rbac.roles2users[u][r]
-	if !ok {
-		return false, nil
+// activeUserRoles returns Roles assigned to User in Domain which Validity contains current time.
+// Caller has to hold the mutex.
+func (rbac *RBAC) activeUserRoles(u User, d Domain) ([]Role, error) {
+	roles, err := rbac.store.UserRoles(u, d)
+	if err != nil {
+		return nil, err
 	}
 
-	delete(rbac.roles2users[u], r)
-	return true, nil
-}
\ No newline at end of file
+	now := rbac.now()
+	out := roles[:0]
+	for _, r := range roles {
+		v, err := rbac.store.UserRoleValidity(u, r, d)
+		if err != nil {
+			return nil, err
+		}
+		if v.ActiveAt(now) {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
@@ -3,6 +3,7 @@ package rbac
 import (
 	"testing"
 	"fmt"
+	"errors"
 )
 
 func TestRegisterUser(t *testing.T) {
@@ -16,12 +17,12 @@ func TestRegisterUser(t *testing.T) {
 		t.Errorf("[case 1] invalid output: expected %t got %t ", true, ok)
 	}
 
-	if _, ok := rbac.registeredUsers[u]; !ok {
+	if _, ok := memory(rbac).registeredUsers[u]; !ok {
 		t.Errorf("[case 1] user %v not presented in registeredUsers", u)
 	}
 
 	// case 2: user is registered
-	rbac.registeredUsers[u] = struct{}{}
+	memory(rbac).registeredUsers[u] = struct{}{}
 
 	ok = rbac.RegisterUser(u)
 	if ok {
@@ -29,6 +30,34 @@ func TestRegisterUser(t *testing.T) {
 	}
 }
 
+func TestRegisterUserErr(t *testing.T) {
+	failure := errors.New("failure")
+	rbac := NewRBACWithStore(&failingStore{MemoryStore: NewMemoryStore(), err: failure})
+	u := NewUser(defaultUserID)
+
+	// case 1: Store error is dropped by RegisterUser
+	if ok := rbac.RegisterUser(u); ok {
+		t.Errorf("[case 1] invalid output: expected %t got %t ", false, ok)
+	}
+
+	// case 2: Store error is returned by RegisterUserErr
+	ok, err := rbac.RegisterUserErr(u)
+	if err != failure || ok {
+		t.Errorf("[case 2] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+}
+
+// failingStore is MemoryStore failing to add Users
+type failingStore struct {
+	*MemoryStore
+
+	err error
+}
+
+func (s *failingStore) AddUser(u User) (bool, error) {
+	return false, s.err
+}
+
 func TestRemoveUser(t *testing.T) {
 	rbac := NewRBAC()
 
@@ -42,32 +71,32 @@ func TestRemoveUser(t *testing.T) {
 	}
 
 	// case 2 : user is registered, no roles assigned
-	rbac.registeredUsers[u] = struct{}{}
+	memory(rbac).registeredUsers[u] = struct{}{}
 
 	exist = rbac.RemoveUser(u)
 	if !exist {
 		t.Errorf("[case 2] invalid output: expected %t got %t ", true, exist)
 	}
 
-	if _, ok := rbac.registeredUsers[u]; ok {
+	if _, ok := memory(rbac).registeredUsers[u]; ok {
 		t.Errorf("[case 2] user were not removed from registered user list")
 	}
 
 	// case 3: user is registered, role is assigned
-	rbac.registeredUsers[u] = struct{}{}
-	rbac.registeredRoles[r] = struct{}{}
-	rbac.roles2users[u] = map[Role]struct{}{r:{}}
+	memory(rbac).registeredUsers[u] = struct{}{}
+	memory(rbac).registeredRoles[r] = struct{}{}
+	memory(rbac).roles2users[u] = map[Domain]map[Role]Validity{DefaultDomain: {r: {}}}
 
 	exist = rbac.RemoveUser(u)
 	if !exist {
 		t.Errorf("[case 3] invalid output: expected %t got %t ", true, exist)
 	}
 
-	if _, ok := rbac.registeredUsers[u]; ok {
+	if _, ok := memory(rbac).registeredUsers[u]; ok {
 		t.Errorf("[case 3] user were not removed from registered user list")
 	}
 
-	if _, ok := rbac.roles2users[u][r]; ok {
+	if _, ok := memory(rbac).roles2users[u][DefaultDomain][r]; ok {
 		t.Errorf("[case 3] roles were not unassigned from user")
 	}
 }
@@ -174,7 +203,7 @@ func TestAssignRoleToUser(t *testing.T) {
 		t.Errorf("[case 3] invalid output: expected %v, got %v", true, ok)
 	}
 
-	if _, ok := rbac.roles2users[u][r]; !ok {
+	if _, ok := memory(rbac).roles2users[u][DefaultDomain][r]; !ok {
 		t.Errorf("[case 3] assign error: role were not assigned to user")
 	}
 
@@ -182,7 +211,7 @@ func TestAssignRoleToUser(t *testing.T) {
 	rbac.RegisterUser(u)
 	rbac.RegisterRole(r)
 
-	rbac.roles2users[u]=map[Role]struct{}{r:{}}
+	memory(rbac).roles2users[u] = map[Domain]map[Role]Validity{DefaultDomain: {r: {}}}
 
 	ok, err = rbac.AssignRoleToUser(u, r)
 	if err != nil {
@@ -436,7 +465,6 @@ func TestUserHasObjectAction(t *testing.T) {
 	}
 }
 
-
 func TestRemoveRoleFromUser(t *testing.T) {
 	rbac := NewRBAC()
 
@@ -493,7 +521,7 @@ func TestRemoveRoleFromUser(t *testing.T) {
 		t.Errorf("[case 4] invalid output: expected %t, got %t", true, ok)
 	}
 
-	if _, ok := rbac.roles2users[u][r]; ok {
+	if _, ok := memory(rbac).roles2users[u][DefaultDomain][r]; ok {
 		t.Errorf("[case 4] role is not removed from user")
 	}
 }
\ No newline at end of file
@@ -0,0 +1,628 @@
+package rbac
+
+import (
+	"fmt"
+	"math/bits"
+	"time"
+)
+
+// view is published version of effective permission index. It is never modified once published,
+// so checks read it without locking while writers build the next version, see effectiveIndex.
+type view struct {
+	now       func() time.Time
+	separator string
+
+	users       *viewMap[User, *effectiveUser]
+	roles       *viewMap[Role, *grantSet]
+	permissions *viewMap[Permission, bool] // registered Permissions, true if exact
+	wildcards   []Permission
+	actions     *actionGraph
+	// sets are unions of Roles held by Users, see effectiveIndex.intern
+	sets *viewMap[int, *grantSet]
+}
+
+func newView() *view {
+	return &view{
+		now:         time.Now,
+		users:       newViewMap[User, *effectiveUser](hashUser),
+		roles:       newViewMap[Role, *grantSet](hashRole),
+		permissions: newViewMap[Permission, bool](hashPermission),
+		sets:        newViewMap[int, *grantSet](hashID),
+	}
+}
+
+// grantSet is union of Permissions granted and denied to set of Roles, it is never modified once built
+type grantSet struct {
+	grants map[Permission]struct{}
+	denies map[Permission]struct{}
+}
+
+func (s *grantSet) equal(o *grantSet) bool {
+	return samePermissions(s.grants, o.grants) && samePermissions(s.denies, o.denies)
+}
+
+// effectiveUser keeps Role assignments of User in every Domain
+type effectiveUser struct {
+	domains map[Domain]*effectiveDomain
+}
+
+// effectiveDomain keeps Role assignments of User in Domain.
+// Grants of unbounded assignments are merged into one shared set, Roles assigned with Validity
+// are kept apart and checked when they are in effect.
+type effectiveDomain struct {
+	roles map[Role]Validity
+	// set is ID of set of unbounded Roles, zero if there are none
+	set     int
+	bounded []Role
+}
+
+// userHasPermission evaluates Permission for User in Domain the way RBAC.evaluateUserPermission does:
+// Permission is allowed if any Role in effect grants it and none denies it.
+func (v *view) userHasPermission(u User, p Permission, d Domain) (bool, error) {
+	e, ok := v.users.get(u)
+	if !ok {
+		return false, ErrorUserNotRegistered
+	}
+	implying := v.implyingPermissions(p)
+	if !v.permissionKnown(p, implying) {
+		return false, ErrorPermissionNotRegistered
+	}
+	ed, ok := e.domains[d]
+	if !ok {
+		return false, nil
+	}
+
+	denying := v.rules(nil, p)
+	granting := denying
+	for _, q := range implying {
+		granting = v.rules(granting, q)
+	}
+
+	granted := false
+	allows := func(s *grantSet) bool {
+		if containsAnyPermission(s.denies, denying) {
+			return false
+		}
+		granted = granted || containsAnyPermission(s.grants, granting)
+		return true
+	}
+	if ed.set != 0 {
+		if s, ok := v.sets.get(ed.set); ok && !allows(s) {
+			return false, nil
+		}
+	}
+	if len(ed.bounded) == 0 {
+		return granted, nil
+	}
+	now := v.now()
+	for _, r := range ed.bounded {
+		s, ok := v.roles.get(r)
+		if !ok || !ed.roles[r].ActiveAt(now) {
+			continue
+		}
+		if !allows(s) {
+			return false, nil
+		}
+	}
+	return granted, nil
+}
+
+// userHasRole checks if Role is assigned to User in Domain and currently in effect, as RBAC.userHasRole does
+func (v *view) userHasRole(u User, r Role, d Domain) (bool, error) {
+	e, ok := v.users.get(u)
+	if !ok {
+		return false, ErrorUserNotRegistered
+	}
+	if _, ok := v.roles.get(r); !ok {
+		return false, ErrorRoleNotRegistered
+	}
+	ed, ok := e.domains[d]
+	if !ok {
+		return false, nil
+	}
+	validity, ok := ed.roles[r]
+	return ok && validity.ActiveAt(v.now()), nil
+}
+
+// listUserRoles returns Roles assigned to User in Domain and currently in effect, as RBAC.listUserRoles does
+func (v *view) listUserRoles(u User, d Domain) ([]Role, error) {
+	e, ok := v.users.get(u)
+	if !ok {
+		return nil, ErrorUserNotRegistered
+	}
+	ed := e.domains[d]
+	if ed == nil {
+		return []Role{}, nil
+	}
+	now := v.now()
+	out := make([]Role, 0, len(ed.roles))
+	for r, validity := range ed.roles {
+		if validity.ActiveAt(now) {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+// implyingPermissions returns Permissions on the same Object with Actions implying Permission Action
+func (v *view) implyingPermissions(p Permission) []Permission {
+	if v.actions == nil {
+		return nil
+	}
+	implying := v.actions.implying[p.action]
+	if len(implying) == 0 {
+		return nil
+	}
+	out := make([]Permission, 0, len(implying))
+	for _, a := range implying {
+		out = append(out, NewPermission(p.object, a))
+	}
+	return out
+}
+
+// permissionKnown checks Permission the way RBAC.checkPermissionMatch does
+func (v *view) permissionKnown(p Permission, implying []Permission) bool {
+	if v.permissionMatched(p) {
+		return true
+	}
+	for _, q := range implying {
+		if v.permissionMatched(q) {
+			return true
+		}
+	}
+	return false
+}
+
+// permissionMatched checks Permission the way RBAC.permissionKnown does
+func (v *view) permissionMatched(p Permission) bool {
+	if _, ok := v.permissions.get(p); ok {
+		return true
+	}
+	for _, w := range v.wildcards {
+		if w.Match(p) {
+			return true
+		}
+	}
+	for _, a := range objectAncestorPermissions(p, v.separator) {
+		if exact, ok := v.permissions.get(a); ok && !exact {
+			return true
+		}
+		for _, w := range v.wildcards {
+			if w.Match(a) && !v.exact(w) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// rules appends to out rules which apply to Permission the way RBAC.matchingRule tries them:
+// Permission itself, wildcards matching it and the same for Object ancestors, unless rule is exact
+func (v *view) rules(out []Permission, p Permission) []Permission {
+	out = append(out, p)
+	for _, w := range v.wildcards {
+		if w != p && w.Match(p) {
+			out = append(out, w)
+		}
+	}
+	for _, a := range objectAncestorPermissions(p, v.separator) {
+		if !v.exact(a) {
+			out = append(out, a)
+		}
+		for _, w := range v.wildcards {
+			if w != a && w.Match(a) && !v.exact(w) {
+				out = append(out, w)
+			}
+		}
+	}
+	return out
+}
+
+// exact checks if Permission is registered as exact
+func (v *view) exact(p Permission) bool {
+	exact, _ := v.permissions.get(p)
+	return exact
+}
+
+// compare returns error describing the first difference of view from other one
+func (v *view) compare(o *view) error {
+	if !samePermissionFlags(v.permissions, o.permissions) || !samePermissionList(v.wildcards, o.wildcards) {
+		return fmt.Errorf("rbac: effective permission index keeps stale registered permissions")
+	}
+	if !sameActionGraph(v.actions, o.actions) {
+		return fmt.Errorf("rbac: effective permission index keeps stale action implications")
+	}
+
+	var err error
+	if v.roles.count() != o.roles.count() {
+		return fmt.Errorf("rbac: effective permission index keeps %d roles, expected %d", v.roles.count(), o.roles.count())
+	}
+	o.roles.each(func(r Role, s *grantSet) {
+		if kept, ok := v.roles.get(r); err == nil && (!ok || !kept.equal(s)) {
+			err = fmt.Errorf("rbac: effective permissions of role %q are stale", r.id)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if v.users.count() != o.users.count() {
+		return fmt.Errorf("rbac: effective permission index keeps %d users, expected %d", v.users.count(), o.users.count())
+	}
+	o.users.each(func(u User, e *effectiveUser) {
+		if err != nil {
+			return
+		}
+		kept, ok := v.users.get(u)
+		if !ok || len(kept.domains) != len(e.domains) {
+			err = fmt.Errorf("rbac: effective permissions of user %q are stale", u.ID())
+			return
+		}
+		for d, ed := range e.domains {
+			if !v.sameDomain(kept.domains[d], o, ed) {
+				err = fmt.Errorf("rbac: effective permissions of user %q in domain %q are stale", u.ID(), d.String())
+				return
+			}
+		}
+	})
+	return err
+}
+
+// sameDomain checks if entry of view keeps the same assignments and the same set as entry of other view
+func (v *view) sameDomain(e *effectiveDomain, o *view, oe *effectiveDomain) bool {
+	if e == nil || len(e.roles) != len(oe.roles) || (e.set == 0) != (oe.set == 0) {
+		return false
+	}
+	for r, validity := range oe.roles {
+		if kept, ok := e.roles[r]; !ok || !kept.Equal(validity) {
+			return false
+		}
+	}
+	if e.set == 0 {
+		return true
+	}
+	s, ok := v.sets.get(e.set)
+	other, _ := o.sets.get(oe.set)
+	return ok && s.equal(other)
+}
+
+// viewMap is persistent hash array mapped trie: node of each level maps 5 bits of key hash to entries
+// or nodes of the next level. It is never modified once published: new version made by viewMapWriter
+// copies only nodes on paths to keys it changes and shares all others, so publishing change of single entry
+// costs a few small allocations regardless of number of entries.
+type viewMap[K comparable, V any] struct {
+	root *viewNode[K, V]
+	hash func(K) uint32
+	size int
+}
+
+// viewNode is node of viewMap. Entries are ordered by 5 bits of hash node maps, bitmap marks bits present.
+// Node below all hash bits keeps keys with equal hashes in entries list, bitmap is unused there.
+type viewNode[K comparable, V any] struct {
+	// owner identifies writer which created node and may change it in place, see viewMapWriter.own
+	owner   *viewOwner
+	bitmap  uint32
+	entries []viewEntry[K, V]
+}
+
+// viewEntry is key and value, or node of the next level if child is set
+type viewEntry[K comparable, V any] struct {
+	hash  uint32
+	key   K
+	value V
+	child *viewNode[K, V]
+}
+
+// viewOwner identifies viewMapWriter. Nodes refer to it instead of writer itself,
+// so nodes shared by later versions do not keep earlier versions reachable.
+type viewOwner struct {
+	_ byte
+}
+
+// viewHashBits is number of hash bits each level of viewMap maps
+const viewHashBits = 5
+
+func newViewMap[K comparable, V any](hash func(K) uint32) *viewMap[K, V] {
+	return &viewMap[K, V]{hash: hash}
+}
+
+func (m *viewMap[K, V]) get(k K) (V, bool) {
+	h := m.hash(k)
+	n := m.root
+	for shift := uint(0); n != nil; shift += viewHashBits {
+		if shift >= 32 {
+			for _, e := range n.entries {
+				if e.key == k {
+					return e.value, true
+				}
+			}
+			break
+		}
+		i, ok := n.index(h, shift)
+		if !ok {
+			break
+		}
+		e := &n.entries[i]
+		if e.child == nil {
+			if e.key == k {
+				return e.value, true
+			}
+			break
+		}
+		n = e.child
+	}
+	var zero V
+	return zero, false
+}
+
+func (m *viewMap[K, V]) count() int {
+	return m.size
+}
+
+func (m *viewMap[K, V]) each(fn func(K, V)) {
+	m.root.each(fn)
+}
+
+func (n *viewNode[K, V]) each(fn func(K, V)) {
+	if n == nil {
+		return
+	}
+	for _, e := range n.entries {
+		if e.child != nil {
+			e.child.each(fn)
+		} else {
+			fn(e.key, e.value)
+		}
+	}
+}
+
+// index returns position of entry for hash bits at shift, false if there is none
+func (n *viewNode[K, V]) index(h uint32, shift uint) (int, bool) {
+	bit := uint32(1) << (h >> shift & 31)
+	return bits.OnesCount32(n.bitmap & (bit - 1)), n.bitmap&bit != 0
+}
+
+// writer starts new version of map
+func (m *viewMap[K, V]) writer() *viewMapWriter[K, V] {
+	next := *m
+	return &viewMapWriter[K, V]{viewMap: &next, owner: new(viewOwner)}
+}
+
+// viewMapWriter builds new version of viewMap. Nodes of previous versions are copied on their first change,
+// copies are owned by writer and changed in place afterwards, so batch of changes copies every node once.
+// Writer is dropped once its version is published, nothing changes published nodes then.
+type viewMapWriter[K comparable, V any] struct {
+	*viewMap[K, V]
+
+	owner *viewOwner
+}
+
+func (w *viewMapWriter[K, V]) set(k K, v V) {
+	added := false
+	w.root, added = w.insert(w.root, viewEntry[K, V]{hash: w.hash(k), key: k, value: v}, 0)
+	if added {
+		w.size++
+	}
+}
+
+func (w *viewMapWriter[K, V]) delete(k K) {
+	if w.root == nil {
+		return
+	}
+	removed := false
+	w.root, removed = w.remove(w.root, w.hash(k), k, 0)
+	if removed {
+		w.size--
+	}
+}
+
+// insert sets entry in subtree of n at shift, returns new subtree and whether key were added
+func (w *viewMapWriter[K, V]) insert(n *viewNode[K, V], e viewEntry[K, V], shift uint) (*viewNode[K, V], bool) {
+	if n == nil {
+		n = &viewNode[K, V]{owner: w.owner}
+	}
+	if shift >= 32 {
+		for i := range n.entries {
+			if n.entries[i].key == e.key {
+				n = w.own(n)
+				n.entries[i] = e
+				return n, false
+			}
+		}
+		n = w.own(n)
+		n.entries = append(n.entries, e)
+		return n, true
+	}
+
+	i, ok := n.index(e.hash, shift)
+	if !ok {
+		n = w.own(n)
+		n.bitmap |= uint32(1) << (e.hash >> shift & 31)
+		n.entries = append(n.entries, viewEntry[K, V]{})
+		copy(n.entries[i+1:], n.entries[i:])
+		n.entries[i] = e
+		return n, true
+	}
+
+	cur := n.entries[i]
+	switch {
+	case cur.child != nil:
+		child, added := w.insert(cur.child, e, shift+viewHashBits)
+		n = w.own(n)
+		n.entries[i].child = child
+		return n, added
+	case cur.key == e.key:
+		n = w.own(n)
+		n.entries[i] = e
+		return n, false
+	default:
+		n = w.own(n)
+		n.entries[i] = viewEntry[K, V]{hash: e.hash, child: w.pair(cur, e, shift+viewHashBits)}
+		return n, true
+	}
+}
+
+// pair builds subtree at shift holding two entries with hash bits equal above shift
+func (w *viewMapWriter[K, V]) pair(a, b viewEntry[K, V], shift uint) *viewNode[K, V] {
+	n := &viewNode[K, V]{owner: w.owner}
+	if shift >= 32 {
+		n.entries = []viewEntry[K, V]{a, b}
+		return n
+	}
+	ia, ib := a.hash>>shift&31, b.hash>>shift&31
+	n.bitmap = uint32(1)<<ia | uint32(1)<<ib
+	switch {
+	case ia == ib:
+		n.entries = []viewEntry[K, V]{{hash: a.hash, child: w.pair(a, b, shift+viewHashBits)}}
+	case ia < ib:
+		n.entries = []viewEntry[K, V]{a, b}
+	default:
+		n.entries = []viewEntry[K, V]{b, a}
+	}
+	return n
+}
+
+// remove deletes key from subtree of n at shift, returns new subtree and whether key were removed.
+// Node left with single entry is pulled up into its parent, so paths stay as short as keys require.
+func (w *viewMapWriter[K, V]) remove(n *viewNode[K, V], h uint32, k K, shift uint) (*viewNode[K, V], bool) {
+	if shift >= 32 {
+		for i := range n.entries {
+			if n.entries[i].key == k {
+				n = w.own(n)
+				n.entries = append(n.entries[:i], n.entries[i+1:]...)
+				return n, true
+			}
+		}
+		return n, false
+	}
+
+	i, ok := n.index(h, shift)
+	if !ok {
+		return n, false
+	}
+	cur := n.entries[i]
+	if cur.child == nil {
+		if cur.key != k {
+			return n, false
+		}
+		n = w.own(n)
+		n.bitmap &^= uint32(1) << (h >> shift & 31)
+		n.entries = append(n.entries[:i], n.entries[i+1:]...)
+		return n, true
+	}
+
+	child, removed := w.remove(cur.child, h, k, shift+viewHashBits)
+	if !removed {
+		return n, false
+	}
+	n = w.own(n)
+	if len(child.entries) == 1 && child.entries[0].child == nil {
+		n.entries[i] = child.entries[0]
+	} else {
+		n.entries[i].child = child
+	}
+	return n, true
+}
+
+// own returns node writer may change in place, copying node of previous version
+func (w *viewMapWriter[K, V]) own(n *viewNode[K, V]) *viewNode[K, V] {
+	if n.owner == w.owner {
+		return n
+	}
+	entries := make([]viewEntry[K, V], len(n.entries), len(n.entries)+1)
+	copy(entries, n.entries)
+	return &viewNode[K, V]{owner: w.owner, bitmap: n.bitmap, entries: entries}
+}
+
+// hashString is 32-bit FNV-1a hash of s
+func hashString(s string) uint32 {
+	h := uint32(2166136261)
+	for i := 0; i < len(s); i++ {
+		h ^= uint32(s[i])
+		h *= 16777619
+	}
+	return h
+}
+
+func hashUser(u User) uint32 {
+	return hashString(u.id)
+}
+
+func hashRole(r Role) uint32 {
+	return hashString(r.id)
+}
+
+func hashPermission(p Permission) uint32 {
+	return hashString(string(p.object))*16777619 ^ hashString(string(p.action))
+}
+
+func hashID(id int) uint32 {
+	return uint32(id)
+}
+
+func containsAnyPermission(set map[Permission]struct{}, list []Permission) bool {
+	for _, p := range list {
+		if _, ok := set[p]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func samePermissions(a, b map[Permission]struct{}) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for p := range a {
+		if _, ok := b[p]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func samePermissionFlags(a, b *viewMap[Permission, bool]) bool {
+	if a.count() != b.count() {
+		return false
+	}
+	same := true
+	a.each(func(p Permission, flag bool) {
+		if other, ok := b.get(p); !ok || other != flag {
+			same = false
+		}
+	})
+	return same
+}
+
+func samePermissionList(a, b []Permission) bool {
+	set := make(map[Permission]struct{}, len(a))
+	for _, p := range a {
+		set[p] = struct{}{}
+	}
+	other := make(map[Permission]struct{}, len(b))
+	for _, p := range b {
+		other[p] = struct{}{}
+	}
+	return samePermissions(set, other)
+}
+
+func sameActionGraph(a, b *actionGraph) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if len(a.implying) != len(b.implying) {
+		return false
+	}
+	for action, list := range a.implying {
+		other := b.implying[action]
+		if len(list) != len(other) {
+			return false
+		}
+		for i := range list {
+			if list[i] != other[i] {
+				return false
+			}
+		}
+	}
+	return true
+}
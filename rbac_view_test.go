@@ -0,0 +1,65 @@
+package rbac
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestViewMap(t *testing.T) {
+	// few distinct hashes put many keys below all hash bits
+	colliding := func(k int) uint32 { return uint32(k%3) << 30 }
+	for _, hash := range []func(int) uint32{hashID, colliding} {
+		m := newViewMap[int, int](hash)
+		expected := make(map[int]int)
+		rnd := rand.New(rand.NewSource(1))
+
+		for round := 0; round < 50; round++ {
+			published := m
+			kept := make(map[int]int, len(expected))
+			for k, v := range expected {
+				kept[k] = v
+			}
+
+			w := m.writer()
+			for i := 0; i < 40; i++ {
+				k := rnd.Intn(200)
+				if rnd.Intn(3) == 0 {
+					w.delete(k)
+					delete(expected, k)
+				} else {
+					w.set(k, round)
+					expected[k] = round
+				}
+			}
+			m = w.viewMap
+
+			// case 1: new version holds every change
+			if !sameViewMap(m, expected) {
+				t.Fatalf("[case 1] invalid output: round %d differs from expected content", round)
+			}
+
+			// case 2: published version is never modified
+			if !sameViewMap(published, kept) {
+				t.Fatalf("[case 2] invalid output: round %d changed published version", round)
+			}
+		}
+	}
+}
+
+func sameViewMap(m *viewMap[int, int], expected map[int]int) bool {
+	if m.count() != len(expected) {
+		return false
+	}
+	for k, v := range expected {
+		if got, ok := m.get(k); !ok || got != v {
+			return false
+		}
+	}
+	seen := 0
+	m.each(func(k, v int) {
+		if expected[k] == v {
+			seen++
+		}
+	})
+	return seen == len(expected)
+}
@@ -0,0 +1,133 @@
+// Package rbachttp provides net/http middleware authorizing requests with rbac controller.
+package rbachttp
+
+import (
+	"errors"
+	"net/http"
+
+	"rbac"
+)
+
+var (
+	// ErrorNoUser is passed to ErrorWriter when UserFunc finds no User in request
+	ErrorNoUser = errors.New("request has no user")
+	// ErrorForbidden is passed to ErrorWriter when controller denies request
+	ErrorForbidden = errors.New("access denied")
+)
+
+// UserFunc extracts User from request, returns false if request has no User
+type UserFunc func(r *http.Request) (rbac.User, bool)
+
+// PermissionFunc maps request to Object and Action to be checked
+type PermissionFunc func(r *http.Request) (rbac.Object, rbac.Action)
+
+// ErrorWriter writes response to rejected request.
+// err is ErrorNoUser, ErrorNoRoute, ErrorForbidden or error returned by controller.
+type ErrorWriter func(w http.ResponseWriter, r *http.Request, status int, err error)
+
+// Option configures middleware
+type Option func(*config)
+
+type config struct {
+	unauthorized int
+	forbidden    int
+	failed       int
+	noRoute      int
+	write        ErrorWriter
+}
+
+// WithUnauthorizedStatus sets status of response to request without User, 401 by default
+func WithUnauthorizedStatus(status int) Option {
+	return func(c *config) {
+		c.unauthorized = status
+	}
+}
+
+// WithForbiddenStatus sets status of response to denied request, 403 by default
+func WithForbiddenStatus(status int) Option {
+	return func(c *config) {
+		c.forbidden = status
+	}
+}
+
+// WithErrorStatus sets status of response to request failed by controller error, 500 by default
+func WithErrorStatus(status int) Option {
+	return func(c *config) {
+		c.failed = status
+	}
+}
+
+// WithNoRouteStatus sets status of response to request matching no route of RouteMap, 403 by default
+func WithNoRouteStatus(status int) Option {
+	return func(c *config) {
+		c.noRoute = status
+	}
+}
+
+// WithErrorWriter sets writer of responses to rejected requests.
+// Default writer responds with status text and never exposes err to client.
+func WithErrorWriter(write ErrorWriter) Option {
+	return func(c *config) {
+		c.write = write
+	}
+}
+
+// Middleware returns middleware passing request to next handler only if controller allows
+// Object and Action returned by permission to User returned by user.
+// Check is made in Domain carried by request context, see rbac.WithDomain, DefaultDomain if there is none.
+// Request context passed to next handler carries User, see rbac.UserFromContext.
+// User not registered in controller is denied, other controller errors, including unregistered Permission,
+// are reported as failures.
+func Middleware(controller *rbac.RBAC, user UserFunc, permission PermissionFunc, opts ...Option) func(http.Handler) http.Handler {
+	return authorize(controller, user, func(r *http.Request) (rbac.Object, rbac.Action, bool) {
+		o, a := permission(r)
+		return o, a, true
+	}, opts)
+}
+
+// authorize builds middleware checking Object and Action returned by resolve,
+// requests resolve returns false for are rejected with ErrorNoRoute.
+func authorize(controller *rbac.RBAC, user UserFunc, resolve func(r *http.Request) (rbac.Object, rbac.Action, bool), opts []Option) func(http.Handler) http.Handler {
+	c := config{
+		unauthorized: http.StatusUnauthorized,
+		forbidden:    http.StatusForbidden,
+		failed:       http.StatusInternalServerError,
+		noRoute:      http.StatusForbidden,
+		write:        writeStatusText,
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			u, ok := user(r)
+			if !ok {
+				c.write(w, r, c.unauthorized, ErrorNoUser)
+				return
+			}
+
+			o, a, ok := resolve(r)
+			if !ok {
+				c.write(w, r, c.noRoute, ErrorNoRoute)
+				return
+			}
+
+			allowed, err := controller.UserHasObjectActionInDomain(u, o, a, rbac.DomainFromContext(r.Context()))
+			switch {
+			case err == rbac.ErrorUserNotRegistered:
+				c.write(w, r, c.forbidden, ErrorForbidden)
+			case err != nil:
+				c.write(w, r, c.failed, err)
+			case !allowed:
+				c.write(w, r, c.forbidden, ErrorForbidden)
+			default:
+				next.ServeHTTP(w, r.WithContext(rbac.WithUser(r.Context(), u)))
+			}
+		})
+	}
+}
+
+func writeStatusText(w http.ResponseWriter, r *http.Request, status int, err error) {
+	http.Error(w, http.StatusText(status), status)
+}
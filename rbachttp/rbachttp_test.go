@@ -0,0 +1,147 @@
+package rbachttp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"rbac"
+)
+
+func newController() *rbac.RBAC {
+	controller := rbac.NewRBAC()
+
+	u := rbac.NewUser("alice")
+	r := rbac.NewRole("reader")
+	read := rbac.NewPermission(rbac.NewObject("invoice"), rbac.NewAction("GET"))
+	write := rbac.NewPermission(rbac.NewObject("invoice"), rbac.NewAction("POST"))
+
+	controller.RegisterUser(u)
+	controller.RegisterUser(rbac.NewUser("bob"))
+	controller.RegisterRole(r)
+	controller.RegisterPermission(read)
+	controller.RegisterPermission(write)
+	controller.AssignPermissionToRole(r, read)
+	controller.AssignRoleToUser(u, r)
+	return controller
+}
+
+func userFromHeader(r *http.Request) (rbac.User, bool) {
+	id := r.Header.Get("X-User")
+	return rbac.NewUser(id), id != ""
+}
+
+func invoiceMethod(r *http.Request) (rbac.Object, rbac.Action) {
+	return rbac.NewObject("invoice"), rbac.NewAction(r.Method)
+}
+
+var ok = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+})
+
+func serve(h http.Handler, method, user string) int {
+	req := httptest.NewRequest(method, "/invoices", nil)
+	if user != "" {
+		req.Header.Set("X-User", user)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestMiddleware(t *testing.T) {
+	h := Middleware(newController(), userFromHeader, invoiceMethod)(ok)
+
+	tests := []struct {
+		method string
+		user   string
+		status int
+	}{
+		// case 1: allowed
+		{http.MethodGet, "alice", http.StatusOK},
+		// case 2: no user
+		{http.MethodGet, "", http.StatusUnauthorized},
+		// case 3: denied
+		{http.MethodPost, "alice", http.StatusForbidden},
+		// case 4: user is not registered
+		{http.MethodGet, "mallory", http.StatusForbidden},
+		// case 5: permission is not registered
+		{http.MethodDelete, "alice", http.StatusInternalServerError},
+		// case 6: user has no roles
+		{http.MethodGet, "bob", http.StatusForbidden},
+	}
+
+	for i, test := range tests {
+		if status := serve(h, test.method, test.user); status != test.status {
+			t.Errorf("[case %d] invalid output: expected status %d, got %d", i+1, test.status, status)
+		}
+	}
+}
+
+func TestMiddlewareOptions(t *testing.T) {
+	var written error
+	h := Middleware(newController(), userFromHeader, invoiceMethod,
+		WithUnauthorizedStatus(http.StatusForbidden),
+		WithForbiddenStatus(http.StatusNotFound),
+		WithErrorStatus(http.StatusServiceUnavailable),
+		WithErrorWriter(func(w http.ResponseWriter, r *http.Request, status int, err error) {
+			written = err
+			w.WriteHeader(status)
+		}),
+	)(ok)
+
+	// case 1: no user
+	if status := serve(h, http.MethodGet, ""); status != http.StatusForbidden || written != ErrorNoUser {
+		t.Errorf("[case 1] invalid output: expected %d (%v), got %d (%v)", http.StatusForbidden, ErrorNoUser, status, written)
+	}
+
+	// case 2: denied
+	if status := serve(h, http.MethodPost, "alice"); status != http.StatusNotFound || written != ErrorForbidden {
+		t.Errorf("[case 2] invalid output: expected %d (%v), got %d (%v)", http.StatusNotFound, ErrorForbidden, status, written)
+	}
+
+	// case 3: controller error is passed to writer
+	status := serve(h, http.MethodDelete, "alice")
+	if status != http.StatusServiceUnavailable || !errors.Is(written, rbac.ErrorPermissionNotRegistered) {
+		t.Errorf("[case 3] invalid output: expected %d (%v), got %d (%v)", http.StatusServiceUnavailable, rbac.ErrorPermissionNotRegistered, status, written)
+	}
+}
+
+func TestMiddlewareContext(t *testing.T) {
+	var got rbac.User
+	h := Middleware(newController(), userFromHeader, invoiceMethod)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = rbac.UserFromContext(r.Context())
+	}))
+
+	serve(h, http.MethodGet, "alice")
+	if got != rbac.NewUser("alice") {
+		t.Errorf("invalid output: expected %v in context, got %v", rbac.NewUser("alice"), got)
+	}
+}
+
+func TestMiddlewareDomain(t *testing.T) {
+	controller := newController()
+	acme := rbac.NewDomain("acme")
+	controller.AssignRoleToUserInDomain(rbac.NewUser("bob"), rbac.NewRole("reader"), acme)
+
+	h := Middleware(controller, userFromHeader, invoiceMethod)(ok)
+	withDomain := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(rbac.WithDomain(r.Context(), acme)))
+		})
+	}
+
+	// case 1: Role assigned in Domain of request context applies
+	if status := serve(withDomain(h), http.MethodGet, "bob"); status != http.StatusOK {
+		t.Errorf("[case 1] invalid output: expected status %d, got %d", http.StatusOK, status)
+	}
+
+	// case 2: Role assigned in other Domain does not apply
+	if status := serve(h, http.MethodGet, "bob"); status != http.StatusForbidden {
+		t.Errorf("[case 2] invalid output: expected status %d, got %d", http.StatusForbidden, status)
+	}
+	if status := serve(withDomain(h), http.MethodGet, "alice"); status != http.StatusForbidden {
+		t.Errorf("[case 2] invalid output: expected status %d, got %d", http.StatusForbidden, status)
+	}
+}
@@ -0,0 +1,233 @@
+package rbachttp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"rbac"
+)
+
+// ErrorNoRoute is passed to ErrorWriter when RouteMap has no route matching request
+var ErrorNoRoute = errors.New("no route matches request")
+
+// RouteConflictError is returned when added route overlaps existing one and neither of them is more specific
+type RouteConflictError struct {
+	Route    string
+	Existing string
+}
+
+func (e *RouteConflictError) Error() string {
+	return fmt.Sprintf("rbachttp: route %q conflicts with %q", e.Route, e.Existing)
+}
+
+// RouteMap maps requests to Permissions by method and path patterns.
+// Route is written as "[METHOD ]PATH", e.g. "GET /invoices/{id}". Route without method matches any method.
+// Path segment "{name}" matches any single segment, last segment "{name...}" matches one or more remaining segments.
+//
+// When several routes match request, the most specific one wins: route A is more specific than B
+// if every request matching A also matches B. Adding route which overlaps existing one while neither is
+// more specific, or duplicates it, is rejected.
+// RouteMap is safe for concurrent use.
+type RouteMap struct {
+	mutex  sync.RWMutex
+	routes []route
+}
+
+type route struct {
+	raw        string
+	method     string
+	segments   []segment
+	rest       bool
+	permission rbac.Permission
+}
+
+type segment struct {
+	literal string
+	param   bool
+}
+
+// NewRouteMap creates empty RouteMap
+func NewRouteMap() *RouteMap {
+	return &RouteMap{}
+}
+
+// Add maps requests matching route to Permission.
+// Returns *RouteConflictError if route is ambiguous with already added one.
+func (m *RouteMap) Add(pattern string, p rbac.Permission) error {
+	rt, err := parseRoute(pattern)
+	if err != nil {
+		return err
+	}
+	rt.permission = p
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, existing := range m.routes {
+		if !rt.overlaps(existing) {
+			continue
+		}
+		if rt.within(existing) != existing.within(rt) {
+			continue
+		}
+		return &RouteConflictError{Route: pattern, Existing: existing.raw}
+	}
+	m.routes = append(m.routes, rt)
+	return nil
+}
+
+// Resolve returns Permission of the most specific route matching request.
+// Returns false if no route matches.
+func (m *RouteMap) Resolve(r *http.Request) (rbac.Permission, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	parts := splitPath(r.URL.Path)
+	var best *route
+	for i := range m.routes {
+		rt := &m.routes[i]
+		if !rt.matches(r.Method, parts) {
+			continue
+		}
+		if best == nil || rt.within(*best) {
+			best = rt
+		}
+	}
+	if best == nil {
+		return rbac.Permission{}, false
+	}
+	return best.permission, true
+}
+
+// Middleware returns middleware authorizing requests with Permissions resolved by RouteMap.
+// Requests matching no route are rejected with ErrorNoRoute and status set by WithNoRouteStatus, 403 by default.
+// See Middleware for other rules.
+func (m *RouteMap) Middleware(controller *rbac.RBAC, user UserFunc, opts ...Option) func(http.Handler) http.Handler {
+	return authorize(controller, user, func(r *http.Request) (rbac.Object, rbac.Action, bool) {
+		p, ok := m.Resolve(r)
+		return p.Object(), p.Action(), ok
+	}, opts)
+}
+
+func parseRoute(pattern string) (route, error) {
+	rt := route{raw: pattern}
+
+	path := pattern
+	if i := strings.IndexByte(pattern, ' '); i >= 0 {
+		rt.method, path = pattern[:i], strings.TrimLeft(pattern[i+1:], " ")
+		if rt.method == "" {
+			return rt, fmt.Errorf("rbachttp: invalid route %q: empty method", pattern)
+		}
+	}
+	if !strings.HasPrefix(path, "/") {
+		return rt, fmt.Errorf("rbachttp: invalid route %q: path has to start with /", pattern)
+	}
+
+	names := make(map[string]struct{})
+	parts := splitPath(path)
+	for i, part := range parts {
+		if !strings.HasPrefix(part, "{") || !strings.HasSuffix(part, "}") {
+			if strings.ContainsAny(part, "{}") {
+				return rt, fmt.Errorf("rbachttp: invalid route %q: segment %q", pattern, part)
+			}
+			rt.segments = append(rt.segments, segment{literal: part})
+			continue
+		}
+
+		name := part[1 : len(part)-1]
+		if strings.HasSuffix(name, "...") {
+			if i != len(parts)-1 {
+				return rt, fmt.Errorf("rbachttp: invalid route %q: %s has to be the last segment", pattern, part)
+			}
+			name = strings.TrimSuffix(name, "...")
+			rt.rest = true
+		}
+		if name == "" || strings.ContainsAny(name, "{}") {
+			return rt, fmt.Errorf("rbachttp: invalid route %q: segment %q", pattern, part)
+		}
+		if _, ok := names[name]; ok {
+			return rt, fmt.Errorf("rbachttp: invalid route %q: duplicated name %q", pattern, name)
+		}
+		names[name] = struct{}{}
+		if !rt.rest {
+			rt.segments = append(rt.segments, segment{param: true})
+		}
+	}
+	return rt, nil
+}
+
+// splitPath splits path into segments, "/" has single empty segment
+func splitPath(path string) []string {
+	return strings.Split(strings.TrimPrefix(path, "/"), "/")
+}
+
+// matches checks if route matches request method and path segments
+func (rt route) matches(method string, parts []string) bool {
+	if rt.method != "" && rt.method != method {
+		return false
+	}
+	if rt.rest {
+		if len(parts) <= len(rt.segments) {
+			return false
+		}
+	} else if len(parts) != len(rt.segments) {
+		return false
+	}
+	for i, s := range rt.segments {
+		if !s.param && s.literal != parts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// overlaps checks if some request matches both routes
+func (rt route) overlaps(o route) bool {
+	if rt.method != "" && o.method != "" && rt.method != o.method {
+		return false
+	}
+
+	switch {
+	case !rt.rest && !o.rest && len(rt.segments) != len(o.segments):
+		return false
+	case rt.rest && !o.rest && len(o.segments) <= len(rt.segments):
+		return false
+	case o.rest && !rt.rest && len(rt.segments) <= len(o.segments):
+		return false
+	}
+
+	for i := 0; i < len(rt.segments) && i < len(o.segments); i++ {
+		a, b := rt.segments[i], o.segments[i]
+		if !a.param && !b.param && a.literal != b.literal {
+			return false
+		}
+	}
+	return true
+}
+
+// within checks if every request matching rt also matches o
+func (rt route) within(o route) bool {
+	if o.method != "" && rt.method != o.method {
+		return false
+	}
+
+	if o.rest {
+		// segments matched by rest of rt have to be matched by rest of o
+		if len(rt.segments) < len(o.segments) || (!rt.rest && len(rt.segments) == len(o.segments)) {
+			return false
+		}
+	} else if rt.rest || len(rt.segments) != len(o.segments) {
+		return false
+	}
+
+	for i, b := range o.segments {
+		a := rt.segments[i]
+		if !b.param && (a.param || a.literal != b.literal) {
+			return false
+		}
+	}
+	return true
+}
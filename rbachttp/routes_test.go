@@ -0,0 +1,131 @@
+package rbachttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"rbac"
+)
+
+func permission(o, a string) rbac.Permission {
+	return rbac.NewPermission(rbac.NewObject(o), rbac.NewAction(a))
+}
+
+func TestRouteMapAdd(t *testing.T) {
+	m := NewRouteMap()
+	m.Add("GET /invoices/{id}", permission("invoice", "read"))
+	m.Add("/files/{path...}", permission("file", "any"))
+
+	tests := []struct {
+		route    string
+		conflict bool
+		invalid  bool
+	}{
+		// case 1: more specific literal segment
+		{"GET /invoices/latest", false, false},
+		// case 2: other method
+		{"DELETE /invoices/{id}", false, false},
+		// case 3: duplicate with other parameter name
+		{"GET /invoices/{invoice}", true, false},
+		// case 4: neither is more specific
+		{"/invoices/latest", true, false},
+		// case 5: more specific than rest
+		{"GET /files/{dir}/{name}", false, false},
+		// case 6: rest is less specific than single parameter
+		{"/files/{dir}/{rest...}", false, false},
+		// case 7: invalid routes
+		{"invoices", false, true},
+		{"GET /files/{path...}/x", false, true},
+		{"GET /a/{x}/{x}", false, true},
+		{"GET /a/{}", false, true},
+	}
+
+	for i, test := range tests {
+		err := m.Add(test.route, permission("o", "a"))
+		_, conflict := err.(*RouteConflictError)
+		if conflict != test.conflict || (err != nil && !conflict) != test.invalid {
+			t.Errorf("[case %d] invalid output for %q: expected conflict %t, invalid %t, got %v", i+1, test.route, test.conflict, test.invalid, err)
+		}
+	}
+}
+
+func TestRouteMapResolve(t *testing.T) {
+	m := NewRouteMap()
+	routes := map[string]rbac.Permission{
+		"GET /invoices":              permission("invoice", "list"),
+		"GET /invoices/{id}":         permission("invoice", "read"),
+		"GET /invoices/latest":       permission("invoice", "latest"),
+		"/invoices/{id}":             permission("invoice", "write"),
+		"/files/{path...}":           permission("file", "any"),
+		"GET /files/public/{name}":   permission("file", "public"),
+		"GET /files/{dir}/{rest...}": permission("file", "dir"),
+	}
+	for route, p := range routes {
+		if err := m.Add(route, p); err != nil {
+			t.Fatalf("add error: expected err equal nil, got %v", err)
+		}
+	}
+
+	tests := []struct {
+		method   string
+		path     string
+		expected rbac.Permission
+		found    bool
+	}{
+		// case 1: exact route
+		{http.MethodGet, "/invoices", permission("invoice", "list"), true},
+		// case 2: parameter
+		{http.MethodGet, "/invoices/42", permission("invoice", "read"), true},
+		// case 3: literal beats parameter
+		{http.MethodGet, "/invoices/latest", permission("invoice", "latest"), true},
+		// case 4: any method
+		{http.MethodPut, "/invoices/42", permission("invoice", "write"), true},
+		// case 5: rest
+		{http.MethodPut, "/files/a/b/c", permission("file", "any"), true},
+		// case 6: most specific of three matching routes
+		{http.MethodGet, "/files/public/logo.png", permission("file", "public"), true},
+		{http.MethodGet, "/files/private/a/b", permission("file", "dir"), true},
+		// case 7: no route
+		{http.MethodGet, "/reports", rbac.Permission{}, false},
+		{http.MethodGet, "/files", rbac.Permission{}, false},
+	}
+
+	for i, test := range tests {
+		p, found := m.Resolve(httptest.NewRequest(test.method, test.path, nil))
+		if found != test.found || p != test.expected {
+			t.Errorf("[case %d] invalid output for %s %s: expected %v (%t), got %v (%t)", i+1, test.method, test.path, test.expected, test.found, p, found)
+		}
+	}
+}
+
+func TestRouteMapMiddleware(t *testing.T) {
+	m := NewRouteMap()
+	m.Add("GET /invoices/{id}", permission("invoice", "GET"))
+	m.Add("POST /invoices/{id}", permission("invoice", "POST"))
+
+	h := m.Middleware(newController(), userFromHeader)(ok)
+
+	tests := []struct {
+		method string
+		status int
+	}{
+		// case 1: allowed
+		{http.MethodGet, http.StatusOK},
+		// case 2: denied
+		{http.MethodPost, http.StatusForbidden},
+		// case 3: no route
+		{http.MethodDelete, http.StatusForbidden},
+	}
+
+	for i, test := range tests {
+		req := httptest.NewRequest(test.method, "/invoices/42", nil)
+		req.Header.Set("X-User", "alice")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != test.status {
+			t.Errorf("[case %d] invalid output: expected status %d, got %d", i+1, test.status, rec.Code)
+		}
+	}
+}
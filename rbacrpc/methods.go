@@ -0,0 +1,70 @@
+package rbacrpc
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"rbac"
+)
+
+// MethodMap maps full RPC method names to Permissions.
+// Method is written as "/package.Service/Method", "/package.Service/*" covers all methods of service
+// not mapped explicitly.
+// MethodMap is safe for concurrent use.
+type MethodMap struct {
+	mutex   sync.RWMutex
+	methods map[string]rbac.Permission
+}
+
+// NewMethodMap creates empty MethodMap
+func NewMethodMap() *MethodMap {
+	return &MethodMap{methods: make(map[string]rbac.Permission)}
+}
+
+// Add maps full method name to Permission.
+// Returns error if name is malformed or already mapped.
+func (m *MethodMap) Add(fullMethod string, p rbac.Permission) error {
+	if _, _, ok := splitMethod(fullMethod); !ok {
+		return fmt.Errorf("rbacrpc: invalid method %q: expected /package.Service/Method", fullMethod)
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, ok := m.methods[fullMethod]; ok {
+		return fmt.Errorf("rbacrpc: method %q is already mapped", fullMethod)
+	}
+	m.methods[fullMethod] = p
+	return nil
+}
+
+// Resolve returns Permission of full method name, falling back to service wildcard.
+// Returns false if method is not mapped.
+func (m *MethodMap) Resolve(fullMethod string) (rbac.Permission, bool) {
+	service, _, ok := splitMethod(fullMethod)
+	if !ok {
+		return rbac.Permission{}, false
+	}
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if p, ok := m.methods[fullMethod]; ok {
+		return p, true
+	}
+	p, ok := m.methods["/"+service+"/*"]
+	return p, ok
+}
+
+// splitMethod splits "/package.Service/Method" into service and method names
+func splitMethod(fullMethod string) (string, string, bool) {
+	if !strings.HasPrefix(fullMethod, "/") {
+		return "", "", false
+	}
+	parts := strings.Split(fullMethod[1:], "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
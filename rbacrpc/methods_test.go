@@ -0,0 +1,66 @@
+package rbacrpc
+
+import (
+	"testing"
+
+	"rbac"
+)
+
+func permission(o, a string) rbac.Permission {
+	return rbac.NewPermission(rbac.NewObject(o), rbac.NewAction(a))
+}
+
+func TestMethodMapAdd(t *testing.T) {
+	m := NewMethodMap()
+
+	tests := []struct {
+		method string
+		valid  bool
+	}{
+		// case 1: method
+		{"/billing.Invoices/Get", true},
+		// case 2: service wildcard
+		{"/billing.Invoices/*", true},
+		// case 3: duplicate
+		{"/billing.Invoices/Get", false},
+		// case 4: malformed
+		{"billing.Invoices/Get", false},
+		{"/billing.Invoices", false},
+		{"/billing.Invoices/Get/x", false},
+	}
+
+	for i, test := range tests {
+		err := m.Add(test.method, permission("invoice", "read"))
+		if (err == nil) != test.valid {
+			t.Errorf("[case %d] invalid output for %q: expected valid %t, got %v", i+1, test.method, test.valid, err)
+		}
+	}
+}
+
+func TestMethodMapResolve(t *testing.T) {
+	m := NewMethodMap()
+	m.Add("/billing.Invoices/Get", permission("invoice", "read"))
+	m.Add("/billing.Invoices/*", permission("invoice", "write"))
+
+	tests := []struct {
+		method   string
+		expected rbac.Permission
+		found    bool
+	}{
+		// case 1: exact method
+		{"/billing.Invoices/Get", permission("invoice", "read"), true},
+		// case 2: service wildcard
+		{"/billing.Invoices/Delete", permission("invoice", "write"), true},
+		// case 3: other service
+		{"/billing.Payments/Get", rbac.Permission{}, false},
+		// case 4: malformed
+		{"Get", rbac.Permission{}, false},
+	}
+
+	for i, test := range tests {
+		p, found := m.Resolve(test.method)
+		if found != test.found || p != test.expected {
+			t.Errorf("[case %d] invalid output for %q: expected %v (%t), got %v (%t)", i+1, test.method, test.expected, test.found, p, found)
+		}
+	}
+}
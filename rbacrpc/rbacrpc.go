@@ -0,0 +1,155 @@
+// Package rbacrpc provides gRPC-style server interceptors authorizing calls with rbac controller.
+//
+// Interceptor types mirror shapes of google.golang.org/grpc ones without importing grpc.
+// UnaryServerInfo and StreamServerInfo have the same layout as grpc types, so pointers to them
+// can be converted directly:
+//
+//	authz := rbacrpc.UnaryInterceptor(controller, methods, userFromContext)
+//	grpc.UnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+//		return authz(ctx, req, (*rbacrpc.UnaryServerInfo)(info), rbacrpc.UnaryHandler(handler))
+//	})
+//
+// Stream handlers get stream with context carrying User, grpc.ServerStream passed on has to take it over:
+//
+//	streamAuthz := rbacrpc.StreamInterceptor(controller, methods, userFromContext)
+//	grpc.StreamInterceptor(func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+//		return streamAuthz(srv, ss, (*rbacrpc.StreamServerInfo)(info), func(srv any, s rbacrpc.ServerStream) error {
+//			return handler(srv, &contextStream{ServerStream: ss, ctx: s.Context()})
+//		})
+//	})
+package rbacrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"rbac"
+)
+
+var (
+	// ErrorNoUser means call context has no User
+	ErrorNoUser = errors.New("call has no user")
+	// ErrorNoMethod means called method is not mapped to Permission
+	ErrorNoMethod = errors.New("method is not mapped to permission")
+	// ErrorForbidden means controller denied the call
+	ErrorForbidden = errors.New("access denied")
+)
+
+// DeniedError is returned by interceptors for rejected calls.
+// Callers convert it to status code, e.g. Unauthenticated or PermissionDenied.
+type DeniedError struct {
+	FullMethod string
+	// Err is ErrorNoUser, ErrorNoMethod or ErrorForbidden
+	Err error
+}
+
+func (e *DeniedError) Error() string {
+	return fmt.Sprintf("rbacrpc: %s: %v", e.FullMethod, e.Err)
+}
+
+func (e *DeniedError) Unwrap() error {
+	return e.Err
+}
+
+// Unauthenticated checks if call was rejected because it has no User
+func (e *DeniedError) Unauthenticated() bool {
+	return e.Err == ErrorNoUser
+}
+
+type (
+	// UserFunc extracts User from call context, returns false if there is no User.
+	// rbac.UserFromContext may be used if authentication stores User with rbac.WithUser.
+	UserFunc func(ctx context.Context) (rbac.User, bool)
+
+	// UnaryServerInfo mirrors grpc.UnaryServerInfo
+	UnaryServerInfo struct {
+		Server     interface{}
+		FullMethod string
+	}
+	// UnaryHandler mirrors grpc.UnaryHandler
+	UnaryHandler func(ctx context.Context, req interface{}) (interface{}, error)
+	// UnaryServerInterceptor mirrors grpc.UnaryServerInterceptor
+	UnaryServerInterceptor func(ctx context.Context, req interface{}, info *UnaryServerInfo, handler UnaryHandler) (interface{}, error)
+
+	// ServerStream is the part of grpc.ServerStream interceptors need
+	ServerStream interface {
+		Context() context.Context
+	}
+	// StreamServerInfo mirrors grpc.StreamServerInfo
+	StreamServerInfo struct {
+		FullMethod     string
+		IsClientStream bool
+		IsServerStream bool
+	}
+	// StreamHandler mirrors grpc.StreamHandler
+	StreamHandler func(srv interface{}, stream ServerStream) error
+	// StreamServerInterceptor mirrors grpc.StreamServerInterceptor
+	StreamServerInterceptor func(srv interface{}, stream ServerStream, info *StreamServerInfo, handler StreamHandler) error
+)
+
+// Authorize checks that User of ctx may call method according to methods and controller
+// in Domain carried by ctx, see rbac.WithDomain, DefaultDomain if there is none.
+// Returns *DeniedError if call is rejected. User not registered in controller is denied,
+// other controller errors, including unregistered Permission, are returned as is.
+func Authorize(ctx context.Context, controller *rbac.RBAC, methods *MethodMap, user UserFunc, fullMethod string) error {
+	u, ok := user(ctx)
+	if !ok {
+		return &DeniedError{FullMethod: fullMethod, Err: ErrorNoUser}
+	}
+	p, ok := methods.Resolve(fullMethod)
+	if !ok {
+		return &DeniedError{FullMethod: fullMethod, Err: ErrorNoMethod}
+	}
+
+	allowed, err := controller.UserHasObjectActionInDomain(u, p.Object(), p.Action(), rbac.DomainFromContext(ctx))
+	if err == rbac.ErrorUserNotRegistered {
+		return &DeniedError{FullMethod: fullMethod, Err: ErrorForbidden}
+	}
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return &DeniedError{FullMethod: fullMethod, Err: ErrorForbidden}
+	}
+	return nil
+}
+
+// UnaryInterceptor returns interceptor calling handler only if Authorize allows the call.
+// Context passed to handler carries User, see rbac.UserFromContext.
+func UnaryInterceptor(controller *rbac.RBAC, methods *MethodMap, user UserFunc) UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *UnaryServerInfo, handler UnaryHandler) (interface{}, error) {
+		if err := Authorize(ctx, controller, methods, user, info.FullMethod); err != nil {
+			return nil, err
+		}
+		if u, ok := user(ctx); ok {
+			ctx = rbac.WithUser(ctx, u)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor returns interceptor calling handler only if Authorize allows the call.
+// Stream passed to handler wraps stream, its Context carries User, see rbac.UserFromContext.
+func StreamInterceptor(controller *rbac.RBAC, methods *MethodMap, user UserFunc) StreamServerInterceptor {
+	return func(srv interface{}, stream ServerStream, info *StreamServerInfo, handler StreamHandler) error {
+		ctx := stream.Context()
+		if err := Authorize(ctx, controller, methods, user, info.FullMethod); err != nil {
+			return err
+		}
+		if u, ok := user(ctx); ok {
+			stream = &userStream{ServerStream: stream, ctx: rbac.WithUser(ctx, u)}
+		}
+		return handler(srv, stream)
+	}
+}
+
+// userStream is ServerStream with context carrying User
+type userStream struct {
+	ServerStream
+	ctx context.Context
+}
+
+func (s *userStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,155 @@
+package rbacrpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"rbac"
+)
+
+type userKey struct{}
+
+func userFromContext(ctx context.Context) (rbac.User, bool) {
+	u, ok := ctx.Value(userKey{}).(rbac.User)
+	return u, ok
+}
+
+func withUser(id string) context.Context {
+	return context.WithValue(context.Background(), userKey{}, rbac.NewUser(id))
+}
+
+func newAuthorization() (*rbac.RBAC, *MethodMap) {
+	controller := rbac.NewRBAC()
+
+	u := rbac.NewUser("alice")
+	r := rbac.NewRole("reader")
+	read := permission("invoice", "read")
+	write := permission("invoice", "write")
+
+	controller.RegisterUser(u)
+	controller.RegisterRole(r)
+	controller.RegisterPermission(read)
+	controller.RegisterPermission(write)
+	controller.AssignPermissionToRole(r, read)
+	controller.AssignRoleToUser(u, r)
+
+	methods := NewMethodMap()
+	methods.Add("/billing.Invoices/Get", read)
+	methods.Add("/billing.Invoices/Delete", write)
+	methods.Add("/billing.Invoices/Export", permission("invoice", "export"))
+	return controller, methods
+}
+
+func TestAuthorize(t *testing.T) {
+	controller, methods := newAuthorization()
+	acme := rbac.NewDomain("acme")
+	controller.RegisterUser(rbac.NewUser("bob"))
+	controller.AssignRoleToUserInDomain(rbac.NewUser("bob"), rbac.NewRole("reader"), acme)
+
+	tests := []struct {
+		ctx    context.Context
+		method string
+		err    error
+	}{
+		// case 1: allowed
+		{withUser("alice"), "/billing.Invoices/Get", nil},
+		// case 2: no user
+		{context.Background(), "/billing.Invoices/Get", ErrorNoUser},
+		// case 3: method is not mapped
+		{withUser("alice"), "/billing.Invoices/List", ErrorNoMethod},
+		// case 4: denied
+		{withUser("alice"), "/billing.Invoices/Delete", ErrorForbidden},
+		// case 5: user is not registered
+		{withUser("mallory"), "/billing.Invoices/Get", ErrorForbidden},
+		// case 6: permission is not registered
+		{withUser("alice"), "/billing.Invoices/Export", rbac.ErrorPermissionNotRegistered},
+		// case 7: Role assigned in Domain of ctx applies
+		{rbac.WithDomain(withUser("bob"), acme), "/billing.Invoices/Get", nil},
+		// case 8: Role assigned in other Domain does not apply
+		{withUser("bob"), "/billing.Invoices/Get", ErrorForbidden},
+	}
+
+	for i, test := range tests {
+		err := Authorize(test.ctx, controller, methods, userFromContext, test.method)
+		if !errors.Is(err, test.err) || (err != nil && test.err == nil) {
+			t.Errorf("[case %d] invalid output: expected err %v, got %v", i+1, test.err, err)
+		}
+	}
+
+	// denied error tells unauthenticated calls apart
+	var denied *DeniedError
+	err := Authorize(context.Background(), controller, methods, userFromContext, "/billing.Invoices/Get")
+	if !errors.As(err, &denied) || !denied.Unauthenticated() {
+		t.Errorf("[unauthenticated] invalid output: expected unauthenticated *DeniedError, got %v", err)
+	}
+}
+
+// grpcUnaryServerInfo replicates layout of grpc.UnaryServerInfo
+type grpcUnaryServerInfo struct {
+	Server     interface{}
+	FullMethod string
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	controller, methods := newAuthorization()
+	intercept := UnaryInterceptor(controller, methods, userFromContext)
+
+	called := false
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		if _, ok := rbac.UserFromContext(ctx); !ok {
+			t.Errorf("handler context carries no user")
+		}
+		called = true
+		return req, nil
+	}
+
+	// case 1: allowed call reaches handler
+	info := &grpcUnaryServerInfo{FullMethod: "/billing.Invoices/Get"}
+	resp, err := intercept(withUser("alice"), "req", (*UnaryServerInfo)(info), handler)
+	if err != nil || !called || resp != "req" {
+		t.Errorf("[case 1] invalid output: expected handler response, got %v (err %v)", resp, err)
+	}
+
+	// case 2: denied call does not reach handler
+	called = false
+	_, err = intercept(withUser("alice"), "req", &UnaryServerInfo{FullMethod: "/billing.Invoices/Delete"}, handler)
+	if !errors.Is(err, ErrorForbidden) || called {
+		t.Errorf("[case 2] invalid output: expected %v, got %v (handler called %t)", ErrorForbidden, err, called)
+	}
+}
+
+type stream struct {
+	ctx context.Context
+}
+
+func (s stream) Context() context.Context {
+	return s.ctx
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	controller, methods := newAuthorization()
+	intercept := StreamInterceptor(controller, methods, userFromContext)
+
+	called := false
+	handler := func(srv interface{}, ss ServerStream) error {
+		if _, ok := rbac.UserFromContext(ss.Context()); !ok {
+			t.Errorf("handler stream context carries no user")
+		}
+		called = true
+		return nil
+	}
+
+	// case 1: allowed
+	err := intercept(nil, stream{withUser("alice")}, &StreamServerInfo{FullMethod: "/billing.Invoices/Get"}, handler)
+	if err != nil || !called {
+		t.Errorf("[case 1] invalid output: expected handler call, got %v (handler called %t)", err, called)
+	}
+
+	// case 2: no user
+	called = false
+	err = intercept(nil, stream{context.Background()}, &StreamServerInfo{FullMethod: "/billing.Invoices/Get"}, handler)
+	if !errors.Is(err, ErrorNoUser) || called {
+		t.Errorf("[case 2] invalid output: expected %v, got %v (handler called %t)", ErrorNoUser, err, called)
+	}
+}
@@ -0,0 +1,115 @@
+package rbac
+
+import "sync"
+
+// Session is set of Roles User activated for a unit of work, e.g. a request.
+// Session checks take into account only active Roles which are still assigned to User and in effect,
+// so Session never grants more than User holds and usually grants less.
+// Session is safe for concurrent use.
+type Session struct {
+	rbac   *RBAC
+	user   User
+	domain Domain
+
+	mutex  sync.RWMutex
+	active map[Role]struct{}
+}
+
+// User returns owner of Session
+func (s *Session) User() User {
+	return s.user
+}
+
+// Domain returns Domain Session Roles are assigned in
+func (s *Session) Domain() Domain {
+	return s.domain
+}
+
+// ActiveRoles returns Roles active in Session
+func (s *Session) ActiveRoles() []Role {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.activeRoles()
+}
+
+// AddActiveRole activates Role in Session.
+// Role has to be assigned to Session User and in effect.
+// Returns *DSDViolationError if Role can not be active together with already active Roles,
+// false if Role is already active.
+func (s *Session) AddActiveRole(r Role) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.active[r]; ok {
+		return false, nil
+	}
+
+	s.rbac.mutex.RLock()
+	defer s.rbac.mutex.RUnlock()
+
+	if err := s.rbac.checkSessionRoles(s.user, s.domain, []Role{r}); err != nil {
+		return false, err
+	}
+	if err := s.rbac.checkDSD(s.user, append(s.activeRoles(), r)); err != nil {
+		return false, err
+	}
+	s.active[r] = struct{}{}
+	return true, nil
+}
+
+// DropActiveRole deactivates Role in Session.
+// Returns false if Role is not active.
+func (s *Session) DropActiveRole(r Role) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.active[r]; !ok {
+		return false
+	}
+	delete(s.active, r)
+	return true
+}
+
+// HasPermission checks if any of active Roles has provided Permission, directly or inherited.
+// Active Roles which were removed from User or expired since activation are ignored.
+// Evaluation follows UserHasPermission rules otherwise.
+func (s *Session) HasPermission(p Permission) (bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	s.rbac.mutex.RLock()
+	defer s.rbac.mutex.RUnlock()
+
+	if err := s.rbac.checkUser(s.user); err != nil {
+		return false, err
+	}
+	wildcards, err := s.rbac.checkPermissionMatch(p)
+	if err != nil {
+		return false, err
+	}
+
+	assigned, err := s.rbac.activeUserRoles(s.user, s.domain)
+	if err != nil {
+		return false, err
+	}
+	roles := make([]Role, 0, len(s.active))
+	for _, r := range assigned {
+		if _, ok := s.active[r]; ok {
+			roles = append(roles, r)
+		}
+	}
+	return s.rbac.rolesHavePermission(roles, p, wildcards)
+}
+
+// HasObjectAction checks if any of active Roles has Permission with provided Object and Action.
+// See HasPermission.
+func (s *Session) HasObjectAction(o Object, a Action) (bool, error) {
+	return s.HasPermission(NewPermission(o, a))
+}
+
+// activeRoles returns active Roles.
+// Caller has to hold Session mutex.
+func (s *Session) activeRoles() []Role {
+	return rolesOf(s.active)
+}
@@ -0,0 +1,109 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+type migration struct {
+	version int
+	name    string
+	body    string
+}
+
+// Migrate creates or upgrades database schema to the latest version.
+// Every migration runs in its own transaction which first records its version in rbac_schema_migrations table
+// and skips the migration if the version was already recorded, so Migrate is safe to call on every start,
+// also by several Stores sharing database at once: PostgreSQL makes concurrent callers wait on the recorded version,
+// SQLite serializes writing transactions and needs busy timeout set for callers to wait instead of failing.
+func (s *Store) Migrate() error {
+	if _, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS rbac_schema_migrations (version INTEGER NOT NULL PRIMARY KEY)`); err != nil {
+		// table created concurrently by another Store still fails the check on PostgreSQL
+		if _, verr := s.SchemaVersion(); verr != nil {
+			return fmt.Errorf("sqlstore: create migrations table: %w", err)
+		}
+	}
+
+	current, err := s.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	list, err := migrations()
+	if err != nil {
+		return err
+	}
+	for _, m := range list {
+		if m.version <= current {
+			continue
+		}
+		err := s.inTx(func(tx *sql.Tx) error {
+			res, err := tx.Exec(s.rebind(`INSERT INTO rbac_schema_migrations (version) VALUES (?) ON CONFLICT DO NOTHING`), m.version)
+			if err != nil {
+				return err
+			}
+			if n, err := res.RowsAffected(); err != nil || n == 0 {
+				return err
+			}
+			for _, stmt := range strings.Split(m.body, ";") {
+				if strings.TrimSpace(stmt) == "" {
+					continue
+				}
+				if _, err := tx.Exec(stmt); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("sqlstore: migration %s: %w", m.name, err)
+		}
+	}
+	return nil
+}
+
+// SchemaVersion returns version of the last applied migration, 0 if none were applied
+func (s *Store) SchemaVersion() (int, error) {
+	var version sql.NullInt64
+	err := s.db.QueryRow(`SELECT MAX(version) FROM rbac_schema_migrations`).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("sqlstore: read schema version: %w", err)
+	}
+	return int(version.Int64), nil
+}
+
+// migrations returns embedded migrations ordered by version.
+// Migration file name has to start with version number followed by underscore, e.g. 0001_init.sql.
+func migrations() ([]migration, error) {
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]migration, 0, len(entries))
+	for _, e := range entries {
+		name := e.Name()
+		version, err := strconv.Atoi(strings.SplitN(name, "_", 2)[0])
+		if err != nil {
+			return nil, fmt.Errorf("sqlstore: migration %s: invalid version: %w", name, err)
+		}
+		body, err := migrationFiles.ReadFile(path.Join("migrations", name))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, migration{version: version, name: name, body: string(body)})
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].version < out[j].version
+	})
+	return out, nil
+}
@@ -0,0 +1,42 @@
+CREATE TABLE rbac_users (
+	id VARCHAR(255) NOT NULL PRIMARY KEY
+);
+
+CREATE TABLE rbac_roles (
+	id VARCHAR(255) NOT NULL PRIMARY KEY
+);
+
+CREATE TABLE rbac_permissions (
+	object VARCHAR(255) NOT NULL,
+	action VARCHAR(255) NOT NULL,
+	wildcard BOOLEAN NOT NULL,
+	PRIMARY KEY (object, action)
+);
+
+CREATE TABLE rbac_role_permissions (
+	role_id VARCHAR(255) NOT NULL REFERENCES rbac_roles (id),
+	object VARCHAR(255) NOT NULL,
+	action VARCHAR(255) NOT NULL,
+	PRIMARY KEY (role_id, object, action),
+	FOREIGN KEY (object, action) REFERENCES rbac_permissions (object, action)
+);
+
+CREATE TABLE rbac_role_denies (
+	role_id VARCHAR(255) NOT NULL REFERENCES rbac_roles (id),
+	object VARCHAR(255) NOT NULL,
+	action VARCHAR(255) NOT NULL,
+	PRIMARY KEY (role_id, object, action),
+	FOREIGN KEY (object, action) REFERENCES rbac_permissions (object, action)
+);
+
+CREATE TABLE rbac_role_parents (
+	role_id VARCHAR(255) NOT NULL REFERENCES rbac_roles (id),
+	parent_id VARCHAR(255) NOT NULL REFERENCES rbac_roles (id),
+	PRIMARY KEY (role_id, parent_id)
+);
+
+CREATE TABLE rbac_user_roles (
+	user_id VARCHAR(255) NOT NULL REFERENCES rbac_users (id),
+	role_id VARCHAR(255) NOT NULL REFERENCES rbac_roles (id),
+	PRIMARY KEY (user_id, role_id)
+);
@@ -0,0 +1,13 @@
+CREATE TABLE rbac_user_roles_domains (
+	user_id VARCHAR(255) NOT NULL REFERENCES rbac_users (id),
+	domain VARCHAR(255) NOT NULL,
+	role_id VARCHAR(255) NOT NULL REFERENCES rbac_roles (id),
+	PRIMARY KEY (user_id, domain, role_id)
+);
+
+INSERT INTO rbac_user_roles_domains (user_id, domain, role_id)
+	SELECT user_id, '', role_id FROM rbac_user_roles;
+
+DROP TABLE rbac_user_roles;
+
+ALTER TABLE rbac_user_roles_domains RENAME TO rbac_user_roles;
@@ -0,0 +1,3 @@
+ALTER TABLE rbac_user_roles ADD COLUMN not_before BIGINT;
+
+ALTER TABLE rbac_user_roles ADD COLUMN not_after BIGINT;
@@ -0,0 +1,10 @@
+CREATE TABLE rbac_dsd_constraints (
+	name VARCHAR(255) NOT NULL PRIMARY KEY,
+	cardinality INTEGER NOT NULL
+);
+
+CREATE TABLE rbac_dsd_constraint_roles (
+	name VARCHAR(255) NOT NULL REFERENCES rbac_dsd_constraints (name),
+	role_id VARCHAR(255) NOT NULL REFERENCES rbac_roles (id),
+	PRIMARY KEY (name, role_id)
+);
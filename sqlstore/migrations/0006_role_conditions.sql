@@ -0,0 +1,8 @@
+CREATE TABLE rbac_role_conditions (
+	role_id VARCHAR(255) NOT NULL REFERENCES rbac_roles (id),
+	object VARCHAR(255) NOT NULL,
+	action VARCHAR(255) NOT NULL,
+	expression TEXT NOT NULL,
+	PRIMARY KEY (role_id, object, action),
+	FOREIGN KEY (object, action) REFERENCES rbac_permissions (object, action)
+);
@@ -0,0 +1,6 @@
+CREATE TABLE rbac_exact_permissions (
+	object VARCHAR(255) NOT NULL,
+	action VARCHAR(255) NOT NULL,
+	PRIMARY KEY (object, action),
+	FOREIGN KEY (object, action) REFERENCES rbac_permissions (object, action)
+);
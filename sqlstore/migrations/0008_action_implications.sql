@@ -0,0 +1,5 @@
+CREATE TABLE rbac_action_implications (
+	action VARCHAR(255) NOT NULL,
+	implied VARCHAR(255) NOT NULL,
+	PRIMARY KEY (action, implied)
+);
@@ -0,0 +1,5 @@
+CREATE INDEX rbac_role_permissions_permission ON rbac_role_permissions (object, action);
+
+CREATE INDEX rbac_role_parents_parent ON rbac_role_parents (parent_id);
+
+CREATE INDEX rbac_user_roles_role ON rbac_user_roles (role_id, domain);
@@ -0,0 +1,3 @@
+CREATE INDEX rbac_role_denies_permission ON rbac_role_denies (object, action);
+
+CREATE INDEX rbac_role_conditions_permission ON rbac_role_conditions (object, action);
@@ -0,0 +1,729 @@
+// Package sqlstore implements rbac.Store on top of database/sql.
+//
+// Store supports SQLite 3.24+ and PostgreSQL 9.5+ with any of their drivers, placeholder style is configurable.
+// Inserts use INSERT ... ON CONFLICT, so Stores sharing database may add the same rows concurrently;
+// databases without it, like MySQL or SQL Server, are not supported.
+// Database schema is created and upgraded by Store.Migrate using migrations embedded into the package.
+// Removals cascading to several tables run inside transactions.
+//
+// Package tests run against SQLite through modernc.org/sqlite driver, which is not a dependency of the module,
+// so they are built with sqlite tag only: go test -tags sqlite ./sqlstore, CI runs them that way.
+package sqlstore
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"rbac"
+)
+
+// ErrorTxStarted is returned by Begin of Tx, transactions can not be nested
+var ErrorTxStarted = errors.New("sqlstore: transaction already started")
+
+// Placeholder describes bind parameter style of database driver
+type Placeholder int
+
+const (
+	// Question is "?" placeholder style, used by SQLite drivers
+	Question Placeholder = iota
+	// Dollar is "$1" placeholder style, used by PostgreSQL drivers
+	Dollar
+)
+
+// Store is rbac.Store persisting content to SQL database.
+// Store implements rbac.TxStore, so RBAC.Update applies its changes in single database transaction.
+type Store struct {
+	db          *sql.DB
+	placeholder Placeholder
+	// tx is transaction queries of Store started by Begin run in, nil for Store created by New
+	tx *sql.Tx
+}
+
+// New creates Store using db with provided placeholder style.
+// Store.Migrate has to be called before Store is used.
+func New(db *sql.DB, placeholder Placeholder) *Store {
+	return &Store{db: db, placeholder: placeholder}
+}
+
+// AddUser implements rbac.Store
+func (s *Store) AddUser(u rbac.User) (bool, error) {
+	return s.insert(`INSERT INTO rbac_users (id) VALUES (?) ON CONFLICT DO NOTHING`, u.ID())
+}
+
+// RemoveUser implements rbac.Store
+func (s *Store) RemoveUser(u rbac.User) (bool, error) {
+	return s.remove([]string{
+		`DELETE FROM rbac_user_roles WHERE user_id = ?`,
+	}, `DELETE FROM rbac_users WHERE id = ?`, u.ID())
+}
+
+// HasUser implements rbac.Store
+func (s *Store) HasUser(u rbac.User) (bool, error) {
+	return s.exists(`SELECT COUNT(*) FROM rbac_users WHERE id = ?`, u.ID())
+}
+
+// Users implements rbac.Store
+func (s *Store) Users() ([]rbac.User, error) {
+	ids, err := s.ids(`SELECT id FROM rbac_users`)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]rbac.User, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, rbac.NewUser(id))
+	}
+	return out, nil
+}
+
+// AddRole implements rbac.Store
+func (s *Store) AddRole(r rbac.Role) (bool, error) {
+	return s.insert(`INSERT INTO rbac_roles (id) VALUES (?) ON CONFLICT DO NOTHING`, r.ID())
+}
+
+// RemoveRole implements rbac.Store
+func (s *Store) RemoveRole(r rbac.Role) (bool, error) {
+	id := r.ID()
+	return s.remove([]string{
+		`DELETE FROM rbac_user_roles WHERE role_id = ?`,
+		`DELETE FROM rbac_role_permissions WHERE role_id = ?`,
+		`DELETE FROM rbac_role_denies WHERE role_id = ?`,
+		`DELETE FROM rbac_role_conditions WHERE role_id = ?`,
+		`DELETE FROM rbac_role_parents WHERE role_id = ?`,
+		`DELETE FROM rbac_role_parents WHERE parent_id = ?`,
+		exhaustedConstraintRoles("rbac_ssd"),
+		`DELETE FROM rbac_ssd_constraint_roles WHERE role_id = ?`,
+		emptyConstraints("rbac_ssd"),
+		exhaustedConstraintRoles("rbac_dsd"),
+		`DELETE FROM rbac_dsd_constraint_roles WHERE role_id = ?`,
+		emptyConstraints("rbac_dsd"),
+	}, `DELETE FROM rbac_roles WHERE id = ?`, id)
+}
+
+// exhaustedConstraintRoles is query deleting all Roles of constraints containing Role bound to it
+// which would be left with fewer Roles than their cardinality, such constraints can not be violated anymore
+func exhaustedConstraintRoles(prefix string) string {
+	return `DELETE FROM ` + prefix + `_constraint_roles WHERE name IN (
+		SELECT c.name FROM ` + prefix + `_constraints c JOIN ` + prefix + `_constraint_roles r ON r.name = c.name
+		WHERE r.role_id = ? AND c.cardinality >= (SELECT COUNT(*) FROM ` + prefix + `_constraint_roles a WHERE a.name = c.name))`
+}
+
+// emptyConstraints is query deleting constraints having no Roles other than Role bound to it
+func emptyConstraints(prefix string) string {
+	return `DELETE FROM ` + prefix + `_constraints WHERE name NOT IN (
+		SELECT name FROM ` + prefix + `_constraint_roles WHERE role_id <> ?)`
+}
+
+// HasRole implements rbac.Store
+func (s *Store) HasRole(r rbac.Role) (bool, error) {
+	return s.exists(`SELECT COUNT(*) FROM rbac_roles WHERE id = ?`, r.ID())
+}
+
+// Roles implements rbac.Store
+func (s *Store) Roles() ([]rbac.Role, error) {
+	return s.roles(`SELECT id FROM rbac_roles`)
+}
+
+// AddPermission implements rbac.Store
+func (s *Store) AddPermission(p rbac.Permission) (bool, error) {
+	return s.insert(`INSERT INTO rbac_permissions (object, action, wildcard) VALUES (?, ?, ?) ON CONFLICT DO NOTHING`,
+		p.Object().String(), p.Action().String(), p.Wildcard())
+}
+
+// RemovePermission implements rbac.Store
+func (s *Store) RemovePermission(p rbac.Permission) (bool, error) {
+	return s.remove([]string{
+		`DELETE FROM rbac_role_permissions WHERE object = ? AND action = ?`,
+		`DELETE FROM rbac_role_denies WHERE object = ? AND action = ?`,
+		`DELETE FROM rbac_role_conditions WHERE object = ? AND action = ?`,
+		`DELETE FROM rbac_exact_permissions WHERE object = ? AND action = ?`,
+	}, `DELETE FROM rbac_permissions WHERE object = ? AND action = ?`, p.Object().String(), p.Action().String())
+}
+
+// HasPermission implements rbac.Store
+func (s *Store) HasPermission(p rbac.Permission) (bool, error) {
+	return s.exists(`SELECT COUNT(*) FROM rbac_permissions WHERE object = ? AND action = ?`,
+		p.Object().String(), p.Action().String())
+}
+
+// Permissions implements rbac.Store
+func (s *Store) Permissions() ([]rbac.Permission, error) {
+	return s.permissions(`SELECT object, action FROM rbac_permissions`)
+}
+
+// WildcardPermissions implements rbac.Store
+func (s *Store) WildcardPermissions() ([]rbac.Permission, error) {
+	return s.permissions(`SELECT object, action FROM rbac_permissions WHERE wildcard = ?`, true)
+}
+
+// AddExactPermission implements rbac.Store
+func (s *Store) AddExactPermission(p rbac.Permission) (bool, error) {
+	return s.insert(`INSERT INTO rbac_exact_permissions (object, action) VALUES (?, ?) ON CONFLICT DO NOTHING`,
+		p.Object().String(), p.Action().String())
+}
+
+// RemoveExactPermission implements rbac.Store
+func (s *Store) RemoveExactPermission(p rbac.Permission) (bool, error) {
+	return s.remove(nil, `DELETE FROM rbac_exact_permissions WHERE object = ? AND action = ?`,
+		p.Object().String(), p.Action().String())
+}
+
+// HasExactPermission implements rbac.Store
+func (s *Store) HasExactPermission(p rbac.Permission) (bool, error) {
+	return s.exists(`SELECT COUNT(*) FROM rbac_exact_permissions WHERE object = ? AND action = ?`,
+		p.Object().String(), p.Action().String())
+}
+
+// ExactPermissions implements rbac.Store
+func (s *Store) ExactPermissions() ([]rbac.Permission, error) {
+	return s.permissions(`SELECT object, action FROM rbac_exact_permissions`)
+}
+
+// AddRolePermission implements rbac.Store
+func (s *Store) AddRolePermission(r rbac.Role, p rbac.Permission) (bool, error) {
+	return s.insert(`INSERT INTO rbac_role_permissions (role_id, object, action) VALUES (?, ?, ?) ON CONFLICT DO NOTHING`,
+		r.ID(), p.Object().String(), p.Action().String())
+}
+
+// RemoveRolePermission implements rbac.Store
+func (s *Store) RemoveRolePermission(r rbac.Role, p rbac.Permission) (bool, error) {
+	return s.remove(nil, `DELETE FROM rbac_role_permissions WHERE role_id = ? AND object = ? AND action = ?`,
+		r.ID(), p.Object().String(), p.Action().String())
+}
+
+// HasRolePermission implements rbac.Store
+func (s *Store) HasRolePermission(r rbac.Role, p rbac.Permission) (bool, error) {
+	return s.exists(`SELECT COUNT(*) FROM rbac_role_permissions WHERE role_id = ? AND object = ? AND action = ?`,
+		r.ID(), p.Object().String(), p.Action().String())
+}
+
+// RolePermissions implements rbac.Store
+func (s *Store) RolePermissions(r rbac.Role) ([]rbac.Permission, error) {
+	return s.permissions(`SELECT object, action FROM rbac_role_permissions WHERE role_id = ?`, r.ID())
+}
+
+// PermissionRoles implements rbac.Store
+func (s *Store) PermissionRoles(p rbac.Permission) ([]rbac.Role, error) {
+	return s.roles(`SELECT role_id FROM rbac_role_permissions WHERE object = ? AND action = ?`, p.Object().String(), p.Action().String())
+}
+
+// AddRoleDeny implements rbac.Store
+func (s *Store) AddRoleDeny(r rbac.Role, p rbac.Permission) (bool, error) {
+	return s.insert(`INSERT INTO rbac_role_denies (role_id, object, action) VALUES (?, ?, ?) ON CONFLICT DO NOTHING`,
+		r.ID(), p.Object().String(), p.Action().String())
+}
+
+// RemoveRoleDeny implements rbac.Store
+func (s *Store) RemoveRoleDeny(r rbac.Role, p rbac.Permission) (bool, error) {
+	return s.remove(nil, `DELETE FROM rbac_role_denies WHERE role_id = ? AND object = ? AND action = ?`,
+		r.ID(), p.Object().String(), p.Action().String())
+}
+
+// HasRoleDeny implements rbac.Store
+func (s *Store) HasRoleDeny(r rbac.Role, p rbac.Permission) (bool, error) {
+	return s.exists(`SELECT COUNT(*) FROM rbac_role_denies WHERE role_id = ? AND object = ? AND action = ?`,
+		r.ID(), p.Object().String(), p.Action().String())
+}
+
+// RoleDenies implements rbac.Store
+func (s *Store) RoleDenies(r rbac.Role) ([]rbac.Permission, error) {
+	return s.permissions(`SELECT object, action FROM rbac_role_denies WHERE role_id = ?`, r.ID())
+}
+
+// PermissionDenies implements rbac.Store
+func (s *Store) PermissionDenies(p rbac.Permission) ([]rbac.Role, error) {
+	return s.roles(`SELECT role_id FROM rbac_role_denies WHERE object = ? AND action = ?`, p.Object().String(), p.Action().String())
+}
+
+// AddRoleCondition implements rbac.Store
+func (s *Store) AddRoleCondition(r rbac.Role, p rbac.Permission, expr string) (bool, error) {
+	// conflicting row is updated only if expression differs, so unchanged one is not counted
+	return s.insert(`INSERT INTO rbac_role_conditions (role_id, object, action, expression) VALUES (?, ?, ?, ?)
+		ON CONFLICT (role_id, object, action) DO UPDATE SET expression = excluded.expression
+		WHERE rbac_role_conditions.expression <> excluded.expression`,
+		r.ID(), p.Object().String(), p.Action().String(), expr)
+}
+
+// RemoveRoleCondition implements rbac.Store
+func (s *Store) RemoveRoleCondition(r rbac.Role, p rbac.Permission) (bool, error) {
+	return s.remove(nil, `DELETE FROM rbac_role_conditions WHERE role_id = ? AND object = ? AND action = ?`,
+		r.ID(), p.Object().String(), p.Action().String())
+}
+
+// RoleConditions implements rbac.Store
+func (s *Store) RoleConditions(r rbac.Role) (map[rbac.Permission]string, error) {
+	rows, err := s.conn().Query(s.rebind(`SELECT object, action, expression FROM rbac_role_conditions WHERE role_id = ?`), r.ID())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[rbac.Permission]string)
+	for rows.Next() {
+		var object, action, expr string
+		if err := rows.Scan(&object, &action, &expr); err != nil {
+			return nil, err
+		}
+		out[rbac.NewPermission(rbac.NewObject(object), rbac.NewAction(action))] = expr
+	}
+	return out, rows.Err()
+}
+
+// PermissionConditions implements rbac.Store
+func (s *Store) PermissionConditions(p rbac.Permission) (map[rbac.Role]string, error) {
+	rows, err := s.conn().Query(s.rebind(`SELECT role_id, expression FROM rbac_role_conditions WHERE object = ? AND action = ?`),
+		p.Object().String(), p.Action().String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[rbac.Role]string)
+	for rows.Next() {
+		var id, expr string
+		if err := rows.Scan(&id, &expr); err != nil {
+			return nil, err
+		}
+		out[rbac.NewRole(id)] = expr
+	}
+	return out, rows.Err()
+}
+
+// AddActionImplication implements rbac.Store
+func (s *Store) AddActionImplication(i rbac.ActionImplication) (bool, error) {
+	return s.insert(`INSERT INTO rbac_action_implications (action, implied) VALUES (?, ?) ON CONFLICT DO NOTHING`,
+		i.Action.String(), i.Implied.String())
+}
+
+// RemoveActionImplication implements rbac.Store
+func (s *Store) RemoveActionImplication(i rbac.ActionImplication) (bool, error) {
+	return s.remove(nil, `DELETE FROM rbac_action_implications WHERE action = ? AND implied = ?`,
+		i.Action.String(), i.Implied.String())
+}
+
+// ActionImplications implements rbac.Store
+func (s *Store) ActionImplications() ([]rbac.ActionImplication, error) {
+	rows, err := s.conn().Query(`SELECT action, implied FROM rbac_action_implications`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]rbac.ActionImplication, 0)
+	for rows.Next() {
+		var a, implied string
+		if err := rows.Scan(&a, &implied); err != nil {
+			return nil, err
+		}
+		out = append(out, rbac.ActionImplication{Action: rbac.NewAction(a), Implied: rbac.NewAction(implied)})
+	}
+	return out, rows.Err()
+}
+
+// AddRoleParent implements rbac.Store
+func (s *Store) AddRoleParent(child, parent rbac.Role) (bool, error) {
+	return s.insert(`INSERT INTO rbac_role_parents (role_id, parent_id) VALUES (?, ?) ON CONFLICT DO NOTHING`, child.ID(), parent.ID())
+}
+
+// RemoveRoleParent implements rbac.Store
+func (s *Store) RemoveRoleParent(child, parent rbac.Role) (bool, error) {
+	return s.remove(nil, `DELETE FROM rbac_role_parents WHERE role_id = ? AND parent_id = ?`, child.ID(), parent.ID())
+}
+
+// RoleParents implements rbac.Store
+func (s *Store) RoleParents(r rbac.Role) ([]rbac.Role, error) {
+	return s.roles(`SELECT parent_id FROM rbac_role_parents WHERE role_id = ?`, r.ID())
+}
+
+// RoleChildren implements rbac.Store
+func (s *Store) RoleChildren(r rbac.Role) ([]rbac.Role, error) {
+	return s.roles(`SELECT role_id FROM rbac_role_parents WHERE parent_id = ?`, r.ID())
+}
+
+// AddUserRole implements rbac.Store
+func (s *Store) AddUserRole(u rbac.User, r rbac.Role, d rbac.Domain) (bool, error) {
+	return s.insert(`INSERT INTO rbac_user_roles (user_id, domain, role_id) VALUES (?, ?, ?) ON CONFLICT DO NOTHING`, u.ID(), d.String(), r.ID())
+}
+
+// RemoveUserRole implements rbac.Store
+func (s *Store) RemoveUserRole(u rbac.User, r rbac.Role, d rbac.Domain) (bool, error) {
+	return s.remove(nil, `DELETE FROM rbac_user_roles WHERE user_id = ? AND domain = ? AND role_id = ?`,
+		u.ID(), d.String(), r.ID())
+}
+
+// HasUserRole implements rbac.Store
+func (s *Store) HasUserRole(u rbac.User, r rbac.Role, d rbac.Domain) (bool, error) {
+	return s.exists(`SELECT COUNT(*) FROM rbac_user_roles WHERE user_id = ? AND domain = ? AND role_id = ?`,
+		u.ID(), d.String(), r.ID())
+}
+
+// UserRoles implements rbac.Store
+func (s *Store) UserRoles(u rbac.User, d rbac.Domain) ([]rbac.Role, error) {
+	return s.roles(`SELECT role_id FROM rbac_user_roles WHERE user_id = ? AND domain = ?`, u.ID(), d.String())
+}
+
+// RoleUsers implements rbac.Store
+func (s *Store) RoleUsers(r rbac.Role, d rbac.Domain) ([]rbac.User, error) {
+	ids, err := s.ids(`SELECT user_id FROM rbac_user_roles WHERE role_id = ? AND domain = ?`, r.ID(), d.String())
+	if err != nil {
+		return nil, err
+	}
+	out := make([]rbac.User, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, rbac.NewUser(id))
+	}
+	return out, nil
+}
+
+// RoleDomains implements rbac.Store
+func (s *Store) RoleDomains(r rbac.Role) ([]rbac.Domain, error) {
+	ids, err := s.ids(`SELECT DISTINCT domain FROM rbac_user_roles WHERE role_id = ?`, r.ID())
+	if err != nil {
+		return nil, err
+	}
+	out := make([]rbac.Domain, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, rbac.NewDomain(id))
+	}
+	return out, nil
+}
+
+// SetUserRoleValidity implements rbac.Store.
+// Bounds are stored as Unix nanoseconds, NULL stands for unbounded side.
+func (s *Store) SetUserRoleValidity(u rbac.User, r rbac.Role, d rbac.Domain, v rbac.Validity) (bool, error) {
+	changed := false
+	err := s.inTx(func(tx *sql.Tx) error {
+		current, ok, err := s.userRoleValidity(tx, u, r, d)
+		if err != nil || !ok || current.Equal(v) {
+			return err
+		}
+		_, err = tx.Exec(s.rebind(`UPDATE rbac_user_roles SET not_before = ?, not_after = ? WHERE user_id = ? AND domain = ? AND role_id = ?`),
+			toNanos(v.NotBefore), toNanos(v.NotAfter), u.ID(), d.String(), r.ID())
+		if err != nil {
+			return err
+		}
+		changed = true
+		return nil
+	})
+	return changed, err
+}
+
+// UserRoleValidity implements rbac.Store
+func (s *Store) UserRoleValidity(u rbac.User, r rbac.Role, d rbac.Domain) (rbac.Validity, error) {
+	v, _, err := s.userRoleValidity(s.conn(), u, r, d)
+	return v, err
+}
+
+// UserDomains implements rbac.Store
+func (s *Store) UserDomains(u rbac.User) ([]rbac.Domain, error) {
+	ids, err := s.ids(`SELECT DISTINCT domain FROM rbac_user_roles WHERE user_id = ?`, u.ID())
+	if err != nil {
+		return nil, err
+	}
+	out := make([]rbac.Domain, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, rbac.NewDomain(id))
+	}
+	return out, nil
+}
+
+// AddSSDConstraint implements rbac.Store
+func (s *Store) AddSSDConstraint(c rbac.SSDConstraint) (bool, error) {
+	return s.addConstraint("rbac_ssd", c)
+}
+
+// RemoveSSDConstraint implements rbac.Store
+func (s *Store) RemoveSSDConstraint(name string) (bool, error) {
+	return s.removeConstraint("rbac_ssd", name)
+}
+
+// SSDConstraints implements rbac.Store
+func (s *Store) SSDConstraints() ([]rbac.SSDConstraint, error) {
+	return s.constraints("rbac_ssd")
+}
+
+// AddDSDConstraint implements rbac.Store
+func (s *Store) AddDSDConstraint(c rbac.DSDConstraint) (bool, error) {
+	return s.addConstraint("rbac_dsd", rbac.SSDConstraint(c))
+}
+
+// RemoveDSDConstraint implements rbac.Store
+func (s *Store) RemoveDSDConstraint(name string) (bool, error) {
+	return s.removeConstraint("rbac_dsd", name)
+}
+
+// DSDConstraints implements rbac.Store
+func (s *Store) DSDConstraints() ([]rbac.DSDConstraint, error) {
+	list, err := s.constraints("rbac_dsd")
+	if err != nil {
+		return nil, err
+	}
+	out := make([]rbac.DSDConstraint, 0, len(list))
+	for _, c := range list {
+		out = append(out, rbac.DSDConstraint(c))
+	}
+	return out, nil
+}
+
+// addConstraint stores constraint in prefix_constraints and prefix_constraint_roles tables
+func (s *Store) addConstraint(prefix string, c rbac.SSDConstraint) (bool, error) {
+	added := false
+	err := s.inTx(func(tx *sql.Tx) error {
+		res, err := tx.Exec(s.rebind(`INSERT INTO `+prefix+`_constraints (name, cardinality) VALUES (?, ?) ON CONFLICT DO NOTHING`), c.Name, c.N)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil || n == 0 {
+			return err
+		}
+		for _, r := range c.Roles {
+			if _, err := tx.Exec(s.rebind(`INSERT INTO `+prefix+`_constraint_roles (name, role_id) VALUES (?, ?)`), c.Name, r.ID()); err != nil {
+				return err
+			}
+		}
+		added = true
+		return nil
+	})
+	return added, err
+}
+
+func (s *Store) removeConstraint(prefix, name string) (bool, error) {
+	return s.remove([]string{
+		`DELETE FROM ` + prefix + `_constraint_roles WHERE name = ?`,
+	}, `DELETE FROM `+prefix+`_constraints WHERE name = ?`, name)
+}
+
+func (s *Store) constraints(prefix string) ([]rbac.SSDConstraint, error) {
+	rows, err := s.conn().Query(`SELECT name, cardinality FROM ` + prefix + `_constraints`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]rbac.SSDConstraint, 0)
+	for rows.Next() {
+		var c rbac.SSDConstraint
+		if err := rows.Scan(&c.Name, &c.N); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// release connection before querying roles
+	rows.Close()
+
+	for i := range out {
+		out[i].Roles, err = s.roles(`SELECT role_id FROM `+prefix+`_constraint_roles WHERE name = ?`, out[i].Name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// insert runs insert query skipping rows conflicting with existing ones, reports if row were inserted.
+// Concurrent inserts of the same row by several Stores sharing database do not fail, only one of them reports insert.
+func (s *Store) insert(insert string, args ...interface{}) (bool, error) {
+	res, err := s.conn().Exec(s.rebind(insert), args...)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// remove runs cascade queries and then delete query in single transaction.
+// All queries get the same args.
+func (s *Store) remove(cascade []string, del string, args ...interface{}) (bool, error) {
+	removed := false
+	err := s.inTx(func(tx *sql.Tx) error {
+		for _, q := range cascade {
+			if _, err := tx.Exec(s.rebind(q), args...); err != nil {
+				return err
+			}
+		}
+		res, err := tx.Exec(s.rebind(del), args...)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		removed = n > 0
+		return nil
+	})
+	return removed, err
+}
+
+func (s *Store) exists(query string, args ...interface{}) (bool, error) {
+	var n int
+	if err := s.conn().QueryRow(s.rebind(query), args...).Scan(&n); err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (s *Store) ids(query string, args ...interface{}) ([]string, error) {
+	rows, err := s.conn().Query(s.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]string, 0)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		out = append(out, id)
+	}
+	return out, rows.Err()
+}
+
+func (s *Store) roles(query string, args ...interface{}) ([]rbac.Role, error) {
+	ids, err := s.ids(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]rbac.Role, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, rbac.NewRole(id))
+	}
+	return out, nil
+}
+
+func (s *Store) permissions(query string, args ...interface{}) ([]rbac.Permission, error) {
+	rows, err := s.conn().Query(s.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]rbac.Permission, 0)
+	for rows.Next() {
+		var o, a string
+		if err := rows.Scan(&o, &a); err != nil {
+			return nil, err
+		}
+		out = append(out, rbac.NewPermission(rbac.NewObject(o), rbac.NewAction(a)))
+	}
+	return out, rows.Err()
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// conn returns transaction started by Begin, database if there is none
+func (s *Store) conn() querier {
+	if s.tx != nil {
+		return s.tx
+	}
+	return s.db
+}
+
+// userRoleValidity reads Validity of Role assignment, returns false if Role is not assigned
+func (s *Store) userRoleValidity(q querier, u rbac.User, r rbac.Role, d rbac.Domain) (rbac.Validity, bool, error) {
+	var notBefore, notAfter sql.NullInt64
+	err := q.QueryRow(s.rebind(`SELECT not_before, not_after FROM rbac_user_roles WHERE user_id = ? AND domain = ? AND role_id = ?`),
+		u.ID(), d.String(), r.ID()).Scan(&notBefore, &notAfter)
+	if err == sql.ErrNoRows {
+		return rbac.Validity{}, false, nil
+	}
+	if err != nil {
+		return rbac.Validity{}, false, err
+	}
+	return rbac.Validity{NotBefore: fromNanos(notBefore), NotAfter: fromNanos(notAfter)}, true, nil
+}
+
+func toNanos(t time.Time) sql.NullInt64 {
+	if t.IsZero() {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: t.UnixNano(), Valid: true}
+}
+
+func fromNanos(n sql.NullInt64) time.Time {
+	if !n.Valid {
+		return time.Time{}
+	}
+	return time.Unix(0, n.Int64).UTC()
+}
+
+// inTx runs f in transaction, committing it if f succeeds and rolling back otherwise.
+// Store started by Begin runs f in its own transaction, which is committed or rolled back as a whole.
+func (s *Store) inTx(f func(tx *sql.Tx) error) error {
+	if s.tx != nil {
+		return f(s.tx)
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := f(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Begin implements rbac.TxStore.
+// Changes made through returned Tx are visible to other Stores sharing database once committed.
+func (s *Store) Begin() (rbac.StoreTx, error) {
+	if s.tx != nil {
+		return nil, ErrorTxStarted
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Store: &Store{db: s.db, placeholder: s.placeholder, tx: tx}}, nil
+}
+
+// Tx is database transaction of Store started by Store.Begin, it is rbac.StoreTx
+type Tx struct {
+	*Store
+}
+
+// Commit implements rbac.StoreTx
+func (t *Tx) Commit() error {
+	return t.tx.Commit()
+}
+
+// Rollback implements rbac.StoreTx.
+// Transaction which failed to commit is already rolled back by database, Rollback does nothing then.
+func (t *Tx) Rollback() error {
+	if err := t.tx.Rollback(); err != sql.ErrTxDone {
+		return err
+	}
+	return nil
+}
+
+// rebind converts "?" placeholders of query to Store placeholder style
+func (s *Store) rebind(query string) string {
+	if s.placeholder != Dollar {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c != '?' {
+			b.WriteRune(c)
+			continue
+		}
+		n++
+		b.WriteByte('$')
+		b.WriteString(strconv.Itoa(n))
+	}
+	return b.String()
+}
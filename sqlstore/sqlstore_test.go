@@ -0,0 +1,314 @@
+//go:build sqlite
+
+// Tests run against SQLite through modernc.org/sqlite driver, which is not a dependency of the module:
+// CI adds it and runs go test -tags sqlite ./sqlstore, see .travis.yml.
+
+package sqlstore
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "modernc.org/sqlite"
+
+	"rbac"
+	"rbac/storetest"
+)
+
+func newTestStore(t *testing.T) *Store {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("open error: expected err equal nil, got %v", err)
+	}
+	// every connection to :memory: gets its own database
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	s := New(db, Question)
+	if err := s.Migrate(); err != nil {
+		t.Fatalf("migrate error: expected err equal nil, got %v", err)
+	}
+	return s
+}
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, newTestStore(t))
+}
+
+func TestMigrate(t *testing.T) {
+	s := newTestStore(t)
+
+	list, err := migrations()
+	if err != nil {
+		t.Fatalf("migrations error: expected err equal nil, got %v", err)
+	}
+
+	// case 1: all migrations are applied
+	version, err := s.SchemaVersion()
+	if err != nil {
+		t.Errorf("[case 1] version error: expected err equal nil, got %v", err)
+	}
+
+	if version != list[len(list)-1].version {
+		t.Errorf("[case 1] invalid output: expected version %d, got %d", list[len(list)-1].version, version)
+	}
+
+	// case 2: migrating again is no-op
+	if err := s.Migrate(); err != nil {
+		t.Errorf("[case 2] migrate error: expected err equal nil, got %v", err)
+	}
+
+	again, _ := s.SchemaVersion()
+	if again != version {
+		t.Errorf("[case 2] invalid output: expected version %d, got %d", version, again)
+	}
+}
+
+func TestConcurrentMigrate(t *testing.T) {
+	dsn := "file:" + filepath.Join(t.TempDir(), "rbac.db") + "?_pragma=busy_timeout(5000)"
+
+	// replicas starting at once on empty database, each applies only migrations not applied by others
+	const replicas = 4
+	errs := make(chan error, replicas)
+	for i := 0; i < replicas; i++ {
+		db, err := sql.Open("sqlite", dsn)
+		if err != nil {
+			t.Fatalf("open error: expected err equal nil, got %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		go func() {
+			errs <- New(db, Question).Migrate()
+		}()
+	}
+	for i := 0; i < replicas; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("migrate error: expected err equal nil, got %v", err)
+		}
+	}
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatalf("open error: expected err equal nil, got %v", err)
+	}
+	defer db.Close()
+
+	list, _ := migrations()
+	version, err := New(db, Question).SchemaVersion()
+	if err != nil || version != list[len(list)-1].version {
+		t.Errorf("invalid output: expected version %d, got %d (err %v)", list[len(list)-1].version, version, err)
+	}
+}
+
+func TestSharedDatabase(t *testing.T) {
+	s := newTestStore(t)
+
+	// two controllers on the same database, e.g. two service replicas
+	first := rbac.NewRBACWithStore(s)
+	second := rbac.NewRBACWithStore(New(s.db, Question))
+
+	u := rbac.NewUser("user")
+	r := rbac.NewRole("role")
+	p := rbac.NewPermission(rbac.NewObject("invoice"), rbac.NewAction("read"))
+
+	first.RegisterUser(u)
+	first.RegisterRole(r)
+	first.RegisterPermission(p)
+	first.AssignPermissionToRole(r, p)
+	first.AssignRoleToUser(u, r)
+
+	// case 1: changes are visible to other controller
+	ok, err := second.UserHasPermission(u, p)
+	if err != nil || !ok {
+		t.Errorf("[case 1] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	// case 2: role removal cascades
+	second.RemoveRole(r)
+
+	ok, err = first.UserHasPermission(u, p)
+	if err != nil || ok {
+		t.Errorf("[case 2] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+
+	var n int
+	s.db.QueryRow(`SELECT COUNT(*) FROM rbac_role_permissions`).Scan(&n)
+	if n != 0 {
+		t.Errorf("[case 2] role permissions were not removed with role: %d left", n)
+	}
+}
+
+func TestConcurrentInsert(t *testing.T) {
+	s := newTestStore(t)
+
+	// replicas adding the same User at once, only one of them adds it
+	const replicas = 8
+	added := make(chan bool, replicas)
+	errs := make(chan error, replicas)
+	for i := 0; i < replicas; i++ {
+		go func() {
+			ok, err := New(s.db, Question).AddUser(rbac.NewUser("user"))
+			added <- ok
+			errs <- err
+		}()
+	}
+
+	n := 0
+	for i := 0; i < replicas; i++ {
+		if <-added {
+			n++
+		}
+		if err := <-errs; err != nil {
+			t.Errorf("add error: expected err equal nil, got %v", err)
+		}
+	}
+	if n != 1 {
+		t.Errorf("invalid output: expected user added once, got %d", n)
+	}
+}
+
+func TestUpdateTransaction(t *testing.T) {
+	// database file, so replica reads through its own connection while transaction is open
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "rbac.db"))
+	if err != nil {
+		t.Fatalf("open error: expected err equal nil, got %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	s := New(db, Question)
+	if err := s.Migrate(); err != nil {
+		t.Fatalf("migrate error: expected err equal nil, got %v", err)
+	}
+
+	first := rbac.NewRBACWithStore(s)
+	second := rbac.NewRBACWithStore(New(db, Question))
+	u := rbac.NewUser("user")
+
+	// case 1: replica does not observe changes before commit
+	err = first.Update(func(tx *rbac.Tx) error {
+		if _, err := tx.RegisterUserErr(u); err != nil {
+			return err
+		}
+		ok, err := second.UserExistsErr(u)
+		if err != nil || ok {
+			t.Errorf("[case 1] invalid output: expected %t, got %t (err %v)", false, ok, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("[case 1] update error: expected err equal nil, got %v", err)
+	}
+
+	// case 2: replica observes committed changes
+	ok, err := second.UserExistsErr(u)
+	if err != nil || !ok {
+		t.Errorf("[case 2] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	// case 3: transactions can not be nested
+	tx, err := s.Begin()
+	if err != nil {
+		t.Fatalf("[case 3] begin error: expected err equal nil, got %v", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.(*Tx).Begin(); err != ErrorTxStarted {
+		t.Errorf("[case 3] begin error: expected err equal %v, got %v", ErrorTxStarted, err)
+	}
+}
+
+func TestUpdateRollback(t *testing.T) {
+	controller := rbac.NewRBACWithStore(newTestStore(t))
+
+	u := rbac.NewUser("user")
+	r, parent := rbac.NewRole("role"), rbac.NewRole("parent")
+	p := rbac.NewPermission(rbac.NewObject("invoice"), rbac.NewAction("read"))
+	d := rbac.NewDomain("acme")
+
+	controller.RegisterUser(u)
+	controller.RegisterRole(r)
+	controller.RegisterRole(parent)
+	controller.RegisterPermission(p)
+	controller.AssignPermissionToRole(parent, p)
+	controller.DenyPermissionToRole(r, p)
+	controller.AddRoleParent(r, parent)
+	controller.AssignRoleToUserInDomain(u, r, d)
+
+	var before bytes.Buffer
+	controller.Export(&before)
+
+	// case 1: cascading removals are rolled back
+	failure := errors.New("failure")
+	err := controller.Update(func(tx *rbac.Tx) error {
+		tx.RemoveUser(u)
+		tx.RemoveRole(parent)
+		tx.RemovePermission(p)
+		return failure
+	})
+	if err != failure {
+		t.Errorf("[case 1] update error: expected err equal %v, got %v", failure, err)
+	}
+
+	var after bytes.Buffer
+	controller.Export(&after)
+	if after.String() != before.String() {
+		t.Errorf("[case 1] rollback error: expected\n%s\ngot\n%s", before.String(), after.String())
+	}
+}
+
+func TestRebind(t *testing.T) {
+	query := `SELECT 1 FROM t WHERE a = ? AND b = ?`
+
+	q := New(nil, Question).rebind(query)
+	if q != query {
+		t.Errorf("invalid output: expected %s, got %s", query, q)
+	}
+
+	expected := `SELECT 1 FROM t WHERE a = $1 AND b = $2`
+	q = New(nil, Dollar).rebind(query)
+	if q != expected {
+		t.Errorf("invalid output: expected %s, got %s", expected, q)
+	}
+}
+
+func TestMigrateUserRoleDomains(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("open error: expected err equal nil, got %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	list, err := migrations()
+	if err != nil {
+		t.Fatalf("migrations error: expected err equal nil, got %v", err)
+	}
+
+	// database created before domains were introduced
+	for _, stmt := range strings.Split(list[0].body, ";") {
+		if strings.TrimSpace(stmt) == "" {
+			continue
+		}
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("init error: expected err equal nil, got %v", err)
+		}
+	}
+	db.Exec(`CREATE TABLE rbac_schema_migrations (version INTEGER NOT NULL PRIMARY KEY)`)
+	db.Exec(`INSERT INTO rbac_schema_migrations (version) VALUES (1)`)
+	db.Exec(`INSERT INTO rbac_users (id) VALUES ('user')`)
+	db.Exec(`INSERT INTO rbac_roles (id) VALUES ('role')`)
+	db.Exec(`INSERT INTO rbac_user_roles (user_id, role_id) VALUES ('user', 'role')`)
+
+	s := New(db, Question)
+	if err := s.Migrate(); err != nil {
+		t.Fatalf("migrate error: expected err equal nil, got %v", err)
+	}
+
+	// existing assignments are moved to default domain
+	ok, err := s.HasUserRole(rbac.NewUser("user"), rbac.NewRole("role"), rbac.DefaultDomain)
+	if err != nil || !ok {
+		t.Errorf("invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+}
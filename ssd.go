@@ -0,0 +1,39 @@
+package rbac
+
+// SSDConstraint is static separation of duty constraint:
+// no User may hold N or more of Roles in the same Domain, directly or through role hierarchy.
+type SSDConstraint struct {
+	Name  string
+	Roles []Role
+	N     int
+}
+
+// NewSSDConstraint creates SSDConstraint, duplicated Roles are dropped
+func NewSSDConstraint(name string, roles []Role, n int) SSDConstraint {
+	seen := make(map[Role]struct{}, len(roles))
+	unique := make([]Role, 0, len(roles))
+	for _, r := range roles {
+		if _, ok := seen[r]; ok {
+			continue
+		}
+		seen[r] = struct{}{}
+		unique = append(unique, r)
+	}
+	return SSDConstraint{Name: name, Roles: unique, N: n}
+}
+
+// valid checks that constraint is named and can be violated only by holding several Roles
+func (c SSDConstraint) valid() bool {
+	return c.Name != "" && c.N >= 2 && c.N <= len(c.Roles)
+}
+
+// held returns constrained Roles presented in roles
+func (c SSDConstraint) held(roles map[Role]struct{}) []Role {
+	out := make([]Role, 0, len(c.Roles))
+	for _, r := range c.Roles {
+		if _, ok := roles[r]; ok {
+			out = append(out, r)
+		}
+	}
+	return out
+}
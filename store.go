@@ -0,0 +1,120 @@
+package rbac
+
+// Store describes storage backend of RBAC controller.
+// Store keeps Users, Roles, Permissions and relations between them: Permissions assigned and denied to Roles,
+// conditional Permissions along with their expressions, Action implications, Role hierarchy, Roles assigned to Users in Domains along with assignments Validity
+// and separation of duty constraints.
+//
+// Store does not validate references: controller checks that related entities are registered before
+// calling assignment methods. Store has to cascade removals of Users, Roles and Permissions to all relations,
+// removed Role is also dropped from constraints, constraints left with fewer Roles than their cardinality are removed.
+// Methods adding or removing entries return false if there was nothing to change.
+// Reverse lookups (PermissionRoles, PermissionDenies, PermissionConditions, RoleChildren, RoleUsers, RoleDomains)
+// are expected to be served by indexes,
+// controller uses them to answer reverse queries without scanning all Users and Roles.
+//
+// Controller serializes mutations and never runs them concurrently with reads,
+// read methods may be called concurrently with each other.
+type Store interface {
+	AddUser(u User) (bool, error)
+	RemoveUser(u User) (bool, error)
+	HasUser(u User) (bool, error)
+	Users() ([]User, error)
+
+	AddRole(r Role) (bool, error)
+	RemoveRole(r Role) (bool, error)
+	HasRole(r Role) (bool, error)
+	Roles() ([]Role, error)
+
+	AddPermission(p Permission) (bool, error)
+	RemovePermission(p Permission) (bool, error)
+	HasPermission(p Permission) (bool, error)
+	Permissions() ([]Permission, error)
+	// WildcardPermissions returns registered Permissions with wildcard Object or Action.
+	WildcardPermissions() ([]Permission, error)
+	// AddExactPermission marks registered Permission as applying to its own Object only, not to Object descendants.
+	AddExactPermission(p Permission) (bool, error)
+	RemoveExactPermission(p Permission) (bool, error)
+	HasExactPermission(p Permission) (bool, error)
+	ExactPermissions() ([]Permission, error)
+
+	AddRolePermission(r Role, p Permission) (bool, error)
+	RemoveRolePermission(r Role, p Permission) (bool, error)
+	HasRolePermission(r Role, p Permission) (bool, error)
+	RolePermissions(r Role) ([]Permission, error)
+	// PermissionRoles returns Roles Permission is assigned to, it is reverse of RolePermissions.
+	PermissionRoles(p Permission) ([]Role, error)
+
+	AddRoleDeny(r Role, p Permission) (bool, error)
+	RemoveRoleDeny(r Role, p Permission) (bool, error)
+	HasRoleDeny(r Role, p Permission) (bool, error)
+	RoleDenies(r Role) ([]Permission, error)
+	// PermissionDenies returns Roles Permission is denied to, it is reverse of RoleDenies.
+	PermissionDenies(p Permission) ([]Role, error)
+
+	// AddRoleCondition assigns Permission to Role granted only when expression holds,
+	// expression of existing assignment is replaced. Returns false if Role already has Permission on the same expression.
+	AddRoleCondition(r Role, p Permission, expr string) (bool, error)
+	RemoveRoleCondition(r Role, p Permission) (bool, error)
+	// RoleConditions returns expressions of conditional Permissions assigned to Role.
+	RoleConditions(r Role) (map[Permission]string, error)
+	// PermissionConditions returns expressions of Permission conditionally assigned to Roles, it is reverse of RoleConditions.
+	PermissionConditions(p Permission) (map[Role]string, error)
+
+	// AddActionImplication makes Permission with implying Action grant the same Object with implied Action.
+	AddActionImplication(i ActionImplication) (bool, error)
+	RemoveActionImplication(i ActionImplication) (bool, error)
+	ActionImplications() ([]ActionImplication, error)
+
+	AddRoleParent(child, parent Role) (bool, error)
+	RemoveRoleParent(child, parent Role) (bool, error)
+	RoleParents(r Role) ([]Role, error)
+	// RoleChildren returns Roles having Role as direct parent, it is reverse of RoleParents.
+	RoleChildren(r Role) ([]Role, error)
+
+	AddUserRole(u User, r Role, d Domain) (bool, error)
+	RemoveUserRole(u User, r Role, d Domain) (bool, error)
+	HasUserRole(u User, r Role, d Domain) (bool, error)
+	UserRoles(u User, d Domain) ([]Role, error)
+	// RoleUsers returns Users having Role assigned in Domain regardless of Validity, it is reverse of UserRoles.
+	RoleUsers(r Role, d Domain) ([]User, error)
+	// RoleDomains returns Domains Role is assigned to any User in, it is reverse of UserDomains.
+	RoleDomains(r Role) ([]Domain, error)
+	// SetUserRoleValidity bounds Role assignment of User in Domain in time, zero Validity removes bounds.
+	// Returns false if Role is not assigned or assignment already has the same Validity.
+	SetUserRoleValidity(u User, r Role, d Domain, v Validity) (bool, error)
+	// UserRoleValidity returns Validity of Role assignment, zero Validity if assignment is unbounded or missing.
+	UserRoleValidity(u User, r Role, d Domain) (Validity, error)
+	// UserDomains returns Domains User has any Role assigned in.
+	UserDomains(u User) ([]Domain, error)
+
+	// AddSSDConstraint returns false if constraint with the same Name already exists.
+	AddSSDConstraint(c SSDConstraint) (bool, error)
+	RemoveSSDConstraint(name string) (bool, error)
+	SSDConstraints() ([]SSDConstraint, error)
+
+	// AddDSDConstraint returns false if constraint with the same Name already exists.
+	AddDSDConstraint(c DSDConstraint) (bool, error)
+	RemoveDSDConstraint(name string) (bool, error)
+	DSDConstraints() ([]DSDConstraint, error)
+}
+
+// TxStore is Store able to group changes into transaction, like Stores backed by database.
+// RBAC.Update makes its changes through transaction of TxStore, so they are applied at once
+// for every controller sharing the Store.
+type TxStore interface {
+	Store
+
+	// Begin starts transaction. Changes made through returned StoreTx are visible through Store
+	// only once committed.
+	Begin() (StoreTx, error)
+}
+
+// StoreTx is transaction started by TxStore.Begin.
+// It is not used after Commit or Rollback.
+type StoreTx interface {
+	Store
+
+	Commit() error
+	Rollback() error
+}
@@ -0,0 +1,242 @@
+package rbac
+
+// effectiveStore is Store of controller keeping effective permission index: every change made through it
+// marks entries of the index it affects, so they are rebuilt before write lock of controller is released.
+// Marks are taken from changes themselves, so mutations made by Tx and inverse changes replayed by rollback
+// are followed the same way.
+type effectiveStore struct {
+	Store
+
+	index *effectiveIndex
+}
+
+// AddUser implements Store
+func (s *effectiveStore) AddUser(u User) (bool, error) {
+	ok, err := s.Store.AddUser(u)
+	if ok {
+		s.index.touchUser(u)
+	}
+	return ok, err
+}
+
+// RemoveUser implements Store
+func (s *effectiveStore) RemoveUser(u User) (bool, error) {
+	ok, err := s.Store.RemoveUser(u)
+	if ok {
+		s.index.touchUser(u)
+	}
+	return ok, err
+}
+
+// AddRole implements Store
+func (s *effectiveStore) AddRole(r Role) (bool, error) {
+	ok, err := s.Store.AddRole(r)
+	if ok {
+		s.index.touchRole(r)
+	}
+	return ok, err
+}
+
+// RemoveRole implements Store. Holders and descendants of Role are looked up before it is removed,
+// removal drops relations they are found through.
+func (s *effectiveStore) RemoveRole(r Role) (bool, error) {
+	if err := s.touchHolders(r); err != nil {
+		return false, err
+	}
+	if err := s.touchDescendants(r); err != nil {
+		return false, err
+	}
+	ok, err := s.Store.RemoveRole(r)
+	if ok {
+		s.index.touchRole(r)
+	}
+	return ok, err
+}
+
+// AddPermission implements Store
+func (s *effectiveStore) AddPermission(p Permission) (bool, error) {
+	ok, err := s.Store.AddPermission(p)
+	if ok {
+		s.index.touchPermission(p)
+	}
+	return ok, err
+}
+
+// RemovePermission implements Store. Roles Permission is assigned or denied to are looked up before it is removed.
+func (s *effectiveStore) RemovePermission(p Permission) (bool, error) {
+	granting, err := s.Store.PermissionRoles(p)
+	if err != nil {
+		return false, err
+	}
+	denying, err := s.Store.PermissionDenies(p)
+	if err != nil {
+		return false, err
+	}
+	for _, r := range append(granting, denying...) {
+		s.index.touchRole(r)
+	}
+	ok, err := s.Store.RemovePermission(p)
+	if ok {
+		s.index.touchPermission(p)
+	}
+	return ok, err
+}
+
+// AddExactPermission implements Store
+func (s *effectiveStore) AddExactPermission(p Permission) (bool, error) {
+	ok, err := s.Store.AddExactPermission(p)
+	if ok {
+		s.index.touchPermission(p)
+	}
+	return ok, err
+}
+
+// RemoveExactPermission implements Store
+func (s *effectiveStore) RemoveExactPermission(p Permission) (bool, error) {
+	ok, err := s.Store.RemoveExactPermission(p)
+	if ok {
+		s.index.touchPermission(p)
+	}
+	return ok, err
+}
+
+// AddRolePermission implements Store
+func (s *effectiveStore) AddRolePermission(r Role, p Permission) (bool, error) {
+	ok, err := s.Store.AddRolePermission(r, p)
+	if ok {
+		s.index.touchRole(r)
+	}
+	return ok, err
+}
+
+// RemoveRolePermission implements Store
+func (s *effectiveStore) RemoveRolePermission(r Role, p Permission) (bool, error) {
+	ok, err := s.Store.RemoveRolePermission(r, p)
+	if ok {
+		s.index.touchRole(r)
+	}
+	return ok, err
+}
+
+// AddRoleDeny implements Store
+func (s *effectiveStore) AddRoleDeny(r Role, p Permission) (bool, error) {
+	ok, err := s.Store.AddRoleDeny(r, p)
+	if ok {
+		s.index.touchRole(r)
+	}
+	return ok, err
+}
+
+// RemoveRoleDeny implements Store
+func (s *effectiveStore) RemoveRoleDeny(r Role, p Permission) (bool, error) {
+	ok, err := s.Store.RemoveRoleDeny(r, p)
+	if ok {
+		s.index.touchRole(r)
+	}
+	return ok, err
+}
+
+// AddActionImplication implements Store
+func (s *effectiveStore) AddActionImplication(i ActionImplication) (bool, error) {
+	ok, err := s.Store.AddActionImplication(i)
+	if ok {
+		s.index.touchActions()
+	}
+	return ok, err
+}
+
+// RemoveActionImplication implements Store
+func (s *effectiveStore) RemoveActionImplication(i ActionImplication) (bool, error) {
+	ok, err := s.Store.RemoveActionImplication(i)
+	if ok {
+		s.index.touchActions()
+	}
+	return ok, err
+}
+
+// AddRoleParent implements Store, descendants of child are marked when index is refreshed
+func (s *effectiveStore) AddRoleParent(child, parent Role) (bool, error) {
+	ok, err := s.Store.AddRoleParent(child, parent)
+	if ok {
+		s.index.touchRole(child)
+	}
+	return ok, err
+}
+
+// RemoveRoleParent implements Store
+func (s *effectiveStore) RemoveRoleParent(child, parent Role) (bool, error) {
+	ok, err := s.Store.RemoveRoleParent(child, parent)
+	if ok {
+		s.index.touchRole(child)
+	}
+	return ok, err
+}
+
+// AddUserRole implements Store
+func (s *effectiveStore) AddUserRole(u User, r Role, d Domain) (bool, error) {
+	ok, err := s.Store.AddUserRole(u, r, d)
+	if ok {
+		s.index.touchUser(u)
+	}
+	return ok, err
+}
+
+// RemoveUserRole implements Store
+func (s *effectiveStore) RemoveUserRole(u User, r Role, d Domain) (bool, error) {
+	ok, err := s.Store.RemoveUserRole(u, r, d)
+	if ok {
+		s.index.touchUser(u)
+	}
+	return ok, err
+}
+
+// SetUserRoleValidity implements Store
+func (s *effectiveStore) SetUserRoleValidity(u User, r Role, d Domain, v Validity) (bool, error) {
+	ok, err := s.Store.SetUserRoleValidity(u, r, d, v)
+	if ok {
+		s.index.touchUser(u)
+	}
+	return ok, err
+}
+
+// touchHolders marks Users having Role assigned in any Domain
+func (s *effectiveStore) touchHolders(r Role) error {
+	domains, err := s.Store.RoleDomains(r)
+	if err != nil {
+		return err
+	}
+	for _, d := range domains {
+		users, err := s.Store.RoleUsers(r, d)
+		if err != nil {
+			return err
+		}
+		for _, u := range users {
+			s.index.touchUser(u)
+		}
+	}
+	return nil
+}
+
+// touchDescendants marks Roles inheriting Role, directly or transitively
+func (s *effectiveStore) touchDescendants(r Role) error {
+	seen := map[Role]struct{}{r: {}}
+	queue := []Role{r}
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+
+		children, err := s.Store.RoleChildren(next)
+		if err != nil {
+			return err
+		}
+		for _, c := range children {
+			if _, ok := seen[c]; ok {
+				continue
+			}
+			seen[c] = struct{}{}
+			queue = append(queue, c)
+			s.index.touchRole(c)
+		}
+	}
+	return nil
+}
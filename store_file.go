@@ -0,0 +1,211 @@
+package rbac
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// FileStore is reference Store implementation persisting content to JSON file.
+// Content is kept in memory as MemoryStore does and the whole file is rewritten on every change,
+// so FileStore suits small policies and tests rather than production load.
+// File has the same format as document produced by RBAC.Export.
+type FileStore struct {
+	*MemoryStore
+
+	path string
+}
+
+// OpenFileStore creates FileStore persisting content to file at path.
+// Content of existing file is loaded, missing file is created on first change.
+func OpenFileStore(path string) (*FileStore, error) {
+	s := &FileStore{
+		MemoryStore: NewMemoryStore(),
+		path:        path,
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := decodeDocument(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("rbac: file %s: %w", path, err)
+	}
+	if err := NewRBACWithStore(s.MemoryStore).loadDocument(doc); err != nil {
+		return nil, fmt.Errorf("rbac: file %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Path returns path of file FileStore persists content to
+func (s *FileStore) Path() string {
+	return s.path
+}
+
+// AddUser implements Store
+func (s *FileStore) AddUser(u User) (bool, error) {
+	return s.persist(s.MemoryStore.AddUser(u))
+}
+
+// RemoveUser implements Store
+func (s *FileStore) RemoveUser(u User) (bool, error) {
+	return s.persist(s.MemoryStore.RemoveUser(u))
+}
+
+// AddRole implements Store
+func (s *FileStore) AddRole(r Role) (bool, error) {
+	return s.persist(s.MemoryStore.AddRole(r))
+}
+
+// RemoveRole implements Store
+func (s *FileStore) RemoveRole(r Role) (bool, error) {
+	return s.persist(s.MemoryStore.RemoveRole(r))
+}
+
+// AddPermission implements Store
+func (s *FileStore) AddPermission(p Permission) (bool, error) {
+	return s.persist(s.MemoryStore.AddPermission(p))
+}
+
+// RemovePermission implements Store
+func (s *FileStore) RemovePermission(p Permission) (bool, error) {
+	return s.persist(s.MemoryStore.RemovePermission(p))
+}
+
+// AddExactPermission implements Store
+func (s *FileStore) AddExactPermission(p Permission) (bool, error) {
+	return s.persist(s.MemoryStore.AddExactPermission(p))
+}
+
+// RemoveExactPermission implements Store
+func (s *FileStore) RemoveExactPermission(p Permission) (bool, error) {
+	return s.persist(s.MemoryStore.RemoveExactPermission(p))
+}
+
+// AddRolePermission implements Store
+func (s *FileStore) AddRolePermission(r Role, p Permission) (bool, error) {
+	return s.persist(s.MemoryStore.AddRolePermission(r, p))
+}
+
+// RemoveRolePermission implements Store
+func (s *FileStore) RemoveRolePermission(r Role, p Permission) (bool, error) {
+	return s.persist(s.MemoryStore.RemoveRolePermission(r, p))
+}
+
+// AddRoleDeny implements Store
+func (s *FileStore) AddRoleDeny(r Role, p Permission) (bool, error) {
+	return s.persist(s.MemoryStore.AddRoleDeny(r, p))
+}
+
+// RemoveRoleDeny implements Store
+func (s *FileStore) RemoveRoleDeny(r Role, p Permission) (bool, error) {
+	return s.persist(s.MemoryStore.RemoveRoleDeny(r, p))
+}
+
+// AddRoleCondition implements Store
+func (s *FileStore) AddRoleCondition(r Role, p Permission, expr string) (bool, error) {
+	return s.persist(s.MemoryStore.AddRoleCondition(r, p, expr))
+}
+
+// RemoveRoleCondition implements Store
+func (s *FileStore) RemoveRoleCondition(r Role, p Permission) (bool, error) {
+	return s.persist(s.MemoryStore.RemoveRoleCondition(r, p))
+}
+
+// AddActionImplication implements Store
+func (s *FileStore) AddActionImplication(i ActionImplication) (bool, error) {
+	return s.persist(s.MemoryStore.AddActionImplication(i))
+}
+
+// RemoveActionImplication implements Store
+func (s *FileStore) RemoveActionImplication(i ActionImplication) (bool, error) {
+	return s.persist(s.MemoryStore.RemoveActionImplication(i))
+}
+
+// AddRoleParent implements Store
+func (s *FileStore) AddRoleParent(child, parent Role) (bool, error) {
+	return s.persist(s.MemoryStore.AddRoleParent(child, parent))
+}
+
+// RemoveRoleParent implements Store
+func (s *FileStore) RemoveRoleParent(child, parent Role) (bool, error) {
+	return s.persist(s.MemoryStore.RemoveRoleParent(child, parent))
+}
+
+// AddUserRole implements Store
+func (s *FileStore) AddUserRole(u User, r Role, d Domain) (bool, error) {
+	return s.persist(s.MemoryStore.AddUserRole(u, r, d))
+}
+
+// RemoveUserRole implements Store
+func (s *FileStore) RemoveUserRole(u User, r Role, d Domain) (bool, error) {
+	return s.persist(s.MemoryStore.RemoveUserRole(u, r, d))
+}
+
+// SetUserRoleValidity implements Store
+func (s *FileStore) SetUserRoleValidity(u User, r Role, d Domain, v Validity) (bool, error) {
+	return s.persist(s.MemoryStore.SetUserRoleValidity(u, r, d, v))
+}
+
+// AddSSDConstraint implements Store
+func (s *FileStore) AddSSDConstraint(c SSDConstraint) (bool, error) {
+	return s.persist(s.MemoryStore.AddSSDConstraint(c))
+}
+
+// RemoveSSDConstraint implements Store
+func (s *FileStore) RemoveSSDConstraint(name string) (bool, error) {
+	return s.persist(s.MemoryStore.RemoveSSDConstraint(name))
+}
+
+// AddDSDConstraint implements Store
+func (s *FileStore) AddDSDConstraint(c DSDConstraint) (bool, error) {
+	return s.persist(s.MemoryStore.AddDSDConstraint(c))
+}
+
+// RemoveDSDConstraint implements Store
+func (s *FileStore) RemoveDSDConstraint(name string) (bool, error) {
+	return s.persist(s.MemoryStore.RemoveDSDConstraint(name))
+}
+
+// persist saves content to file if it was changed.
+// If saving fails, in-memory content stays changed and the error is returned.
+func (s *FileStore) persist(changed bool, err error) (bool, error) {
+	if err != nil || !changed {
+		return changed, err
+	}
+	return true, s.save()
+}
+
+// save atomically replaces file with current content
+func (s *FileStore) save() error {
+	doc, err := exportDocument(s.MemoryStore)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(doc, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
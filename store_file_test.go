@@ -0,0 +1,85 @@
+package rbac
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileStorePersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rbac.json")
+
+	// case 1: missing file is not created until first change
+	s, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("[case 1] open error: expected err equal nil, got %v", err)
+	}
+
+	if s.Path() != path {
+		t.Errorf("[case 1] invalid output: expected path %s, got %s", path, s.Path())
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("[case 1] file error: expected file to not exist, got %v", err)
+	}
+
+	// case 2: controller content survives reopening
+	rbac := NewRBACWithStore(s)
+
+	u := NewUser(defaultUserID)
+	child := NewRole("child")
+	parent := NewRole("parent")
+	p := NewPermission(NewObject(defaultObjectID), NewAction(defaultActionID))
+
+	rbac.RegisterUser(u)
+	rbac.RegisterRole(child)
+	rbac.RegisterRole(parent)
+	rbac.RegisterPermission(p)
+	rbac.AssignPermissionToRole(parent, p)
+	rbac.AddRoleParent(child, parent)
+	rbac.AssignRoleToUser(u, child)
+
+	reopened, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("[case 2] open error: expected err equal nil, got %v", err)
+	}
+
+	ok, err := NewRBACWithStore(reopened).UserHasPermission(u, p)
+	if err != nil || !ok {
+		t.Errorf("[case 2] invalid output: expected %t, got %t (err %v)", true, ok, err)
+	}
+
+	// case 3: removals are persisted too
+	rbac.RemoveRole(parent)
+
+	reopened, err = OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("[case 3] open error: expected err equal nil, got %v", err)
+	}
+
+	ok, err = NewRBACWithStore(reopened).UserHasPermission(u, p)
+	if err != nil || ok {
+		t.Errorf("[case 3] invalid output: expected %t, got %t (err %v)", false, ok, err)
+	}
+}
+
+func TestOpenFileStoreInvalid(t *testing.T) {
+	dir := t.TempDir()
+
+	cases := []string{
+		`not a json`,
+		`{"version":0}`,
+		`{"version":1,"users":["u"],"user_roles":[{"user":"u","role":"r"}]}`,
+	}
+
+	for i, c := range cases {
+		path := filepath.Join(dir, "rbac.json")
+		if err := os.WriteFile(path, []byte(c), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := OpenFileStore(path); err == nil {
+			t.Errorf("[case %d] open error: expected error, got nil", i+1)
+		}
+	}
+}
@@ -0,0 +1,527 @@
+package rbac
+
+// journalStore is StoreTx of Store not implementing TxStore: changes are applied to Store immediately
+// and inverse of every change is recorded, so changes can be undone by Rollback.
+// Cascading removals of Users, Roles and Permissions record relations they drop, found through
+// Store reverse lookups, so recording costs as much as the removal itself.
+// Changes which changed nothing are not recorded.
+type journalStore struct {
+	Store
+
+	undo []func() error
+}
+
+// record adds inverse of change made by method returning ok and err
+func (s *journalStore) record(ok bool, err error, inverse func() error) (bool, error) {
+	if ok {
+		s.undo = append(s.undo, inverse)
+	}
+	return ok, err
+}
+
+// Commit implements StoreTx, changes are already applied so journal is just cleared
+func (s *journalStore) Commit() error {
+	s.undo = nil
+	return nil
+}
+
+// Rollback implements StoreTx: it undoes recorded changes, the latest first, and clears journal.
+// Returns error of the first inverse that failed, content of Store is left partially restored then.
+func (s *journalStore) Rollback() error {
+	for len(s.undo) > 0 {
+		last := len(s.undo) - 1
+		if err := s.undo[last](); err != nil {
+			return err
+		}
+		s.undo = s.undo[:last]
+	}
+	return nil
+}
+
+// AddUser implements Store
+func (s *journalStore) AddUser(u User) (bool, error) {
+	ok, err := s.Store.AddUser(u)
+	return s.record(ok, err, func() error {
+		_, err := s.Store.RemoveUser(u)
+		return err
+	})
+}
+
+// RemoveUser implements Store
+func (s *journalStore) RemoveUser(u User) (bool, error) {
+	domains, err := s.Store.UserDomains(u)
+	if err != nil {
+		return false, err
+	}
+	assignments := make([]assignment, 0)
+	for _, d := range domains {
+		roles, err := s.Store.UserRoles(u, d)
+		if err != nil {
+			return false, err
+		}
+		for _, r := range roles {
+			a, err := s.assignment(u, r, d)
+			if err != nil {
+				return false, err
+			}
+			assignments = append(assignments, a)
+		}
+	}
+
+	ok, err := s.Store.RemoveUser(u)
+	return s.record(ok, err, func() error {
+		if _, err := s.Store.AddUser(u); err != nil {
+			return err
+		}
+		return s.restoreAssignments(assignments)
+	})
+}
+
+// AddRole implements Store
+func (s *journalStore) AddRole(r Role) (bool, error) {
+	ok, err := s.Store.AddRole(r)
+	return s.record(ok, err, func() error {
+		_, err := s.Store.RemoveRole(r)
+		return err
+	})
+}
+
+// RemoveRole implements Store
+func (s *journalStore) RemoveRole(r Role) (bool, error) {
+	exists, err := s.Store.HasRole(r)
+	if err != nil || !exists {
+		return false, err
+	}
+
+	perms, err := s.Store.RolePermissions(r)
+	if err != nil {
+		return false, err
+	}
+	denies, err := s.Store.RoleDenies(r)
+	if err != nil {
+		return false, err
+	}
+	conds, err := s.Store.RoleConditions(r)
+	if err != nil {
+		return false, err
+	}
+	parents, err := s.Store.RoleParents(r)
+	if err != nil {
+		return false, err
+	}
+	children, err := s.Store.RoleChildren(r)
+	if err != nil {
+		return false, err
+	}
+	domains, err := s.Store.RoleDomains(r)
+	if err != nil {
+		return false, err
+	}
+	assignments := make([]assignment, 0)
+	for _, d := range domains {
+		users, err := s.Store.RoleUsers(r, d)
+		if err != nil {
+			return false, err
+		}
+		for _, u := range users {
+			a, err := s.assignment(u, r, d)
+			if err != nil {
+				return false, err
+			}
+			assignments = append(assignments, a)
+		}
+	}
+	ssd, err := s.Store.SSDConstraints()
+	if err != nil {
+		return false, err
+	}
+	dsd, err := s.Store.DSDConstraints()
+	if err != nil {
+		return false, err
+	}
+
+	ok, err := s.Store.RemoveRole(r)
+	return s.record(ok, err, func() error {
+		if _, err := s.Store.AddRole(r); err != nil {
+			return err
+		}
+		for _, p := range perms {
+			if _, err := s.Store.AddRolePermission(r, p); err != nil {
+				return err
+			}
+		}
+		for _, p := range denies {
+			if _, err := s.Store.AddRoleDeny(r, p); err != nil {
+				return err
+			}
+		}
+		for p, expr := range conds {
+			if _, err := s.Store.AddRoleCondition(r, p, expr); err != nil {
+				return err
+			}
+		}
+		for _, parent := range parents {
+			if _, err := s.Store.AddRoleParent(r, parent); err != nil {
+				return err
+			}
+		}
+		for _, child := range children {
+			if _, err := s.Store.AddRoleParent(child, r); err != nil {
+				return err
+			}
+		}
+		if err := s.restoreAssignments(assignments); err != nil {
+			return err
+		}
+		for _, c := range ssd {
+			if containsRole(c.Roles, r) {
+				if err := s.restoreSSDConstraint(c); err != nil {
+					return err
+				}
+			}
+		}
+		for _, c := range dsd {
+			if containsRole(c.Roles, r) {
+				if err := s.restoreDSDConstraint(c); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// AddPermission implements Store
+func (s *journalStore) AddPermission(p Permission) (bool, error) {
+	ok, err := s.Store.AddPermission(p)
+	return s.record(ok, err, func() error {
+		_, err := s.Store.RemovePermission(p)
+		return err
+	})
+}
+
+// RemovePermission implements Store
+func (s *journalStore) RemovePermission(p Permission) (bool, error) {
+	exists, err := s.Store.HasPermission(p)
+	if err != nil || !exists {
+		return false, err
+	}
+
+	exact, err := s.Store.HasExactPermission(p)
+	if err != nil {
+		return false, err
+	}
+	granting, err := s.Store.PermissionRoles(p)
+	if err != nil {
+		return false, err
+	}
+	denying, err := s.Store.PermissionDenies(p)
+	if err != nil {
+		return false, err
+	}
+	conds, err := s.Store.PermissionConditions(p)
+	if err != nil {
+		return false, err
+	}
+
+	ok, err := s.Store.RemovePermission(p)
+	return s.record(ok, err, func() error {
+		if _, err := s.Store.AddPermission(p); err != nil {
+			return err
+		}
+		if exact {
+			if _, err := s.Store.AddExactPermission(p); err != nil {
+				return err
+			}
+		}
+		for _, r := range granting {
+			if _, err := s.Store.AddRolePermission(r, p); err != nil {
+				return err
+			}
+		}
+		for _, r := range denying {
+			if _, err := s.Store.AddRoleDeny(r, p); err != nil {
+				return err
+			}
+		}
+		for r, expr := range conds {
+			if _, err := s.Store.AddRoleCondition(r, p, expr); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// AddExactPermission implements Store
+func (s *journalStore) AddExactPermission(p Permission) (bool, error) {
+	ok, err := s.Store.AddExactPermission(p)
+	return s.record(ok, err, func() error {
+		_, err := s.Store.RemoveExactPermission(p)
+		return err
+	})
+}
+
+// RemoveExactPermission implements Store
+func (s *journalStore) RemoveExactPermission(p Permission) (bool, error) {
+	ok, err := s.Store.RemoveExactPermission(p)
+	return s.record(ok, err, func() error {
+		_, err := s.Store.AddExactPermission(p)
+		return err
+	})
+}
+
+// AddRolePermission implements Store
+func (s *journalStore) AddRolePermission(r Role, p Permission) (bool, error) {
+	ok, err := s.Store.AddRolePermission(r, p)
+	return s.record(ok, err, func() error {
+		_, err := s.Store.RemoveRolePermission(r, p)
+		return err
+	})
+}
+
+// RemoveRolePermission implements Store
+func (s *journalStore) RemoveRolePermission(r Role, p Permission) (bool, error) {
+	ok, err := s.Store.RemoveRolePermission(r, p)
+	return s.record(ok, err, func() error {
+		_, err := s.Store.AddRolePermission(r, p)
+		return err
+	})
+}
+
+// AddRoleDeny implements Store
+func (s *journalStore) AddRoleDeny(r Role, p Permission) (bool, error) {
+	ok, err := s.Store.AddRoleDeny(r, p)
+	return s.record(ok, err, func() error {
+		_, err := s.Store.RemoveRoleDeny(r, p)
+		return err
+	})
+}
+
+// RemoveRoleDeny implements Store
+func (s *journalStore) RemoveRoleDeny(r Role, p Permission) (bool, error) {
+	ok, err := s.Store.RemoveRoleDeny(r, p)
+	return s.record(ok, err, func() error {
+		_, err := s.Store.AddRoleDeny(r, p)
+		return err
+	})
+}
+
+// AddRoleCondition implements Store
+func (s *journalStore) AddRoleCondition(r Role, p Permission, expr string) (bool, error) {
+	conds, err := s.Store.RoleConditions(r)
+	if err != nil {
+		return false, err
+	}
+	previous, replaced := conds[p]
+
+	ok, err := s.Store.AddRoleCondition(r, p, expr)
+	return s.record(ok, err, func() error {
+		if replaced {
+			_, err := s.Store.AddRoleCondition(r, p, previous)
+			return err
+		}
+		_, err := s.Store.RemoveRoleCondition(r, p)
+		return err
+	})
+}
+
+// RemoveRoleCondition implements Store
+func (s *journalStore) RemoveRoleCondition(r Role, p Permission) (bool, error) {
+	conds, err := s.Store.RoleConditions(r)
+	if err != nil {
+		return false, err
+	}
+	previous := conds[p]
+
+	ok, err := s.Store.RemoveRoleCondition(r, p)
+	return s.record(ok, err, func() error {
+		_, err := s.Store.AddRoleCondition(r, p, previous)
+		return err
+	})
+}
+
+// AddActionImplication implements Store
+func (s *journalStore) AddActionImplication(i ActionImplication) (bool, error) {
+	ok, err := s.Store.AddActionImplication(i)
+	return s.record(ok, err, func() error {
+		_, err := s.Store.RemoveActionImplication(i)
+		return err
+	})
+}
+
+// RemoveActionImplication implements Store
+func (s *journalStore) RemoveActionImplication(i ActionImplication) (bool, error) {
+	ok, err := s.Store.RemoveActionImplication(i)
+	return s.record(ok, err, func() error {
+		_, err := s.Store.AddActionImplication(i)
+		return err
+	})
+}
+
+// AddRoleParent implements Store
+func (s *journalStore) AddRoleParent(child, parent Role) (bool, error) {
+	ok, err := s.Store.AddRoleParent(child, parent)
+	return s.record(ok, err, func() error {
+		_, err := s.Store.RemoveRoleParent(child, parent)
+		return err
+	})
+}
+
+// RemoveRoleParent implements Store
+func (s *journalStore) RemoveRoleParent(child, parent Role) (bool, error) {
+	ok, err := s.Store.RemoveRoleParent(child, parent)
+	return s.record(ok, err, func() error {
+		_, err := s.Store.AddRoleParent(child, parent)
+		return err
+	})
+}
+
+// AddUserRole implements Store
+func (s *journalStore) AddUserRole(u User, r Role, d Domain) (bool, error) {
+	ok, err := s.Store.AddUserRole(u, r, d)
+	return s.record(ok, err, func() error {
+		_, err := s.Store.RemoveUserRole(u, r, d)
+		return err
+	})
+}
+
+// RemoveUserRole implements Store
+func (s *journalStore) RemoveUserRole(u User, r Role, d Domain) (bool, error) {
+	a, err := s.assignment(u, r, d)
+	if err != nil {
+		return false, err
+	}
+
+	ok, err := s.Store.RemoveUserRole(u, r, d)
+	return s.record(ok, err, func() error {
+		return s.restoreAssignments([]assignment{a})
+	})
+}
+
+// SetUserRoleValidity implements Store
+func (s *journalStore) SetUserRoleValidity(u User, r Role, d Domain, v Validity) (bool, error) {
+	previous, err := s.Store.UserRoleValidity(u, r, d)
+	if err != nil {
+		return false, err
+	}
+
+	ok, err := s.Store.SetUserRoleValidity(u, r, d, v)
+	return s.record(ok, err, func() error {
+		_, err := s.Store.SetUserRoleValidity(u, r, d, previous)
+		return err
+	})
+}
+
+// AddSSDConstraint implements Store
+func (s *journalStore) AddSSDConstraint(c SSDConstraint) (bool, error) {
+	ok, err := s.Store.AddSSDConstraint(c)
+	return s.record(ok, err, func() error {
+		_, err := s.Store.RemoveSSDConstraint(c.Name)
+		return err
+	})
+}
+
+// RemoveSSDConstraint implements Store
+func (s *journalStore) RemoveSSDConstraint(name string) (bool, error) {
+	constraints, err := s.Store.SSDConstraints()
+	if err != nil {
+		return false, err
+	}
+	var previous SSDConstraint
+	for _, c := range constraints {
+		if c.Name == name {
+			previous = c
+		}
+	}
+
+	ok, err := s.Store.RemoveSSDConstraint(name)
+	return s.record(ok, err, func() error {
+		_, err := s.Store.AddSSDConstraint(previous)
+		return err
+	})
+}
+
+// AddDSDConstraint implements Store
+func (s *journalStore) AddDSDConstraint(c DSDConstraint) (bool, error) {
+	ok, err := s.Store.AddDSDConstraint(c)
+	return s.record(ok, err, func() error {
+		_, err := s.Store.RemoveDSDConstraint(c.Name)
+		return err
+	})
+}
+
+// RemoveDSDConstraint implements Store
+func (s *journalStore) RemoveDSDConstraint(name string) (bool, error) {
+	constraints, err := s.Store.DSDConstraints()
+	if err != nil {
+		return false, err
+	}
+	var previous DSDConstraint
+	for _, c := range constraints {
+		if c.Name == name {
+			previous = c
+		}
+	}
+
+	ok, err := s.Store.RemoveDSDConstraint(name)
+	return s.record(ok, err, func() error {
+		_, err := s.Store.AddDSDConstraint(previous)
+		return err
+	})
+}
+
+// assignment is Role assigned to User in Domain along with its Validity
+type assignment struct {
+	user     User
+	role     Role
+	domain   Domain
+	validity Validity
+}
+
+func (s *journalStore) assignment(u User, r Role, d Domain) (assignment, error) {
+	v, err := s.Store.UserRoleValidity(u, r, d)
+	if err != nil {
+		return assignment{}, err
+	}
+	return assignment{user: u, role: r, domain: d, validity: v}, nil
+}
+
+func (s *journalStore) restoreAssignments(list []assignment) error {
+	for _, a := range list {
+		if _, err := s.Store.AddUserRole(a.user, a.role, a.domain); err != nil {
+			return err
+		}
+		if _, err := s.Store.SetUserRoleValidity(a.user, a.role, a.domain, a.validity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreSSDConstraint replaces constraint, removal of Role drops it from constraints instead of removing them
+func (s *journalStore) restoreSSDConstraint(c SSDConstraint) error {
+	if _, err := s.Store.RemoveSSDConstraint(c.Name); err != nil {
+		return err
+	}
+	_, err := s.Store.AddSSDConstraint(c)
+	return err
+}
+
+// restoreDSDConstraint replaces constraint, see restoreSSDConstraint
+func (s *journalStore) restoreDSDConstraint(c DSDConstraint) error {
+	if _, err := s.Store.RemoveDSDConstraint(c.Name); err != nil {
+		return err
+	}
+	_, err := s.Store.AddDSDConstraint(c)
+	return err
+}
+
+func containsRole(list []Role, r Role) bool {
+	for _, tmp := range list {
+		if tmp == r {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,687 @@
+package rbac
+
+// MemoryStore is default in-memory Store of RBAC controller.
+// MemoryStore is not synchronized, it relies on the controller to serialize mutations.
+type MemoryStore struct {
+	registeredPermissions map[Permission]struct{}
+	registeredWildcards   map[Permission]struct{}
+	exactPermissions      map[Permission]struct{}
+	registeredRoles       map[Role]struct{}
+	registeredUsers       map[User]struct{}
+
+	perms2roles   map[Role]map[Permission]struct{}
+	denies2roles  map[Role]map[Permission]struct{}
+	conds2roles   map[Role]map[Permission]string
+	roles2users   map[User]map[Domain]map[Role]Validity
+	parents2roles map[Role]map[Role]struct{}
+	implications  map[ActionImplication]struct{}
+
+	// reverse indexes of perms2roles, denies2roles, conds2roles, parents2roles and roles2users,
+	// cascading removals of Roles and Permissions walk them to touch affected entries only
+	roles2perms    map[Permission]map[Role]struct{}
+	roles2denies   map[Permission]map[Role]struct{}
+	roles2conds    map[Permission]map[Role]struct{}
+	children2roles map[Role]map[Role]struct{}
+	users2roles    map[Role]map[Domain]map[User]struct{}
+
+	ssdConstraints map[string]SSDConstraint
+	dsdConstraints map[string]SSDConstraint
+}
+
+// NewMemoryStore creates empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		registeredPermissions: make(map[Permission]struct{}),
+		registeredWildcards:   make(map[Permission]struct{}),
+		exactPermissions:      make(map[Permission]struct{}),
+		registeredRoles:       make(map[Role]struct{}),
+		registeredUsers:       make(map[User]struct{}),
+
+		perms2roles:   make(map[Role]map[Permission]struct{}),
+		denies2roles:  make(map[Role]map[Permission]struct{}),
+		conds2roles:   make(map[Role]map[Permission]string),
+		roles2users:   make(map[User]map[Domain]map[Role]Validity),
+		parents2roles: make(map[Role]map[Role]struct{}),
+		implications:  make(map[ActionImplication]struct{}),
+
+		roles2perms:    make(map[Permission]map[Role]struct{}),
+		roles2denies:   make(map[Permission]map[Role]struct{}),
+		roles2conds:    make(map[Permission]map[Role]struct{}),
+		children2roles: make(map[Role]map[Role]struct{}),
+		users2roles:    make(map[Role]map[Domain]map[User]struct{}),
+
+		ssdConstraints: make(map[string]SSDConstraint),
+		dsdConstraints: make(map[string]SSDConstraint),
+	}
+}
+
+// AddUser implements Store
+func (s *MemoryStore) AddUser(u User) (bool, error) {
+	if _, ok := s.registeredUsers[u]; ok {
+		return false, nil
+	}
+	s.registeredUsers[u] = struct{}{}
+	return true, nil
+}
+
+// RemoveUser implements Store
+func (s *MemoryStore) RemoveUser(u User) (bool, error) {
+	if _, ok := s.registeredUsers[u]; !ok {
+		return false, nil
+	}
+	for d, roles := range s.roles2users[u] {
+		for r := range roles {
+			s.unindexUserRole(u, r, d)
+		}
+	}
+	delete(s.roles2users, u)
+	delete(s.registeredUsers, u)
+	return true, nil
+}
+
+// HasUser implements Store
+func (s *MemoryStore) HasUser(u User) (bool, error) {
+	_, ok := s.registeredUsers[u]
+	return ok, nil
+}
+
+// Users implements Store
+func (s *MemoryStore) Users() ([]User, error) {
+	out := make([]User, 0, len(s.registeredUsers))
+	for u := range s.registeredUsers {
+		out = append(out, u)
+	}
+	return out, nil
+}
+
+// AddRole implements Store
+func (s *MemoryStore) AddRole(r Role) (bool, error) {
+	if _, ok := s.registeredRoles[r]; ok {
+		return false, nil
+	}
+	s.registeredRoles[r] = struct{}{}
+	return true, nil
+}
+
+// RemoveRole implements Store
+func (s *MemoryStore) RemoveRole(r Role) (bool, error) {
+	if _, ok := s.registeredRoles[r]; !ok {
+		return false, nil
+	}
+
+	// removing Role from Users holding it
+	for d, users := range s.users2roles[r] {
+		for u := range users {
+			delete(s.roles2users[u][d], r)
+			if len(s.roles2users[u][d]) == 0 {
+				delete(s.roles2users[u], d)
+			}
+		}
+	}
+	delete(s.users2roles, r)
+
+	// removing Role from hierarchy
+	for parent := range s.parents2roles[r] {
+		removeRoleRole(s.children2roles, parent, r)
+	}
+	delete(s.parents2roles, r)
+	for child := range s.children2roles[r] {
+		removeRoleRole(s.parents2roles, child, r)
+	}
+	delete(s.children2roles, r)
+
+	// removing Role from constraints
+	dropConstraintRole(s.ssdConstraints, r)
+	dropConstraintRole(s.dsdConstraints, r)
+
+	for p := range s.perms2roles[r] {
+		removePermissionRole(s.roles2perms, p, r)
+	}
+	for p := range s.denies2roles[r] {
+		removePermissionRole(s.roles2denies, p, r)
+	}
+	for p := range s.conds2roles[r] {
+		removePermissionRole(s.roles2conds, p, r)
+	}
+	delete(s.perms2roles, r)
+	delete(s.denies2roles, r)
+	delete(s.conds2roles, r)
+	delete(s.registeredRoles, r)
+	return true, nil
+}
+
+// HasRole implements Store
+func (s *MemoryStore) HasRole(r Role) (bool, error) {
+	_, ok := s.registeredRoles[r]
+	return ok, nil
+}
+
+// Roles implements Store
+func (s *MemoryStore) Roles() ([]Role, error) {
+	out := make([]Role, 0, len(s.registeredRoles))
+	for r := range s.registeredRoles {
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+// AddPermission implements Store
+func (s *MemoryStore) AddPermission(p Permission) (bool, error) {
+	if _, ok := s.registeredPermissions[p]; ok {
+		return false, nil
+	}
+	s.registeredPermissions[p] = struct{}{}
+	if p.Wildcard() {
+		s.registeredWildcards[p] = struct{}{}
+	}
+	return true, nil
+}
+
+// RemovePermission implements Store
+func (s *MemoryStore) RemovePermission(p Permission) (bool, error) {
+	if _, ok := s.registeredPermissions[p]; !ok {
+		return false, nil
+	}
+
+	// removing Permission from Roles holding it
+	for r := range s.roles2perms[p] {
+		delete(s.perms2roles[r], p)
+	}
+	for r := range s.roles2denies[p] {
+		delete(s.denies2roles[r], p)
+	}
+	for r := range s.roles2conds[p] {
+		delete(s.conds2roles[r], p)
+	}
+	delete(s.roles2perms, p)
+	delete(s.roles2denies, p)
+	delete(s.roles2conds, p)
+
+	delete(s.registeredPermissions, p)
+	delete(s.registeredWildcards, p)
+	delete(s.exactPermissions, p)
+	return true, nil
+}
+
+// HasPermission implements Store
+func (s *MemoryStore) HasPermission(p Permission) (bool, error) {
+	_, ok := s.registeredPermissions[p]
+	return ok, nil
+}
+
+// Permissions implements Store
+func (s *MemoryStore) Permissions() ([]Permission, error) {
+	return permissionsOf(s.registeredPermissions), nil
+}
+
+// WildcardPermissions implements Store
+func (s *MemoryStore) WildcardPermissions() ([]Permission, error) {
+	return permissionsOf(s.registeredWildcards), nil
+}
+
+// AddExactPermission implements Store
+func (s *MemoryStore) AddExactPermission(p Permission) (bool, error) {
+	if _, ok := s.exactPermissions[p]; ok {
+		return false, nil
+	}
+	s.exactPermissions[p] = struct{}{}
+	return true, nil
+}
+
+// RemoveExactPermission implements Store
+func (s *MemoryStore) RemoveExactPermission(p Permission) (bool, error) {
+	if _, ok := s.exactPermissions[p]; !ok {
+		return false, nil
+	}
+	delete(s.exactPermissions, p)
+	return true, nil
+}
+
+// HasExactPermission implements Store
+func (s *MemoryStore) HasExactPermission(p Permission) (bool, error) {
+	_, ok := s.exactPermissions[p]
+	return ok, nil
+}
+
+// ExactPermissions implements Store
+func (s *MemoryStore) ExactPermissions() ([]Permission, error) {
+	return permissionsOf(s.exactPermissions), nil
+}
+
+// AddRolePermission implements Store
+func (s *MemoryStore) AddRolePermission(r Role, p Permission) (bool, error) {
+	if !addRolePermission(s.perms2roles, r, p) {
+		return false, nil
+	}
+	addPermissionRole(s.roles2perms, p, r)
+	return true, nil
+}
+
+// RemoveRolePermission implements Store
+func (s *MemoryStore) RemoveRolePermission(r Role, p Permission) (bool, error) {
+	if !removeRolePermission(s.perms2roles, r, p) {
+		return false, nil
+	}
+	removePermissionRole(s.roles2perms, p, r)
+	return true, nil
+}
+
+// HasRolePermission implements Store
+func (s *MemoryStore) HasRolePermission(r Role, p Permission) (bool, error) {
+	_, ok := s.perms2roles[r][p]
+	return ok, nil
+}
+
+// RolePermissions implements Store
+func (s *MemoryStore) RolePermissions(r Role) ([]Permission, error) {
+	return permissionsOf(s.perms2roles[r]), nil
+}
+
+// PermissionRoles implements Store
+func (s *MemoryStore) PermissionRoles(p Permission) ([]Role, error) {
+	return rolesOf(s.roles2perms[p]), nil
+}
+
+// AddRoleDeny implements Store
+func (s *MemoryStore) AddRoleDeny(r Role, p Permission) (bool, error) {
+	if !addRolePermission(s.denies2roles, r, p) {
+		return false, nil
+	}
+	addPermissionRole(s.roles2denies, p, r)
+	return true, nil
+}
+
+// RemoveRoleDeny implements Store
+func (s *MemoryStore) RemoveRoleDeny(r Role, p Permission) (bool, error) {
+	if !removeRolePermission(s.denies2roles, r, p) {
+		return false, nil
+	}
+	removePermissionRole(s.roles2denies, p, r)
+	return true, nil
+}
+
+// HasRoleDeny implements Store
+func (s *MemoryStore) HasRoleDeny(r Role, p Permission) (bool, error) {
+	_, ok := s.denies2roles[r][p]
+	return ok, nil
+}
+
+// RoleDenies implements Store
+func (s *MemoryStore) RoleDenies(r Role) ([]Permission, error) {
+	return permissionsOf(s.denies2roles[r]), nil
+}
+
+// PermissionDenies implements Store
+func (s *MemoryStore) PermissionDenies(p Permission) ([]Role, error) {
+	return rolesOf(s.roles2denies[p]), nil
+}
+
+// AddRoleCondition implements Store
+func (s *MemoryStore) AddRoleCondition(r Role, p Permission, expr string) (bool, error) {
+	conds, ok := s.conds2roles[r]
+	if !ok {
+		conds = make(map[Permission]string)
+		s.conds2roles[r] = conds
+	}
+	if current, ok := conds[p]; ok && current == expr {
+		return false, nil
+	}
+	conds[p] = expr
+	addPermissionRole(s.roles2conds, p, r)
+	return true, nil
+}
+
+// RemoveRoleCondition implements Store
+func (s *MemoryStore) RemoveRoleCondition(r Role, p Permission) (bool, error) {
+	if _, ok := s.conds2roles[r][p]; !ok {
+		return false, nil
+	}
+	delete(s.conds2roles[r], p)
+	removePermissionRole(s.roles2conds, p, r)
+	return true, nil
+}
+
+// RoleConditions implements Store
+func (s *MemoryStore) RoleConditions(r Role) (map[Permission]string, error) {
+	out := make(map[Permission]string, len(s.conds2roles[r]))
+	for p, expr := range s.conds2roles[r] {
+		out[p] = expr
+	}
+	return out, nil
+}
+
+// PermissionConditions implements Store
+func (s *MemoryStore) PermissionConditions(p Permission) (map[Role]string, error) {
+	out := make(map[Role]string, len(s.roles2conds[p]))
+	for r := range s.roles2conds[p] {
+		out[r] = s.conds2roles[r][p]
+	}
+	return out, nil
+}
+
+// AddActionImplication implements Store
+func (s *MemoryStore) AddActionImplication(i ActionImplication) (bool, error) {
+	if _, ok := s.implications[i]; ok {
+		return false, nil
+	}
+	s.implications[i] = struct{}{}
+	return true, nil
+}
+
+// RemoveActionImplication implements Store
+func (s *MemoryStore) RemoveActionImplication(i ActionImplication) (bool, error) {
+	if _, ok := s.implications[i]; !ok {
+		return false, nil
+	}
+	delete(s.implications, i)
+	return true, nil
+}
+
+// ActionImplications implements Store
+func (s *MemoryStore) ActionImplications() ([]ActionImplication, error) {
+	out := make([]ActionImplication, 0, len(s.implications))
+	for i := range s.implications {
+		out = append(out, i)
+	}
+	return out, nil
+}
+
+// AddRoleParent implements Store
+func (s *MemoryStore) AddRoleParent(child, parent Role) (bool, error) {
+	parents, ok := s.parents2roles[child]
+	if !ok {
+		parents = make(map[Role]struct{})
+		s.parents2roles[child] = parents
+	}
+	if _, ok := parents[parent]; ok {
+		return false, nil
+	}
+	parents[parent] = struct{}{}
+	addRoleRole(s.children2roles, parent, child)
+	return true, nil
+}
+
+// RemoveRoleParent implements Store
+func (s *MemoryStore) RemoveRoleParent(child, parent Role) (bool, error) {
+	if _, ok := s.parents2roles[child][parent]; !ok {
+		return false, nil
+	}
+	delete(s.parents2roles[child], parent)
+	removeRoleRole(s.children2roles, parent, child)
+	return true, nil
+}
+
+// RoleParents implements Store
+func (s *MemoryStore) RoleParents(r Role) ([]Role, error) {
+	return rolesOf(s.parents2roles[r]), nil
+}
+
+// RoleChildren implements Store
+func (s *MemoryStore) RoleChildren(r Role) ([]Role, error) {
+	return rolesOf(s.children2roles[r]), nil
+}
+
+// AddUserRole implements Store
+func (s *MemoryStore) AddUserRole(u User, r Role, d Domain) (bool, error) {
+	domains, ok := s.roles2users[u]
+	if !ok {
+		domains = make(map[Domain]map[Role]Validity)
+		s.roles2users[u] = domains
+	}
+	userRoles, ok := domains[d]
+	if !ok {
+		userRoles = make(map[Role]Validity)
+		domains[d] = userRoles
+	}
+	if _, ok := userRoles[r]; ok {
+		return false, nil
+	}
+	userRoles[r] = Validity{}
+
+	domainUsers, ok := s.users2roles[r]
+	if !ok {
+		domainUsers = make(map[Domain]map[User]struct{})
+		s.users2roles[r] = domainUsers
+	}
+	users, ok := domainUsers[d]
+	if !ok {
+		users = make(map[User]struct{})
+		domainUsers[d] = users
+	}
+	users[u] = struct{}{}
+	return true, nil
+}
+
+// RemoveUserRole implements Store
+func (s *MemoryStore) RemoveUserRole(u User, r Role, d Domain) (bool, error) {
+	userRoles := s.roles2users[u][d]
+	if _, ok := userRoles[r]; !ok {
+		return false, nil
+	}
+	delete(userRoles, r)
+	if len(userRoles) == 0 {
+		delete(s.roles2users[u], d)
+	}
+	s.unindexUserRole(u, r, d)
+	return true, nil
+}
+
+// HasUserRole implements Store
+func (s *MemoryStore) HasUserRole(u User, r Role, d Domain) (bool, error) {
+	_, ok := s.roles2users[u][d][r]
+	return ok, nil
+}
+
+// UserRoles implements Store
+func (s *MemoryStore) UserRoles(u User, d Domain) ([]Role, error) {
+	out := make([]Role, 0, len(s.roles2users[u][d]))
+	for r := range s.roles2users[u][d] {
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+// RoleUsers implements Store
+func (s *MemoryStore) RoleUsers(r Role, d Domain) ([]User, error) {
+	out := make([]User, 0, len(s.users2roles[r][d]))
+	for u := range s.users2roles[r][d] {
+		out = append(out, u)
+	}
+	return out, nil
+}
+
+// RoleDomains implements Store
+func (s *MemoryStore) RoleDomains(r Role) ([]Domain, error) {
+	out := make([]Domain, 0, len(s.users2roles[r]))
+	for d := range s.users2roles[r] {
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+// SetUserRoleValidity implements Store
+func (s *MemoryStore) SetUserRoleValidity(u User, r Role, d Domain, v Validity) (bool, error) {
+	current, ok := s.roles2users[u][d][r]
+	if !ok || current.Equal(v) {
+		return false, nil
+	}
+	s.roles2users[u][d][r] = v
+	return true, nil
+}
+
+// UserRoleValidity implements Store
+func (s *MemoryStore) UserRoleValidity(u User, r Role, d Domain) (Validity, error) {
+	return s.roles2users[u][d][r], nil
+}
+
+// UserDomains implements Store
+func (s *MemoryStore) UserDomains(u User) ([]Domain, error) {
+	out := make([]Domain, 0, len(s.roles2users[u]))
+	for d, roles := range s.roles2users[u] {
+		if len(roles) > 0 {
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+
+// AddSSDConstraint implements Store
+func (s *MemoryStore) AddSSDConstraint(c SSDConstraint) (bool, error) {
+	return addConstraint(s.ssdConstraints, c), nil
+}
+
+// RemoveSSDConstraint implements Store
+func (s *MemoryStore) RemoveSSDConstraint(name string) (bool, error) {
+	return removeConstraint(s.ssdConstraints, name), nil
+}
+
+// SSDConstraints implements Store
+func (s *MemoryStore) SSDConstraints() ([]SSDConstraint, error) {
+	return constraintsOf(s.ssdConstraints), nil
+}
+
+// AddDSDConstraint implements Store
+func (s *MemoryStore) AddDSDConstraint(c DSDConstraint) (bool, error) {
+	return addConstraint(s.dsdConstraints, SSDConstraint(c)), nil
+}
+
+// RemoveDSDConstraint implements Store
+func (s *MemoryStore) RemoveDSDConstraint(name string) (bool, error) {
+	return removeConstraint(s.dsdConstraints, name), nil
+}
+
+// DSDConstraints implements Store
+func (s *MemoryStore) DSDConstraints() ([]DSDConstraint, error) {
+	list := constraintsOf(s.dsdConstraints)
+	out := make([]DSDConstraint, 0, len(list))
+	for _, c := range list {
+		out = append(out, DSDConstraint(c))
+	}
+	return out, nil
+}
+
+// unindexUserRole drops Role assignment from users2roles index
+func (s *MemoryStore) unindexUserRole(u User, r Role, d Domain) {
+	users := s.users2roles[r][d]
+	delete(users, u)
+	if len(users) == 0 {
+		delete(s.users2roles[r], d)
+	}
+	if len(s.users2roles[r]) == 0 {
+		delete(s.users2roles, r)
+	}
+}
+
+func addRolePermission(set map[Role]map[Permission]struct{}, r Role, p Permission) bool {
+	perms, ok := set[r]
+	if !ok {
+		perms = make(map[Permission]struct{})
+		set[r] = perms
+	}
+	if _, ok := perms[p]; ok {
+		return false
+	}
+	perms[p] = struct{}{}
+	return true
+}
+
+func removeRolePermission(set map[Role]map[Permission]struct{}, r Role, p Permission) bool {
+	if _, ok := set[r][p]; !ok {
+		return false
+	}
+	delete(set[r], p)
+	return true
+}
+
+func addPermissionRole(set map[Permission]map[Role]struct{}, p Permission, r Role) {
+	roles, ok := set[p]
+	if !ok {
+		roles = make(map[Role]struct{})
+		set[p] = roles
+	}
+	roles[r] = struct{}{}
+}
+
+func removePermissionRole(set map[Permission]map[Role]struct{}, p Permission, r Role) {
+	delete(set[p], r)
+	if len(set[p]) == 0 {
+		delete(set, p)
+	}
+}
+
+func addRoleRole(set map[Role]map[Role]struct{}, key, r Role) {
+	roles, ok := set[key]
+	if !ok {
+		roles = make(map[Role]struct{})
+		set[key] = roles
+	}
+	roles[r] = struct{}{}
+}
+
+func removeRoleRole(set map[Role]map[Role]struct{}, key, r Role) {
+	delete(set[key], r)
+	if len(set[key]) == 0 {
+		delete(set, key)
+	}
+}
+
+func permissionsOf(set map[Permission]struct{}) []Permission {
+	out := make([]Permission, 0, len(set))
+	for p := range set {
+		out = append(out, p)
+	}
+	return out
+}
+
+func rolesOf(set map[Role]struct{}) []Role {
+	out := make([]Role, 0, len(set))
+	for r := range set {
+		out = append(out, r)
+	}
+	return out
+}
+
+func addConstraint(set map[string]SSDConstraint, c SSDConstraint) bool {
+	if _, ok := set[c.Name]; ok {
+		return false
+	}
+	c.Roles = append([]Role(nil), c.Roles...)
+	set[c.Name] = c
+	return true
+}
+
+func removeConstraint(set map[string]SSDConstraint, name string) bool {
+	if _, ok := set[name]; !ok {
+		return false
+	}
+	delete(set, name)
+	return true
+}
+
+func constraintsOf(set map[string]SSDConstraint) []SSDConstraint {
+	out := make([]SSDConstraint, 0, len(set))
+	for _, c := range set {
+		c.Roles = append([]Role(nil), c.Roles...)
+		out = append(out, c)
+	}
+	return out
+}
+
+// dropConstraintRole removes Role from constraints, constraint left with fewer Roles than its cardinality
+// can not be violated anymore and is removed as well
+func dropConstraintRole(set map[string]SSDConstraint, r Role) {
+	for name, c := range set {
+		roles := make([]Role, 0, len(c.Roles))
+		for _, cr := range c.Roles {
+			if cr != r {
+				roles = append(roles, cr)
+			}
+		}
+		if len(roles) < c.N {
+			delete(set, name)
+			continue
+		}
+		c.Roles = roles
+		set[name] = c
+	}
+}
@@ -0,0 +1,168 @@
+package rbac
+
+import (
+	"fmt"
+	"runtime/debug"
+	"testing"
+)
+
+// memory returns MemoryStore of controller created with NewRBAC
+func memory(rbac *RBAC) *MemoryStore {
+	return rbac.store.(*effectiveStore).Store.(*MemoryStore)
+}
+
+func TestNewMemoryStore(t *testing.T) {
+	s := NewMemoryStore()
+
+	if s.registeredPermissions == nil {
+		t.Errorf("store initialization error: registeredPermissions is nil")
+	}
+
+	if s.registeredWildcards == nil {
+		t.Errorf("store initialization error: registeredWildcards is nil")
+	}
+
+	if s.registeredRoles == nil {
+		t.Errorf("store initialization error: registeredRoles is nil")
+	}
+
+	if s.registeredUsers == nil {
+		t.Errorf("store initialization error: registeredUsers is nil")
+	}
+
+	if s.perms2roles == nil {
+		t.Errorf("store initialization error: perms2roles is nil")
+	}
+
+	if s.denies2roles == nil {
+		t.Errorf("store initialization error: denies2roles is nil")
+	}
+
+	if s.roles2users == nil {
+		t.Errorf("store initialization error: roles2users is nil")
+	}
+
+	if s.roles2denies == nil {
+		t.Errorf("store initialization error: roles2denies is nil")
+	}
+
+	if s.roles2conds == nil {
+		t.Errorf("store initialization error: roles2conds is nil")
+	}
+
+	if s.parents2roles == nil {
+		t.Errorf("store initialization error: parents2roles is nil")
+	}
+
+	if s.ssdConstraints == nil {
+		t.Errorf("store initialization error: ssdConstraints is nil")
+	}
+
+	if s.dsdConstraints == nil {
+		t.Errorf("store initialization error: dsdConstraints is nil")
+	}
+}
+
+func TestMemoryStoreRemovalIndexes(t *testing.T) {
+	rbac := removalPolicy(10, 2)
+	s := memory(rbac)
+
+	r, other := NewRole("role-0"), NewRole("role-1")
+	p := NewPermission(NewObject("object-1"), NewAction("read"))
+	rbac.AddRoleParent(other, r)
+	rbac.DenyPermissionToRole(r, p)
+	rbac.AssignPermissionToRoleWhen(r, p, "true")
+
+	// case 1: removed Role is dropped from Users, hierarchy and reverse indexes
+	rbac.RemoveRole(r)
+	if _, ok := s.users2roles[r]; ok {
+		t.Errorf("[case 1] index error: users2roles keeps removed role")
+	}
+	if _, ok := s.roles2users[NewUser("user-0")][DefaultDomain]; ok {
+		t.Errorf("[case 1] index error: roles2users keeps empty domain of removed role")
+	}
+	if _, ok := s.parents2roles[other][r]; ok {
+		t.Errorf("[case 1] index error: parents2roles keeps removed role")
+	}
+	if _, ok := s.roles2denies[p][r]; ok {
+		t.Errorf("[case 1] index error: roles2denies keeps removed role")
+	}
+	if _, ok := s.roles2conds[p][r]; ok {
+		t.Errorf("[case 1] index error: roles2conds keeps removed role")
+	}
+
+	// case 2: removed Permission is dropped from Roles and reverse indexes
+	rbac.DenyPermissionToRole(other, p)
+	rbac.RemovePermission(p)
+	if _, ok := s.perms2roles[other][p]; ok {
+		t.Errorf("[case 2] index error: perms2roles keeps removed permission")
+	}
+	if _, ok := s.denies2roles[other][p]; ok {
+		t.Errorf("[case 2] index error: denies2roles keeps removed permission")
+	}
+	if _, ok := s.roles2perms[p]; ok {
+		t.Errorf("[case 2] index error: roles2perms keeps removed permission")
+	}
+	if _, ok := s.roles2denies[p]; ok {
+		t.Errorf("[case 2] index error: roles2denies keeps removed permission")
+	}
+}
+
+// removalPolicy builds controller over MemoryStore with users Users, each assigned one of roles Roles,
+// every Role granted, denied and conditionally granted Permission of its own
+func removalPolicy(users, roles int) *RBAC {
+	s := NewMemoryStore()
+	for i := 0; i < roles; i++ {
+		r := NewRole(fmt.Sprintf("role-%d", i))
+		p := NewPermission(NewObject(fmt.Sprintf("object-%d", i)), NewAction("read"))
+		s.AddRole(r)
+		s.AddPermission(p)
+		s.AddRolePermission(r, p)
+		s.AddRoleDeny(r, NewPermission(p.object, NewAction("delete")))
+		s.AddRoleCondition(r, NewPermission(p.object, NewAction("write")), "true")
+	}
+	for i := 0; i < users; i++ {
+		u := NewUser(fmt.Sprintf("user-%d", i))
+		s.AddUser(u)
+		s.AddUserRole(u, NewRole(fmt.Sprintf("role-%d", i%roles)), DefaultDomain)
+	}
+	return NewRBACWithStore(s)
+}
+
+func BenchmarkRemoveRole(b *testing.B) {
+	rbac := removalPolicy(1000000, 10000)
+	u := NewUser("user-0")
+	r := NewRole("removed")
+
+	// collecting heap of 10^6 Users would dominate removal measured
+	defer debug.SetGCPercent(debug.SetGCPercent(-1))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		rbac.RegisterRole(r)
+		rbac.AssignRoleToUser(u, r)
+		rbac.AddRoleParent(r, NewRole("role-1"))
+		b.StartTimer()
+
+		rbac.RemoveRole(r)
+	}
+}
+
+func BenchmarkRemovePermission(b *testing.B) {
+	rbac := removalPolicy(1000000, 10000)
+	r := NewRole("role-0")
+	p := NewPermission(NewObject("removed"), NewAction("read"))
+
+	// collecting heap of 10^6 Users would dominate removal measured
+	defer debug.SetGCPercent(debug.SetGCPercent(-1))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		rbac.RegisterPermission(p)
+		rbac.AssignPermissionToRole(r, p)
+		rbac.DenyPermissionToRole(NewRole("role-1"), p)
+		b.StartTimer()
+
+		rbac.RemovePermission(p)
+	}
+}
@@ -0,0 +1,475 @@
+// Package storetest provides conformance tests for rbac.Store implementations.
+package storetest
+
+import (
+	"testing"
+	"time"
+
+	"rbac"
+)
+
+// Run checks that empty Store s follows rbac.Store contract
+func Run(t *testing.T, s rbac.Store) {
+	u := rbac.NewUser("user")
+	r := rbac.NewRole("role")
+	parent := rbac.NewRole("parent")
+	d := rbac.NewDomain("domain")
+	p := rbac.NewPermission(rbac.NewObject("object"), rbac.NewAction("action"))
+	w := rbac.NewPermission(rbac.NewObject("object"), rbac.NewAction("*"))
+
+	mustChange := func(step string, ok bool, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("%s error: expected err equal nil, got %v", step, err)
+		}
+		if !ok {
+			t.Errorf("%s invalid output: expected %t, got %t", step, true, ok)
+		}
+	}
+
+	mustKeep := func(step string, ok bool, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("%s error: expected err equal nil, got %v", step, err)
+		}
+		if ok {
+			t.Errorf("%s invalid output: expected %t, got %t", step, false, ok)
+		}
+	}
+
+	// entities
+	ok, err := s.AddUser(u)
+	mustChange("AddUser", ok, err)
+	ok, err = s.AddUser(u)
+	mustKeep("AddUser twice", ok, err)
+	ok, err = s.HasUser(u)
+	mustChange("HasUser", ok, err)
+
+	ok, err = s.AddRole(r)
+	mustChange("AddRole", ok, err)
+	ok, err = s.AddRole(parent)
+	mustChange("AddRole parent", ok, err)
+	ok, err = s.AddRole(r)
+	mustKeep("AddRole twice", ok, err)
+	ok, err = s.HasRole(r)
+	mustChange("HasRole", ok, err)
+
+	ok, err = s.AddPermission(p)
+	mustChange("AddPermission", ok, err)
+	ok, err = s.AddPermission(w)
+	mustChange("AddPermission wildcard", ok, err)
+	ok, err = s.AddPermission(p)
+	mustKeep("AddPermission twice", ok, err)
+	ok, err = s.HasPermission(p)
+	mustChange("HasPermission", ok, err)
+
+	users, err := s.Users()
+	if err != nil || len(users) != 1 || users[0] != u {
+		t.Errorf("Users invalid output: expected [%v], got %v (err %v)", u, users, err)
+	}
+
+	roles, err := s.Roles()
+	if err != nil || len(roles) != 2 || !hasRole(r, roles) || !hasRole(parent, roles) {
+		t.Errorf("Roles invalid output: expected [%v %v], got %v (err %v)", r, parent, roles, err)
+	}
+
+	perms, err := s.Permissions()
+	if err != nil || len(perms) != 2 || !hasPermission(p, perms) || !hasPermission(w, perms) {
+		t.Errorf("Permissions invalid output: expected [%v %v], got %v (err %v)", p, w, perms, err)
+	}
+
+	wildcards, err := s.WildcardPermissions()
+	if err != nil || len(wildcards) != 1 || wildcards[0] != w {
+		t.Errorf("WildcardPermissions invalid output: expected [%v], got %v (err %v)", w, wildcards, err)
+	}
+
+	ok, err = s.AddExactPermission(p)
+	mustChange("AddExactPermission", ok, err)
+	ok, err = s.AddExactPermission(p)
+	mustKeep("AddExactPermission twice", ok, err)
+	ok, err = s.HasExactPermission(p)
+	mustChange("HasExactPermission", ok, err)
+	ok, err = s.HasExactPermission(w)
+	mustKeep("HasExactPermission of inheritable permission", ok, err)
+
+	exact, err := s.ExactPermissions()
+	if err != nil || len(exact) != 1 || exact[0] != p {
+		t.Errorf("ExactPermissions invalid output: expected [%v], got %v (err %v)", p, exact, err)
+	}
+
+	ok, err = s.RemoveExactPermission(p)
+	mustChange("RemoveExactPermission", ok, err)
+	ok, err = s.RemoveExactPermission(p)
+	mustKeep("RemoveExactPermission twice", ok, err)
+
+	// relations
+	ok, err = s.AddRolePermission(r, p)
+	mustChange("AddRolePermission", ok, err)
+	ok, err = s.AddRolePermission(r, p)
+	mustKeep("AddRolePermission twice", ok, err)
+	ok, err = s.HasRolePermission(r, p)
+	mustChange("HasRolePermission", ok, err)
+
+	ok, err = s.AddRoleDeny(r, w)
+	mustChange("AddRoleDeny", ok, err)
+	ok, err = s.AddRoleDeny(r, w)
+	mustKeep("AddRoleDeny twice", ok, err)
+	ok, err = s.HasRoleDeny(r, w)
+	mustChange("HasRoleDeny", ok, err)
+
+	ok, err = s.AddRoleCondition(r, w, "subject.id == 'user'")
+	mustChange("AddRoleCondition", ok, err)
+	ok, err = s.AddRoleCondition(r, w, "subject.id == 'user'")
+	mustKeep("AddRoleCondition twice", ok, err)
+	ok, err = s.AddRoleCondition(r, w, "subject.id != 'user'")
+	mustChange("AddRoleCondition replacing expression", ok, err)
+
+	implication := rbac.ActionImplication{Action: rbac.NewAction("write"), Implied: rbac.NewAction("read")}
+	ok, err = s.AddActionImplication(implication)
+	mustChange("AddActionImplication", ok, err)
+	ok, err = s.AddActionImplication(implication)
+	mustKeep("AddActionImplication twice", ok, err)
+
+	implications, err := s.ActionImplications()
+	if err != nil || len(implications) != 1 || implications[0] != implication {
+		t.Errorf("ActionImplications invalid output: expected [%v], got %v (err %v)", implication, implications, err)
+	}
+
+	ok, err = s.RemoveActionImplication(implication)
+	mustChange("RemoveActionImplication", ok, err)
+	ok, err = s.RemoveActionImplication(implication)
+	mustKeep("RemoveActionImplication twice", ok, err)
+
+	ok, err = s.AddRoleParent(r, parent)
+	mustChange("AddRoleParent", ok, err)
+	ok, err = s.AddRoleParent(r, parent)
+	mustKeep("AddRoleParent twice", ok, err)
+
+	ok, err = s.AddUserRole(u, r, d)
+	mustChange("AddUserRole", ok, err)
+	ok, err = s.AddUserRole(u, r, d)
+	mustKeep("AddUserRole twice", ok, err)
+	ok, err = s.HasUserRole(u, r, d)
+	mustChange("HasUserRole", ok, err)
+	ok, err = s.HasUserRole(u, r, rbac.DefaultDomain)
+	mustKeep("HasUserRole in other domain", ok, err)
+
+	perms, err = s.RolePermissions(r)
+	if err != nil || len(perms) != 1 || perms[0] != p {
+		t.Errorf("RolePermissions invalid output: expected [%v], got %v (err %v)", p, perms, err)
+	}
+
+	perms, err = s.RoleDenies(r)
+	if err != nil || len(perms) != 1 || perms[0] != w {
+		t.Errorf("RoleDenies invalid output: expected [%v], got %v (err %v)", w, perms, err)
+	}
+
+	conds, err := s.RoleConditions(r)
+	if err != nil || len(conds) != 1 || conds[w] != "subject.id != 'user'" {
+		t.Errorf("RoleConditions invalid output: expected map[%v:subject.id != 'user'], got %v (err %v)", w, conds, err)
+	}
+
+	roles, err = s.RoleParents(r)
+	if err != nil || len(roles) != 1 || roles[0] != parent {
+		t.Errorf("RoleParents invalid output: expected [%v], got %v (err %v)", parent, roles, err)
+	}
+
+	roles, err = s.UserRoles(u, d)
+	if err != nil || len(roles) != 1 || roles[0] != r {
+		t.Errorf("UserRoles invalid output: expected [%v], got %v (err %v)", r, roles, err)
+	}
+
+	roles, err = s.UserRoles(u, rbac.DefaultDomain)
+	if err != nil || len(roles) != 0 {
+		t.Errorf("UserRoles in other domain invalid output: expected [], got %v (err %v)", roles, err)
+	}
+
+	// reverse lookups
+	roles, err = s.PermissionRoles(p)
+	if err != nil || len(roles) != 1 || roles[0] != r {
+		t.Errorf("PermissionRoles invalid output: expected [%v], got %v (err %v)", r, roles, err)
+	}
+
+	roles, err = s.PermissionDenies(w)
+	if err != nil || len(roles) != 1 || roles[0] != r {
+		t.Errorf("PermissionDenies invalid output: expected [%v], got %v (err %v)", r, roles, err)
+	}
+
+	exprs, err := s.PermissionConditions(w)
+	if err != nil || len(exprs) != 1 || exprs[r] != "subject.id != 'user'" {
+		t.Errorf("PermissionConditions invalid output: expected map[%v:subject.id != 'user'], got %v (err %v)", r, exprs, err)
+	}
+
+	roles, err = s.RoleChildren(parent)
+	if err != nil || len(roles) != 1 || roles[0] != r {
+		t.Errorf("RoleChildren invalid output: expected [%v], got %v (err %v)", r, roles, err)
+	}
+
+	users, err = s.RoleUsers(r, d)
+	if err != nil || len(users) != 1 || users[0] != u {
+		t.Errorf("RoleUsers invalid output: expected [%v], got %v (err %v)", u, users, err)
+	}
+
+	users, err = s.RoleUsers(r, rbac.DefaultDomain)
+	if err != nil || len(users) != 0 {
+		t.Errorf("RoleUsers in other domain invalid output: expected [], got %v (err %v)", users, err)
+	}
+
+	domains, err := s.UserDomains(u)
+	if err != nil || len(domains) != 1 || domains[0] != d {
+		t.Errorf("UserDomains invalid output: expected [%v], got %v (err %v)", d, domains, err)
+	}
+
+	domains, err = s.RoleDomains(r)
+	if err != nil || len(domains) != 1 || domains[0] != d {
+		t.Errorf("RoleDomains invalid output: expected [%v], got %v (err %v)", d, domains, err)
+	}
+
+	v, err := s.UserRoleValidity(u, r, d)
+	if err != nil || !v.IsZero() {
+		t.Errorf("UserRoleValidity invalid output: expected unbounded, got %v (err %v)", v, err)
+	}
+
+	bounded := rbac.Between(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC))
+	ok, err = s.SetUserRoleValidity(u, r, d, bounded)
+	mustChange("SetUserRoleValidity", ok, err)
+	ok, err = s.SetUserRoleValidity(u, r, d, bounded)
+	mustKeep("SetUserRoleValidity twice", ok, err)
+	ok, err = s.SetUserRoleValidity(u, r, rbac.DefaultDomain, bounded)
+	mustKeep("SetUserRoleValidity of not assigned role", ok, err)
+
+	v, err = s.UserRoleValidity(u, r, d)
+	if err != nil || !v.Equal(bounded) {
+		t.Errorf("UserRoleValidity invalid output: expected %v, got %v (err %v)", bounded, v, err)
+	}
+
+	// relations removal
+	ok, err = s.RemoveRolePermission(r, p)
+	mustChange("RemoveRolePermission", ok, err)
+	ok, err = s.RemoveRolePermission(r, p)
+	mustKeep("RemoveRolePermission twice", ok, err)
+
+	ok, err = s.RemoveRoleDeny(r, w)
+	mustChange("RemoveRoleDeny", ok, err)
+	ok, err = s.RemoveRoleDeny(r, w)
+	mustKeep("RemoveRoleDeny twice", ok, err)
+
+	ok, err = s.RemoveRoleCondition(r, w)
+	mustChange("RemoveRoleCondition", ok, err)
+	ok, err = s.RemoveRoleCondition(r, w)
+	mustKeep("RemoveRoleCondition twice", ok, err)
+
+	ok, err = s.RemoveRoleParent(r, parent)
+	mustChange("RemoveRoleParent", ok, err)
+	ok, err = s.RemoveRoleParent(r, parent)
+	mustKeep("RemoveRoleParent twice", ok, err)
+
+	roles, err = s.PermissionRoles(p)
+	if err != nil || len(roles) != 0 {
+		t.Errorf("PermissionRoles of removed assignment invalid output: expected [], got %v (err %v)", roles, err)
+	}
+	roles, err = s.PermissionDenies(w)
+	if err != nil || len(roles) != 0 {
+		t.Errorf("PermissionDenies of removed deny invalid output: expected [], got %v (err %v)", roles, err)
+	}
+	exprs, err = s.PermissionConditions(w)
+	if err != nil || len(exprs) != 0 {
+		t.Errorf("PermissionConditions of removed condition invalid output: expected map[], got %v (err %v)", exprs, err)
+	}
+	roles, err = s.RoleChildren(parent)
+	if err != nil || len(roles) != 0 {
+		t.Errorf("RoleChildren of removed parent invalid output: expected [], got %v (err %v)", roles, err)
+	}
+
+	ok, err = s.RemoveUserRole(u, r, rbac.DefaultDomain)
+	mustKeep("RemoveUserRole in other domain", ok, err)
+	ok, err = s.RemoveUserRole(u, r, d)
+	mustChange("RemoveUserRole", ok, err)
+	ok, err = s.RemoveUserRole(u, r, d)
+	mustKeep("RemoveUserRole twice", ok, err)
+	users, err = s.RoleUsers(r, d)
+	if err != nil || len(users) != 0 {
+		t.Errorf("RoleUsers of removed assignment invalid output: expected [], got %v (err %v)", users, err)
+	}
+
+	domains, err = s.UserDomains(u)
+	if err != nil || len(domains) != 0 {
+		t.Errorf("UserDomains without roles invalid output: expected [], got %v (err %v)", domains, err)
+	}
+
+	domains, err = s.RoleDomains(r)
+	if err != nil || len(domains) != 0 {
+		t.Errorf("RoleDomains without users invalid output: expected [], got %v (err %v)", domains, err)
+	}
+
+	s.AddUserRole(u, r, d)
+	v, err = s.UserRoleValidity(u, r, d)
+	if err != nil || !v.IsZero() {
+		t.Errorf("UserRoleValidity of reassigned role invalid output: expected unbounded, got %v (err %v)", v, err)
+	}
+	s.RemoveUserRole(u, r, d)
+
+	// constraints
+	other := rbac.NewRole("other")
+	s.AddRole(other)
+
+	c := rbac.NewSSDConstraint("constraint", []rbac.Role{r, parent, other}, 2)
+	ok, err = s.AddSSDConstraint(c)
+	mustChange("AddSSDConstraint", ok, err)
+	ok, err = s.AddSSDConstraint(c)
+	mustKeep("AddSSDConstraint twice", ok, err)
+
+	constraints, err := s.SSDConstraints()
+	if err != nil || len(constraints) != 1 || constraints[0].Name != c.Name || constraints[0].N != c.N ||
+		len(constraints[0].Roles) != 3 || !hasRole(r, constraints[0].Roles) || !hasRole(parent, constraints[0].Roles) {
+		t.Errorf("SSDConstraints invalid output: expected [%v], got %v (err %v)", c, constraints, err)
+	}
+
+	dc := rbac.NewDSDConstraint("dynamic", []rbac.Role{r, parent}, 2)
+	ok, err = s.AddDSDConstraint(dc)
+	mustChange("AddDSDConstraint", ok, err)
+	ok, err = s.AddDSDConstraint(dc)
+	mustKeep("AddDSDConstraint twice", ok, err)
+
+	dynamic, err := s.DSDConstraints()
+	if err != nil || len(dynamic) != 1 || dynamic[0].Name != dc.Name || dynamic[0].N != dc.N || len(dynamic[0].Roles) != 2 {
+		t.Errorf("DSDConstraints invalid output: expected [%v], got %v (err %v)", dc, dynamic, err)
+	}
+
+	// cascades
+	s.AddRolePermission(r, p)
+	s.AddRoleDeny(parent, p)
+	s.AddRoleCondition(r, p, "true")
+	s.AddExactPermission(p)
+	s.AddRoleCondition(parent, w, "true")
+	s.AddRoleParent(r, parent)
+	s.AddUserRole(u, r, d)
+
+	ok, err = s.RemovePermission(p)
+	mustChange("RemovePermission", ok, err)
+	roles, err = s.PermissionRoles(p)
+	if err != nil || len(roles) != 0 {
+		t.Errorf("PermissionRoles of removed permission invalid output: expected [], got %v (err %v)", roles, err)
+	}
+	roles, err = s.PermissionDenies(p)
+	if err != nil || len(roles) != 0 {
+		t.Errorf("PermissionDenies of removed permission invalid output: expected [], got %v (err %v)", roles, err)
+	}
+	exprs, err = s.PermissionConditions(p)
+	if err != nil || len(exprs) != 0 {
+		t.Errorf("PermissionConditions of removed permission invalid output: expected map[], got %v (err %v)", exprs, err)
+	}
+	ok, err = s.HasRolePermission(r, p)
+	mustKeep("HasRolePermission of removed permission", ok, err)
+	ok, err = s.HasRoleDeny(parent, p)
+	mustKeep("HasRoleDeny of removed permission", ok, err)
+	ok, err = s.HasExactPermission(p)
+	mustKeep("HasExactPermission of removed permission", ok, err)
+	conds, err = s.RoleConditions(r)
+	if err != nil || len(conds) != 0 {
+		t.Errorf("RoleConditions of removed permission invalid output: expected map[], got %v (err %v)", conds, err)
+	}
+
+	ok, err = s.RemoveRole(parent)
+	mustChange("RemoveRole", ok, err)
+	roles, err = s.RoleChildren(parent)
+	if err != nil || len(roles) != 0 {
+		t.Errorf("RoleChildren of removed role invalid output: expected [], got %v (err %v)", roles, err)
+	}
+	conds, err = s.RoleConditions(parent)
+	if err != nil || len(conds) != 0 {
+		t.Errorf("RoleConditions of removed role invalid output: expected map[], got %v (err %v)", conds, err)
+	}
+	roles, err = s.RoleParents(r)
+	if err != nil || len(roles) != 0 {
+		t.Errorf("RoleParents of removed role invalid output: expected [], got %v (err %v)", roles, err)
+	}
+	constraints, err = s.SSDConstraints()
+	if err != nil || len(constraints) != 1 || len(constraints[0].Roles) != 2 || hasRole(parent, constraints[0].Roles) {
+		t.Errorf("SSDConstraints of removed role invalid output: expected [%v %v], got %v (err %v)", r, other, constraints, err)
+	}
+	// constraint left with fewer Roles than its cardinality can not be violated and is dropped
+	dynamic, err = s.DSDConstraints()
+	if err != nil || len(dynamic) != 0 {
+		t.Errorf("DSDConstraints of removed role invalid output: expected [], got %v (err %v)", dynamic, err)
+	}
+
+	ok, err = s.RemoveRole(r)
+	mustChange("RemoveRole", ok, err)
+	ok, err = s.HasUserRole(u, r, d)
+	mustKeep("HasUserRole of removed role", ok, err)
+	users, err = s.RoleUsers(r, d)
+	if err != nil || len(users) != 0 {
+		t.Errorf("RoleUsers of removed role invalid output: expected [], got %v (err %v)", users, err)
+	}
+	ok, err = s.RemoveRole(r)
+	mustKeep("RemoveRole twice", ok, err)
+	constraints, err = s.SSDConstraints()
+	if err != nil || len(constraints) != 0 {
+		t.Errorf("SSDConstraints of removed role invalid output: expected [], got %v (err %v)", constraints, err)
+	}
+
+	s.AddRole(r)
+	s.AddUserRole(u, r, d)
+
+	ok, err = s.RemoveUser(u)
+	mustChange("RemoveUser", ok, err)
+	users, err = s.RoleUsers(r, d)
+	if err != nil || len(users) != 0 {
+		t.Errorf("RoleUsers of removed user invalid output: expected [], got %v (err %v)", users, err)
+	}
+	ok, err = s.RemoveUser(u)
+	mustKeep("RemoveUser twice", ok, err)
+	roles, err = s.UserRoles(u, d)
+	if err != nil || len(roles) != 0 {
+		t.Errorf("UserRoles of removed user invalid output: expected [], got %v (err %v)", roles, err)
+	}
+
+	ok, err = s.RemovePermission(w)
+	mustChange("RemovePermission wildcard", ok, err)
+	wildcards, err = s.WildcardPermissions()
+	if err != nil || len(wildcards) != 0 {
+		t.Errorf("WildcardPermissions invalid output: expected [], got %v (err %v)", wildcards, err)
+	}
+
+	c = rbac.NewSSDConstraint(c.Name, []rbac.Role{r, other}, 2)
+	s.AddSSDConstraint(c)
+	dc = rbac.NewDSDConstraint(dc.Name, []rbac.Role{r, other}, 2)
+	s.AddDSDConstraint(dc)
+
+	ok, err = s.RemoveSSDConstraint(c.Name)
+	mustChange("RemoveSSDConstraint", ok, err)
+	ok, err = s.RemoveSSDConstraint(c.Name)
+	mustKeep("RemoveSSDConstraint twice", ok, err)
+	constraints, err = s.SSDConstraints()
+	if err != nil || len(constraints) != 0 {
+		t.Errorf("SSDConstraints invalid output: expected [], got %v (err %v)", constraints, err)
+	}
+
+	ok, err = s.RemoveDSDConstraint(dc.Name)
+	mustChange("RemoveDSDConstraint", ok, err)
+	ok, err = s.RemoveDSDConstraint(dc.Name)
+	mustKeep("RemoveDSDConstraint twice", ok, err)
+	dynamic, err = s.DSDConstraints()
+	if err != nil || len(dynamic) != 0 {
+		t.Errorf("DSDConstraints invalid output: expected [], got %v (err %v)", dynamic, err)
+	}
+}
+
+func hasRole(r rbac.Role, list []rbac.Role) bool {
+	for _, tmp := range list {
+		if tmp == r {
+			return true
+		}
+	}
+	return false
+}
+
+func hasPermission(p rbac.Permission, list []rbac.Permission) bool {
+	for _, tmp := range list {
+		if tmp == p {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,21 @@
+package storetest
+
+import (
+	"path/filepath"
+	"testing"
+
+	"rbac"
+)
+
+func TestMemoryStore(t *testing.T) {
+	Run(t, rbac.NewMemoryStore())
+}
+
+func TestFileStore(t *testing.T) {
+	s, err := rbac.OpenFileStore(filepath.Join(t.TempDir(), "rbac.json"))
+	if err != nil {
+		t.Fatalf("open error: expected err equal nil, got %v", err)
+	}
+
+	Run(t, s)
+}
@@ -0,0 +1,49 @@
+package rbac
+
+import "time"
+
+// Validity bounds the time Role assignment is in effect.
+// Zero NotBefore or NotAfter leaves assignment unbounded on that side, zero Validity never expires.
+type Validity struct {
+	NotBefore time.Time
+	NotAfter  time.Time
+}
+
+// Until creates Validity ending at t
+func Until(t time.Time) Validity {
+	return Validity{NotAfter: t}
+}
+
+// Between creates Validity starting at notBefore and ending at notAfter
+func Between(notBefore, notAfter time.Time) Validity {
+	return Validity{NotBefore: notBefore, NotAfter: notAfter}
+}
+
+// IsZero checks if Validity is unbounded
+func (v Validity) IsZero() bool {
+	return v.NotBefore.IsZero() && v.NotAfter.IsZero()
+}
+
+// Equal checks if both Validities have the same bounds
+func (v Validity) Equal(o Validity) bool {
+	return v.NotBefore.Equal(o.NotBefore) && v.NotAfter.Equal(o.NotAfter)
+}
+
+// ActiveAt checks if assignment is in effect at t.
+// Both bounds are inclusive.
+func (v Validity) ActiveAt(t time.Time) bool {
+	if !v.NotBefore.IsZero() && t.Before(v.NotBefore) {
+		return false
+	}
+	return !v.ExpiredAt(t)
+}
+
+// ExpiredAt checks if assignment ended before t and will never be in effect again
+func (v Validity) ExpiredAt(t time.Time) bool {
+	return !v.NotAfter.IsZero() && t.After(v.NotAfter)
+}
+
+// valid checks that Validity does not end before it starts
+func (v Validity) valid() bool {
+	return v.NotBefore.IsZero() || v.NotAfter.IsZero() || !v.NotAfter.Before(v.NotBefore)
+}
@@ -0,0 +1,52 @@
+package rbac
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidityActiveAt(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+
+	tests := []struct {
+		v       Validity
+		t       time.Time
+		active  bool
+		expired bool
+	}{
+		// case 1: unbounded
+		{Validity{}, start, true, false},
+		// case 2: before start
+		{Between(start, end), start.Add(-time.Second), false, false},
+		// case 3: at start
+		{Between(start, end), start, true, false},
+		// case 4: at end
+		{Between(start, end), end, true, false},
+		// case 5: after end
+		{Between(start, end), end.Add(time.Second), false, true},
+		// case 6: only end
+		{Until(end), start.Add(-time.Hour), true, false},
+	}
+
+	for i, test := range tests {
+		if active := test.v.ActiveAt(test.t); active != test.active {
+			t.Errorf("[case %d] invalid output: expected active %t, got %t", i+1, test.active, active)
+		}
+		if expired := test.v.ExpiredAt(test.t); expired != test.expired {
+			t.Errorf("[case %d] invalid output: expected expired %t, got %t", i+1, test.expired, expired)
+		}
+	}
+}
+
+func TestValidityValid(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	if !Until(start).valid() {
+		t.Errorf("[case 1] invalid output: expected %t, got %t", true, false)
+	}
+
+	if Between(start, start.Add(-time.Second)).valid() {
+		t.Errorf("[case 2] invalid output: expected %t, got %t", false, true)
+	}
+}